@@ -33,6 +33,7 @@ type APIHandlerOptions struct {
 	LicenseManager                *license.Manager
 	LogsParsingPipelineController *logparsingpipeline.LogParsingPipelineController
 	Cache                         cache.Cache
+	CacheTTL                      time.Duration
 	// Querier Influx Interval
 	FluxInterval time.Duration
 }
@@ -58,6 +59,7 @@ func NewAPIHandler(opts APIHandlerOptions) (*APIHandler, error) {
 		FeatureFlags:                  opts.FeatureFlags,
 		LogsParsingPipelineController: opts.LogsParsingPipelineController,
 		Cache:                         opts.Cache,
+		CacheTTL:                      opts.CacheTTL,
 		FluxInterval:                  opts.FluxInterval,
 	})
 
@@ -33,6 +33,8 @@ import (
 	"go.signoz.io/signoz/pkg/query-service/agentConf"
 	baseapp "go.signoz.io/signoz/pkg/query-service/app"
 	"go.signoz.io/signoz/pkg/query-service/app/dashboards"
+	"go.signoz.io/signoz/pkg/query-service/app/derivedmetrics"
+	"go.signoz.io/signoz/pkg/query-service/app/errorgroups"
 	baseexplorer "go.signoz.io/signoz/pkg/query-service/app/explorer"
 	"go.signoz.io/signoz/pkg/query-service/app/logparsingpipeline"
 	"go.signoz.io/signoz/pkg/query-service/app/opamp"
@@ -78,6 +80,9 @@ type Server struct {
 	ruleManager   *rules.Manager
 	separatePorts bool
 
+	// derivedMetrics materializes the metrics derived from the logs
+	derivedMetrics *derivedmetrics.Materializer
+
 	// public http router
 	httpConn   net.Listener
 	httpServer *http.Server
@@ -111,6 +116,8 @@ func NewServer(serverOptions *ServerOptions) (*Server, error) {
 	}
 
 	baseexplorer.InitWithDSN(baseconst.RELATIONAL_DATASOURCE_PATH)
+	derivedmetrics.InitWithDSN(baseconst.RELATIONAL_DATASOURCE_PATH)
+	errorgroups.InitWithDSN(baseconst.RELATIONAL_DATASOURCE_PATH)
 
 	localDB, err := dashboards.InitDB(baseconst.RELATIONAL_DATASOURCE_PATH)
 
@@ -158,6 +165,14 @@ func NewServer(serverOptions *ServerOptions) (*Server, error) {
 	}
 
 	<-readerReady
+	if baseconst.IsEmbeddedAlertManager() {
+		channels, apiErr := reader.GetChannels()
+		if apiErr != nil {
+			return nil, apiErr.Err
+		}
+		basealm.LoadChannels(*channels)
+	}
+
 	rm, err := makeRulesManager(serverOptions.PromConfigPath,
 		baseconst.GetAlertManagerApiPrefix(),
 		serverOptions.RuleRepoURL,
@@ -206,12 +221,14 @@ func NewServer(serverOptions *ServerOptions) (*Server, error) {
 	telemetry.GetInstance().SetSaasOperator(constants.SaasSegmentKey)
 
 	var c cache.Cache
+	var cacheTTL time.Duration
 	if serverOptions.CacheConfigPath != "" {
 		cacheOpts, err := cache.LoadFromYAMLCacheConfigFile(serverOptions.CacheConfigPath)
 		if err != nil {
 			return nil, err
 		}
 		c = cache.NewCache(cacheOpts)
+		cacheTTL = cacheOpts.TTL
 	}
 
 	fluxInterval, err := time.ParseDuration(serverOptions.FluxInterval)
@@ -235,6 +252,7 @@ func NewServer(serverOptions *ServerOptions) (*Server, error) {
 		LicenseManager:                lm,
 		LogsParsingPipelineController: logParsingPipelineController,
 		Cache:                         c,
+		CacheTTL:                      cacheTTL,
 		FluxInterval:                  fluxInterval,
 	}
 
@@ -243,10 +261,16 @@ func NewServer(serverOptions *ServerOptions) (*Server, error) {
 		return nil, err
 	}
 
+	derivedMetrics := derivedmetrics.NewMaterializer(reader, derivedmetrics.Options{
+		Interval: baseconst.DerivedMetricsInterval,
+		Delay:    baseconst.DerivedMetricsDelay,
+	})
+
 	s := &Server{
 		// logger: logger,
 		// tracer: tracer,
 		ruleManager:        rm,
+		derivedMetrics:     derivedMetrics,
 		serverOptions:      serverOptions,
 		unavailableChannel: make(chan healthcheck.Status),
 		usageManager:       usageManager,
@@ -548,6 +572,8 @@ func (s *Server) Start() error {
 		zap.S().Info("msg: Rules disabled as rules.disable is set to TRUE")
 	}
 
+	s.derivedMetrics.Start()
+
 	err := s.initListeners()
 	if err != nil {
 		return err
@@ -630,6 +656,10 @@ func (s *Server) Stop() error {
 		s.ruleManager.Stop()
 	}
 
+	if s.derivedMetrics != nil {
+		s.derivedMetrics.Stop()
+	}
+
 	// stop usage manager
 	s.usageManager.Stop()
 
@@ -45,7 +45,9 @@ import (
 
 	"go.signoz.io/signoz/pkg/query-service/app/logs"
 	"go.signoz.io/signoz/pkg/query-service/app/services"
+	"go.signoz.io/signoz/pkg/query-service/app/traces/spantree"
 	"go.signoz.io/signoz/pkg/query-service/auth"
+	"go.signoz.io/signoz/pkg/query-service/common"
 	"go.signoz.io/signoz/pkg/query-service/constants"
 	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
 	"go.signoz.io/signoz/pkg/query-service/interfaces"
@@ -53,6 +55,7 @@ import (
 	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
 	"go.signoz.io/signoz/pkg/query-service/telemetry"
 	"go.signoz.io/signoz/pkg/query-service/utils"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
 )
 
 const (
@@ -75,6 +78,10 @@ const (
 	maxProgressiveSteps                   = 4
 	charset                               = "abcdefghijklmnopqrstuvwxyz" +
 		"ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+	// serviceMapTraceLimit is the most traces the service map filtered on
+	// the spans is read from
+	serviceMapTraceLimit = 10000
 )
 
 var (
@@ -1955,7 +1962,9 @@ func (r *ClickHouseReader) GetUsage(ctx context.Context, queryParams *model.GetU
 	return &usageItems, nil
 }
 
-func (r *ClickHouseReader) SearchTraces(ctx context.Context, traceId string, spanId string, levelUp int, levelDown int, spanLimit int, smartTraceAlgorithm func(payload []model.SearchSpanResponseItem, targetSpanId string, levelUp int, levelDown int, spanLimit int) ([]model.SearchSpansResult, error)) (*[]model.SearchSpansResult, error) {
+// GetTraceSpans returns the spans of the trace, their timestamps are in
+// nanoseconds
+func (r *ClickHouseReader) GetTraceSpans(ctx context.Context, traceId string) ([]model.SearchSpanResponseItem, error) {
 
 	var searchScanResponses []model.SearchSpanDBResponseItem
 
@@ -1973,32 +1982,48 @@ func (r *ClickHouseReader) SearchTraces(ctx context.Context, traceId string, spa
 	}
 	end := time.Now()
 	zap.S().Debug("getTraceSQLQuery took: ", end.Sub(start))
-	searchSpansResult := []model.SearchSpansResult{{
-		Columns: []string{"__time", "SpanId", "TraceId", "ServiceName", "Name", "Kind", "DurationNano", "TagsKeys", "TagsValues", "References", "Events", "HasError"},
-		Events:  make([][]interface{}, len(searchScanResponses)),
-	},
-	}
 
 	searchSpanResponses := []model.SearchSpanResponseItem{}
 	start = time.Now()
 	for _, item := range searchScanResponses {
 		var jsonItem model.SearchSpanResponseItem
 		easyjson.Unmarshal([]byte(item.Model), &jsonItem)
-		jsonItem.TimeUnixNano = uint64(item.Timestamp.UnixNano() / 1000000)
+		jsonItem.TimeUnixNano = uint64(item.Timestamp.UnixNano())
 		searchSpanResponses = append(searchSpanResponses, jsonItem)
 	}
 	end = time.Now()
 	zap.S().Debug("getTraceSQLQuery unmarshal took: ", end.Sub(start))
 
+	return searchSpanResponses, nil
+}
+
+func (r *ClickHouseReader) SearchTraces(ctx context.Context, traceId string, spanId string, levelUp int, levelDown int, spanLimit int, smartTraceAlgorithm func(payload []model.SearchSpanResponseItem, targetSpanId string, levelUp int, levelDown int, spanLimit int) ([]model.SearchSpansResult, error)) (*[]model.SearchSpansResult, error) {
+
+	searchSpanResponses, err := r.GetTraceSpans(ctx, traceId)
+	if err != nil {
+		return nil, err
+	}
+	analysis := spantree.Analyze(searchSpanResponses)
+	// the trace detail reads the timestamps in milliseconds
+	for i := range searchSpanResponses {
+		searchSpanResponses[i].TimeUnixNano /= 1000000
+	}
+
+	searchSpansResult := []model.SearchSpansResult{{
+		Columns: []string{"__time", "SpanId", "TraceId", "ServiceName", "Name", "Kind", "DurationNano", "TagsKeys", "TagsValues", "References", "Events", "HasError"},
+		Events:  make([][]interface{}, len(searchSpanResponses)),
+	},
+	}
+
 	err = r.featureFlags.CheckFeature(model.SmartTraceDetail)
 	smartAlgoEnabled := err == nil
-	if len(searchScanResponses) > spanLimit && spanId != "" && smartAlgoEnabled {
-		start = time.Now()
+	if len(searchSpanResponses) > spanLimit && spanId != "" && smartAlgoEnabled {
+		start := time.Now()
 		searchSpansResult, err = smartTraceAlgorithm(searchSpanResponses, spanId, levelUp, levelDown, spanLimit)
 		if err != nil {
 			return nil, err
 		}
-		end = time.Now()
+		end := time.Now()
 		zap.S().Debug("smartTraceAlgo took: ", end.Sub(start))
 	} else {
 		for i, item := range searchSpanResponses {
@@ -2006,22 +2031,50 @@ func (r *ClickHouseReader) SearchTraces(ctx context.Context, traceId string, spa
 			searchSpansResult[0].Events[i] = spanEvents
 		}
 	}
+	if len(searchSpansResult) > 0 {
+		searchSpansResult[0].Analysis = analysis
+	}
 
 	return &searchSpansResult, nil
 }
 
 func (r *ClickHouseReader) GetDependencyGraph(ctx context.Context, queryParams *model.GetServicesParams) (*[]model.ServiceMapDependencyResponseItem, error) {
 
+	response, err := r.getDependencyGraph(ctx, queryParams.Start, queryParams.End, queryParams.Tags)
+	if err != nil {
+		return nil, err
+	}
+	if queryParams.ComparisonStart == nil || queryParams.ComparisonEnd == nil {
+		return &response, nil
+	}
+
+	comparison, err := r.getDependencyGraph(ctx, queryParams.ComparisonStart, queryParams.ComparisonEnd, queryParams.Tags)
+	if err != nil {
+		return nil, err
+	}
+	response = services.CompareDependencyGraph(response, comparison)
+	return &response, nil
+}
+
+// getDependencyGraph returns the edges of the dependency graph between start
+// and end. The dependency graph table has the deployment environment and the
+// k8s cluster and namespace only, the graph filtered on any other tag is built
+// from the spans. The tags filter the called spans, like the table does.
+func (r *ClickHouseReader) getDependencyGraph(ctx context.Context, start, end *time.Time, tagParams []model.TagQueryParam) ([]model.ServiceMapDependencyResponseItem, error) {
+
 	response := []model.ServiceMapDependencyResponseItem{}
 
+	tags := createTagQueryFromTagQueryParams(tagParams)
 	args := []interface{}{}
-	args = append(args,
-		clickhouse.Named("start", uint64(queryParams.Start.Unix())),
-		clickhouse.Named("end", uint64(queryParams.End.Unix())),
-		clickhouse.Named("duration", uint64(queryParams.End.Unix()-queryParams.Start.Unix())),
-	)
+	var query string
+	if services.UsesDependencyGraphTable(tags) {
+		args = append(args,
+			clickhouse.Named("start", uint64(start.Unix())),
+			clickhouse.Named("end", uint64(end.Unix())),
+			clickhouse.Named("duration", uint64(end.Unix()-start.Unix())),
+		)
 
-	query := fmt.Sprintf(`
+		query = fmt.Sprintf(`
 		WITH
 			quantilesMergeState(0.5, 0.75, 0.9, 0.95, 0.99)(duration_quantiles_state) AS duration_quantiles_state,
 			finalizeAggregation(duration_quantiles_state) AS result
@@ -2038,13 +2091,67 @@ func (r *ClickHouseReader) GetDependencyGraph(ctx context.Context, queryParams *
 			sum(error_count)/sum(total_count) * 100 as errorRate
 		FROM %s.%s
 		WHERE toUInt64(toDateTime(timestamp)) >= @start AND toUInt64(toDateTime(timestamp)) <= @end`,
-		r.TraceDB, r.dependencyGraphTable,
-	)
+			r.TraceDB, r.dependencyGraphTable,
+		)
 
-	tags := createTagQueryFromTagQueryParams(queryParams.Tags)
-	filterQuery, filterArgs := services.BuildServiceMapQuery(tags)
-	query += filterQuery + " GROUP BY src, dest;"
-	args = append(args, filterArgs...)
+		filterQuery, filterArgs := services.BuildServiceMapQuery(tags)
+		query += filterQuery + " GROUP BY src, dest;"
+		args = append(args, filterArgs...)
+	} else {
+		args = append(args,
+			clickhouse.Named("start", strconv.FormatInt(start.UnixNano(), 10)),
+			clickhouse.Named("end", strconv.FormatInt(end.UnixNano(), 10)),
+			clickhouse.Named("duration", uint64(end.Unix()-start.Unix())),
+			clickhouse.Named("traceLimit", serviceMapTraceLimit),
+		)
+
+		filterQuery, filterArgs, apiErr := buildQueryWithTagParams(ctx, tags)
+		if apiErr != nil {
+			return nil, apiErr.Err
+		}
+		args = append(args, filterArgs...)
+
+		// the calls are joined from the spans of at most serviceMapTraceLimit
+		// traces with a matching span. When more traces match, the counts are
+		// scaled by the sampled fraction of the traces and the edges are
+		// marked as sampled. The filter applies to the called spans only, so
+		// that the calls into the filtered spans from the services outside of
+		// them are kept.
+		matching := fmt.Sprintf("FROM %s.%s WHERE timestamp >= @start AND timestamp <= @end%s", r.TraceDB, r.indexTable, filterQuery)
+		traces := fmt.Sprintf("SELECT DISTINCT traceID %s LIMIT @traceLimit", matching)
+		query = fmt.Sprintf(`
+		WITH
+			quantiles(0.5, 0.75, 0.9, 0.95, 0.99)(childSpans.durationNano) AS result,
+			(SELECT uniq(traceID) %s) AS matchingTraces,
+			greatest(matchingTraces / @traceLimit, 1) AS sampleScale
+		SELECT
+			parentSpans.serviceName as parent,
+			childSpans.serviceName as child,
+			result[1] AS p50,
+			result[2] AS p75,
+			result[3] AS p90,
+			result[4] AS p95,
+			result[5] AS p99,
+			toUInt64(round(count() * sampleScale)) as callCount,
+			count() * sampleScale / @duration AS callRate,
+			countIf(childSpans.statusCode = 2)/count() * 100 as errorRate,
+			toBool(matchingTraces > @traceLimit) as sampled
+		FROM (
+			SELECT traceID, spanID, serviceName
+			FROM %s.%s
+			WHERE timestamp >= @start AND timestamp <= @end AND traceID GLOBAL IN (%s)
+		) AS parentSpans
+		INNER JOIN (
+			SELECT traceID, parentSpanID, serviceName, durationNano, statusCode
+			FROM %s.%s
+			WHERE timestamp >= @start AND timestamp <= @end AND traceID GLOBAL IN (%s)%s
+		) AS childSpans
+		ON parentSpans.traceID = childSpans.traceID AND parentSpans.spanID = childSpans.parentSpanID
+		WHERE parentSpans.serviceName != childSpans.serviceName
+		GROUP BY parent, child;`,
+			matching, r.TraceDB, r.indexTable, traces, r.TraceDB, r.indexTable, traces, filterQuery,
+		)
+	}
 
 	zap.S().Debug(query, args)
 
@@ -2055,7 +2162,7 @@ func (r *ClickHouseReader) GetDependencyGraph(ctx context.Context, queryParams *
 		return nil, fmt.Errorf("error in processing sql query %w", err)
 	}
 
-	return &response, nil
+	return response, nil
 }
 
 func (r *ClickHouseReader) GetFilteredSpansAggregates(ctx context.Context, queryParams *model.GetFilteredSpanAggregatesParams) (*model.GetFilteredSpansAggregatesResponse, *model.ApiError) {
@@ -2820,6 +2927,59 @@ func (r *ClickHouseReader) CountErrors(ctx context.Context, queryParams *model.C
 	return errorCount, nil
 }
 
+// GetErrorGroupRows returns the errors of the ingested groups, the errors of
+// a group are split by the top frames of their stack trace when frames is set
+func (r *ClickHouseReader) GetErrorGroupRows(ctx context.Context, queryParams *model.ListErrorsParams, frames int, limit int) ([]model.ErrorGroupRow, *model.ApiError) {
+
+	var rows []model.ErrorGroupRow
+
+	framesQuery := "''"
+	args := []interface{}{clickhouse.Named("timestampL", strconv.FormatInt(queryParams.Start.UnixNano(), 10)), clickhouse.Named("timestampU", strconv.FormatInt(queryParams.End.UnixNano(), 10))}
+	if frames > 0 {
+		// the first line of the stack trace is the exception
+		framesQuery = "arrayStringConcat(arraySlice(arrayFilter(x -> x != '', arrayMap(x -> trimBoth(x), splitByChar('\\n', exceptionStacktrace))), 2, @frames), '\\n')"
+		args = append(args, clickhouse.Named("frames", frames))
+	}
+
+	query := fmt.Sprintf("SELECT groupID, serviceName, exceptionType, exceptionMessage, %s as frames, count() AS exceptionCount, min(timestamp) as firstSeen, max(timestamp) as lastSeen, argMax(resourceTagsMap['service.version'], timestamp) as lastRelease FROM %s.%s WHERE timestamp >= @timestampL AND timestamp <= @timestampU", framesQuery, r.TraceDB, r.errorTable)
+
+	if len(queryParams.ServiceName) != 0 {
+		query = query + " AND serviceName ilike @serviceName"
+		args = append(args, clickhouse.Named("serviceName", "%"+queryParams.ServiceName+"%"))
+	}
+	if len(queryParams.ExceptionType) != 0 {
+		query = query + " AND exceptionType ilike @exceptionType"
+		args = append(args, clickhouse.Named("exceptionType", "%"+queryParams.ExceptionType+"%"))
+	}
+
+	// create TagQuery from TagQueryParams
+	tags := createTagQueryFromTagQueryParams(queryParams.Tags)
+	subQuery, argsSubQuery, errStatus := buildQueryWithTagParams(ctx, tags)
+	query += subQuery
+	args = append(args, argsSubQuery...)
+
+	if errStatus != nil {
+		zap.S().Error("Error in processing tags: ", errStatus)
+		return nil, errStatus
+	}
+	query = query + " GROUP BY groupID, serviceName, exceptionType, exceptionMessage, frames"
+	if limit > 0 {
+		// the most recently seen rows are kept
+		query = query + " ORDER BY lastSeen DESC LIMIT @limit"
+		args = append(args, clickhouse.Named("limit", limit))
+	}
+
+	err := r.db.Select(ctx, &rows, query, args...)
+	zap.S().Info(query)
+
+	if err != nil {
+		zap.S().Debug("Error in processing sql query: ", err)
+		return nil, &model.ApiError{Typ: model.ErrorExec, Err: fmt.Errorf("Error in processing sql query")}
+	}
+
+	return rows, nil
+}
+
 func (r *ClickHouseReader) GetErrorFromErrorID(ctx context.Context, queryParams *model.GetErrorParams) (*model.ErrorWithSpan, *model.ApiError) {
 
 	if queryParams.ErrorID == "" {
@@ -4411,11 +4571,30 @@ func readRowsForTimeSeriesResult(rows driver.Rows, vars []interface{}, columnNam
 	return seriesList, nil
 }
 
+// collectQueryStats records the progress of the query in the stats of the
+// context if any, the returned func records the query once it is read
+func collectQueryStats(ctx context.Context, query string) (context.Context, func()) {
+	stats := common.GetQueryStatsFromContext(ctx)
+	if stats == nil {
+		return ctx, func() {}
+	}
+	start := time.Now()
+	ctx = clickhouse.Context(ctx, clickhouse.WithProgress(func(p *clickhouse.Progress) {
+		stats.AddRead(p.Rows, p.Bytes)
+	}))
+	return ctx, func() {
+		stats.AddQuery(query, time.Since(start))
+	}
+}
+
 // GetTimeSeriesResultV3 runs the query and returns list of time series
 func (r *ClickHouseReader) GetTimeSeriesResultV3(ctx context.Context, query string) ([]*v3.Series, error) {
 
 	defer utils.Elapsed("GetTimeSeriesResultV3", query)()
 
+	ctx, done := collectQueryStats(ctx, query)
+	defer done()
+
 	rows, err := r.db.Query(ctx, query)
 
 	if err != nil {
@@ -4441,11 +4620,30 @@ func (r *ClickHouseReader) GetListResultV3(ctx context.Context, query string) ([
 
 	defer utils.Elapsed("GetListResultV3", query)()
 
+	var rowList []*v3.Row
+	err := r.StreamListResultV3(ctx, query, func(row *v3.Row) error {
+		rowList = append(rowList, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rowList, nil
+
+}
+
+// StreamListResultV3 runs the query and passes the rows to fn as they are
+// read, without keeping them in memory. Reading stops at the first error of fn.
+func (r *ClickHouseReader) StreamListResultV3(ctx context.Context, query string, fn func(*v3.Row) error) error {
+
+	ctx, done := collectQueryStats(ctx, query)
+	defer done()
+
 	rows, err := r.db.Query(ctx, query)
 
 	if err != nil {
 		zap.S().Errorf("error while reading time series result %v", err)
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
@@ -4454,15 +4652,13 @@ func (r *ClickHouseReader) GetListResultV3(ctx context.Context, query string) ([
 		columnNames = rows.Columns()
 	)
 
-	var rowList []*v3.Row
-
 	for rows.Next() {
 		var vars = make([]interface{}, len(columnTypes))
 		for i := range columnTypes {
 			vars[i] = reflect.New(columnTypes[i].ScanType()).Interface()
 		}
 		if err := rows.Scan(vars...); err != nil {
-			return nil, err
+			return err
 		}
 		row := map[string]interface{}{}
 		var t time.Time
@@ -4475,12 +4671,142 @@ func (r *ClickHouseReader) GetListResultV3(ctx context.Context, query string) ([
 				row[columnNames[idx]] = v
 			}
 		}
-		rowList = append(rowList, &v3.Row{Timestamp: t, Data: row})
+		if err := fn(&v3.Row{Timestamp: t, Data: row}); err != nil {
+			return err
+		}
 	}
 
-	return rowList, nil
+	return rows.Err()
+}
+
+// GetCallGraphV3 runs the call graph query and returns its edges
+func (r *ClickHouseReader) GetCallGraphV3(ctx context.Context, query string) ([]*v3.CallGraphEdge, error) {
+
+	defer utils.Elapsed("GetCallGraphV3", query)()
+
+	ctx, done := collectQueryStats(ctx, query)
+	defer done()
+
+	var rows []v3.CallGraphEdge
+	if err := r.db.Select(ctx, &rows, query); err != nil {
+		zap.S().Errorf("error while reading the call graph %v", err)
+		return nil, err
+	}
+	edges := make([]*v3.CallGraphEdge, 0, len(rows))
+	for i := range rows {
+		edges = append(edges, &rows[i])
+	}
+	return edges, nil
+}
+
+// MetricNameExists reports whether any time series of the metric is written
+func (r *ClickHouseReader) MetricNameExists(ctx context.Context, metricName string) (bool, error) {
+	query := fmt.Sprintf("SELECT count() FROM (SELECT 1 FROM %s.%s WHERE metric_name = @metricName LIMIT 1)", signozMetricDBName, signozTSTableName)
+	var count uint64
+	if err := r.db.QueryRow(ctx, query, clickhouse.Named("metricName", metricName)).Scan(&count); err != nil {
+		return false, fmt.Errorf("error while checking the metric name: %v", err)
+	}
+	return count > 0, nil
+}
+
+// WriteMetricSamplesV3 writes the points of the series as the samples of the
+// metric, the labels of the series are the labels of the metric
+func (r *ClickHouseReader) WriteMetricSamplesV3(ctx context.Context, metricName string, series []*v3.Series) error {
+
+	tsBatch, err := r.db.PrepareBatch(ctx, fmt.Sprintf("INSERT INTO %s.%s (metric_name, temporality, timestamp_ms, fingerprint, labels)", signozMetricDBName, signozTSTableName))
+	if err != nil {
+		return fmt.Errorf("error while preparing the time series batch: %v", err)
+	}
+	samplesBatch, err := r.db.PrepareBatch(ctx, fmt.Sprintf("INSERT INTO %s.%s (metric_name, fingerprint, timestamp_ms, value)", signozMetricDBName, signozSampleTableName))
+	if err != nil {
+		return fmt.Errorf("error while preparing the samples batch: %v", err)
+	}
+
+	var samples int
+	for _, s := range series {
+		if len(s.Points) == 0 {
+			continue
+		}
+		lbls := make(map[string]string, len(s.Labels)+1)
+		for name, value := range s.Labels {
+			lbls[name] = value
+		}
+		lbls[labels.MetricNameLabel] = metricName
+		fingerprint := labels.FromMap(lbls).Hash()
+		encoded, err := json.Marshal(lbls)
+		if err != nil {
+			return err
+		}
+
+		if err := tsBatch.Append(metricName, string(v3.Unspecified), s.Points[0].Timestamp, fingerprint, string(encoded)); err != nil {
+			return fmt.Errorf("error while appending the time series: %v", err)
+		}
+		for _, p := range s.Points {
+			if err := samplesBatch.Append(metricName, fingerprint, p.Timestamp, p.Value); err != nil {
+				return fmt.Errorf("error while appending the sample: %v", err)
+			}
+			samples++
+		}
+	}
+	if samples == 0 {
+		return nil
+	}
+
+	// the series are written first so that the samples are never read
+	// without their labels
+	if err := tsBatch.Send(); err != nil {
+		return fmt.Errorf("error while writing the time series: %v", err)
+	}
+	if err := samplesBatch.Send(); err != nil {
+		return fmt.Errorf("error while writing the samples: %v", err)
+	}
+	return nil
+}
+
+// EstimateQueryV3 estimates the data read by the query with EXPLAIN ESTIMATE,
+// the bytes are estimated from the average size of the rows of the tables read
+func (r *ClickHouseReader) EstimateQueryV3(ctx context.Context, query string) (*v3.QueryEstimate, error) {
 
+	defer utils.Elapsed("EstimateQueryV3", query)()
+
+	rows, err := r.db.Query(ctx, "EXPLAIN ESTIMATE "+query)
+	if err != nil {
+		zap.S().Errorf("error while estimating query %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	estimate := &v3.QueryEstimate{Query: query, Estimated: true}
+	tableRows := map[string]uint64{}
+	for rows.Next() {
+		var database, table string
+		var parts, rowsRead, marks uint64
+		if err := rows.Scan(&database, &table, &parts, &rowsRead, &marks); err != nil {
+			return nil, err
+		}
+		estimate.Parts += parts
+		estimate.Rows += rowsRead
+		estimate.Marks += marks
+		tableRows[database+"."+table] += rowsRead
+	}
+
+	for name, rowsRead := range tableRows {
+		database, table, _ := strings.Cut(name, ".")
+		var totalBytes, totalRows uint64
+		err := r.db.QueryRow(ctx,
+			"SELECT sum(data_uncompressed_bytes), sum(rows) FROM system.parts WHERE active AND database = ? AND table = ?",
+			database, table,
+		).Scan(&totalBytes, &totalRows)
+		if err != nil {
+			return nil, err
+		}
+		if totalRows > 0 {
+			estimate.Bytes += uint64(float64(rowsRead) * float64(totalBytes) / float64(totalRows))
+		}
+	}
+	return estimate, nil
 }
+
 func (r *ClickHouseReader) CheckClickHouse(ctx context.Context) error {
 	rows, err := r.db.Query(ctx, "SELECT 1")
 	if err != nil {
@@ -4704,46 +5030,27 @@ func (r *ClickHouseReader) GetSpanAttributeKeys(ctx context.Context) (map[string
 	return response, nil
 }
 
-func (r *ClickHouseReader) LiveTailLogsV3(ctx context.Context, query string, timestampStart uint64, idStart string, client *v3.LogsLiveTailClient) {
-	if timestampStart == 0 {
-		timestampStart = uint64(time.Now().UnixNano())
-	} else {
-		timestampStart = uint64(utils.GetEpochNanoSecs(int64(timestampStart)))
+// TailLogsV3 returns the logs of the live tail query following the position,
+// the oldest first
+func (r *ClickHouseReader) TailLogsV3(ctx context.Context, query string, timestampStart uint64, idStart string, limit int) ([]model.SignozLog, error) {
+	tmpQuery := fmt.Sprintf("%s(timestamp, id) > (%d, %s) order by timestamp asc, id asc limit %d", query, timestampStart, utils.ClickHouseFormattedValue(idStart), limit)
+
+	// using the old structure since we can directly read it to the struct as use it.
+	response := []model.SignozLog{}
+	if err := r.db.Select(ctx, &response, tmpQuery); err != nil {
+		return nil, err
 	}
+	return response, nil
+}
 
-	ticker := time.NewTicker(time.Duration(r.liveTailRefreshSeconds) * time.Second)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ctx.Done():
-			done := true
-			client.Done <- &done
-			zap.S().Debug("closing go routine : " + client.Name)
-			return
-		case <-ticker.C:
-			// get the new 100 logs as anything more older won't make sense
-			tmpQuery := fmt.Sprintf("timestamp >='%d'", timestampStart)
-			if idStart != "" {
-				tmpQuery = fmt.Sprintf("%s AND id > '%s'", tmpQuery, idStart)
-			}
-			// the reason we are doing desc is that we need the latest logs first
-			tmpQuery = query + tmpQuery + " order by timestamp desc, id desc limit 100"
+// LastLogsV3 returns the last logs of the live tail query up to the position,
+// the most recent first
+func (r *ClickHouseReader) LastLogsV3(ctx context.Context, query string, timestampEnd uint64, idEnd string, limit int) ([]model.SignozLog, error) {
+	tmpQuery := fmt.Sprintf("%s(timestamp, id) <= (%d, %s) order by timestamp desc, id desc limit %d", query, timestampEnd, utils.ClickHouseFormattedValue(idEnd), limit)
 
-			// using the old structure since we can directly read it to the struct as use it.
-			response := []model.SignozLog{}
-			err := r.db.Select(ctx, &response, tmpQuery)
-			if err != nil {
-				zap.S().Error(err)
-				client.Error <- err
-				return
-			}
-			for i := len(response) - 1; i >= 0; i-- {
-				client.Logs <- &response[i]
-				if i == 0 {
-					timestampStart = response[i].Timestamp
-					idStart = response[i].ID
-				}
-			}
-		}
+	response := []model.SignozLog{}
+	if err := r.db.Select(ctx, &response, tmpQuery); err != nil {
+		return nil, err
 	}
+	return response, nil
 }
@@ -0,0 +1,178 @@
+package derivedmetrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"go.signoz.io/signoz/pkg/query-service/auth"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+var db *sqlx.DB
+
+type DerivedMetric struct {
+	ID                string    `db:"id"`
+	Name              string    `db:"name"`
+	Description       string    `db:"description"`
+	Data              string    `db:"data"`
+	MaterializedUntil int64     `db:"materialized_until"`
+	CreatedAt         time.Time `db:"created_at"`
+	CreatedBy         string    `db:"created_by"`
+	UpdatedAt         time.Time `db:"updated_at"`
+	UpdatedBy         string    `db:"updated_by"`
+}
+
+// InitWithDSN sets up the connection pool global variable and creates the
+// derived metrics table.
+func InitWithDSN(dataSourceName string) (*sqlx.DB, error) {
+	var err error
+
+	db, err = sqlx.Open("sqlite3", dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	tableSchema := `CREATE TABLE IF NOT EXISTS derived_metrics (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		description TEXT,
+		data TEXT NOT NULL,
+		materialized_until INTEGER NOT NULL DEFAULT 0,
+		created_at datetime NOT NULL,
+		created_by TEXT,
+		updated_at datetime NOT NULL,
+		updated_by TEXT
+	);`
+
+	_, err = db.Exec(tableSchema)
+	if err != nil {
+		return nil, fmt.Errorf("error in creating derived metrics table: %s", err.Error())
+	}
+
+	return db, nil
+}
+
+func InitWithDB(sqlDB *sqlx.DB) {
+	db = sqlDB
+}
+
+func (m *DerivedMetric) toV3() (*v3.DerivedMetric, error) {
+	var query v3.BuilderQuery
+	if err := json.Unmarshal([]byte(m.Data), &query); err != nil {
+		return nil, fmt.Errorf("error in unmarshalling derived metric query: %s", err.Error())
+	}
+	return &v3.DerivedMetric{
+		ID:                m.ID,
+		Name:              m.Name,
+		Description:       m.Description,
+		Query:             &query,
+		MaterializedUntil: m.MaterializedUntil,
+		CreatedAt:         m.CreatedAt,
+		CreatedBy:         m.CreatedBy,
+		UpdatedAt:         m.UpdatedAt,
+		UpdatedBy:         m.UpdatedBy,
+	}, nil
+}
+
+func GetDerivedMetrics() ([]*v3.DerivedMetric, error) {
+	var metrics []DerivedMetric
+	err := db.Select(&metrics, "SELECT * FROM derived_metrics ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("error in getting derived metrics: %s", err.Error())
+	}
+
+	derivedMetrics := make([]*v3.DerivedMetric, 0, len(metrics))
+	for i := range metrics {
+		metric, err := metrics[i].toV3()
+		if err != nil {
+			return nil, err
+		}
+		derivedMetrics = append(derivedMetrics, metric)
+	}
+	return derivedMetrics, nil
+}
+
+func GetDerivedMetric(id string) (*v3.DerivedMetric, error) {
+	var metric DerivedMetric
+	err := db.Get(&metric, "SELECT * FROM derived_metrics WHERE id = ?", id)
+	if err != nil {
+		return nil, fmt.Errorf("error in getting derived metric: %s", err.Error())
+	}
+	return metric.toV3()
+}
+
+func CreateDerivedMetric(ctx context.Context, metric v3.DerivedMetric) (string, error) {
+	data, err := json.Marshal(metric.Query)
+	if err != nil {
+		return "", fmt.Errorf("error in marshalling derived metric query: %s", err.Error())
+	}
+
+	email, err := auth.GetEmailFromJwt(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	id := uuid.New().String()
+	now := time.Now()
+	_, err = db.Exec(
+		"INSERT INTO derived_metrics (id, name, description, data, materialized_until, created_at, created_by, updated_at, updated_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		id,
+		metric.Name,
+		metric.Description,
+		data,
+		0,
+		now,
+		email,
+		now,
+		email,
+	)
+	if err != nil {
+		return "", fmt.Errorf("error in creating derived metric: %s", err.Error())
+	}
+	return id, nil
+}
+
+// UpdateDerivedMetric updates the definition of the metric, the new query is
+// materialized from the end of the intervals already materialized
+func UpdateDerivedMetric(ctx context.Context, id string, metric v3.DerivedMetric) error {
+	data, err := json.Marshal(metric.Query)
+	if err != nil {
+		return fmt.Errorf("error in marshalling derived metric query: %s", err.Error())
+	}
+
+	email, err := auth.GetEmailFromJwt(ctx)
+	if err != nil {
+		return err
+	}
+
+	res, err := db.Exec("UPDATE derived_metrics SET updated_at = ?, updated_by = ?, name = ?, description = ?, data = ? WHERE id = ?",
+		time.Now(), email, metric.Name, metric.Description, data, id)
+	if err != nil {
+		return fmt.Errorf("error in updating derived metric: %s", err.Error())
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("derived metric %s not found", id)
+	}
+	return nil
+}
+
+func DeleteDerivedMetric(id string) error {
+	_, err := db.Exec("DELETE FROM derived_metrics WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("error in deleting derived metric: %s", err.Error())
+	}
+	return nil
+}
+
+// setMaterializedUntil records the end of the last materialized interval
+func setMaterializedUntil(id string, until int64) error {
+	_, err := db.Exec("UPDATE derived_metrics SET materialized_until = ? WHERE id = ?", until, id)
+	if err != nil {
+		return fmt.Errorf("error in updating derived metric: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package derivedmetrics
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	logsV3 "go.signoz.io/signoz/pkg/query-service/app/logs/v3"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultInterval = time.Minute
+	defaultDelay    = 2 * time.Minute
+	// defaultBackfill is how far back a new metric is materialized
+	defaultBackfill = time.Hour
+	// defaultMaxWindow is the longest time range read by a single query
+	defaultMaxWindow = 6 * time.Hour
+	// materializeTimeout bounds a materialization of all the metrics
+	materializeTimeout = 5 * time.Minute
+)
+
+// Reader reads the series of the logs queries and writes the samples of the
+// derived metrics
+type Reader interface {
+	GetTimeSeriesResultV3(ctx context.Context, query string) ([]*v3.Series, error)
+	WriteMetricSamplesV3(ctx context.Context, metricName string, series []*v3.Series) error
+}
+
+type Options struct {
+	// Interval is how often the metrics are materialized
+	Interval time.Duration
+	// Delay is how long the logs of an interval are waited for before the
+	// interval is materialized
+	Delay time.Duration
+	// Backfill is how far back a new metric is materialized
+	Backfill time.Duration
+	// MaxWindow is the longest time range read by a single query, longer
+	// ranges are materialized in several queries
+	MaxWindow time.Duration
+}
+
+// Materializer periodically runs the queries of the derived metrics on the
+// logs received since their last run and writes the points as samples of the
+// metrics. The metrics are then read like any other metric, in the query
+// builder, in PromQL and in the alert rules.
+type Materializer struct {
+	reader Reader
+	opts   Options
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewMaterializer(reader Reader, opts Options) *Materializer {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultInterval
+	}
+	if opts.Delay <= 0 {
+		opts.Delay = defaultDelay
+	}
+	if opts.Backfill <= 0 {
+		opts.Backfill = defaultBackfill
+	}
+	if opts.MaxWindow <= 0 {
+		opts.MaxWindow = defaultMaxWindow
+	}
+	return &Materializer{
+		reader: reader,
+		opts:   opts,
+		done:   make(chan struct{}),
+	}
+}
+
+// Start materializes the metrics every interval until the materializer is
+// stopped
+func (m *Materializer) Start() {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.done:
+				return
+			case now := <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), materializeTimeout)
+				m.MaterializeAll(ctx, now)
+				cancel()
+			}
+		}
+	}()
+}
+
+func (m *Materializer) Stop() {
+	close(m.done)
+	m.wg.Wait()
+}
+
+// MaterializeAll materializes every metric up to now, a failing metric does
+// not hold the others back and is retried from the same point the next time
+func (m *Materializer) MaterializeAll(ctx context.Context, now time.Time) {
+	metrics, err := GetDerivedMetrics()
+	if err != nil {
+		zap.S().Errorf("failed to get the derived metrics: %v", err)
+		return
+	}
+	for _, metric := range metrics {
+		err := m.Materialize(ctx, metric, now, func(until int64) error {
+			return setMaterializedUntil(metric.ID, until)
+		})
+		if err != nil {
+			zap.S().Errorf("failed to materialize the derived metric %s: %v", metric.Name, err)
+		}
+	}
+}
+
+// window returns the next time range of the metric to materialize, in
+// milliseconds. The range is made of the complete steps received before the
+// delay, up to the max window, and is empty when there is nothing to
+// materialize.
+func (m *Materializer) window(metric *v3.DerivedMetric, now time.Time) (int64, int64) {
+	step := metric.Query.StepInterval * 1000
+
+	end := now.Add(-m.opts.Delay).UnixMilli()
+	end -= end % step
+
+	start := metric.MaterializedUntil
+	if start == 0 {
+		start = end - m.opts.Backfill.Milliseconds()
+	}
+	// the range starts after the last materialized step even when the step
+	// of the metric changed, so that no sample is written twice
+	if rem := start % step; rem != 0 {
+		start += step - rem
+	}
+
+	if end <= start {
+		return start, start
+	}
+	// both ends are aligned, the range has at least a step
+	if end-start > m.opts.MaxWindow.Milliseconds() {
+		window := m.opts.MaxWindow.Milliseconds()
+		window -= window % step
+		if window == 0 {
+			window = step
+		}
+		end = start + window
+	}
+	return start, end
+}
+
+// Materialize writes the samples of the metric up to now, save records the
+// end of every range written
+func (m *Materializer) Materialize(ctx context.Context, metric *v3.DerivedMetric, now time.Time, save func(until int64) error) error {
+	for {
+		start, end := m.window(metric, now)
+		if end <= start {
+			return nil
+		}
+
+		// the end of the query is inclusive, the logs at the end belong to
+		// the first step of the next range
+		query, err := logsV3.PrepareLogsQuery(start, end, v3.QueryTypeBuilder, v3.PanelTypeGraph, metric.Query, logsV3.Options{})
+		if err != nil {
+			return err
+		}
+		series, err := m.reader.GetTimeSeriesResultV3(ctx, query)
+		if err != nil {
+			return err
+		}
+		if err := m.reader.WriteMetricSamplesV3(ctx, metric.Name, metricSeries(series, start, end)); err != nil {
+			return err
+		}
+
+		if err := save(end); err != nil {
+			return err
+		}
+		metric.MaterializedUntil = end
+	}
+}
+
+// metricSeries returns the points of the series in [start, end) with the
+// labels renamed to valid metric label names
+func metricSeries(series []*v3.Series, start, end int64) []*v3.Series {
+	result := make([]*v3.Series, 0, len(series))
+	for _, s := range series {
+		labels := make(map[string]string, len(s.Labels))
+		for name, value := range s.Labels {
+			labels[labelName(name)] = value
+		}
+		points := make([]v3.Point, 0, len(s.Points))
+		for _, p := range s.Points {
+			if p.Timestamp >= start && p.Timestamp < end {
+				points = append(points, p)
+			}
+		}
+		if len(points) > 0 {
+			result = append(result, &v3.Series{Labels: labels, Points: points})
+		}
+	}
+	return result
+}
+
+// labelName replaces the characters not allowed in the label names of the
+// metrics, such as the dots of the attribute names, with underscores
+func labelName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
@@ -0,0 +1,156 @@
+package derivedmetrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+type written struct {
+	metricName string
+	series     []*v3.Series
+}
+
+// seriesReader records the queries run and the samples written
+type seriesReader struct {
+	queries []string
+	writes  []written
+}
+
+func (r *seriesReader) GetTimeSeriesResultV3(ctx context.Context, query string) ([]*v3.Series, error) {
+	r.queries = append(r.queries, query)
+	return nil, nil
+}
+
+func (r *seriesReader) WriteMetricSamplesV3(ctx context.Context, metricName string, series []*v3.Series) error {
+	r.writes = append(r.writes, written{metricName: metricName, series: series})
+	return nil
+}
+
+func errorsMetric(materializedUntil int64) *v3.DerivedMetric {
+	return &v3.DerivedMetric{
+		ID:   "1",
+		Name: "frontend_errors",
+		Query: &v3.BuilderQuery{
+			QueryName:         "A",
+			Expression:        "A",
+			DataSource:        v3.DataSourceLogs,
+			AggregateOperator: v3.AggregateOperatorCount,
+			StepInterval:      60,
+			GroupBy:           []v3.AttributeKey{{Key: "service.name", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeResource}},
+		},
+		MaterializedUntil: materializedUntil,
+	}
+}
+
+func TestMaterializerWindow(t *testing.T) {
+	m := NewMaterializer(&seriesReader{}, Options{Delay: 2 * time.Minute, Backfill: time.Hour, MaxWindow: 6 * time.Hour})
+	now := time.UnixMilli(1700000130000) // 30s in a minute
+
+	// a new metric is backfilled up to the last complete step before the delay
+	start, end := m.window(errorsMetric(0), now)
+	assert.Equal(t, int64(1699999980000), end)
+	assert.Equal(t, end-time.Hour.Milliseconds(), start)
+
+	// the range continues from the last materialized step
+	start, end = m.window(errorsMetric(1699999860000), now)
+	assert.Equal(t, int64(1699999860000), start)
+	assert.Equal(t, int64(1699999980000), end)
+
+	// nothing is left to materialize
+	start, end = m.window(errorsMetric(1699999980000), now)
+	assert.Equal(t, start, end)
+
+	// a range not aligned on the step starts at the next step
+	start, _ = m.window(errorsMetric(1699999870000), now)
+	assert.Equal(t, int64(1699999920000), start)
+
+	// long ranges are split
+	start, end = m.window(errorsMetric(1699999980000-24*time.Hour.Milliseconds()), now)
+	assert.Equal(t, 6*time.Hour.Milliseconds(), end-start)
+}
+
+func TestMaterialize(t *testing.T) {
+	reader := &seriesReader{}
+	m := NewMaterializer(reader, Options{Delay: 2 * time.Minute, MaxWindow: 30 * time.Minute})
+	now := time.UnixMilli(1700000130000)
+	metric := errorsMetric(1699999980000 - time.Hour.Milliseconds())
+
+	var saved []int64
+	err := m.Materialize(context.Background(), metric, now, func(until int64) error {
+		saved = append(saved, until)
+		return nil
+	})
+	require.NoError(t, err)
+
+	// the hour is materialized in two ranges of 30 minutes
+	assert.Equal(t, []int64{1699999980000 - 30*time.Minute.Milliseconds(), 1699999980000}, saved)
+	assert.Equal(t, int64(1699999980000), metric.MaterializedUntil)
+	require.Len(t, reader.queries, 2)
+	assert.Contains(t, reader.queries[0], "signoz_logs")
+	assert.Contains(t, reader.queries[0], "group by service.name")
+	require.Len(t, reader.writes, 2)
+	assert.Equal(t, "frontend_errors", reader.writes[0].metricName)
+
+	// nothing is left to materialize
+	err = m.Materialize(context.Background(), metric, now, func(until int64) error {
+		t.Fatal("nothing should be saved")
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Len(t, reader.queries, 2)
+}
+
+func TestMetricSeries(t *testing.T) {
+	series := metricSeries([]*v3.Series{
+		{
+			Labels: map[string]string{"service.name": "frontend", "1st": "a"},
+			Points: []v3.Point{
+				{Timestamp: 60000, Value: 1},
+				{Timestamp: 120000, Value: 2},
+				// the inclusive end of the query
+				{Timestamp: 180000, Value: 3},
+			},
+		},
+		{
+			Labels: map[string]string{"service.name": "backend"},
+			Points: []v3.Point{{Timestamp: 180000, Value: 4}},
+		},
+	}, 60000, 180000)
+
+	require.Len(t, series, 1)
+	assert.Equal(t, map[string]string{"service_name": "frontend", "_1st": "a"}, series[0].Labels)
+	assert.Equal(t, []v3.Point{{Timestamp: 60000, Value: 1}, {Timestamp: 120000, Value: 2}}, series[0].Points)
+}
+
+func TestDerivedMetricValidate(t *testing.T) {
+	metric := errorsMetric(0)
+	assert.NoError(t, metric.Validate(nil))
+
+	metric.Name = "frontend.errors"
+	assert.Error(t, metric.Validate(nil))
+
+	metric = errorsMetric(0)
+	metric.Query.AggregateOperator = v3.AggregateOperatorNoOp
+	assert.Error(t, metric.Validate(nil))
+
+	metric = errorsMetric(0)
+	metric.Query.DataSource = v3.DataSourceTraces
+	assert.Error(t, metric.Validate(nil))
+
+	metric = errorsMetric(0)
+	metric.Query.Limit = 10
+	assert.Error(t, metric.Validate(nil))
+
+	metric = errorsMetric(0)
+	metricExists := func(name string) (bool, error) {
+		return name == "frontend_errors", nil
+	}
+	assert.Error(t, metric.Validate(metricExists))
+	metric.Name = "frontend_errors_total"
+	assert.NoError(t, metric.Validate(metricExists))
+}
@@ -0,0 +1,245 @@
+package errorgroups
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"go.signoz.io/signoz/pkg/query-service/auth"
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+var db *sqlx.DB
+
+type ErrorGroupingRule struct {
+	ID        string    `db:"id"`
+	Name      string    `db:"name"`
+	Data      string    `db:"data"`
+	CreatedAt time.Time `db:"created_at"`
+	CreatedBy string    `db:"created_by"`
+	UpdatedAt time.Time `db:"updated_at"`
+	UpdatedBy string    `db:"updated_by"`
+}
+
+type ErrorGroupStatus struct {
+	Fingerprint       string    `db:"fingerprint"`
+	State             string    `db:"state"`
+	ResolvedInRelease string    `db:"resolved_in_release"`
+	GroupIDs          string    `db:"group_ids"`
+	UpdatedAt         time.Time `db:"updated_at"`
+	UpdatedBy         string    `db:"updated_by"`
+}
+
+// InitWithDSN sets up the connection pool global variable and creates the
+// error grouping rules and error group states tables.
+func InitWithDSN(dataSourceName string) (*sqlx.DB, error) {
+	var err error
+
+	db, err = sqlx.Open("sqlite3", dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	tableSchema := `CREATE TABLE IF NOT EXISTS error_grouping_rules (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		data TEXT NOT NULL,
+		created_at datetime NOT NULL,
+		created_by TEXT,
+		updated_at datetime NOT NULL,
+		updated_by TEXT
+	);
+	CREATE TABLE IF NOT EXISTS error_group_states (
+		fingerprint TEXT PRIMARY KEY,
+		state TEXT NOT NULL,
+		resolved_in_release TEXT NOT NULL DEFAULT '',
+		group_ids TEXT NOT NULL DEFAULT '',
+		updated_at datetime NOT NULL,
+		updated_by TEXT NOT NULL DEFAULT ''
+	);`
+
+	_, err = db.Exec(tableSchema)
+	if err != nil {
+		return nil, fmt.Errorf("error in creating error groups tables: %s", err.Error())
+	}
+
+	return db, nil
+}
+
+func InitWithDB(sqlDB *sqlx.DB) {
+	db = sqlDB
+}
+
+func (r *ErrorGroupingRule) toModel() (*model.ErrorGroupingRule, error) {
+	var rule model.ErrorGroupingRule
+	if err := json.Unmarshal([]byte(r.Data), &rule); err != nil {
+		return nil, fmt.Errorf("error in unmarshalling error grouping rule: %s", err.Error())
+	}
+	rule.ID = r.ID
+	rule.Name = r.Name
+	rule.CreatedAt = r.CreatedAt
+	rule.CreatedBy = r.CreatedBy
+	rule.UpdatedAt = r.UpdatedAt
+	rule.UpdatedBy = r.UpdatedBy
+	return &rule, nil
+}
+
+func (s *ErrorGroupStatus) toModel() *model.ErrorGroupStatus {
+	status := &model.ErrorGroupStatus{
+		Fingerprint:       s.Fingerprint,
+		State:             model.ErrorGroupState(s.State),
+		ResolvedInRelease: s.ResolvedInRelease,
+		UpdatedAt:         s.UpdatedAt,
+		UpdatedBy:         s.UpdatedBy,
+	}
+	if s.GroupIDs != "" {
+		status.GroupIDs = strings.Split(s.GroupIDs, ",")
+	}
+	return status
+}
+
+// GetRules returns the error grouping rules in the order they are applied
+func GetRules() ([]*model.ErrorGroupingRule, error) {
+	var rules []ErrorGroupingRule
+	err := db.Select(&rules, "SELECT * FROM error_grouping_rules ORDER BY created_at, id")
+	if err != nil {
+		return nil, fmt.Errorf("error in getting error grouping rules: %s", err.Error())
+	}
+
+	groupingRules := make([]*model.ErrorGroupingRule, 0, len(rules))
+	for i := range rules {
+		rule, err := rules[i].toModel()
+		if err != nil {
+			return nil, err
+		}
+		groupingRules = append(groupingRules, rule)
+	}
+	return groupingRules, nil
+}
+
+func GetRule(id string) (*model.ErrorGroupingRule, error) {
+	var rule ErrorGroupingRule
+	err := db.Get(&rule, "SELECT * FROM error_grouping_rules WHERE id = ?", id)
+	if err != nil {
+		return nil, fmt.Errorf("error in getting error grouping rule: %s", err.Error())
+	}
+	return rule.toModel()
+}
+
+func CreateRule(ctx context.Context, rule model.ErrorGroupingRule) (string, error) {
+	data, err := json.Marshal(rule)
+	if err != nil {
+		return "", fmt.Errorf("error in marshalling error grouping rule: %s", err.Error())
+	}
+
+	email, err := auth.GetEmailFromJwt(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	id := uuid.New().String()
+	now := time.Now()
+	_, err = db.Exec(
+		"INSERT INTO error_grouping_rules (id, name, data, created_at, created_by, updated_at, updated_by) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		id,
+		rule.Name,
+		data,
+		now,
+		email,
+		now,
+		email,
+	)
+	if err != nil {
+		return "", fmt.Errorf("error in creating error grouping rule: %s", err.Error())
+	}
+	return id, nil
+}
+
+// UpdateRule updates the definition of the rule, the rule keeps its place in
+// the order the rules are applied
+func UpdateRule(ctx context.Context, id string, rule model.ErrorGroupingRule) error {
+	data, err := json.Marshal(rule)
+	if err != nil {
+		return fmt.Errorf("error in marshalling error grouping rule: %s", err.Error())
+	}
+
+	email, err := auth.GetEmailFromJwt(ctx)
+	if err != nil {
+		return err
+	}
+
+	res, err := db.Exec("UPDATE error_grouping_rules SET updated_at = ?, updated_by = ?, name = ?, data = ? WHERE id = ?",
+		time.Now(), email, rule.Name, data, id)
+	if err != nil {
+		return fmt.Errorf("error in updating error grouping rule: %s", err.Error())
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("error grouping rule %s not found", id)
+	}
+	return nil
+}
+
+func DeleteRule(id string) error {
+	_, err := db.Exec("DELETE FROM error_grouping_rules WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("error in deleting error grouping rule: %s", err.Error())
+	}
+	return nil
+}
+
+// GetStatus returns the state of the error group, the groups with no state
+// set are unresolved
+func GetStatus(fingerprint string) (*model.ErrorGroupStatus, error) {
+	var status ErrorGroupStatus
+	err := db.Get(&status, "SELECT * FROM error_group_states WHERE fingerprint = ?", fingerprint)
+	if err == sql.ErrNoRows {
+		return &model.ErrorGroupStatus{Fingerprint: fingerprint, State: model.ErrorGroupUnresolved}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error in getting error group state: %s", err.Error())
+	}
+	return status.toModel(), nil
+}
+
+func getStatuses() (map[string]*model.ErrorGroupStatus, error) {
+	var statuses []ErrorGroupStatus
+	err := db.Select(&statuses, "SELECT * FROM error_group_states")
+	if err != nil {
+		return nil, fmt.Errorf("error in getting error group states: %s", err.Error())
+	}
+
+	byFingerprint := make(map[string]*model.ErrorGroupStatus, len(statuses))
+	for i := range statuses {
+		byFingerprint[statuses[i].Fingerprint] = statuses[i].toModel()
+	}
+	return byFingerprint, nil
+}
+
+// SetStatus sets the state of the error group
+func SetStatus(ctx context.Context, status model.ErrorGroupStatus) (*model.ErrorGroupStatus, error) {
+	email, err := auth.GetEmailFromJwt(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status.UpdatedAt = time.Now()
+	status.UpdatedBy = email
+	_, err = db.Exec(`INSERT INTO error_group_states (fingerprint, state, resolved_in_release, group_ids, updated_at, updated_by) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(fingerprint) DO UPDATE SET state = excluded.state, resolved_in_release = excluded.resolved_in_release, group_ids = excluded.group_ids, updated_at = excluded.updated_at, updated_by = excluded.updated_by`,
+		status.Fingerprint,
+		status.State,
+		status.ResolvedInRelease,
+		strings.Join(status.GroupIDs, ","),
+		status.UpdatedAt,
+		status.UpdatedBy,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error in setting error group state: %s", err.Error())
+	}
+	return &status, nil
+}
@@ -0,0 +1,93 @@
+package errorgroups
+
+import (
+	"context"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.uber.org/zap"
+)
+
+// maxErrorGroupRows is the most rows of the ingested groups read to make the
+// error groups, the most recently seen. The rows are grouped, filtered,
+// sorted and paged in memory since the grouping rules are not run in
+// clickhouse.
+const maxErrorGroupRows = 10000
+
+// Reader reads the errors of the ingested groups
+type Reader interface {
+	GetErrorGroupRows(ctx context.Context, params *model.ListErrorsParams, frames int, limit int) ([]model.ErrorGroupRow, *model.ApiError)
+}
+
+// groupErrors returns the error groups made by the grouping rules with their
+// states, filtered by the states of the params
+func groupErrors(ctx context.Context, reader Reader, params *model.ListErrorsParams) ([]*group, *model.ApiError) {
+	rules, err := GetRules()
+	if err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+	grouper, err := NewGrouper(rules)
+	if err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+	statuses, err := getStatuses()
+	if err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+
+	rows, apiErr := reader.GetErrorGroupRows(ctx, params, grouper.Frames(), maxErrorGroupRows)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if len(rows) == maxErrorGroupRows {
+		zap.S().Warnf("the error groups are made of the %d most recently seen ingested groups only", maxErrorGroupRows)
+	}
+
+	groups := grouper.Group(rows)
+	applyStates(groups, statuses)
+	return filterStates(groups, params.States), nil
+}
+
+// ListErrors returns a page of the error groups made by the grouping rules
+func ListErrors(ctx context.Context, reader Reader, params *model.ListErrorsParams) (*[]model.Error, *model.ApiError) {
+	groups, apiErr := groupErrors(ctx, reader, params)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	sortGroups(groups, params.OrderParam, params.Order)
+
+	if params.Offset > 0 {
+		if params.Offset >= int64(len(groups)) {
+			groups = nil
+		} else {
+			groups = groups[params.Offset:]
+		}
+	}
+	if params.Limit > 0 && params.Limit < int64(len(groups)) {
+		groups = groups[:params.Limit]
+	}
+
+	errors := make([]model.Error, 0, len(groups))
+	for _, grp := range groups {
+		errors = append(errors, grp.Error)
+	}
+	return &errors, nil
+}
+
+// CountErrors returns the number of the error groups made by the grouping
+// rules
+func CountErrors(ctx context.Context, reader Reader, params *model.CountErrorsParams) (uint64, *model.ApiError) {
+	groups, apiErr := groupErrors(ctx, reader, &model.ListErrorsParams{
+		StartStr:      params.StartStr,
+		EndStr:        params.EndStr,
+		Start:         params.Start,
+		End:           params.End,
+		ServiceName:   params.ServiceName,
+		ExceptionType: params.ExceptionType,
+		Tags:          params.Tags,
+		States:        params.States,
+	})
+	if apiErr != nil {
+		return 0, apiErr
+	}
+	return uint64(len(groups)), nil
+}
@@ -0,0 +1,297 @@
+package errorgroups
+
+import (
+	"crypto/md5"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+// Grouper groups the errors of the ingested groups by the grouping rules
+type Grouper struct {
+	rules    []*model.ErrorGroupingRule
+	patterns map[string]*regexp.Regexp
+}
+
+func NewGrouper(rules []*model.ErrorGroupingRule) (*Grouper, error) {
+	g := &Grouper{rules: rules, patterns: make(map[string]*regexp.Regexp)}
+	for _, rule := range rules {
+		if rule.Type != model.ErrorGroupingRuleNormalize {
+			continue
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern of error grouping rule %s: %s", rule.Name, err.Error())
+		}
+		g.patterns[rule.ID] = re
+	}
+	return g, nil
+}
+
+// Frames returns the number of top frames of the stack traces the rules group
+// by
+func (g *Grouper) Frames() int {
+	frames := 0
+	for _, rule := range g.rules {
+		if rule.Type == model.ErrorGroupingRuleFingerprint && rule.Frames > frames {
+			frames = rule.Frames
+		}
+	}
+	return frames
+}
+
+func matches(rule *model.ErrorGroupingRule, row *model.ErrorGroupRow) bool {
+	if rule.ServiceName != "" && rule.ServiceName != row.ServiceName {
+		return false
+	}
+	if rule.ExceptionType != "" && rule.ExceptionType != row.ExceptionType {
+		return false
+	}
+	if len(rule.GroupIDs) == 0 {
+		return true
+	}
+	for _, groupID := range rule.GroupIDs {
+		if groupID == row.GroupID {
+			return true
+		}
+	}
+	return false
+}
+
+// message returns the message of the error normalized by the rules
+func (g *Grouper) message(row *model.ErrorGroupRow) string {
+	message := row.ExceptionMsg
+	for _, rule := range g.rules {
+		if re, ok := g.patterns[rule.ID]; ok && matches(rule, row) {
+			message = re.ReplaceAllLiteralString(message, rule.Replacement)
+		}
+	}
+	return message
+}
+
+// key returns the key of the group of the error, the errors with the same key
+// are in the same group
+func (g *Grouper) key(row *model.ErrorGroupRow) string {
+	message := g.message(row)
+	for _, rule := range g.rules {
+		if rule.Type == model.ErrorGroupingRuleNormalize || !matches(rule, row) {
+			continue
+		}
+		if rule.Type == model.ErrorGroupingRuleMerge {
+			return strings.Join([]string{"merge", rule.ID}, "\x00")
+		}
+
+		parts := []string{"fingerprint", rule.ID}
+		// the groups split by the rule are not merged with each other
+		if len(rule.GroupIDs) > 0 {
+			parts = append(parts, row.GroupID)
+		}
+		for _, field := range rule.Fields {
+			switch field {
+			case model.ErrorFingerprintServiceName:
+				parts = append(parts, row.ServiceName)
+			case model.ErrorFingerprintExceptionType:
+				parts = append(parts, row.ExceptionType)
+			case model.ErrorFingerprintMessage:
+				parts = append(parts, message)
+			case model.ErrorFingerprintFrames:
+				frames := strings.Split(row.Frames, "\n")
+				if len(frames) > rule.Frames {
+					frames = frames[:rule.Frames]
+				}
+				parts = append(parts, strings.Join(frames, "\n"))
+			}
+		}
+		return strings.Join(parts, "\x00")
+	}
+	return strings.Join([]string{"default", row.ServiceName, row.ExceptionType, message}, "\x00")
+}
+
+// group is an error group made by the grouping rules
+type group struct {
+	model.Error
+	key         string
+	groupIDs    map[string]struct{}
+	lastRelease string
+}
+
+// Group groups the errors by the rules. A group is identified by the ingested
+// group it is made of when the rules neither merged nor split that group,
+// otherwise by the hash of its key.
+func (g *Grouper) Group(rows []model.ErrorGroupRow) []*group {
+	byKey := make(map[string]*group)
+	var groups []*group
+	keysOfGroupID := make(map[string]map[string]struct{})
+	for i := range rows {
+		row := &rows[i]
+		key := g.key(row)
+		if keysOfGroupID[row.GroupID] == nil {
+			keysOfGroupID[row.GroupID] = make(map[string]struct{})
+		}
+		keysOfGroupID[row.GroupID][key] = struct{}{}
+
+		grp, ok := byKey[key]
+		if !ok {
+			grp = &group{key: key, groupIDs: make(map[string]struct{})}
+			grp.FirstSeen = row.FirstSeen
+			byKey[key] = grp
+			groups = append(groups, grp)
+		}
+		grp.groupIDs[row.GroupID] = struct{}{}
+		grp.ExceptionCount += row.ExceptionCount
+		if row.FirstSeen.Before(grp.FirstSeen) {
+			grp.FirstSeen = row.FirstSeen
+		}
+		// the group shows its last error
+		if !row.LastSeen.Before(grp.LastSeen) {
+			grp.LastSeen = row.LastSeen
+			grp.GroupID = row.GroupID
+			grp.ServiceName = row.ServiceName
+			grp.ExceptionType = row.ExceptionType
+			grp.ExceptionMsg = row.ExceptionMsg
+			grp.lastRelease = row.LastRelease
+		}
+	}
+
+	for _, grp := range groups {
+		grp.GroupIDs = make([]string, 0, len(grp.groupIDs))
+		for groupID := range grp.groupIDs {
+			grp.GroupIDs = append(grp.GroupIDs, groupID)
+		}
+		sort.Strings(grp.GroupIDs)
+		if len(grp.GroupIDs) == 1 && len(keysOfGroupID[grp.GroupIDs[0]]) == 1 {
+			grp.Fingerprint = grp.GroupIDs[0]
+		} else {
+			grp.Fingerprint = fmt.Sprintf("%x", md5.Sum([]byte(grp.key)))
+		}
+	}
+	return groups
+}
+
+// releaseVersion returns the numbers of the version of the release, like
+// 1.2.0 or v1.2.0, the pre-release and build suffixes are ignored
+func releaseVersion(release string) ([]int, bool) {
+	release = strings.TrimPrefix(release, "v")
+	if i := strings.IndexAny(release, "-+"); i >= 0 {
+		release = release[:i]
+	}
+	if release == "" {
+		return nil, false
+	}
+	var version []int
+	for _, part := range strings.Split(release, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		version = append(version, n)
+	}
+	return version, true
+}
+
+// olderRelease tells if the release is known to be older than the other, the
+// releases that are not versions are not ordered
+func olderRelease(release, other string) bool {
+	version, ok := releaseVersion(release)
+	if !ok {
+		return false
+	}
+	otherVersion, ok := releaseVersion(other)
+	if !ok {
+		return false
+	}
+	for i := 0; i < len(version) || i < len(otherVersion); i++ {
+		var n, otherN int
+		if i < len(version) {
+			n = version[i]
+		}
+		if i < len(otherVersion) {
+			otherN = otherVersion[i]
+		}
+		if n != otherN {
+			return n < otherN
+		}
+	}
+	return false
+}
+
+// applyStates sets the states of the groups. A group with no state of its
+// fingerprint, which changes when the grouping rules are edited, takes the
+// latest state set on one of its ingested groups. The resolved groups seen
+// again regressed, the regression is derived when the groups are read and is
+// not stored. The errors seen after the group was resolved in a release older
+// than the fixing release are still expected and do not regress the group.
+func applyStates(groups []*group, statuses map[string]*model.ErrorGroupStatus) {
+	byGroupID := make(map[string]*model.ErrorGroupStatus)
+	for _, status := range statuses {
+		for _, groupID := range status.GroupIDs {
+			if latest, ok := byGroupID[groupID]; !ok || status.UpdatedAt.After(latest.UpdatedAt) {
+				byGroupID[groupID] = status
+			}
+		}
+	}
+
+	for _, grp := range groups {
+		status, ok := statuses[grp.Fingerprint]
+		if !ok {
+			for _, groupID := range grp.GroupIDs {
+				if s, found := byGroupID[groupID]; found && (status == nil || s.UpdatedAt.After(status.UpdatedAt)) {
+					status = s
+				}
+			}
+		}
+		if status == nil {
+			grp.State = model.ErrorGroupUnresolved
+			continue
+		}
+		grp.State = status.State
+		if status.State == model.ErrorGroupResolved && grp.LastSeen.After(status.UpdatedAt) &&
+			!olderRelease(grp.lastRelease, status.ResolvedInRelease) {
+			grp.State = model.ErrorGroupRegressed
+		}
+	}
+}
+
+func filterStates(groups []*group, states []model.ErrorGroupState) []*group {
+	if len(states) == 0 {
+		return groups
+	}
+	filtered := groups[:0]
+	for _, grp := range groups {
+		for _, state := range states {
+			if grp.State == state {
+				filtered = append(filtered, grp)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+func sortGroups(groups []*group, orderParam, order string) {
+	less := func(i, j int) bool {
+		switch orderParam {
+		case "exceptionType":
+			return groups[i].ExceptionType < groups[j].ExceptionType
+		case "exceptionCount":
+			return groups[i].ExceptionCount < groups[j].ExceptionCount
+		case "firstSeen":
+			return groups[i].FirstSeen.Before(groups[j].FirstSeen)
+		case "serviceName":
+			return groups[i].ServiceName < groups[j].ServiceName
+		default:
+			return groups[i].LastSeen.Before(groups[j].LastSeen)
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if order == constants.Ascending {
+			return less(i, j)
+		}
+		return less(j, i)
+	})
+}
@@ -0,0 +1,186 @@
+package errorgroups
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+func row(groupID, service, message, frames string, count uint64, lastSeen int64) model.ErrorGroupRow {
+	return model.ErrorGroupRow{
+		GroupID:        groupID,
+		ServiceName:    service,
+		ExceptionType:  "NotFoundError",
+		ExceptionMsg:   message,
+		Frames:         frames,
+		ExceptionCount: count,
+		FirstSeen:      time.Unix(lastSeen-100, 0),
+		LastSeen:       time.Unix(lastSeen, 0),
+	}
+}
+
+func TestGroupWithoutRules(t *testing.T) {
+	grouper, err := NewGrouper(nil)
+	require.NoError(t, err)
+	assert.Zero(t, grouper.Frames())
+
+	groups := grouper.Group([]model.ErrorGroupRow{
+		row("g1", "customer", "customer 1 not found", "", 3, 1000),
+		row("g2", "customer", "customer 2 not found", "", 2, 2000),
+	})
+	require.Len(t, groups, 2)
+	// the ingested groups are kept as they are
+	assert.Equal(t, "g1", groups[0].Fingerprint)
+	assert.Equal(t, []string{"g1"}, groups[0].GroupIDs)
+	assert.Equal(t, "g2", groups[1].Fingerprint)
+}
+
+func TestGroupNormalizedMessages(t *testing.T) {
+	grouper, err := NewGrouper([]*model.ErrorGroupingRule{
+		{ID: "ids", Type: model.ErrorGroupingRuleNormalize, ServiceName: "customer", Pattern: `\d+`, Replacement: "<id>"},
+	})
+	require.NoError(t, err)
+
+	groups := grouper.Group([]model.ErrorGroupRow{
+		row("g1", "customer", "customer 1 not found", "", 3, 1000),
+		row("g2", "customer", "customer 2 not found", "", 2, 2000),
+		row("g3", "driver", "driver 3 not found", "", 1, 1500),
+		row("g4", "driver", "driver 4 not found", "", 1, 1500),
+	})
+	require.Len(t, groups, 3)
+
+	customer := groups[0]
+	assert.Equal(t, []string{"g1", "g2"}, customer.GroupIDs)
+	assert.Len(t, customer.Fingerprint, 32)
+	assert.Equal(t, uint64(5), customer.ExceptionCount)
+	assert.Equal(t, time.Unix(900, 0), customer.FirstSeen)
+	assert.Equal(t, time.Unix(2000, 0), customer.LastSeen)
+	// the group shows its last error
+	assert.Equal(t, "g2", customer.GroupID)
+	assert.Equal(t, "customer 2 not found", customer.ExceptionMsg)
+
+	// the rule is limited to the customer service
+	assert.Equal(t, "g3", groups[1].Fingerprint)
+	assert.Equal(t, "g4", groups[2].Fingerprint)
+}
+
+func TestGroupFingerprintAndMergeRules(t *testing.T) {
+	grouper, err := NewGrouper([]*model.ErrorGroupingRule{
+		{ID: "merge", Type: model.ErrorGroupingRuleMerge, GroupIDs: []string{"g1", "g2"}},
+		{ID: "split", Type: model.ErrorGroupingRuleFingerprint, GroupIDs: []string{"g3"}, Fields: []model.ErrorFingerprintField{model.ErrorFingerprintFrames}, Frames: 1},
+		{ID: "frames", Type: model.ErrorGroupingRuleFingerprint, ServiceName: "route", Fields: []model.ErrorFingerprintField{model.ErrorFingerprintServiceName, model.ErrorFingerprintFrames}, Frames: 2},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, grouper.Frames())
+
+	groups := grouper.Group([]model.ErrorGroupRow{
+		row("g1", "customer", "customer not found", "", 1, 1000),
+		row("g2", "customer", "customer missing", "", 1, 1000),
+		row("g3", "driver", "driver not found", "at find\nat handle", 1, 1000),
+		row("g3", "driver", "driver not found", "at list\nat handle", 1, 1000),
+		row("g4", "route", "route not found", "at route\nat handle", 1, 1000),
+		row("g5", "route", "no route", "at route\nat handle", 1, 1000),
+		row("g6", "route", "no route", "at route\nat compute", 1, 1000),
+	})
+	require.Len(t, groups, 5)
+
+	assert.Equal(t, []string{"g1", "g2"}, groups[0].GroupIDs)
+	// the group is split by its top frame
+	assert.Equal(t, []string{"g3"}, groups[1].GroupIDs)
+	assert.Equal(t, []string{"g3"}, groups[2].GroupIDs)
+	assert.NotEqual(t, "g3", groups[1].Fingerprint)
+	assert.NotEqual(t, groups[1].Fingerprint, groups[2].Fingerprint)
+	// the groups with the same top frames are merged
+	assert.Equal(t, []string{"g4", "g5"}, groups[3].GroupIDs)
+	assert.Equal(t, "g6", groups[4].Fingerprint)
+}
+
+func TestApplyStates(t *testing.T) {
+	resolvedAt := time.Unix(1500, 0)
+	groups := []*group{
+		{Error: model.Error{Fingerprint: "new", LastSeen: time.Unix(2000, 0)}},
+		{Error: model.Error{Fingerprint: "ignored", LastSeen: time.Unix(2000, 0)}},
+		{Error: model.Error{Fingerprint: "resolved", LastSeen: time.Unix(1000, 0)}},
+		{Error: model.Error{Fingerprint: "seen again", LastSeen: time.Unix(2000, 0)}},
+		{Error: model.Error{Fingerprint: "old release", LastSeen: time.Unix(2000, 0)}, lastRelease: "1.1.0"},
+		{Error: model.Error{Fingerprint: "fixed release", LastSeen: time.Unix(2000, 0)}, lastRelease: "1.2.0"},
+		{Error: model.Error{Fingerprint: "later release", LastSeen: time.Unix(2000, 0)}, lastRelease: "v1.10.0"},
+		{Error: model.Error{Fingerprint: "unknown release", LastSeen: time.Unix(2000, 0)}},
+	}
+	applyStates(groups, map[string]*model.ErrorGroupStatus{
+		"ignored":         {State: model.ErrorGroupIgnored},
+		"resolved":        {State: model.ErrorGroupResolved, UpdatedAt: resolvedAt},
+		"seen again":      {State: model.ErrorGroupResolved, UpdatedAt: resolvedAt},
+		"old release":     {State: model.ErrorGroupResolved, ResolvedInRelease: "1.2.0", UpdatedAt: resolvedAt},
+		"fixed release":   {State: model.ErrorGroupResolved, ResolvedInRelease: "1.2.0", UpdatedAt: resolvedAt},
+		"later release":   {State: model.ErrorGroupResolved, ResolvedInRelease: "1.2.0", UpdatedAt: resolvedAt},
+		"unknown release": {State: model.ErrorGroupResolved, ResolvedInRelease: "1.2.0", UpdatedAt: resolvedAt},
+	})
+
+	var states []model.ErrorGroupState
+	for _, grp := range groups {
+		states = append(states, grp.State)
+	}
+	assert.Equal(t, []model.ErrorGroupState{
+		model.ErrorGroupUnresolved,
+		model.ErrorGroupIgnored,
+		model.ErrorGroupResolved,
+		model.ErrorGroupRegressed,
+		model.ErrorGroupResolved,
+		model.ErrorGroupRegressed,
+		model.ErrorGroupRegressed,
+		model.ErrorGroupRegressed,
+	}, states)
+
+	filtered := filterStates(groups, []model.ErrorGroupState{model.ErrorGroupUnresolved, model.ErrorGroupRegressed})
+	require.Len(t, filtered, 5)
+	assert.Equal(t, "new", filtered[0].Fingerprint)
+}
+
+func TestApplyStatesOfGroupIDs(t *testing.T) {
+	// the groups are merged by a rule made after the states were set
+	groups := []*group{
+		{Error: model.Error{Fingerprint: "merged", GroupIDs: []string{"g1", "g2"}, LastSeen: time.Unix(1000, 0)}},
+		{Error: model.Error{Fingerprint: "other", GroupIDs: []string{"g3"}, LastSeen: time.Unix(1000, 0)}},
+	}
+	applyStates(groups, map[string]*model.ErrorGroupStatus{
+		"g1":      {State: model.ErrorGroupIgnored, GroupIDs: []string{"g1"}, UpdatedAt: time.Unix(1500, 0)},
+		"g2":      {State: model.ErrorGroupResolved, GroupIDs: []string{"g2"}, UpdatedAt: time.Unix(1600, 0)},
+		"removed": {State: model.ErrorGroupIgnored, GroupIDs: []string{"g4"}, UpdatedAt: time.Unix(1500, 0)},
+	})
+
+	// the latest state of the ingested groups applies
+	assert.Equal(t, model.ErrorGroupResolved, groups[0].State)
+	assert.Equal(t, model.ErrorGroupUnresolved, groups[1].State)
+}
+
+func TestSortGroups(t *testing.T) {
+	groups := []*group{
+		{Error: model.Error{Fingerprint: "a", ExceptionCount: 1, LastSeen: time.Unix(3000, 0)}},
+		{Error: model.Error{Fingerprint: "b", ExceptionCount: 3, LastSeen: time.Unix(1000, 0)}},
+		{Error: model.Error{Fingerprint: "c", ExceptionCount: 2, LastSeen: time.Unix(2000, 0)}},
+	}
+
+	sortGroups(groups, "exceptionCount", "descending")
+	assert.Equal(t, "b", groups[0].Fingerprint)
+	assert.Equal(t, "c", groups[1].Fingerprint)
+
+	sortGroups(groups, "lastSeen", "ascending")
+	assert.Equal(t, "b", groups[0].Fingerprint)
+	assert.Equal(t, "a", groups[2].Fingerprint)
+}
+
+func TestOlderRelease(t *testing.T) {
+	assert.True(t, olderRelease("1.1.9", "1.2.0"))
+	assert.True(t, olderRelease("v1.9", "1.10.0"))
+	assert.True(t, olderRelease("1.2.0-rc.1", "1.3"))
+	assert.False(t, olderRelease("1.2.0", "1.2"))
+	assert.False(t, olderRelease("1.3.0", "1.2.0"))
+	// the releases that are not versions are not ordered
+	assert.False(t, olderRelease("abc123", "1.2.0"))
+	assert.False(t, olderRelease("", "1.2.0"))
+	assert.False(t, olderRelease("1.1.0", ""))
+}
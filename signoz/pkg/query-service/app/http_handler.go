@@ -22,7 +22,10 @@ import (
 
 	"go.signoz.io/signoz/pkg/query-service/agentConf"
 	"go.signoz.io/signoz/pkg/query-service/app/dashboards"
+	"go.signoz.io/signoz/pkg/query-service/app/derivedmetrics"
+	"go.signoz.io/signoz/pkg/query-service/app/errorgroups"
 	"go.signoz.io/signoz/pkg/query-service/app/explorer"
+	"go.signoz.io/signoz/pkg/query-service/app/livetail"
 	"go.signoz.io/signoz/pkg/query-service/app/logs"
 	logsv3 "go.signoz.io/signoz/pkg/query-service/app/logs/v3"
 	"go.signoz.io/signoz/pkg/query-service/app/metrics"
@@ -30,11 +33,13 @@ import (
 	"go.signoz.io/signoz/pkg/query-service/app/parser"
 	"go.signoz.io/signoz/pkg/query-service/app/querier"
 	"go.signoz.io/signoz/pkg/query-service/app/queryBuilder"
+	"go.signoz.io/signoz/pkg/query-service/app/traces/spantree"
 	tracesV3 "go.signoz.io/signoz/pkg/query-service/app/traces/v3"
 	"go.signoz.io/signoz/pkg/query-service/auth"
 	"go.signoz.io/signoz/pkg/query-service/cache"
 	"go.signoz.io/signoz/pkg/query-service/constants"
 	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils"
 	querytemplate "go.signoz.io/signoz/pkg/query-service/utils/queryTemplate"
 
 	"go.uber.org/multierr"
@@ -79,6 +84,7 @@ type APIHandler struct {
 	ready             func(http.HandlerFunc) http.HandlerFunc
 	querier           interfaces.Querier
 	queryBuilder      *queryBuilder.QueryBuilder
+	liveTail          *livetail.Tail
 	preferDelta       bool
 	preferSpanMetrics bool
 
@@ -122,6 +128,9 @@ type APIHandlerOpts struct {
 	// cache
 	Cache cache.Cache
 
+	// TTL of the query cache entries
+	CacheTTL time.Duration
+
 	// Querier Influx Interval
 	FluxInterval time.Duration
 }
@@ -137,9 +146,11 @@ func NewAPIHandler(opts APIHandlerOpts) (*APIHandler, error) {
 	querierOpts := querier.QuerierOptions{
 		Reader:        opts.Reader,
 		Cache:         opts.Cache,
+		CacheTTL:      opts.CacheTTL,
 		KeyGenerator:  queryBuilder.NewKeyGenerator(),
 		FluxInterval:  opts.FluxInterval,
 		FeatureLookup: opts.FeatureFlags,
+		Limits:        querier.DefaultQueryLimits(),
 	}
 
 	querier := querier.NewQuerier(querierOpts)
@@ -167,6 +178,11 @@ func NewAPIHandler(opts APIHandlerOpts) (*APIHandler, error) {
 	}
 	aH.queryBuilder = queryBuilder.NewQueryBuilder(builderOpts, aH.featureFlags)
 
+	aH.liveTail = livetail.NewTail(opts.Reader, livetail.Options{
+		MaxLogsPerPoll:     constants.LiveTailMaxLogsPerPoll,
+		MaxEventsPerSecond: float64(constants.LiveTailMaxEventsPerSecond),
+	})
+
 	aH.ready = aH.testReady
 
 	dashboards.LoadDashboardFiles(aH.featureFlags)
@@ -253,7 +269,7 @@ func RespondError(w http.ResponseWriter, apiErr model.BaseApiError, data interfa
 	switch apiErr.Type() {
 	case model.ErrorBadData:
 		code = http.StatusBadRequest
-	case model.ErrorExec:
+	case model.ErrorExec, model.ErrorLimitExceeded:
 		code = 422
 	case model.ErrorCanceled, model.ErrorTimeout:
 		code = http.StatusServiceUnavailable
@@ -314,6 +330,11 @@ func (aH *APIHandler) RegisterQueryRangeV3Routes(router *mux.Router, am *AuthMid
 	subRouter.HandleFunc("/autocomplete/attribute_values", am.ViewAccess(
 		withCacheControl(AutoCompleteCacheControlAge, aH.autoCompleteAttributeValues))).Methods(http.MethodGet)
 	subRouter.HandleFunc("/query_range", am.ViewAccess(aH.QueryRangeV3)).Methods(http.MethodPost)
+	subRouter.HandleFunc("/query_range/export", am.ViewAccess(aH.exportQueryRange)).Methods(http.MethodPost)
+
+	// query cache management
+	subRouter.HandleFunc("/query_cache", am.AdminAccess(aH.listQueryCache)).Methods(http.MethodGet)
+	subRouter.HandleFunc("/query_cache", am.AdminAccess(aH.evictQueryCache)).Methods(http.MethodDelete)
 
 	// live logs
 	subRouter.HandleFunc("/logs/livetail", am.ViewAccess(aH.liveTailLogs)).Methods(http.MethodGet)
@@ -340,13 +361,25 @@ func (aH *APIHandler) RegisterRoutes(router *mux.Router, am *AuthMiddleware) {
 	router.HandleFunc("/api/v1/testChannel", am.EditAccess(aH.testChannel)).Methods(http.MethodPost)
 
 	router.HandleFunc("/api/v1/alerts", am.ViewAccess(aH.getAlerts)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/notifications/deliveries", am.ViewAccess(aH.getNotificationDeliveries)).Methods(http.MethodGet)
 
 	router.HandleFunc("/api/v1/rules", am.ViewAccess(aH.listRules)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/export", am.ViewAccess(aH.exportRules)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/import", am.EditAccess(aH.importRules)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/rules/backtest", am.EditAccess(aH.backtestRule)).Methods(http.MethodPost)
 	router.HandleFunc("/api/v1/rules/{id}", am.ViewAccess(aH.getRule)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/{id}/history", am.ViewAccess(aH.getRuleStateHistory)).Methods(http.MethodGet)
 	router.HandleFunc("/api/v1/rules", am.EditAccess(aH.createRule)).Methods(http.MethodPost)
 	router.HandleFunc("/api/v1/rules/{id}", am.EditAccess(aH.editRule)).Methods(http.MethodPut)
 	router.HandleFunc("/api/v1/rules/{id}", am.EditAccess(aH.deleteRule)).Methods(http.MethodDelete)
 	router.HandleFunc("/api/v1/rules/{id}", am.EditAccess(aH.patchRule)).Methods(http.MethodPatch)
+
+	router.HandleFunc("/api/v1/silences", am.ViewAccess(aH.listSilences)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/silences/suppressed", am.ViewAccess(aH.listSuppressedAlerts)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/silences/{id}", am.ViewAccess(aH.getSilence)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/silences", am.EditAccess(aH.createSilence)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/silences/{id}", am.EditAccess(aH.editSilence)).Methods(http.MethodPut)
+	router.HandleFunc("/api/v1/silences/{id}", am.EditAccess(aH.deleteSilence)).Methods(http.MethodDelete)
 	router.HandleFunc("/api/v1/testRule", am.EditAccess(aH.testRule)).Methods(http.MethodPost)
 
 	router.HandleFunc("/api/v1/dashboards", am.ViewAccess(aH.getDashboards)).Methods(http.MethodGet)
@@ -364,6 +397,12 @@ func (aH *APIHandler) RegisterRoutes(router *mux.Router, am *AuthMiddleware) {
 	router.HandleFunc("/api/v1/explorer/views/{viewId}", am.ViewAccess(aH.updateSavedView)).Methods(http.MethodPut)
 	router.HandleFunc("/api/v1/explorer/views/{viewId}", am.ViewAccess(aH.deleteSavedView)).Methods(http.MethodDelete)
 
+	router.HandleFunc("/api/v1/derived_metrics", am.ViewAccess(aH.getDerivedMetrics)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/derived_metrics", am.EditAccess(aH.createDerivedMetric)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/derived_metrics/{id}", am.ViewAccess(aH.getDerivedMetric)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/derived_metrics/{id}", am.EditAccess(aH.updateDerivedMetric)).Methods(http.MethodPut)
+	router.HandleFunc("/api/v1/derived_metrics/{id}", am.EditAccess(aH.deleteDerivedMetric)).Methods(http.MethodDelete)
+
 	router.HandleFunc("/api/v1/feedback", am.OpenAccess(aH.submitFeedback)).Methods(http.MethodPost)
 	// router.HandleFunc("/api/v1/get_percentiles", aH.getApplicationPercentiles).Methods(http.MethodGet)
 	router.HandleFunc("/api/v1/services", am.ViewAccess(aH.getServices)).Methods(http.MethodPost)
@@ -372,6 +411,7 @@ func (aH *APIHandler) RegisterRoutes(router *mux.Router, am *AuthMiddleware) {
 	router.HandleFunc("/api/v1/service/top_operations", am.ViewAccess(aH.getTopOperations)).Methods(http.MethodPost)
 	router.HandleFunc("/api/v1/service/top_level_operations", am.ViewAccess(aH.getServicesTopLevelOps)).Methods(http.MethodPost)
 	router.HandleFunc("/api/v1/traces/{traceId}", am.ViewAccess(aH.SearchTraces)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/traces/compare", am.ViewAccess(aH.compareTraces)).Methods(http.MethodPost)
 	router.HandleFunc("/api/v1/usage", am.ViewAccess(aH.getUsage)).Methods(http.MethodGet)
 	router.HandleFunc("/api/v1/dependency_graph", am.ViewAccess(aH.dependencyGraph)).Methods(http.MethodPost)
 	router.HandleFunc("/api/v1/settings/ttl", am.AdminAccess(aH.setTTL)).Methods(http.MethodPost)
@@ -399,6 +439,12 @@ func (aH *APIHandler) RegisterRoutes(router *mux.Router, am *AuthMiddleware) {
 	router.HandleFunc("/api/v1/errorFromErrorID", am.ViewAccess(aH.getErrorFromErrorID)).Methods(http.MethodGet)
 	router.HandleFunc("/api/v1/errorFromGroupID", am.ViewAccess(aH.getErrorFromGroupID)).Methods(http.MethodGet)
 	router.HandleFunc("/api/v1/nextPrevErrorIDs", am.ViewAccess(aH.getNextPrevErrorIDs)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/errorGroupState", am.EditAccess(aH.setErrorGroupState)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/errorGroupingRules", am.ViewAccess(aH.getErrorGroupingRules)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/errorGroupingRules", am.EditAccess(aH.createErrorGroupingRule)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/errorGroupingRules/{id}", am.ViewAccess(aH.getErrorGroupingRule)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/errorGroupingRules/{id}", am.EditAccess(aH.updateErrorGroupingRule)).Methods(http.MethodPut)
+	router.HandleFunc("/api/v1/errorGroupingRules/{id}", am.EditAccess(aH.deleteErrorGroupingRule)).Methods(http.MethodDelete)
 
 	router.HandleFunc("/api/v1/disks", am.ViewAccess(aH.getDisks)).Methods(http.MethodGet)
 
@@ -457,6 +503,115 @@ func (aH *APIHandler) getRule(w http.ResponseWriter, r *http.Request) {
 	aH.Respond(w, ruleResponse)
 }
 
+func parseRuleBundleFormat(r *http.Request) (string, error) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		return rules.BundleFormatYAML, nil
+	}
+	if format != rules.BundleFormatYAML && format != rules.BundleFormatJSON {
+		return "", fmt.Errorf("invalid format %s, must be one of yaml or json", format)
+	}
+	return format, nil
+}
+
+// exportRules writes all the rules as a yaml or json bundle
+func (aH *APIHandler) exportRules(w http.ResponseWriter, r *http.Request) {
+	format, err := parseRuleBundleFormat(r)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	bundle, err := aH.ruleManager.ExportRules(r.Context())
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	content, err := rules.EncodeRuleBundle(bundle, format)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+
+	contentType := "application/yaml"
+	if format == rules.BundleFormatJSON {
+		contentType = "application/json"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"rules.%s\"", format))
+	w.WriteHeader(http.StatusOK)
+	w.Write(content)
+}
+
+// importRules applies a yaml or json bundle of rules, with dryRun set
+// only the diff against the stored rules is returned
+func (aH *APIHandler) importRules(w http.ResponseWriter, r *http.Request) {
+	format, err := parseRuleBundleFormat(r)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+	prune, _ := strconv.ParseBool(r.URL.Query().Get("prune"))
+
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	bundle, err := rules.DecodeRuleBundle(body, format)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	result, err := aH.ruleManager.ImportRules(r.Context(), bundle, dryRun, prune)
+	if err != nil {
+		if apiErr, ok := err.(*model.ApiError); ok {
+			RespondError(w, apiErr, nil)
+			return
+		}
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, result)
+		return
+	}
+	aH.Respond(w, result)
+}
+
+func (aH *APIHandler) getRuleStateHistory(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	end := time.Now()
+	if r.URL.Query().Get("end") != "" {
+		endTime, err := parseTime("end", r)
+		if err != nil {
+			RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+			return
+		}
+		end = *endTime
+	}
+	start := end.Add(-24 * time.Hour)
+	if r.URL.Query().Get("start") != "" {
+		startTime, err := parseTime("start", r)
+		if err != nil {
+			RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+			return
+		}
+		start = *startTime
+	}
+	if start.After(end) {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("start time cannot be after end time")}, nil)
+		return
+	}
+
+	timeline, err := aH.ruleManager.GetRuleStateHistory(r.Context(), id, start, end)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	aH.Respond(w, timeline)
+}
+
 func (aH *APIHandler) metricAutocompleteMetricName(w http.ResponseWriter, r *http.Request) {
 	matchText := r.URL.Query().Get("match")
 	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
@@ -777,6 +932,73 @@ func (aH *APIHandler) listRules(w http.ResponseWriter, r *http.Request) {
 	aH.Respond(w, rules)
 }
 
+func (aH *APIHandler) listSilences(w http.ResponseWriter, r *http.Request) {
+	silences, err := aH.ruleManager.ListSilences(r.Context())
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	aH.Respond(w, silences)
+}
+
+func (aH *APIHandler) listSuppressedAlerts(w http.ResponseWriter, r *http.Request) {
+	aH.Respond(w, aH.ruleManager.SuppressedAlerts())
+}
+
+func (aH *APIHandler) getSilence(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	silence, err := aH.ruleManager.GetSilence(r.Context(), id)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	aH.Respond(w, silence)
+}
+
+func (aH *APIHandler) createSilence(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		zap.S().Errorf("Error in getting req body for create silence API\n", err)
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	silence, err := aH.ruleManager.CreateSilence(r.Context(), string(body))
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	aH.Respond(w, silence)
+}
+
+func (aH *APIHandler) editSilence(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		zap.S().Errorf("msg: error in getting req body of edit silence API\n", "\t error:", err)
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	if err := aH.ruleManager.EditSilence(r.Context(), string(body), id); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	aH.Respond(w, "silence successfully edited")
+}
+
+func (aH *APIHandler) deleteSilence(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if err := aH.ruleManager.DeleteSilence(r.Context(), id); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	aH.Respond(w, "silence successfully deleted")
+}
+
 func (aH *APIHandler) getDashboards(w http.ResponseWriter, r *http.Request) {
 
 	allDashboards, err := dashboards.GetDashboards(r.Context())
@@ -1048,6 +1270,40 @@ func (aH *APIHandler) testRule(w http.ResponseWriter, r *http.Request) {
 	aH.Respond(w, response)
 }
 
+// backtestRule replays the rule in the request body over the start and end
+// time range without sending notifications
+func (aH *APIHandler) backtestRule(w http.ResponseWriter, r *http.Request) {
+
+	start, err := parseTime("start", r)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	end, err := parseTime("end", r)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		zap.S().Errorf("Error in getting req body in backtest rule API\n", err)
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+	defer cancel()
+
+	backtest, apiErr := aH.ruleManager.BacktestRule(ctx, string(body), *start, *end)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, backtest)
+}
+
 func (aH *APIHandler) deleteRule(w http.ResponseWriter, r *http.Request) {
 
 	id := mux.Vars(r)["id"]
@@ -1221,6 +1477,11 @@ func (aH *APIHandler) createChannel(w http.ResponseWriter, r *http.Request) {
 }
 
 func (aH *APIHandler) getAlerts(w http.ResponseWriter, r *http.Request) {
+	if dispatcher, ok := aH.alertManager.(*am.Dispatcher); ok {
+		aH.Respond(w, dispatcher.Alerts())
+		return
+	}
+
 	params := r.URL.Query()
 	amEndpoint := constants.GetAlertManagerApiPrefix()
 	resp, err := http.Get(amEndpoint + "v1/alerts" + "?" + params.Encode())
@@ -1239,6 +1500,16 @@ func (aH *APIHandler) getAlerts(w http.ResponseWriter, r *http.Request) {
 	aH.Respond(w, string(body))
 }
 
+// getNotificationDeliveries returns the delivery log of the embedded alert manager
+func (aH *APIHandler) getNotificationDeliveries(w http.ResponseWriter, r *http.Request) {
+	dispatcher, ok := aH.alertManager.(*am.Dispatcher)
+	if !ok {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("delivery log is only available with the embedded alert manager")}, nil)
+		return
+	}
+	aH.Respond(w, dispatcher.DeliveryLog())
+}
+
 func (aH *APIHandler) createRule(w http.ResponseWriter, r *http.Request) {
 
 	defer r.Body.Close()
@@ -1533,13 +1804,38 @@ func (aH *APIHandler) SearchTraces(w http.ResponseWriter, r *http.Request) {
 
 }
 
+func (aH *APIHandler) compareTraces(w http.ResponseWriter, r *http.Request) {
+
+	params, err := parseCompareTracesRequest(r)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	traces := make([][]model.SearchSpanResponseItem, 0, 2)
+	for _, traceID := range []string{params.BaseTraceID, params.ComparisonTraceID} {
+		spans, err := aH.reader.GetTraceSpans(r.Context(), traceID)
+		if err != nil {
+			RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+			return
+		}
+		if len(spans) == 0 {
+			RespondError(w, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("trace %s not found", traceID)}, nil)
+			return
+		}
+		traces = append(traces, spans)
+	}
+
+	aH.WriteJSON(w, r, spantree.Compare(params.BaseTraceID, traces[0], params.ComparisonTraceID, traces[1]))
+}
+
 func (aH *APIHandler) listErrors(w http.ResponseWriter, r *http.Request) {
 
 	query, err := parseListErrorsRequest(r)
 	if aH.HandleError(w, err, http.StatusBadRequest) {
 		return
 	}
-	result, apiErr := aH.reader.ListErrors(r.Context(), query)
+	result, apiErr := errorgroups.ListErrors(r.Context(), aH.reader, query)
 	if apiErr != nil && aH.HandleError(w, apiErr.Err, http.StatusInternalServerError) {
 		return
 	}
@@ -1553,7 +1849,7 @@ func (aH *APIHandler) countErrors(w http.ResponseWriter, r *http.Request) {
 	if aH.HandleError(w, err, http.StatusBadRequest) {
 		return
 	}
-	result, apiErr := aH.reader.CountErrors(r.Context(), query)
+	result, apiErr := errorgroups.CountErrors(r.Context(), aH.reader, query)
 	if apiErr != nil {
 		RespondError(w, apiErr, nil)
 		return
@@ -1604,9 +1900,114 @@ func (aH *APIHandler) getErrorFromGroupID(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	fingerprint := query.Fingerprint
+	if fingerprint == "" {
+		fingerprint = query.GroupID
+	}
+	status, err := errorgroups.GetStatus(fingerprint)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	result.State = status.State
+
 	aH.WriteJSON(w, r, result)
 }
 
+func (aH *APIHandler) setErrorGroupState(w http.ResponseWriter, r *http.Request) {
+	var status model.ErrorGroupStatus
+	err := json.NewDecoder(r.Body).Decode(&status)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if err := status.Validate(); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	result, err := errorgroups.SetStatus(r.Context(), status)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+
+	aH.Respond(w, result)
+}
+
+func (aH *APIHandler) getErrorGroupingRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := errorgroups.GetRules()
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	aH.Respond(w, rules)
+}
+
+func (aH *APIHandler) createErrorGroupingRule(w http.ResponseWriter, r *http.Request) {
+	var rule model.ErrorGroupingRule
+	err := json.NewDecoder(r.Body).Decode(&rule)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if err := rule.Validate(); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	id, err := errorgroups.CreateRule(r.Context(), rule)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+
+	aH.Respond(w, id)
+}
+
+func (aH *APIHandler) getErrorGroupingRule(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	rule, err := errorgroups.GetRule(id)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorNotFound, Err: err}, nil)
+		return
+	}
+
+	aH.Respond(w, rule)
+}
+
+func (aH *APIHandler) updateErrorGroupingRule(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	var rule model.ErrorGroupingRule
+	err := json.NewDecoder(r.Body).Decode(&rule)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if err := rule.Validate(); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	err = errorgroups.UpdateRule(r.Context(), id, rule)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+
+	aH.Respond(w, rule)
+}
+
+func (aH *APIHandler) deleteErrorGroupingRule(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	err := errorgroups.DeleteRule(id)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+
+	aH.Respond(w, nil)
+}
+
 func (aH *APIHandler) getSpanFilters(w http.ResponseWriter, r *http.Request) {
 
 	query, err := parseSpanFilterRequestBody(r)
@@ -2586,6 +2987,31 @@ func (ah *APIHandler) CreateLogsPipeline(w http.ResponseWriter, r *http.Request)
 	ah.Respond(w, res)
 }
 
+func parseQueryCacheFilter(r *http.Request) v3.QueryCacheFilter {
+	return v3.QueryCacheFilter{
+		QueryHash:   r.URL.Query().Get("queryHash"),
+		QueryName:   r.URL.Query().Get("queryName"),
+		DashboardID: r.URL.Query().Get("dashboardId"),
+	}
+}
+
+func (aH *APIHandler) listQueryCache(w http.ResponseWriter, r *http.Request) {
+	filter := parseQueryCacheFilter(r)
+	aH.Respond(w, aH.querier.ListCachedQueries(filter))
+}
+
+// evictQueryCache removes the cache entries matching the queryHash, queryName and dashboardId
+// query params, at least one of them is required to avoid dropping the whole cache by mistake
+func (aH *APIHandler) evictQueryCache(w http.ResponseWriter, r *http.Request) {
+	filter := parseQueryCacheFilter(r)
+	if filter.IsEmpty() && r.URL.Query().Get("all") != "true" {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("one of queryHash, queryName, dashboardId or all=true is required")}, nil)
+		return
+	}
+	evicted := aH.querier.EvictCachedQueries(filter)
+	aH.Respond(w, map[string]int{"evicted": evicted})
+}
+
 func (aH *APIHandler) getSavedViews(w http.ResponseWriter, r *http.Request) {
 	// get sourcePage, name, and category from the query params
 	sourcePage := r.URL.Query().Get("sourcePage")
@@ -2667,6 +3093,94 @@ func (aH *APIHandler) deleteSavedView(w http.ResponseWriter, r *http.Request) {
 	aH.Respond(w, nil)
 }
 
+func (aH *APIHandler) getDerivedMetrics(w http.ResponseWriter, r *http.Request) {
+	metrics, err := derivedmetrics.GetDerivedMetrics()
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	aH.Respond(w, metrics)
+}
+
+func (aH *APIHandler) createDerivedMetric(w http.ResponseWriter, r *http.Request) {
+	var metric v3.DerivedMetric
+	err := json.NewDecoder(r.Body).Decode(&metric)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	metricExists := func(name string) (bool, error) {
+		return aH.reader.MetricNameExists(r.Context(), name)
+	}
+	if err := metric.Validate(metricExists); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	id, err := derivedmetrics.CreateDerivedMetric(r.Context(), metric)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+
+	aH.Respond(w, id)
+}
+
+func (aH *APIHandler) getDerivedMetric(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	metric, err := derivedmetrics.GetDerivedMetric(id)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorNotFound, Err: err}, nil)
+		return
+	}
+
+	aH.Respond(w, metric)
+}
+
+func (aH *APIHandler) updateDerivedMetric(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	var metric v3.DerivedMetric
+	err := json.NewDecoder(r.Body).Decode(&metric)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	stored, err := derivedmetrics.GetDerivedMetric(id)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorNotFound, Err: err}, nil)
+		return
+	}
+	// the samples of the metric itself are written under its current name
+	metricExists := func(name string) (bool, error) {
+		if name == stored.Name {
+			return false, nil
+		}
+		return aH.reader.MetricNameExists(r.Context(), name)
+	}
+	if err := metric.Validate(metricExists); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	err = derivedmetrics.UpdateDerivedMetric(r.Context(), id, metric)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+
+	aH.Respond(w, metric)
+}
+
+func (aH *APIHandler) deleteDerivedMetric(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	err := derivedmetrics.DeleteDerivedMetric(id)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+
+	aH.Respond(w, nil)
+}
+
 func (aH *APIHandler) autocompleteAggregateAttributes(w http.ResponseWriter, r *http.Request) {
 	var response *v3.AggregateAttributeResponse
 	req, err := parseAggregateAttributeRequest(r)
@@ -3029,6 +3543,16 @@ func (aH *APIHandler) queryRangeV3(ctx context.Context, queryRangeParams *v3.Que
 		}
 	}
 
+	estimates, apiErr := aH.querier.EstimateQueryRange(ctx, queryRangeParams, spanKeys)
+	if apiErr != nil {
+		RespondError(w, apiErr, estimates)
+		return
+	}
+	if queryRangeParams.DryRun {
+		aH.Respond(w, v3.QueryRangeDryRunResponse{Queries: estimates})
+		return
+	}
+
 	result, err, errQuriesByName = aH.querier.QueryRange(ctx, queryRangeParams, spanKeys)
 
 	if err != nil {
@@ -3163,7 +3687,7 @@ func (aH *APIHandler) liveTailLogs(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var err error
-	var queryString string
+	queries := map[string]*v3.FilterSet{}
 	switch queryRangeParams.CompositeQuery.QueryType {
 	case v3.QueryTypeBuilder:
 		// check if any enrichment is required for logs if yes then enrich them
@@ -3179,10 +3703,14 @@ func (aH *APIHandler) liveTailLogs(w http.ResponseWriter, r *http.Request) {
 			logsv3.Enrich(queryRangeParams, fields)
 		}
 
-		queryString, err = aH.queryBuilder.PrepareLiveTailQuery(queryRangeParams)
-		if err != nil {
-			RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
-			return
+		// every query of the connection is a filter of the logs sent
+		for queryName, query := range queryRangeParams.CompositeQuery.BuilderQueries {
+			if query.Expression != queryName || query.DataSource != v3.DataSourceLogs || query.AggregateOperator != v3.AggregateOperatorNoOp {
+				err = fmt.Errorf("unsupported query %s in live tail", queryName)
+				RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+				return
+			}
+			queries[queryName] = query.Filters
 		}
 
 	default:
@@ -3191,15 +3719,15 @@ func (aH *APIHandler) liveTailLogs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// create the client
-	client := &v3.LogsLiveTailClient{Name: r.RemoteAddr, Logs: make(chan *model.SignozLog, 1000), Done: make(chan *bool), Error: make(chan error)}
-	go aH.reader.LiveTailLogsV3(r.Context(), queryString, uint64(queryRangeParams.Start), "", client)
-
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.WriteHeader(200)
+	var maxEventsPerSecond float64
+	if maxEventsPerSecondStr := r.URL.Query().Get("maxEventsPerSecond"); maxEventsPerSecondStr != "" {
+		maxEventsPerSecond, err = strconv.ParseFloat(maxEventsPerSecondStr, 64)
+		if err != nil || maxEventsPerSecond < 0 {
+			err = fmt.Errorf("invalid maxEventsPerSecond: %s", maxEventsPerSecondStr)
+			RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+			return
+		}
+	}
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
@@ -3207,20 +3735,50 @@ func (aH *APIHandler) liveTailLogs(w http.ResponseWriter, r *http.Request) {
 		RespondError(w, &err, "streaming is not supported")
 		return
 	}
+
+	// the logs are sent from the start of the query, now when not set
+	var start uint64
+	if queryRangeParams.Start > 0 {
+		start = uint64(utils.GetEpochNanoSecs(queryRangeParams.Start))
+	}
+
+	// subscribe to the live tail shared by all the connections
+	subscriber, err := aH.liveTail.Subscribe(r.RemoteAddr, queries, maxEventsPerSecond, start)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	defer aH.liveTail.Unsubscribe(subscriber)
+
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(200)
+
 	// flush the headers
 	flusher.Flush()
+
+	// the dropped logs are reported every second
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
 	for {
 		select {
-		case log := <-client.Logs:
+		case log := <-subscriber.Logs:
 			var buf bytes.Buffer
 			enc := json.NewEncoder(&buf)
 			enc.Encode(log)
 			fmt.Fprintf(w, "data: %v\n\n", buf.String())
 			flusher.Flush()
-		case <-client.Done:
-			zap.S().Debug("done!")
+		case <-ticker.C:
+			if dropped := subscriber.Dropped(); dropped > 0 {
+				fmt.Fprintf(w, "event: dropped\ndata: {\"dropped\": %d}\n\n", dropped)
+				flusher.Flush()
+			}
+		case <-r.Context().Done():
+			zap.S().Debug("closing live tail: " + subscriber.Name)
 			return
-		case err := <-client.Error:
+		case err := <-subscriber.Error:
 			zap.S().Error("error occured!", err)
 			fmt.Fprintf(w, "event: error\ndata: %v\n\n", err.Error())
 			flusher.Flush()
@@ -3228,3 +3786,103 @@ func (aH *APIHandler) liveTailLogs(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+// exportQueryRange streams the rows of a logs or traces list query as
+// newline delimited json or csv
+func (aH *APIHandler) exportQueryRange(w http.ResponseWriter, r *http.Request) {
+	format := v3.ExportFormat(r.URL.Query().Get("format"))
+	if format == "" {
+		format = v3.ExportFormatNDJSON
+	}
+	if err := format.Validate(); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	queryRangeParams, apiErrorObj := ParseQueryRangeParams(r)
+	if apiErrorObj != nil {
+		zap.S().Errorf(apiErrorObj.Err.Error())
+		RespondError(w, apiErrorObj, nil)
+		return
+	}
+	if queryRangeParams.CompositeQuery.QueryType != v3.QueryTypeBuilder {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("only builder queries can be exported")}, nil)
+		return
+	}
+
+	if logsv3.EnrichmentRequired(queryRangeParams) {
+		fields, err := aH.getLogFieldsV3(r.Context(), queryRangeParams)
+		if err != nil {
+			RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+			return
+		}
+		logsv3.Enrich(queryRangeParams, fields)
+	}
+	spanKeys, err := aH.getSpanKeysV3(r.Context(), queryRangeParams)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		err := model.ApiError{Typ: model.ErrorStreamingNotSupported, Err: nil}
+		RespondError(w, &err, "streaming is not supported")
+		return
+	}
+
+	var columns []v3.AttributeKey
+	for _, query := range queryRangeParams.CompositeQuery.BuilderQueries {
+		if !query.Disabled {
+			columns = query.SelectColumns
+		}
+	}
+	writer := querier.NewExportWriter(w, format, columns)
+
+	// the headers are written with the first row so that the errors of the
+	// first query can still be responded
+	started := false
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		contentType := "application/x-ndjson"
+		if format == v3.ExportFormatCSV {
+			contentType = "text/csv"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"export.%s\"", format))
+		w.WriteHeader(http.StatusOK)
+	}
+
+	err = aH.querier.ExportList(r.Context(), queryRangeParams, spanKeys, func(row *v3.Row) error {
+		start()
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+		if writer.Rows()%1000 == 0 {
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		if !started {
+			RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+			return
+		}
+		// the response is cut short, the client sees an incomplete download
+		zap.S().Errorf("error while exporting query range: %v", err)
+		return
+	}
+
+	start()
+	if err := writer.Flush(); err != nil {
+		zap.S().Errorf("error while exporting query range: %v", err)
+		return
+	}
+	flusher.Flush()
+}
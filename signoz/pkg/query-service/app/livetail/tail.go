@@ -0,0 +1,366 @@
+package livetail
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	logsV3 "go.signoz.io/signoz/pkg/query-service/app/logs/v3"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultRefresh        = 5 * time.Second
+	defaultMaxLogsPerPoll = 1000
+	defaultBufferSize     = 1000
+	pollTimeout           = time.Minute
+)
+
+// Reader reads the logs of a live tail query following a position, the oldest
+// first, and the last logs up to a position, the most recent first
+type Reader interface {
+	TailLogsV3(ctx context.Context, query string, timestampStart uint64, idStart string, limit int) ([]model.SignozLog, error)
+	LastLogsV3(ctx context.Context, query string, timestampEnd uint64, idEnd string, limit int) ([]model.SignozLog, error)
+}
+
+type Options struct {
+	// Refresh is how often the new logs are read
+	Refresh time.Duration
+	// MaxLogsPerPoll is the most logs read at every refresh, the logs coming
+	// faster are read at the following refreshes
+	MaxLogsPerPoll int
+	// MaxEventsPerSecond is the most logs sent to a subscriber every second,
+	// zero is no limit
+	MaxEventsPerSecond float64
+	// BufferSize is the number of logs buffered for a subscriber
+	BufferSize int
+}
+
+// Log is a log sent to a subscriber with the names of its queries the log
+// matches
+type Log struct {
+	*model.SignozLog
+	QueryNames []string `json:"queryNames,omitempty"`
+}
+
+type subscriberQuery struct {
+	name    string
+	filters *v3.FilterSet
+	matcher *logsV3.LogMatcher
+}
+
+// Subscriber receives the new logs matching any of its queries
+type Subscriber struct {
+	Name string
+	Logs chan *Log
+	// Error receives the error ending the subscription
+	Error chan error
+
+	queries []subscriberQuery
+	// start is the timestamp in nanoseconds of the logs the subscriber is
+	// sent first, the last logs received by the tail before the subscriber,
+	// up to the size of its buffer, are read once for it
+	start    uint64
+	caughtUp bool
+	// the logs sent are limited by a token bucket refilled at rate
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	dropped uint64
+}
+
+// Dropped returns the number of logs dropped since the last call, the
+// matching logs are dropped when they come faster than the subscriber
+// can receive them
+func (s *Subscriber) Dropped() uint64 {
+	return atomic.SwapUint64(&s.dropped, 0)
+}
+
+// sample returns n of the logs evenly spread over them
+func sample(logs []*Log, n int) []*Log {
+	if n >= len(logs) {
+		return logs
+	}
+	if n <= 0 {
+		return nil
+	}
+	sampled := make([]*Log, 0, n)
+	for i := 0; i < n; i++ {
+		sampled = append(sampled, logs[i*len(logs)/n])
+	}
+	return sampled
+}
+
+// dispatch sends the logs matching the queries of the subscriber, the logs
+// are sampled when there are more than the subscriber can receive
+func (s *Subscriber) dispatch(logs []*model.SignozLog, now time.Time) {
+	var matched []*Log
+	for _, log := range logs {
+		var names []string
+		for _, query := range s.queries {
+			if query.matcher.Match(log) {
+				names = append(names, query.name)
+			}
+		}
+		if len(names) > 0 {
+			matched = append(matched, &Log{SignozLog: log, QueryNames: names})
+		}
+	}
+
+	budget := len(matched)
+	if s.rate > 0 {
+		s.tokens = math.Min(s.burst, s.tokens+now.Sub(s.last).Seconds()*s.rate)
+		s.last = now
+		if float64(budget) > s.tokens {
+			budget = int(s.tokens)
+		}
+	}
+	if free := cap(s.Logs) - len(s.Logs); budget > free {
+		budget = free
+	}
+
+	sampled := sample(matched, budget)
+	dropped := len(matched) - len(sampled)
+	for _, log := range sampled {
+		select {
+		case s.Logs <- log:
+			s.tokens--
+		default:
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		atomic.AddUint64(&s.dropped, uint64(dropped))
+	}
+}
+
+// Tail reads the new logs once for all its subscribers and sends them the
+// logs matching their queries
+type Tail struct {
+	reader Reader
+	opts   Options
+
+	mu          sync.Mutex
+	subscribers map[*Subscriber]struct{}
+	running     bool
+}
+
+func NewTail(reader Reader, opts Options) *Tail {
+	if opts.Refresh == 0 {
+		opts.Refresh = defaultRefresh
+	}
+	if opts.MaxLogsPerPoll == 0 {
+		opts.MaxLogsPerPoll = defaultMaxLogsPerPoll
+	}
+	if opts.BufferSize == 0 {
+		opts.BufferSize = defaultBufferSize
+	}
+	return &Tail{
+		reader:      reader,
+		opts:        opts,
+		subscribers: make(map[*Subscriber]struct{}),
+	}
+}
+
+// Subscribe subscribes to the logs matching any of the filters by query name
+// since start, in nanoseconds, zero is now. At most maxEventsPerSecond logs
+// are sent every second, bounded by the limit of the tail, zero is the limit
+// of the tail.
+func (t *Tail) Subscribe(name string, queries map[string]*v3.FilterSet, maxEventsPerSecond float64, start uint64) (*Subscriber, error) {
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("live tail requires at least one query")
+	}
+
+	s := &Subscriber{
+		Name:     name,
+		Logs:     make(chan *Log, t.opts.BufferSize),
+		Error:    make(chan error, 1),
+		rate:     t.opts.MaxEventsPerSecond,
+		start:    start,
+		caughtUp: start == 0,
+	}
+	if maxEventsPerSecond > 0 && (s.rate == 0 || maxEventsPerSecond < s.rate) {
+		s.rate = maxEventsPerSecond
+	}
+	if s.rate > 0 {
+		// a refresh worth of logs can be sent at once
+		s.burst = s.rate * math.Max(1, t.opts.Refresh.Seconds())
+		s.tokens = s.burst
+		s.last = time.Now()
+	}
+
+	names := make([]string, 0, len(queries))
+	for queryName := range queries {
+		names = append(names, queryName)
+	}
+	sort.Strings(names)
+	for _, queryName := range names {
+		// the filters are checked here so that they cannot fail the shared query
+		if _, err := logsV3.BuildSharedLiveTailQuery([]*v3.FilterSet{queries[queryName]}); err != nil {
+			return nil, fmt.Errorf("invalid filters of query %s: %w", queryName, err)
+		}
+		matcher, err := logsV3.NewLogMatcher(queries[queryName])
+		if err != nil {
+			return nil, fmt.Errorf("invalid filters of query %s: %w", queryName, err)
+		}
+		s.queries = append(s.queries, subscriberQuery{name: queryName, filters: queries[queryName], matcher: matcher})
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.subscribers[s] = struct{}{}
+	if !t.running {
+		t.running = true
+		go t.run()
+	}
+	return s, nil
+}
+
+// Unsubscribe stops sending the logs to the subscriber
+func (t *Tail) Unsubscribe(s *Subscriber) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.subscribers, s)
+}
+
+// current returns the subscribers, the tail stops when there is none
+func (t *Tail) current() []*Subscriber {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.subscribers) == 0 {
+		t.running = false
+		return nil
+	}
+	subscribers := make([]*Subscriber, 0, len(t.subscribers))
+	for s := range t.subscribers {
+		subscribers = append(subscribers, s)
+	}
+	return subscribers
+}
+
+// position is the position of a log in the logs ordered by timestamp and id
+type position struct {
+	timestamp uint64
+	id        string
+}
+
+func filtersOf(subscribers ...*Subscriber) []*v3.FilterSet {
+	var filters []*v3.FilterSet
+	for _, s := range subscribers {
+		for _, query := range s.queries {
+			filters = append(filters, query.filters)
+		}
+	}
+	return filters
+}
+
+func toPointers(response []model.SignozLog) []*model.SignozLog {
+	logs := make([]*model.SignozLog, len(response))
+	for i := range response {
+		logs[i] = &response[i]
+	}
+	return logs
+}
+
+// read reads the logs matching any of the filters following the position, the
+// oldest first
+func (t *Tail) read(filters []*v3.FilterSet, from position) ([]*model.SignozLog, error) {
+	query, err := logsV3.BuildSharedLiveTailQuery(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pollTimeout)
+	defer cancel()
+	response, err := t.reader.TailLogsV3(ctx, query, from.timestamp, from.id, t.opts.MaxLogsPerPoll)
+	if err != nil {
+		return nil, err
+	}
+	return toPointers(response), nil
+}
+
+// catchUp sends the subscriber the last logs since its start up to the
+// position of the tail. The logs are read once, no more than the buffer of the
+// subscriber holds, so that the other subscribers are not held up.
+func (t *Tail) catchUp(s *Subscriber, until position, now time.Time) error {
+	query, err := logsV3.BuildSharedLiveTailQuery(filtersOf(s))
+	if err != nil {
+		return err
+	}
+	query += fmt.Sprintf("timestamp >= %d AND ", s.start)
+
+	ctx, cancel := context.WithTimeout(context.Background(), pollTimeout)
+	defer cancel()
+	response, err := t.reader.LastLogsV3(ctx, query, until.timestamp, until.id, t.opts.BufferSize)
+	if err != nil {
+		return err
+	}
+
+	logs := toPointers(response)
+	for i, j := 0, len(logs)-1; i < j; i, j = i+1, j-1 {
+		logs[i], logs[j] = logs[j], logs[i]
+	}
+	s.dispatch(logs, now)
+	return nil
+}
+
+// fail ends the subscription with the error
+func (t *Tail) fail(s *Subscriber, err error) {
+	t.Unsubscribe(s)
+	s.Error <- err
+}
+
+func (t *Tail) run() {
+	tail := position{timestamp: uint64(time.Now().UnixNano())}
+
+	ticker := time.NewTicker(t.opts.Refresh)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		subscribers := t.current()
+		if subscribers == nil {
+			return
+		}
+
+		// the new subscribers are sent the logs read before they subscribed
+		// first
+		ready := subscribers[:0]
+		for _, s := range subscribers {
+			if !s.caughtUp {
+				if err := t.catchUp(s, tail, now); err != nil {
+					zap.S().Error("error while reading the live tail logs: ", err)
+					t.fail(s, err)
+					continue
+				}
+				s.caughtUp = true
+			}
+			ready = append(ready, s)
+		}
+		if len(ready) == 0 {
+			continue
+		}
+
+		logs, err := t.read(filtersOf(ready...), tail)
+		if err != nil {
+			zap.S().Error("error while reading the live tail logs: ", err)
+			for _, s := range ready {
+				t.fail(s, err)
+			}
+			continue
+		}
+		if len(logs) > 0 {
+			tail = position{timestamp: logs[len(logs)-1].Timestamp, id: logs[len(logs)-1].ID}
+		}
+
+		for _, s := range ready {
+			s.dispatch(logs, now)
+		}
+	}
+}
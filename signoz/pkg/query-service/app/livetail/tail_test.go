@@ -0,0 +1,235 @@
+package livetail
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	logsV3 "go.signoz.io/signoz/pkg/query-service/app/logs/v3"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+// tailReader returns the logs once and counts the polls
+type tailReader struct {
+	mu      sync.Mutex
+	polls   int
+	queries []string
+	logs    []model.SignozLog
+}
+
+func (r *tailReader) TailLogsV3(ctx context.Context, query string, timestampStart uint64, idStart string, limit int) ([]model.SignozLog, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.polls++
+	r.queries = append(r.queries, query)
+	logs := r.logs
+	r.logs = nil
+	return logs, nil
+}
+
+func (r *tailReader) LastLogsV3(ctx context.Context, query string, timestampEnd uint64, idEnd string, limit int) ([]model.SignozLog, error) {
+	return nil, nil
+}
+
+var errorFilters = &v3.FilterSet{Operator: "AND", Items: []v3.FilterItem{
+	{Key: v3.AttributeKey{Key: "severity_text", DataType: v3.AttributeKeyDataTypeString, IsColumn: true}, Value: "ERROR", Operator: "="},
+}}
+
+func TestTail(t *testing.T) {
+	reader := &tailReader{logs: []model.SignozLog{
+		// the oldest first
+		{Timestamp: 1, ID: "a", SeverityText: "ERROR"},
+		{Timestamp: 2, ID: "b", SeverityText: "INFO"},
+		{Timestamp: 3, ID: "c", SeverityText: "ERROR"},
+	}}
+	tail := NewTail(reader, Options{Refresh: 10 * time.Millisecond})
+
+	var subscribers []*Subscriber
+	for _, name := range []string{"first", "second"} {
+		s, err := tail.Subscribe(name, map[string]*v3.FilterSet{"A": errorFilters}, 0, 0)
+		require.NoError(t, err)
+		subscribers = append(subscribers, s)
+	}
+
+	for _, s := range subscribers {
+		for _, id := range []string{"a", "c"} {
+			select {
+			case log := <-s.Logs:
+				assert.Equal(t, id, log.ID)
+				assert.Equal(t, []string{"A"}, log.QueryNames)
+			case <-time.After(time.Second):
+				t.Fatalf("%s did not receive log %s", s.Name, id)
+			}
+		}
+	}
+
+	for _, s := range subscribers {
+		tail.Unsubscribe(s)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	reader.mu.Lock()
+	defer reader.mu.Unlock()
+	// every poll reads the logs of both subscribers
+	for _, query := range reader.queries {
+		assert.Contains(t, query, "where ((severity_text = 'ERROR')) AND ")
+	}
+	tail.mu.Lock()
+	defer tail.mu.Unlock()
+	assert.False(t, tail.running)
+
+	_, err := tail.Subscribe("invalid", map[string]*v3.FilterSet{"A": {Items: []v3.FilterItem{
+		{Key: v3.AttributeKey{Key: "severity_text", DataType: v3.AttributeKeyDataTypeString, IsColumn: true}, Value: "ERROR", Operator: "~"},
+	}}}, 0, 0)
+	assert.Error(t, err)
+}
+
+var startRe = regexp.MustCompile(`timestamp >= (\d+) AND `)
+
+// logsTable reads the logs around the position like the logs table, the
+// filters are left to the subscribers
+type logsTable struct {
+	logs []model.SignozLog
+}
+
+func after(log model.SignozLog, timestamp uint64, id string) bool {
+	return log.Timestamp > timestamp || (log.Timestamp == timestamp && log.ID > id)
+}
+
+func (r *logsTable) TailLogsV3(ctx context.Context, query string, timestampStart uint64, idStart string, limit int) ([]model.SignozLog, error) {
+	var logs []model.SignozLog
+	for _, log := range r.logs {
+		if !after(log, timestampStart, idStart) {
+			continue
+		}
+		if len(logs) == limit {
+			break
+		}
+		logs = append(logs, log)
+	}
+	return logs, nil
+}
+
+func (r *logsTable) LastLogsV3(ctx context.Context, query string, timestampEnd uint64, idEnd string, limit int) ([]model.SignozLog, error) {
+	var start uint64
+	if match := startRe.FindStringSubmatch(query); match != nil {
+		var err error
+		if start, err = strconv.ParseUint(match[1], 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	var logs []model.SignozLog
+	for i := len(r.logs) - 1; i >= 0 && len(logs) < limit; i-- {
+		log := r.logs[i]
+		if log.Timestamp >= start && !after(log, timestampEnd, idEnd) {
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
+
+func TestTailFromStart(t *testing.T) {
+	now := uint64(time.Now().UnixNano())
+	reader := &logsTable{}
+	for i, id := range []string{"a", "b", "c"} {
+		reader.logs = append(reader.logs, model.SignozLog{Timestamp: now - uint64(3-i)*uint64(time.Second), ID: id})
+	}
+	// the logs received after the tail started, more than read by a poll
+	for i, id := range []string{"d", "e", "f"} {
+		reader.logs = append(reader.logs, model.SignozLog{Timestamp: now + uint64(i+1)*uint64(time.Hour), ID: id})
+	}
+	tail := NewTail(reader, Options{Refresh: 10 * time.Millisecond, MaxLogsPerPoll: 1})
+
+	fromStart, err := tail.Subscribe("fromStart", map[string]*v3.FilterSet{"A": nil}, 0, now-uint64(2500*time.Millisecond))
+	require.NoError(t, err)
+	fromNow, err := tail.Subscribe("fromNow", map[string]*v3.FilterSet{"A": nil}, 0, 0)
+	require.NoError(t, err)
+	defer tail.Unsubscribe(fromStart)
+	defer tail.Unsubscribe(fromNow)
+
+	expected := map[*Subscriber][]string{
+		fromStart: {"b", "c", "d", "e", "f"},
+		fromNow:   {"d", "e", "f"},
+	}
+	for s, ids := range expected {
+		var received []string
+		for range ids {
+			select {
+			case log := <-s.Logs:
+				received = append(received, log.ID)
+			case <-time.After(time.Second):
+				t.Fatalf("%s received %v", s.Name, received)
+			}
+		}
+		assert.Equal(t, ids, received, "logs received by %s", s.Name)
+		assert.Equal(t, uint64(0), s.Dropped())
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	now := time.Now()
+	s := &Subscriber{
+		Logs:   make(chan *Log, 100),
+		rate:   10,
+		burst:  10,
+		tokens: 10,
+		last:   now,
+		queries: []subscriberQuery{
+			{name: "A", matcher: mustMatcher(t, errorFilters)},
+			{name: "B", matcher: mustMatcher(t, nil)},
+		},
+	}
+
+	var logs []*model.SignozLog
+	for i := 0; i < 40; i++ {
+		logs = append(logs, &model.SignozLog{Timestamp: uint64(i), SeverityText: "ERROR"})
+	}
+
+	// the logs above the rate are sampled over the poll
+	s.dispatch(logs, now)
+	require.Len(t, s.Logs, 10)
+	assert.Equal(t, uint64(30), s.Dropped())
+	assert.Equal(t, uint64(0), s.Dropped())
+	first := <-s.Logs
+	second := <-s.Logs
+	assert.Equal(t, uint64(0), first.Timestamp)
+	assert.Equal(t, uint64(4), second.Timestamp)
+	assert.Equal(t, []string{"A", "B"}, first.QueryNames)
+
+	// the tokens are refilled over time
+	s.dispatch(logs[:5], now.Add(200*time.Millisecond))
+	assert.Len(t, s.Logs, 10)
+	assert.Equal(t, uint64(3), s.Dropped())
+}
+
+func mustMatcher(t *testing.T, fs *v3.FilterSet) *logsV3.LogMatcher {
+	matcher, err := logsV3.NewLogMatcher(fs)
+	require.NoError(t, err)
+	return matcher
+}
+
+func TestCatchUp(t *testing.T) {
+	reader := &logsTable{}
+	for i, id := range []string{"a", "b", "c", "d", "e"} {
+		reader.logs = append(reader.logs, model.SignozLog{Timestamp: uint64(i + 1), ID: id})
+	}
+	tail := NewTail(reader, Options{BufferSize: 2})
+	s := &Subscriber{
+		Logs:    make(chan *Log, 2),
+		queries: []subscriberQuery{{name: "A", matcher: mustMatcher(t, nil)}},
+		start:   2,
+	}
+
+	// only the last logs up to the tail, as many as buffered, are read
+	require.NoError(t, tail.catchUp(s, position{timestamp: 4, id: "d"}, time.Now()))
+	require.Len(t, s.Logs, 2)
+	assert.Equal(t, "c", (<-s.Logs).ID)
+	assert.Equal(t, "d", (<-s.Logs).ID)
+	assert.Equal(t, uint64(0), s.Dropped())
+}
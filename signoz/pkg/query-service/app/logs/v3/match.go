@@ -0,0 +1,333 @@
+package v3
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils"
+)
+
+// LogMatcher evaluates the filters of a logs query on logs in-process, with
+// the semantics of the where clause the query builder generates for them
+type LogMatcher struct {
+	items []itemMatcher
+}
+
+type itemMatcher struct {
+	key   v3.AttributeKey
+	op    v3.FilterOperator
+	value interface{}
+	// re is the compiled pattern of the regex, like and contains operators
+	re *regexp.Regexp
+	// path is the path in the body of the json filters
+	path []string
+}
+
+// likeToRegexp converts the pattern of ILIKE to a regular expression
+func likeToRegexp(pattern string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("(?is)^")
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			sb.WriteString(".*")
+		case r == '_':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+// NewLogMatcher returns the matcher of the filters, the filters are
+// validated the same way they are when building the query
+func NewLogMatcher(fs *v3.FilterSet) (*LogMatcher, error) {
+	m := &LogMatcher{}
+	if fs == nil {
+		return m, nil
+	}
+
+	for _, item := range fs.Items {
+		op := v3.FilterOperator(strings.ToLower(strings.TrimSpace(string(item.Operator))))
+		operators := logOperators
+		if item.Key.IsJSON {
+			operators = jsonLogOperators
+		}
+		if _, ok := operators[op]; !ok {
+			return nil, fmt.Errorf("unsupported operator: %s", op)
+		}
+
+		matcher := itemMatcher{key: item.Key, op: op}
+		dataType := item.Key.DataType
+		if item.Key.IsJSON {
+			keyArr := strings.Split(item.Key.Key, ".")
+			if len(keyArr) < 2 || keyArr[0] != "body" {
+				return nil, fmt.Errorf("incorrect key %s, json keys must start with body", item.Key.Key)
+			}
+			for _, part := range keyArr[1:] {
+				matcher.path = append(matcher.path, strings.TrimSuffix(part, "[*]"))
+			}
+			if val, ok := arrayValueTypeMapping[string(dataType)]; ok {
+				if op != v3.FilterOperatorHas && op != v3.FilterOperatorNotHas {
+					return nil, fmt.Errorf("only has operator is supported for array")
+				}
+				dataType = v3.AttributeKeyDataType(val)
+			}
+		}
+
+		if op != v3.FilterOperatorExists && op != v3.FilterOperatorNotExists {
+			value, err := utils.ValidateAndCastValue(item.Value, dataType)
+			if err != nil {
+				return nil, fmt.Errorf("failed to validate and cast value for %s: %v", item.Key.Key, err)
+			}
+			matcher.value = value
+		}
+
+		var err error
+		switch op {
+		case v3.FilterOperatorRegex, v3.FilterOperatorNotRegex:
+			matcher.re, err = regexp.Compile(fmt.Sprintf("%v", matcher.value))
+		case v3.FilterOperatorLike, v3.FilterOperatorNotLike:
+			matcher.re, err = likeToRegexp(fmt.Sprintf("%v", matcher.value))
+		case v3.FilterOperatorContains, v3.FilterOperatorNotContains:
+			matcher.re, err = likeToRegexp(fmt.Sprintf("%%%v%%", item.Value))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern for %s: %v", item.Key.Key, err)
+		}
+		m.items = append(m.items, matcher)
+	}
+	return m, nil
+}
+
+// Match reports whether the log matches all the filters
+func (m *LogMatcher) Match(log *model.SignozLog) bool {
+	var body map[string]interface{}
+	var bodyParsed bool
+	for _, item := range m.items {
+		var value interface{}
+		var exists bool
+		if item.key.IsJSON {
+			if !bodyParsed {
+				bodyParsed = true
+				if err := json.Unmarshal([]byte(log.Body), &body); err != nil {
+					body = nil
+				}
+			}
+			value, exists = jsonValue(body, item.path)
+			if !exists && item.op != v3.FilterOperatorNotExists {
+				// the json filters check that the path exists
+				return false
+			}
+		} else {
+			value, exists = logValue(log, item.key)
+		}
+		if !item.match(value, exists) {
+			return false
+		}
+	}
+	return true
+}
+
+func jsonValue(body map[string]interface{}, path []string) (interface{}, bool) {
+	var value interface{} = body
+	for _, part := range path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[part]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// logValue returns the value of the key in the log, or the zero value of its
+// data type when the log does not have it, as clickhouse does
+func logValue(log *model.SignozLog, key v3.AttributeKey) (interface{}, bool) {
+	if key.IsColumn && key.Type == v3.AttributeKeyTypeUnspecified {
+		switch key.Key {
+		case "timestamp":
+			return int64(log.Timestamp), true
+		case "id":
+			return log.ID, true
+		case "trace_id":
+			return log.TraceID, log.TraceID != ""
+		case "span_id":
+			return log.SpanID, log.SpanID != ""
+		case "trace_flags":
+			return int64(log.TraceFlags), log.TraceFlags != 0
+		case "severity_text":
+			return log.SeverityText, log.SeverityText != ""
+		case "severity_number":
+			return int64(log.SeverityNumber), log.SeverityNumber != 0
+		case "body":
+			return log.Body, log.Body != ""
+		}
+	}
+
+	if key.Type == v3.AttributeKeyTypeResource {
+		value, ok := log.Resources_string[key.Key]
+		return value, ok
+	}
+	switch key.DataType {
+	case v3.AttributeKeyDataTypeInt64:
+		value, ok := log.Attributes_int64[key.Key]
+		return value, ok
+	case v3.AttributeKeyDataTypeFloat64:
+		value, ok := log.Attributes_float64[key.Key]
+		return value, ok
+	case v3.AttributeKeyDataTypeBool:
+		value, ok := log.Attributes_bool[key.Key]
+		return value, ok
+	default:
+		value, ok := log.Attributes_string[key.Key]
+		return value, ok
+	}
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case int:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case uint8:
+		return float64(x), true
+	case uint32:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	case float32:
+		return float64(x), true
+	case float64:
+		return x, true
+	}
+	return 0, false
+}
+
+// compareValues returns the order of the values, the values of different
+// types are not ordered
+func compareValues(a, b interface{}) (int, bool) {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	switch x := a.(type) {
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(x, y), true
+	case bool:
+		y, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		if x == y {
+			return 0, true
+		}
+		if !x {
+			return -1, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
+
+func equalValues(a, b interface{}) bool {
+	c, ok := compareValues(a, b)
+	return ok && c == 0
+}
+
+func containsValue(values interface{}, value interface{}) bool {
+	list, ok := values.([]interface{})
+	if !ok {
+		return equalValues(value, values)
+	}
+	for _, v := range list {
+		if equalValues(value, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (item itemMatcher) match(value interface{}, exists bool) bool {
+	if !exists && value == nil {
+		value = zeroValue(item.key.DataType)
+	}
+
+	switch item.op {
+	case v3.FilterOperatorExists:
+		return exists
+	case v3.FilterOperatorNotExists:
+		return !exists
+	case v3.FilterOperatorEqual:
+		return equalValues(value, item.value)
+	case v3.FilterOperatorNotEqual:
+		return !equalValues(value, item.value)
+	case v3.FilterOperatorLessThan, v3.FilterOperatorLessThanOrEq, v3.FilterOperatorGreaterThan, v3.FilterOperatorGreaterThanOrEq:
+		c, ok := compareValues(value, item.value)
+		if !ok {
+			return false
+		}
+		switch item.op {
+		case v3.FilterOperatorLessThan:
+			return c < 0
+		case v3.FilterOperatorLessThanOrEq:
+			return c <= 0
+		case v3.FilterOperatorGreaterThan:
+			return c > 0
+		default:
+			return c >= 0
+		}
+	case v3.FilterOperatorIn:
+		return containsValue(item.value, value)
+	case v3.FilterOperatorNotIn:
+		return !containsValue(item.value, value)
+	case v3.FilterOperatorHas:
+		return containsValue(value, item.value)
+	case v3.FilterOperatorNotHas:
+		return !containsValue(value, item.value)
+	case v3.FilterOperatorRegex, v3.FilterOperatorLike, v3.FilterOperatorContains:
+		return item.re.MatchString(fmt.Sprintf("%v", value))
+	case v3.FilterOperatorNotRegex, v3.FilterOperatorNotLike, v3.FilterOperatorNotContains:
+		return !item.re.MatchString(fmt.Sprintf("%v", value))
+	}
+	return false
+}
+
+func zeroValue(dataType v3.AttributeKeyDataType) interface{} {
+	switch dataType {
+	case v3.AttributeKeyDataTypeInt64, v3.AttributeKeyDataTypeFloat64:
+		return float64(0)
+	case v3.AttributeKeyDataTypeBool:
+		return false
+	default:
+		return ""
+	}
+}
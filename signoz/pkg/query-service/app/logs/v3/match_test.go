@@ -0,0 +1,133 @@
+package v3
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+var testMatchLog = &model.SignozLog{
+	Timestamp:         1680066360726000000,
+	ID:                "2TNh4vp2TpiWyLt3SzuadLJF2s4",
+	SeverityText:      "ERROR",
+	SeverityNumber:    17,
+	Body:              `{"message": "payment failed", "user": {"id": 42, "roles": ["admin", "billing"]}}`,
+	Resources_string:  map[string]string{"service.name": "checkout"},
+	Attributes_string: map[string]string{"method": "POST", "path": "/api/v1/pay"},
+	Attributes_int64:  map[string]int64{"status": 502},
+}
+
+var testLogMatcherData = []struct {
+	Name    string
+	Filters *v3.FilterSet
+	Match   bool
+}{
+	{
+		Name:    "no filters",
+		Filters: nil,
+		Match:   true,
+	},
+	{
+		Name: "equal resource and column",
+		Filters: &v3.FilterSet{Operator: "AND", Items: []v3.FilterItem{
+			{Key: v3.AttributeKey{Key: "service.name", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeResource}, Value: "checkout", Operator: "="},
+			{Key: v3.AttributeKey{Key: "severity_text", DataType: v3.AttributeKeyDataTypeString, IsColumn: true}, Value: "ERROR", Operator: "="},
+		}},
+		Match: true,
+	},
+	{
+		Name: "one filter not matching",
+		Filters: &v3.FilterSet{Operator: "AND", Items: []v3.FilterItem{
+			{Key: v3.AttributeKey{Key: "service.name", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeResource}, Value: "checkout", Operator: "="},
+			{Key: v3.AttributeKey{Key: "method", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag}, Value: "GET", Operator: "="},
+		}},
+		Match: false,
+	},
+	{
+		Name: "numbers and in",
+		Filters: &v3.FilterSet{Operator: "AND", Items: []v3.FilterItem{
+			{Key: v3.AttributeKey{Key: "status", DataType: v3.AttributeKeyDataTypeInt64, Type: v3.AttributeKeyTypeTag}, Value: 500, Operator: ">="},
+			{Key: v3.AttributeKey{Key: "method", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag}, Value: []interface{}{"PUT", "POST"}, Operator: "in"},
+		}},
+		Match: true,
+	},
+	{
+		Name: "like, contains and regex",
+		Filters: &v3.FilterSet{Operator: "AND", Items: []v3.FilterItem{
+			{Key: v3.AttributeKey{Key: "path", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag}, Value: "/API/%", Operator: "like"},
+			{Key: v3.AttributeKey{Key: "body", DataType: v3.AttributeKeyDataTypeString, IsColumn: true}, Value: "FAILED", Operator: "contains"},
+			{Key: v3.AttributeKey{Key: "path", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag}, Value: "v[0-9]+", Operator: "regex"},
+		}},
+		Match: true,
+	},
+	{
+		Name: "exists",
+		Filters: &v3.FilterSet{Operator: "AND", Items: []v3.FilterItem{
+			{Key: v3.AttributeKey{Key: "method", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag}, Operator: "exists"},
+			{Key: v3.AttributeKey{Key: "user", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag}, Operator: "nexists"},
+		}},
+		Match: true,
+	},
+	{
+		Name: "missing attribute is the zero value",
+		Filters: &v3.FilterSet{Operator: "AND", Items: []v3.FilterItem{
+			{Key: v3.AttributeKey{Key: "user", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag}, Value: "bob", Operator: "!="},
+		}},
+		Match: true,
+	},
+	{
+		Name: "json body",
+		Filters: &v3.FilterSet{Operator: "AND", Items: []v3.FilterItem{
+			{Key: v3.AttributeKey{Key: "body.user.id", DataType: v3.AttributeKeyDataTypeInt64, IsJSON: true}, Value: 42, Operator: "="},
+			{Key: v3.AttributeKey{Key: "body.user.roles[*]", DataType: "array(string)", IsJSON: true}, Value: "billing", Operator: "has"},
+		}},
+		Match: true,
+	},
+	{
+		Name: "json body missing path",
+		Filters: &v3.FilterSet{Operator: "AND", Items: []v3.FilterItem{
+			{Key: v3.AttributeKey{Key: "body.user.name", DataType: v3.AttributeKeyDataTypeString, IsJSON: true}, Value: "bob", Operator: "!="},
+		}},
+		Match: false,
+	},
+}
+
+func TestLogMatcher(t *testing.T) {
+	for _, tt := range testLogMatcherData {
+		Convey(tt.Name, t, func() {
+			matcher, err := NewLogMatcher(tt.Filters)
+			So(err, ShouldBeNil)
+			So(matcher.Match(testMatchLog), ShouldEqual, tt.Match)
+		})
+	}
+
+	Convey("invalid operator", t, func() {
+		_, err := NewLogMatcher(&v3.FilterSet{Operator: "AND", Items: []v3.FilterItem{
+			{Key: v3.AttributeKey{Key: "method", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag}, Value: "GET", Operator: "~"},
+		}})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestBuildSharedLiveTailQuery(t *testing.T) {
+	method := &v3.FilterSet{Operator: "AND", Items: []v3.FilterItem{
+		{Key: v3.AttributeKey{Key: "method", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag}, Value: "GET", Operator: "="},
+	}}
+	status := &v3.FilterSet{Operator: "AND", Items: []v3.FilterItem{
+		{Key: v3.AttributeKey{Key: "severity_text", DataType: v3.AttributeKeyDataTypeString, IsColumn: true}, Value: "ERROR", Operator: "="},
+	}}
+
+	Convey("the same filters are read once", t, func() {
+		query, err := BuildSharedLiveTailQuery([]*v3.FilterSet{method, status, method})
+		So(err, ShouldBeNil)
+		So(query, ShouldEndWith, "from signoz_logs.distributed_logs where ((attributes_string_value[indexOf(attributes_string_key, 'method')] = 'GET') OR (severity_text = 'ERROR')) AND ")
+	})
+
+	Convey("no filters read every log", t, func() {
+		query, err := BuildSharedLiveTailQuery([]*v3.FilterSet{method, nil})
+		So(err, ShouldBeNil)
+		So(query, ShouldEndWith, "from signoz_logs.distributed_logs where ")
+	})
+}
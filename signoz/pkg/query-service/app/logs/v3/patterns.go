@@ -0,0 +1,182 @@
+package v3
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+// PatternWildcard replaces the parts of a pattern varying between the logs
+const PatternWildcard = "<*>"
+
+const (
+	// patternSimilarity is the share of the tokens a body must have in common
+	// with a pattern to be grouped in it
+	patternSimilarity = 0.5
+	// patternSampleIDs is the number of ids of sample logs of a pattern
+	patternSampleIDs = 5
+)
+
+// patternMasks match the variable parts of the tokens, which are masked
+// before the bodies are compared
+var patternMasks = []*regexp.Regexp{
+	// uuids
+	regexp.MustCompile(`(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`),
+	// ipv4 addresses, with an optional port
+	regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(:\d+)?\b`),
+	// ipv6 addresses
+	regexp.MustCompile(`(?i)\b([0-9a-f]{0,4}:){2,7}[0-9a-f]{1,4}\b`),
+	// hexadecimal numbers
+	regexp.MustCompile(`(?i)\b0x[0-9a-f]+\b`),
+	// numbers
+	regexp.MustCompile(`[-+]?\b\d+(\.\d+)?`),
+}
+
+// maskToken replaces the variable parts of the token by the wildcard
+func maskToken(token string) string {
+	for _, mask := range patternMasks {
+		token = mask.ReplaceAllString(token, PatternWildcard)
+	}
+	return token
+}
+
+type patternCluster struct {
+	tokens  []string
+	pattern *v3.LogPattern
+}
+
+// similarity returns the share of the tokens matching the tokens of the
+// cluster, the wildcards match any token as in Drain, and the number of
+// wildcards of the cluster
+func (c *patternCluster) similarity(tokens []string) (float64, int) {
+	if len(tokens) == 0 {
+		return 1, 0
+	}
+	var same, wildcards int
+	for i, token := range c.tokens {
+		if token == PatternWildcard {
+			wildcards++
+			same++
+			continue
+		}
+		if token == tokens[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(tokens)), wildcards
+}
+
+// PatternMiner groups log bodies into patterns the way Drain does: the bodies
+// are split into tokens and the variable tokens are masked, then the bodies
+// with the same number of tokens and the same first token are grouped with
+// the most similar pattern, or start a new one. The tokens differing between
+// a pattern and the bodies grouped in it become wildcards.
+type PatternMiner struct {
+	groups   map[string][]*patternCluster
+	clusters []*patternCluster
+}
+
+func NewPatternMiner() *PatternMiner {
+	return &PatternMiner{groups: make(map[string][]*patternCluster)}
+}
+
+// Add groups the body of the log in a pattern
+func (m *PatternMiner) Add(body, id string, timestamp time.Time) {
+	tokens := strings.Fields(body)
+	for i, token := range tokens {
+		tokens[i] = maskToken(token)
+	}
+
+	first := ""
+	if len(tokens) > 0 && !strings.Contains(tokens[0], PatternWildcard) {
+		first = tokens[0]
+	}
+	key := fmt.Sprintf("%d %s", len(tokens), first)
+
+	var best *patternCluster
+	bestSimilarity, bestWildcards := -1.0, -1
+	for _, cluster := range m.groups[key] {
+		similarity, wildcards := cluster.similarity(tokens)
+		if similarity > bestSimilarity || (similarity == bestSimilarity && wildcards > bestWildcards) {
+			best, bestSimilarity, bestWildcards = cluster, similarity, wildcards
+		}
+	}
+
+	if best == nil || bestSimilarity < patternSimilarity {
+		best = &patternCluster{
+			tokens:  tokens,
+			pattern: &v3.LogPattern{FirstSeen: timestamp, LastSeen: timestamp},
+		}
+		m.groups[key] = append(m.groups[key], best)
+		m.clusters = append(m.clusters, best)
+	} else {
+		for i, token := range best.tokens {
+			if token != tokens[i] {
+				best.tokens[i] = PatternWildcard
+			}
+		}
+	}
+
+	pattern := best.pattern
+	pattern.Count++
+	if timestamp.Before(pattern.FirstSeen) {
+		pattern.FirstSeen = timestamp
+	}
+	if timestamp.After(pattern.LastSeen) {
+		pattern.LastSeen = timestamp
+	}
+	if len(pattern.SampleIDs) < patternSampleIDs {
+		pattern.SampleIDs = append(pattern.SampleIDs, id)
+	}
+}
+
+// Patterns returns the patterns, the most frequent first
+func (m *PatternMiner) Patterns() []*v3.LogPattern {
+	patterns := make([]*v3.LogPattern, 0, len(m.clusters))
+	for _, cluster := range m.clusters {
+		cluster.pattern.Pattern = strings.Join(cluster.tokens, " ")
+		patterns = append(patterns, cluster.pattern)
+	}
+	sort.SliceStable(patterns, func(i, j int) bool {
+		if patterns[i].Count != patterns[j].Count {
+			return patterns[i].Count > patterns[j].Count
+		}
+		return patterns[i].Pattern < patterns[j].Pattern
+	})
+	return patterns
+}
+
+// escapeLike escapes the wildcards of ILIKE in the value
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// PatternFilterSet returns the filters selecting the logs of the pattern
+// among the logs of the filters. The tokens of the pattern are matched in
+// order on the body, the whitespace between them is not.
+func PatternFilterSet(pattern string, filters *v3.FilterSet) *v3.FilterSet {
+	parts := []string{""}
+	for _, token := range strings.Fields(pattern) {
+		literals := strings.Split(token, PatternWildcard)
+		for i := range literals {
+			literals[i] = escapeLike(literals[i])
+		}
+		parts = append(parts, strings.Join(literals, "%"))
+	}
+	parts = append(parts, "")
+
+	fs := &v3.FilterSet{Operator: "AND"}
+	if filters != nil {
+		fs.Items = append(fs.Items, filters.Items...)
+	}
+	fs.Items = append(fs.Items, v3.FilterItem{
+		Key:      v3.AttributeKey{Key: "body", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeUnspecified, IsColumn: true},
+		Operator: v3.FilterOperatorLike,
+		Value:    strings.Join(parts, "%"),
+	})
+	return fs
+}
@@ -0,0 +1,101 @@
+package v3
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+var testMaskTokenData = []struct {
+	Token  string
+	Masked string
+}{
+	{Token: "user", Masked: "user"},
+	{Token: "1234", Masked: "<*>"},
+	{Token: "latency=12.5ms", Masked: "latency=<*>ms"},
+	{Token: "id=3fa85f64-5717-4562-b3fc-2c963f66afa6,", Masked: "id=<*>,"},
+	{Token: "10.0.0.12:8080", Masked: "<*>"},
+	{Token: "[fe80::1ff:fe23:4567:890a]", Masked: "[<*>]"},
+	{Token: "0x1F3A", Masked: "<*>"},
+	{Token: "v2", Masked: "v2"},
+}
+
+func TestMaskToken(t *testing.T) {
+	for _, tt := range testMaskTokenData {
+		Convey(tt.Token, t, func() {
+			So(maskToken(tt.Token), ShouldEqual, tt.Masked)
+		})
+	}
+}
+
+func TestPatternMiner(t *testing.T) {
+	start := time.Unix(1680066360, 0)
+	bodies := []string{
+		"payment failed for user alice after 3 retries",
+		"connected to 10.0.0.1:5432",
+		"payment failed for user bob after 5 retries",
+		"payment failed for user carol after 1 retries",
+		"connected to 10.0.0.2:5432",
+		"shutting down",
+	}
+
+	miner := NewPatternMiner()
+	for i, body := range bodies {
+		miner.Add(body, string(rune('a'+i)), start.Add(time.Duration(i)*time.Second))
+	}
+	patterns := miner.Patterns()
+
+	Convey("the bodies are grouped by pattern", t, func() {
+		So(patterns, ShouldHaveLength, 3)
+
+		So(patterns[0].Pattern, ShouldEqual, "payment failed for user <*> after <*> retries")
+		So(patterns[0].Count, ShouldEqual, 3)
+		So(patterns[0].SampleIDs, ShouldResemble, []string{"a", "c", "d"})
+		So(patterns[0].FirstSeen, ShouldEqual, start)
+		So(patterns[0].LastSeen, ShouldEqual, start.Add(3*time.Second))
+
+		So(patterns[1].Pattern, ShouldEqual, "connected to <*>")
+		So(patterns[1].Count, ShouldEqual, 2)
+		So(patterns[2].Pattern, ShouldEqual, "shutting down")
+	})
+
+	Convey("the filters of a pattern select its logs", t, func() {
+		filters := &v3.FilterSet{Operator: "AND", Items: []v3.FilterItem{
+			{Key: v3.AttributeKey{Key: "severity_text", DataType: v3.AttributeKeyDataTypeString, IsColumn: true}, Value: "ERROR", Operator: "="},
+		}}
+		fs := PatternFilterSet(patterns[0].Pattern, filters)
+		So(fs.Items, ShouldHaveLength, 2)
+		So(fs.Items[1].Value, ShouldEqual, "%payment%failed%for%user%%%after%%%retries%")
+
+		matcher, err := NewLogMatcher(fs)
+		So(err, ShouldBeNil)
+		So(matcher.Match(&model.SignozLog{SeverityText: "ERROR", Body: "payment  failed for user dave after 10 retries\n"}), ShouldBeTrue)
+		So(matcher.Match(&model.SignozLog{SeverityText: "ERROR", Body: "connected to 10.0.0.1:5432"}), ShouldBeFalse)
+
+		// the wildcards of like in the pattern are escaped
+		fs = PatternFilterSet("disk 100% full_", nil)
+		So(fs.Items[0].Value, ShouldEqual, "%disk%100\\%%full\\_%")
+		matcher, err = NewLogMatcher(fs)
+		So(err, ShouldBeNil)
+		So(matcher.Match(&model.SignozLog{Body: "disk 100% full_"}), ShouldBeTrue)
+		So(matcher.Match(&model.SignozLog{Body: "disk 1000 fullx"}), ShouldBeFalse)
+	})
+}
+
+func TestPatternMinerWildcards(t *testing.T) {
+	miner := NewPatternMiner()
+	miner.Add("user alice logged in from web", "a", time.Unix(0, 0))
+	miner.Add("user bob logged out from app", "b", time.Unix(1, 0))
+	// the wildcards of the pattern match any token
+	miner.Add("user carol signed in from cli", "c", time.Unix(2, 0))
+	patterns := miner.Patterns()
+
+	Convey("the wildcards count as matching tokens", t, func() {
+		So(patterns, ShouldHaveLength, 1)
+		So(patterns[0].Pattern, ShouldEqual, "user <*> <*> <*> from <*>")
+		So(patterns[0].Count, ShouldEqual, 3)
+	})
+}
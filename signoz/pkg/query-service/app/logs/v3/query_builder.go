@@ -339,6 +339,14 @@ func buildLogsQuery(panelType v3.PanelType, start, end, step int64, mq *v3.Build
 		query := fmt.Sprintf(queryTmpl, op, filterSubQuery, groupBy, having, orderBy)
 		return query, nil
 	case v3.AggregateOperatorNoOp:
+		if mq.After != nil {
+			filterSubQuery += fmt.Sprintf(" AND (timestamp, id) %s (%d, %s)", mq.After.Operator(), mq.After.Timestamp, utils.ClickHouseFormattedValue(mq.After.ID))
+			orderBy = fmt.Sprintf("timestamp %s, id %s", strings.ToUpper(mq.After.Order), strings.ToUpper(mq.After.Order))
+		} else if order, ok := mq.ListOrder(); ok {
+			// the logs with the same timestamp are in the same order on every
+			// page, the pages are continued by timestamp and id
+			orderBy = fmt.Sprintf("timestamp %s, id %s", strings.ToUpper(order), strings.ToUpper(order))
+		}
 		queryTmpl := constants.LogsSQLSelect + "from signoz_logs.distributed_logs where %s%s order by %s"
 		query := fmt.Sprintf(queryTmpl, timeFilter, filterSubQuery, orderBy)
 		return query, nil
@@ -366,6 +374,34 @@ func buildLogsLiveTailQuery(mq *v3.BuilderQuery) (string, error) {
 	}
 }
 
+// BuildSharedLiveTailQuery returns the live tail query of the logs matching
+// any of the filter sets, the same filter sets are read once
+func BuildSharedLiveTailQuery(filters []*v3.FilterSet) (string, error) {
+	var conditions []string
+	seen := map[string]bool{}
+	matchAll := len(filters) == 0
+	for _, fs := range filters {
+		filterSubQuery, err := buildLogsTimeSeriesFilterQuery(fs, nil, v3.AttributeKey{})
+		if err != nil {
+			return "", err
+		}
+		if filterSubQuery == "" {
+			matchAll = true
+			continue
+		}
+		if !seen[filterSubQuery] {
+			seen[filterSubQuery] = true
+			conditions = append(conditions, "("+filterSubQuery+")")
+		}
+	}
+
+	query := constants.LogsSQLSelect + "from signoz_logs.distributed_logs where "
+	if !matchAll {
+		query = query + "(" + strings.Join(conditions, " OR ") + ") AND "
+	}
+	return query, nil
+}
+
 // groupBy returns a string of comma separated tags for group by clause
 // `ts` is always added to the group by clause
 func groupBy(panelType v3.PanelType, graphLimitQtype string, tags ...string) string {
@@ -482,6 +518,18 @@ func isOrderByTs(orderBy []v3.OrderBy) bool {
 // step is in seconds
 func PrepareLogsQuery(start, end int64, queryType v3.QueryType, panelType v3.PanelType, mq *v3.BuilderQuery, options Options) (string, error) {
 
+	if mq.Cursor != "" && mq.After == nil && panelType == v3.PanelTypeList {
+		after, err := v3.ParseListCursor(mq.Cursor)
+		if err != nil {
+			return "", err
+		}
+		query := *mq
+		query.After = after
+		mq = &query
+	}
+
+	start, end = mq.ShiftTimeRange(start, end)
+
 	// adjust the start and end time to the step interval
 	start = start - (start % (mq.StepInterval * 1000))
 	end = end - (end % (mq.StepInterval * 1000))
@@ -506,13 +554,16 @@ func PrepareLogsQuery(start, end int64, queryType v3.QueryType, panelType v3.Pan
 		if err != nil {
 			return "", err
 		}
-		return query, nil
+		return utils.AddTimeShiftToQuery(query, mq.TimeShift, mq.StepInterval), nil
 	}
 
 	query, err := buildLogsQuery(panelType, start, end, mq.StepInterval, mq, options.GraphLimitQtype, options.PreferRPM)
 	if err != nil {
 		return "", err
 	}
+	if panelType == v3.PanelTypeGraph {
+		query = utils.AddTimeShiftToQuery(query, mq.TimeShift, mq.StepInterval)
+	}
 	if panelType == v3.PanelTypeValue {
 		query, err = reduceQuery(query, mq.ReduceTo, mq.AggregateOperator)
 	}
@@ -530,8 +581,9 @@ func PrepareLogsQuery(start, end int64, queryType v3.QueryType, panelType v3.Pan
 				query = addLimitToQuery(query, mq.PageSize)
 			}
 
-			// add offset to the query only if it is not orderd by timestamp.
-			if !isOrderByTs(mq.OrderBy) {
+			// add offset to the query only if it is not orderd by timestamp
+			// and does not start after a position.
+			if !isOrderByTs(mq.OrderBy) && mq.After == nil {
 				query = addOffsetToQuery(query, mq.Offset)
 			}
 
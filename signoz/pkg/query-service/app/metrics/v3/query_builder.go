@@ -444,6 +444,8 @@ func reduceQuery(query string, reduceTo v3.ReduceToOperator, aggregateOperator v
 // step is in seconds
 func PrepareMetricQuery(start, end int64, queryType v3.QueryType, panelType v3.PanelType, mq *v3.BuilderQuery, options Options) (string, error) {
 
+	start, end = mq.ShiftTimeRange(start, end)
+
 	// adjust the start and end time to be aligned with the step interval
 	start = start - (start % (mq.StepInterval * 1000))
 	end = end - (end % (mq.StepInterval * 1000))
@@ -490,6 +492,10 @@ func PrepareMetricQuery(start, end int64, queryType v3.QueryType, panelType v3.P
 		query = fmt.Sprintf("SELECT * FROM (%s) HAVING %s", query, having(mq.Having))
 	}
 
+	if panelType == v3.PanelTypeGraph {
+		query = utils.AddTimeShiftToQuery(query, mq.TimeShift, mq.StepInterval)
+	}
+
 	if panelType == v3.PanelTypeValue {
 		query, err = reduceQuery(query, mq.ReduceTo, mq.AggregateOperator)
 	}
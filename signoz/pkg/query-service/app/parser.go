@@ -232,6 +232,20 @@ func parseGetServicesRequest(r *http.Request) (*model.GetServicesParams, error)
 	}
 
 	postData.Period = int(postData.End.Unix() - postData.Start.Unix())
+
+	if len(postData.ComparisonStartTime) != 0 || len(postData.ComparisonEndTime) != 0 {
+		postData.ComparisonStart, err = parseTimeStr(postData.ComparisonStartTime, "comparisonStart")
+		if err != nil {
+			return nil, err
+		}
+		postData.ComparisonEnd, err = parseTimeMinusBufferStr(postData.ComparisonEndTime, "comparisonEnd")
+		if err != nil {
+			return nil, err
+		}
+		if !postData.ComparisonEnd.After(*postData.ComparisonStart) {
+			return nil, fmt.Errorf("comparisonEnd must be after comparisonStart")
+		}
+	}
 	return postData, nil
 }
 
@@ -259,6 +273,17 @@ func ParseSearchTracesParams(r *http.Request) (string, string, int, int, error)
 	return traceId, spanId, levelUpInt, levelDownInt, nil
 }
 
+func parseCompareTracesRequest(r *http.Request) (*model.CompareTracesParams, error) {
+	var postData model.CompareTracesParams
+	if err := json.NewDecoder(r.Body).Decode(&postData); err != nil {
+		return nil, err
+	}
+	if postData.BaseTraceID == "" || postData.ComparisonTraceID == "" {
+		return nil, fmt.Errorf("baseTraceId and comparisonTraceId are required")
+	}
+	return &postData, nil
+}
+
 func DoesExistInSlice(item string, list []string) bool {
 	for _, element := range list {
 		if item == element {
@@ -522,6 +547,12 @@ func parseListErrorsRequest(r *http.Request) (*model.ListErrorsParams, error) {
 		return nil, errors.New(fmt.Sprintf("given orderParam: %s is not allowed in query", postData.OrderParam))
 	}
 
+	for _, state := range postData.States {
+		if err := state.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
 	return postData, nil
 }
 
@@ -542,6 +573,12 @@ func parseCountErrorsRequest(r *http.Request) (*model.CountErrorsParams, error)
 	if err != nil {
 		return nil, err
 	}
+
+	for _, state := range postData.States {
+		if err := state.Validate(); err != nil {
+			return nil, err
+		}
+	}
 	return postData, nil
 }
 
@@ -560,9 +597,10 @@ func parseGetErrorRequest(r *http.Request) (*model.GetErrorParams, error) {
 	errorID := r.URL.Query().Get("errorID")
 
 	params := &model.GetErrorParams{
-		Timestamp: timestamp,
-		GroupID:   groupID,
-		ErrorID:   errorID,
+		Timestamp:   timestamp,
+		GroupID:     groupID,
+		ErrorID:     errorID,
+		Fingerprint: r.URL.Query().Get("fingerprint"),
 	}
 
 	return params, nil
@@ -925,11 +963,21 @@ func validateQueryRangeParamsV3(qp *v3.QueryRangeParamsV3) error {
 	if len(errs) > 0 {
 		return multierr.Combine(errs...)
 	}
+
+	for name, q := range qp.CompositeQuery.BuilderQueries {
+		if q.Expression == name {
+			continue
+		}
+		if err := queryBuilder.ValidateFormula(qp.CompositeQuery, q); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // validateExpressions validates the math expressions using the list of
-// allowed functions.
+// allowed functions. The variables are the names of the queries, formulas
+// included as long as they do not depend on themselves.
 func validateExpressions(expressions []string, funcs map[string]govaluate.ExpressionFunction, cq *v3.CompositeQuery) []error {
 	var errs []error
 	for _, exp := range expressions {
@@ -941,8 +989,8 @@ func validateExpressions(expressions []string, funcs map[string]govaluate.Expres
 		variables := evalExp.Vars()
 		for _, v := range variables {
 			var hasVariable bool
-			for _, q := range cq.BuilderQueries {
-				if q.Expression == v {
+			for name, q := range cq.BuilderQueries {
+				if q.Expression == v || name == v {
 					hasVariable = true
 					break
 				}
@@ -952,6 +1000,24 @@ func validateExpressions(expressions []string, funcs map[string]govaluate.Expres
 			}
 		}
 	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	formulas := make(map[string][]string)
+	for name, q := range cq.BuilderQueries {
+		if q.Expression == name {
+			continue
+		}
+		operands, err := queryBuilder.FormulaOperands(q)
+		if err != nil {
+			return []error{err}
+		}
+		formulas[name] = operands
+	}
+	if _, err := queryBuilder.FormulaOrder(formulas); err != nil {
+		errs = append(errs, err)
+	}
 	return errs
 }
 
@@ -547,6 +547,25 @@ func TestParseQueryRangeParamsCompositeQuery(t *testing.T) {
 			expectErr: true,
 			errMsg:    "builder query A is invalid: group by is invalid",
 		},
+		{
+			desc: "time shift not a multiple of the step interval",
+			compositeQuery: v3.CompositeQuery{
+				PanelType: v3.PanelTypeGraph,
+				QueryType: v3.QueryTypeBuilder,
+				BuilderQueries: map[string]*v3.BuilderQuery{
+					"A": {
+						QueryName:         "A",
+						DataSource:        "logs",
+						AggregateOperator: "count",
+						StepInterval:      60,
+						TimeShift:         90,
+						Expression:        "A",
+					},
+				},
+			},
+			expectErr: true,
+			errMsg:    "time shift must be a multiple of the step interval 60",
+		},
 	}
 
 	for _, tc := range reqCases {
@@ -645,6 +664,63 @@ func TestParseQueryRangeParamsExpressions(t *testing.T) {
 			expectErr: true,
 			errMsg:    "unknown variable B; unknown variable C",
 		},
+		{
+			desc: "formula as variable",
+			compositeQuery: v3.CompositeQuery{
+				PanelType: v3.PanelTypeGraph,
+				QueryType: v3.QueryTypeBuilder,
+				BuilderQueries: map[string]*v3.BuilderQuery{
+					"A": {
+						QueryName:          "A",
+						StepInterval:       60,
+						DataSource:         v3.DataSourceMetrics,
+						AggregateOperator:  v3.AggregateOperatorSum,
+						AggregateAttribute: v3.AttributeKey{Key: "attribute_metrics"},
+						Expression:         "A",
+					},
+					"F1": {
+						QueryName:    "F1",
+						StepInterval: 60,
+						Expression:   "A + 1",
+					},
+					"F2": {
+						QueryName:    "F2",
+						StepInterval: 60,
+						Expression:   "F1 * 2",
+					},
+				},
+			},
+			expectErr: false,
+		},
+		{
+			desc: "formula depending on itself",
+			compositeQuery: v3.CompositeQuery{
+				PanelType: v3.PanelTypeGraph,
+				QueryType: v3.QueryTypeBuilder,
+				BuilderQueries: map[string]*v3.BuilderQuery{
+					"A": {
+						QueryName:          "A",
+						StepInterval:       60,
+						DataSource:         v3.DataSourceMetrics,
+						AggregateOperator:  v3.AggregateOperatorSum,
+						AggregateAttribute: v3.AttributeKey{Key: "attribute_metrics"},
+						Expression:         "A",
+					},
+					"F1": {
+						QueryName:    "F1",
+						StepInterval: 60,
+						Expression:   "A + F2",
+					},
+					"F2": {
+						QueryName:    "F2",
+						StepInterval: 60,
+						Expression:   "F1 * 2",
+					},
+				},
+			},
+			expectErr: true,
+			errMsg:    "depends on itself",
+		},
 	}
 
 	for _, tc := range reqCases {
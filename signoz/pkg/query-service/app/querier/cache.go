@@ -0,0 +1,463 @@
+package querier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/cespare/xxhash"
+	"go.signoz.io/signoz/pkg/query-service/cache"
+	"go.signoz.io/signoz/pkg/query-service/common"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultCacheTTL = time.Hour
+	// defaultBucketWindow is the width of the time window covered by a single cache entry.
+	// Windows are aligned to the epoch, so queries over overlapping ranges share entries.
+	defaultBucketWindow = 6 * time.Hour
+)
+
+// cachedBucket is the value stored in the cache for one aligned window of a query.
+// Start and End (inclusive, in milliseconds) is the part of the window that has been
+// fetched from the database and Series holds the points in that part.
+type cachedBucket struct {
+	Start  int64        `json:"start"`
+	End    int64        `json:"end"`
+	Series []*v3.Series `json:"series"`
+}
+
+// queryHash returns the short identifier of a cache key; it is used to build the
+// bucket keys and to refer to the cached query in the cache admin API
+func queryHash(cacheKey string) string {
+	return strconv.FormatUint(xxhash.Sum64String(cacheKey), 16)
+}
+
+func bucketCacheKey(hash string, bucketStart int64) string {
+	return fmt.Sprintf("%s-%d", hash, bucketStart)
+}
+
+// bucketWindow returns the window width for the given step, the window is a multiple
+// of the step so that bucket boundaries are always aligned to the step.
+func bucketWindow(stepMs int64) int64 {
+	window := defaultBucketWindow.Milliseconds()
+	if stepMs <= 0 || window%stepMs == 0 {
+		return window
+	}
+	return (window/stepMs + 1) * stepMs
+}
+
+func alignDown(ts, stepMs int64) int64 {
+	if stepMs <= 0 {
+		return ts
+	}
+	return ts - (ts % stepMs)
+}
+
+// alignRange aligns the start to the step and extends the end to the last millisecond
+// of its step, the points returned by the queries are always at multiples of the step
+// so the aligned range contains exactly the same points.
+func alignRange(start, end, stepMs int64) (int64, int64) {
+	if stepMs <= 0 {
+		return start, end
+	}
+	return alignDown(start, stepMs), alignDown(end, stepMs) + stepMs - 1
+}
+
+// findMissingTimeRanges returns the parts of [start, end] that are not covered by the
+// cached buckets. Misses are extended to keep the coverage of each bucket contiguous
+// and adjacent misses are merged so that they can be fetched with a single query.
+func findMissingTimeRanges(start, end, window int64, buckets map[int64]*cachedBucket) []missInterval {
+	var misses []missInterval
+	for bucketStart := start - (start % window); bucketStart <= end; bucketStart += window {
+		wantStart := max(start, bucketStart)
+		wantEnd := min(end, bucketStart+window-1)
+
+		cached, ok := buckets[bucketStart]
+		if !ok || cached.Start > cached.End {
+			misses = append(misses, missInterval{start: wantStart, end: wantEnd})
+			continue
+		}
+		if cached.Start > wantStart {
+			misses = append(misses, missInterval{start: wantStart, end: cached.Start - 1})
+		}
+		if cached.End < wantEnd {
+			misses = append(misses, missInterval{start: cached.End + 1, end: wantEnd})
+		}
+	}
+
+	var merged []missInterval
+	for _, miss := range misses {
+		if len(merged) > 0 && merged[len(merged)-1].end+1 == miss.start {
+			merged[len(merged)-1].end = miss.end
+			continue
+		}
+		merged = append(merged, miss)
+	}
+	return merged
+}
+
+// filterSeries returns copies of the series with only the points in [start, end]
+func filterSeries(seriesList []*v3.Series, start, end int64) []*v3.Series {
+	filtered := make([]*v3.Series, 0, len(seriesList))
+	for _, series := range seriesList {
+		points := make([]v3.Point, 0)
+		for _, point := range series.Points {
+			if point.Timestamp >= start && point.Timestamp <= end {
+				points = append(points, point)
+			}
+		}
+		if len(points) == 0 {
+			continue
+		}
+		filtered = append(filtered, &v3.Series{Labels: series.Labels, Points: points})
+	}
+	return filtered
+}
+
+// findCachedTimeRanges returns the parts of [start, end] covered by the cached buckets
+func findCachedTimeRanges(start, end, window int64, buckets map[int64]*cachedBucket) []v3.TimeRange {
+	var ranges []v3.TimeRange
+	for bucketStart := start - (start % window); bucketStart <= end; bucketStart += window {
+		cached, ok := buckets[bucketStart]
+		if !ok {
+			continue
+		}
+		r := v3.TimeRange{Start: max(start, cached.Start), End: min(end, cached.End)}
+		if r.Start > r.End {
+			continue
+		}
+		if len(ranges) > 0 && ranges[len(ranges)-1].End+1 >= r.Start {
+			ranges[len(ranges)-1].End = max(ranges[len(ranges)-1].End, r.End)
+			continue
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// queryWithCache returns the series for the requested range of the query identified by
+// cacheKey. The cached buckets are used for the covered parts of the range and fetch is
+// called for each missing part. The buckets touched by the misses are stored back with
+// the newly fetched data, except for the last fluxInterval which might still change.
+func (q *querier) queryWithCache(
+	ctx context.Context,
+	params *v3.QueryRangeParamsV3,
+	queryName string,
+	cacheKey string,
+	stepMs int64,
+	fetch func(start, end int64) ([]*v3.Series, error),
+) ([]*v3.Series, error) {
+	if cacheKey == "" || params.NoCache || q.cache == nil {
+		return fetch(params.Start, params.End)
+	}
+
+	start, end := alignRange(params.Start, params.End, stepMs)
+	window := bucketWindow(stepMs)
+	hash := queryHash(cacheKey)
+
+	buckets := make(map[int64]*cachedBucket)
+	for bucketStart := start - (start % window); bucketStart <= end; bucketStart += window {
+		data, retrieveStatus, err := q.cache.Retrieve(bucketCacheKey(hash, bucketStart), true)
+		zap.S().Debug("cache retrieve status", zap.String("status", retrieveStatus.String()))
+		if err != nil || data == nil {
+			continue
+		}
+		var cached cachedBucket
+		if err := json.Unmarshal(data, &cached); err != nil {
+			// ideally we should not be getting an error here
+			zap.S().Error("error unmarshalling cached data", zap.Error(err))
+			continue
+		}
+		buckets[bucketStart] = &cached
+	}
+
+	misses := findMissingTimeRanges(start, end, window, buckets)
+	if stats := common.GetQueryStatsFromContext(ctx); stats != nil {
+		missed := make([]v3.TimeRange, 0, len(misses))
+		for _, miss := range misses {
+			missed = append(missed, v3.TimeRange{Start: miss.start, End: miss.end})
+		}
+		stats.SetCacheRanges(findCachedTimeRanges(start, end, window, buckets), missed)
+	}
+	missedSeries := make([]*v3.Series, 0)
+	for _, miss := range misses {
+		series, err := fetch(miss.start, miss.end)
+		if err != nil {
+			return nil, err
+		}
+		missedSeries = mergeSerieses(missedSeries, filterSeries(series, miss.start, miss.end))
+	}
+
+	result := make([]*v3.Series, 0)
+	for bucketStart := start - (start % window); bucketStart <= end; bucketStart += window {
+		if cached, ok := buckets[bucketStart]; ok {
+			result = mergeSerieses(result, filterSeries(cached.Series, max(start, cached.Start), min(end, cached.End)))
+		}
+	}
+	result = mergeSerieses(result, filterSeries(missedSeries, start, end))
+
+	if len(misses) == 0 {
+		return result, nil
+	}
+
+	// data newer than the flux interval might still be in flux and not yet available
+	fluxBoundary := alignDown(time.Now().UnixMilli()-q.fluxInterval.Milliseconds(), stepMs) - 1
+	for bucketStart := start - (start % window); bucketStart <= end; bucketStart += window {
+		bucketEnd := bucketStart + window - 1
+		touched := false
+		for _, miss := range misses {
+			if miss.start <= bucketEnd && miss.end >= bucketStart {
+				touched = true
+				break
+			}
+		}
+		if !touched {
+			continue
+		}
+
+		coverage := cachedBucket{Start: max(start, bucketStart), End: min(end, bucketEnd)}
+		var cachedSeries []*v3.Series
+		if cached, ok := buckets[bucketStart]; ok && cached.Start <= cached.End {
+			coverage.Start = min(coverage.Start, cached.Start)
+			coverage.End = max(coverage.End, cached.End)
+			cachedSeries = filterSeries(cached.Series, cached.Start, cached.End)
+		}
+		coverage.End = min(coverage.End, fluxBoundary)
+		if coverage.Start > coverage.End {
+			continue
+		}
+		coverage.Series = filterSeries(mergeSerieses(cachedSeries, filterSeries(missedSeries, coverage.Start, coverage.End)), coverage.Start, coverage.End)
+
+		data, err := json.Marshal(coverage)
+		if err != nil {
+			zap.S().Error("error marshalling merged series", zap.Error(err))
+			continue
+		}
+		key := bucketCacheKey(hash, bucketStart)
+		if err := q.cache.Store(key, data, q.cacheTTL); err != nil {
+			zap.S().Error("error storing merged series", zap.Error(err))
+			continue
+		}
+		q.cacheIndex.add(hash, queryName, params.DashboardID, bucketStart, q.cacheTTL)
+	}
+	return result, nil
+}
+
+// cacheIndex keeps track of the cache entries written by the querier, so that they
+// can be listed and evicted through the cache admin API. The index is stored in the
+// cache with the entries, so it is shared by the query services using the same cache.
+// It is made of sets with expiring members, which the cache updates one member at a
+// time: the buckets, the query names and the dashboards of every query hash, and the
+// hashes of all the queries and of the queries of every dashboard.
+type cacheIndex struct {
+	cache cache.Cache
+}
+
+// indexEntry is the index of the buckets of a query hash
+type indexEntry struct {
+	QueryNames   []string
+	DashboardIDs []string
+	// Buckets maps the bucket start to the expiry of the bucket
+	Buckets   map[int64]time.Time
+	UpdatedAt time.Time
+}
+
+func newCacheIndex(c cache.Cache) *cacheIndex {
+	return &cacheIndex{cache: c}
+}
+
+// indexKey returns the key of the set of the buckets of the hash, or of the
+// given part of its index
+func indexKey(hash, part string) string {
+	if part == "" {
+		return "querycache-index-" + hash
+	}
+	return "querycache-index-" + hash + "-" + part
+}
+
+// hashListKey returns the key of the hashes of the queries of the dashboard, or of
+// all the queries when dashboardID is empty
+func hashListKey(dashboardID string) string {
+	if dashboardID == "" {
+		return "querycache-index"
+	}
+	return "querycache-index-dashboard-" + dashboardID
+}
+
+func (i *cacheIndex) members(key string) map[string]time.Time {
+	members, err := i.cache.SetMembers(key)
+	if err != nil {
+		zap.S().Error("error reading cache index", zap.String("key", key), zap.Error(err))
+		return map[string]time.Time{}
+	}
+	return members
+}
+
+func (i *cacheIndex) addMember(key, member string, expiresAt time.Time) {
+	if err := i.cache.AddToSet(key, member, expiresAt); err != nil {
+		zap.S().Error("error updating cache index", zap.String("key", key), zap.Error(err))
+	}
+}
+
+func (i *cacheIndex) removeMember(key, member string) {
+	if err := i.cache.RemoveFromSet(key, member); err != nil {
+		zap.S().Error("error updating cache index", zap.String("key", key), zap.Error(err))
+	}
+}
+
+func sortedMembers(members map[string]time.Time) []string {
+	values := make([]string, 0, len(members))
+	for member := range members {
+		values = append(values, member)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// entry returns the index entry of the hash with its unexpired buckets
+func (i *cacheIndex) entry(hash string) (*indexEntry, bool) {
+	entry := &indexEntry{Buckets: make(map[int64]time.Time)}
+	for member, expiresAt := range i.members(indexKey(hash, "")) {
+		bucketStart, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			continue
+		}
+		entry.Buckets[bucketStart] = expiresAt
+	}
+	if len(entry.Buckets) == 0 {
+		return nil, false
+	}
+	entry.QueryNames = sortedMembers(i.members(indexKey(hash, "queries")))
+	entry.DashboardIDs = sortedMembers(i.members(indexKey(hash, "dashboards")))
+	if data, _, err := i.cache.Retrieve(indexKey(hash, "updated"), false); err == nil && data != nil {
+		if err := json.Unmarshal(data, &entry.UpdatedAt); err != nil {
+			zap.S().Error("error unmarshalling cache index", zap.String("hash", hash), zap.Error(err))
+		}
+	}
+	return entry, true
+}
+
+// hashes returns the unexpired hashes of the list
+func (i *cacheIndex) hashes(dashboardID string) map[string]time.Time {
+	return i.members(hashListKey(dashboardID))
+}
+
+func (i *cacheIndex) add(hash, queryName, dashboardID string, bucketStart int64, ttl time.Duration) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	i.addMember(indexKey(hash, ""), strconv.FormatInt(bucketStart, 10), expiresAt)
+	i.addMember(indexKey(hash, "queries"), queryName, expiresAt)
+	if dashboardID != "" {
+		i.addMember(indexKey(hash, "dashboards"), dashboardID, expiresAt)
+	}
+	if data, err := json.Marshal(now); err == nil {
+		if err := i.cache.Store(indexKey(hash, "updated"), data, ttl); err != nil {
+			zap.S().Error("error storing cache index", zap.String("hash", hash), zap.Error(err))
+		}
+	}
+
+	// the hash is listed as long as its last bucket
+	i.addMember(hashListKey(""), hash, expiresAt)
+	for dashboardID := range i.members(indexKey(hash, "dashboards")) {
+		i.addMember(hashListKey(dashboardID), hash, expiresAt)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *cacheIndex) matches(entry *indexEntry, filter v3.QueryCacheFilter) bool {
+	if filter.QueryName != "" && !contains(entry.QueryNames, filter.QueryName) {
+		return false
+	}
+	if filter.DashboardID != "" && !contains(entry.DashboardIDs, filter.DashboardID) {
+		return false
+	}
+	return true
+}
+
+// find returns the matching index entries by hash, the entries are looked up by the
+// most selective key of the filter
+func (i *cacheIndex) find(filter v3.QueryCacheFilter) map[string]*indexEntry {
+	var hashes []string
+	if filter.QueryHash != "" {
+		hashes = []string{filter.QueryHash}
+	} else {
+		for hash := range i.hashes(filter.DashboardID) {
+			hashes = append(hashes, hash)
+		}
+	}
+
+	entries := make(map[string]*indexEntry)
+	for _, hash := range hashes {
+		if entry, ok := i.entry(hash); ok && i.matches(entry, filter) {
+			entries[hash] = entry
+		}
+	}
+	return entries
+}
+
+func (i *cacheIndex) list(filter v3.QueryCacheFilter) []*v3.QueryCacheEntry {
+	result := make([]*v3.QueryCacheEntry, 0)
+	for hash, entry := range i.find(filter) {
+		buckets := make([]int64, 0, len(entry.Buckets))
+		for bucketStart := range entry.Buckets {
+			buckets = append(buckets, bucketStart)
+		}
+		sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+		result = append(result, &v3.QueryCacheEntry{
+			QueryHash:    hash,
+			QueryNames:   entry.QueryNames,
+			DashboardIDs: entry.DashboardIDs,
+			Buckets:      buckets,
+			UpdatedAt:    entry.UpdatedAt,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].QueryHash < result[j].QueryHash })
+	return result
+}
+
+// remove drops the matching entries from the index and returns their bucket keys
+func (i *cacheIndex) remove(filter v3.QueryCacheFilter) []string {
+	var keys []string
+	for hash, entry := range i.find(filter) {
+		for bucketStart := range entry.Buckets {
+			keys = append(keys, bucketCacheKey(hash, bucketStart))
+		}
+		i.cache.BulkRemove([]string{indexKey(hash, ""), indexKey(hash, "queries"), indexKey(hash, "dashboards"), indexKey(hash, "updated")})
+		i.removeMember(hashListKey(""), hash)
+		for _, dashboardID := range entry.DashboardIDs {
+			i.removeMember(hashListKey(dashboardID), hash)
+		}
+	}
+	return keys
+}
+
+func (q *querier) ListCachedQueries(filter v3.QueryCacheFilter) []*v3.QueryCacheEntry {
+	if q.cache == nil {
+		return []*v3.QueryCacheEntry{}
+	}
+	return q.cacheIndex.list(filter)
+}
+
+func (q *querier) EvictCachedQueries(filter v3.QueryCacheFilter) int {
+	if q.cache == nil {
+		return 0
+	}
+	keys := q.cacheIndex.remove(filter)
+	if len(keys) > 0 {
+		q.cache.BulkRemove(keys)
+	}
+	return len(keys)
+}
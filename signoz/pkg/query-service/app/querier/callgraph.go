@@ -0,0 +1,75 @@
+package querier
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	tracesV3 "go.signoz.io/signoz/pkg/query-service/app/traces/v3"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.uber.org/multierr"
+)
+
+// callGraphTraceLimit is the most traces of a query merged into its call graph
+const callGraphTraceLimit = 1000
+
+// runBuilderCallGraphQueries merges the span trees of the traces of the
+// builder queries into the graphs of the calls between operations
+func (q *querier) runBuilderCallGraphQueries(ctx context.Context, params *v3.QueryRangeParamsV3, keys map[string]v3.AttributeKey) ([]*v3.Result, error, map[string]string) {
+	ch := make(chan channelResult, len(params.CompositeQuery.BuilderQueries))
+	var wg sync.WaitGroup
+
+	for name, builderQuery := range params.CompositeQuery.BuilderQueries {
+		if builderQuery.Disabled {
+			continue
+		}
+		if builderQuery.DataSource != v3.DataSourceTraces || builderQuery.AggregateOperator != v3.AggregateOperatorNoOp {
+			return nil, fmt.Errorf("call graphs are only supported for traces list queries"), map[string]string{name: builderQuery.Expression}
+		}
+
+		traceLimit := uint64(callGraphTraceLimit)
+		if builderQuery.Limit > 0 && builderQuery.Limit < traceLimit {
+			traceLimit = builderQuery.Limit
+		}
+		query, err := tracesV3.PrepareCallGraphQuery(params.Start, params.End, builderQuery, keys, traceLimit)
+		if err != nil {
+			return nil, err, map[string]string{name: err.Error()}
+		}
+		q.recordQuery(query)
+
+		wg.Add(1)
+		go func(name, query string) {
+			defer wg.Done()
+			ctx, stats := withQueryStats(ctx, params)
+			edges, err := q.reader.GetCallGraphV3(ctx, query)
+			if err != nil {
+				ch <- channelResult{Err: fmt.Errorf("error in query-%s: %v", name, err), Name: name, Query: query}
+				return
+			}
+			ch <- channelResult{CallGraph: edges, Name: name, Query: query, Stats: stats}
+		}(name, query)
+	}
+
+	wg.Wait()
+	close(ch)
+
+	var errs []error
+	errQuriesByName := make(map[string]string)
+	res := make([]*v3.Result, 0)
+	for r := range ch {
+		if r.Err != nil {
+			errs = append(errs, r.Err)
+			errQuriesByName[r.Name] = r.Query
+			continue
+		}
+		res = append(res, &v3.Result{
+			QueryName: r.Name,
+			CallGraph: r.CallGraph,
+			Stats:     r.Stats,
+		})
+	}
+	if len(errs) != 0 {
+		return nil, fmt.Errorf("encountered multiple errors: %s", multierr.Combine(errs...)), errQuriesByName
+	}
+	return res, nil, nil
+}
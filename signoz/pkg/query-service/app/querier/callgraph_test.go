@@ -0,0 +1,75 @@
+package querier
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/featureManager"
+	"go.signoz.io/signoz/pkg/query-service/interfaces"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+// callGraphReader returns the edges for every call graph query
+type callGraphReader struct {
+	interfaces.Reader
+	queries []string
+	edges   []*v3.CallGraphEdge
+}
+
+func (r *callGraphReader) GetCallGraphV3(ctx context.Context, query string) ([]*v3.CallGraphEdge, error) {
+	r.queries = append(r.queries, query)
+	return r.edges, nil
+}
+
+func TestRunBuilderCallGraphQueries(t *testing.T) {
+	reader := &callGraphReader{edges: []*v3.CallGraphEdge{{
+		ParentServiceName: "customer",
+		ParentName:        "HTTP GET /customer",
+		ServiceName:       "mysql",
+		Name:              "SQL SELECT",
+		Calls:             200,
+		Traces:            10,
+		CallsPerTrace:     20,
+		FanOut:            20,
+		MaxFanOut:         25,
+	}}}
+	q := NewQuerier(QuerierOptions{
+		Reader:        reader,
+		FeatureLookup: featureManager.StartManager(),
+	})
+
+	params := &v3.QueryRangeParamsV3{
+		Start: 1675115596722,
+		End:   1675115596722 + 120*60*1000,
+		CompositeQuery: &v3.CompositeQuery{
+			QueryType: v3.QueryTypeBuilder,
+			PanelType: v3.PanelTypeCallGraph,
+			BuilderQueries: map[string]*v3.BuilderQuery{
+				"A": {
+					QueryName:         "A",
+					StepInterval:      60,
+					DataSource:        v3.DataSourceTraces,
+					AggregateOperator: v3.AggregateOperatorNoOp,
+					Expression:        "A",
+					Filters:           &v3.FilterSet{Operator: "AND"},
+					Limit:             50,
+				},
+			},
+		},
+	}
+
+	results, err, _ := q.QueryRange(context.Background(), params, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "A", results[0].QueryName)
+	assert.Equal(t, reader.edges, results[0].CallGraph)
+	require.Len(t, reader.queries, 1)
+	assert.Contains(t, reader.queries[0], "LIMIT 50")
+
+	// the call graph is built from the traces of list queries only
+	params.CompositeQuery.BuilderQueries["A"].AggregateOperator = v3.AggregateOperatorCount
+	_, err, _ = q.QueryRange(context.Background(), params, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,272 @@
+package querier
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"time"
+
+	logsV3 "go.signoz.io/signoz/pkg/query-service/app/logs/v3"
+	tracesV3 "go.signoz.io/signoz/pkg/query-service/app/traces/v3"
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+// exportPageSize is the number of rows read by each query of an export
+var exportPageSize = 10000
+
+// exportQuery returns the list query of the request to export
+func exportQuery(params *v3.QueryRangeParamsV3) (*v3.BuilderQuery, error) {
+	if params.CompositeQuery == nil || params.CompositeQuery.QueryType != v3.QueryTypeBuilder ||
+		params.CompositeQuery.PanelType != v3.PanelTypeList {
+		return nil, fmt.Errorf("only builder queries of list panels can be exported")
+	}
+
+	var query *v3.BuilderQuery
+	for _, q := range params.CompositeQuery.BuilderQueries {
+		if q.Disabled {
+			continue
+		}
+		if query != nil {
+			return nil, fmt.Errorf("only a single query can be exported")
+		}
+		query = q
+	}
+	if query == nil {
+		return nil, fmt.Errorf("no query to export")
+	}
+	if query.DataSource != v3.DataSourceLogs && query.DataSource != v3.DataSourceTraces {
+		return nil, fmt.Errorf("only logs and traces can be exported")
+	}
+	if query.AggregateOperator != v3.AggregateOperatorNoOp {
+		return nil, fmt.Errorf("only list queries without aggregation can be exported")
+	}
+	// the pages are read by timestamp and id
+	if _, ok := query.ListOrder(); !ok {
+		return nil, fmt.Errorf("only list queries ordered by timestamp can be exported")
+	}
+	return query, nil
+}
+
+// ExportList streams the rows of the list query of the request to fn. The rows
+// are read in pages ordered by timestamp and id, every page starting after the
+// last row of the previous one, so that the pages do not get slower the deeper
+// the export goes and the rows arriving meanwhile do not shift them.
+func (q *querier) ExportList(ctx context.Context, params *v3.QueryRangeParamsV3, keys map[string]v3.AttributeKey, fn func(*v3.Row) error) error {
+	query, err := exportQuery(params)
+	if err != nil {
+		return err
+	}
+
+	order, _ := query.ListOrder()
+	// the first page starts at the edge of the time range
+	after := &v3.ListPosition{Timestamp: uint64(params.End+1) * uint64(time.Millisecond), Order: order}
+	if order == "asc" {
+		after.Timestamp = uint64(params.Start) * uint64(time.Millisecond)
+	}
+
+	var exported uint64
+	for {
+		pageSize := uint64(exportPageSize)
+		if query.Limit > 0 && query.Limit-exported < pageSize {
+			pageSize = query.Limit - exported
+		}
+
+		page := *query
+		page.Limit = 0
+		page.Offset = 0
+		page.PageSize = pageSize
+		page.OrderBy = []v3.OrderBy{{ColumnName: constants.TIMESTAMP, Order: order}}
+		page.After = after
+
+		var sql string
+		if query.DataSource == v3.DataSourceLogs {
+			sql, err = logsV3.PrepareLogsQuery(params.Start, params.End, v3.QueryTypeBuilder, v3.PanelTypeList, &page, logsV3.Options{})
+		} else {
+			// the trace lists are not paged, the limit is the size of the page
+			page.Limit = pageSize
+			sql, err = tracesV3.PrepareTracesQuery(params.Start, params.End, v3.PanelTypeList, &page, keys, tracesV3.Options{})
+		}
+		if err != nil {
+			return err
+		}
+
+		var count uint64
+		var last *v3.Row
+		q.recordQuery(sql)
+		err = q.reader.StreamListResultV3(ctx, sql, func(row *v3.Row) error {
+			count++
+			last = row
+			return fn(row)
+		})
+		if err != nil {
+			return err
+		}
+
+		exported += count
+		if count < pageSize || (query.Limit > 0 && exported >= query.Limit) {
+			return nil
+		}
+		if after, err = rowPosition(query.DataSource, last, order); err != nil {
+			return err
+		}
+	}
+}
+
+// ExportWriter writes the exported rows as newline delimited json or csv
+type ExportWriter struct {
+	format  v3.ExportFormat
+	columns []v3.AttributeKey
+	w       io.Writer
+	csv     *csv.Writer
+	rows    int
+}
+
+// NewExportWriter returns a writer of the rows with the given columns, all the
+// columns of the rows are written when none is given
+func NewExportWriter(w io.Writer, format v3.ExportFormat, columns []v3.AttributeKey) *ExportWriter {
+	ew := &ExportWriter{format: format, columns: columns, w: w}
+	if format == v3.ExportFormatCSV {
+		ew.csv = csv.NewWriter(w)
+	}
+	return ew
+}
+
+// Rows returns the number of rows written
+func (ew *ExportWriter) Rows() int {
+	return ew.rows
+}
+
+// rowValue returns the value of the column, the attributes of logs are
+// looked up in the attribute maps
+func rowValue(row *v3.Row, key v3.AttributeKey) interface{} {
+	if key.Key == constants.TIMESTAMP {
+		return row.Timestamp
+	}
+	if v, ok := row.Data[key.Key]; ok {
+		return indirect(v)
+	}
+
+	maps := []string{"attributes_string", "attributes_int64", "attributes_float64", "attributes_bool", "resources_string"}
+	if key.Type == v3.AttributeKeyTypeResource {
+		maps = []string{"resources_string"}
+	}
+	for _, name := range maps {
+		m := reflect.ValueOf(indirect(row.Data[name]))
+		if m.Kind() != reflect.Map {
+			continue
+		}
+		if v := m.MapIndex(reflect.ValueOf(key.Key)); v.IsValid() {
+			return v.Interface()
+		}
+	}
+	return nil
+}
+
+func indirect(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		return rv.Elem().Interface()
+	}
+	return v
+}
+
+// columnNames returns the names of the columns written, taken from the first
+// row when no column is selected
+func (ew *ExportWriter) columnNames(row *v3.Row) []string {
+	if len(ew.columns) == 0 && row != nil {
+		for name := range row.Data {
+			ew.columns = append(ew.columns, v3.AttributeKey{Key: name})
+		}
+		sort.Slice(ew.columns, func(i, j int) bool {
+			return ew.columns[i].Key < ew.columns[j].Key
+		})
+	}
+	names := []string{constants.TIMESTAMP}
+	for _, column := range ew.columns {
+		if column.Key != constants.TIMESTAMP {
+			names = append(names, column.Key)
+		}
+	}
+	return names
+}
+
+func csvValue(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprintf("%v", x)
+	default:
+		b, err := json.Marshal(x)
+		if err != nil {
+			return fmt.Sprintf("%v", x)
+		}
+		return string(b)
+	}
+}
+
+// Write writes the row
+func (ew *ExportWriter) Write(row *v3.Row) error {
+	names := ew.columnNames(row)
+	if ew.rows == 0 && ew.format == v3.ExportFormatCSV {
+		if err := ew.csv.Write(names); err != nil {
+			return err
+		}
+	}
+	ew.rows++
+
+	switch ew.format {
+	case v3.ExportFormatCSV:
+		record := make([]string, 0, len(names))
+		record = append(record, csvValue(row.Timestamp))
+		for _, name := range names[1:] {
+			record = append(record, csvValue(rowValue(row, v3.AttributeKey{Key: name, Type: ew.columnType(name)})))
+		}
+		return ew.csv.Write(record)
+	default:
+		line := make(map[string]interface{}, len(names))
+		line[constants.TIMESTAMP] = row.Timestamp
+		for _, name := range names[1:] {
+			line[name] = rowValue(row, v3.AttributeKey{Key: name, Type: ew.columnType(name)})
+		}
+		b, err := json.Marshal(line)
+		if err != nil {
+			return err
+		}
+		_, err = ew.w.Write(append(b, '\n'))
+		return err
+	}
+}
+
+func (ew *ExportWriter) columnType(name string) v3.AttributeKeyType {
+	for _, column := range ew.columns {
+		if column.Key == name {
+			return column.Type
+		}
+	}
+	return ""
+}
+
+// Flush writes the buffered rows, the csv header is written when there was
+// no row to write
+func (ew *ExportWriter) Flush() error {
+	if ew.format != v3.ExportFormatCSV {
+		return nil
+	}
+	if ew.rows == 0 && len(ew.columns) > 0 {
+		if err := ew.csv.Write(ew.columnNames(nil)); err != nil {
+			return err
+		}
+	}
+	ew.csv.Flush()
+	return ew.csv.Error()
+}
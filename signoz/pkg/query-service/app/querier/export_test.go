@@ -0,0 +1,162 @@
+package querier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/featureManager"
+	"go.signoz.io/signoz/pkg/query-service/interfaces"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+// pagesReader returns the rows of every query from the rows left, a page at
+// a time
+type pagesReader struct {
+	interfaces.Reader
+	rows []*v3.Row
+}
+
+func (r *pagesReader) StreamListResultV3(ctx context.Context, query string, fn func(*v3.Row) error) error {
+	var limit int
+	fmt.Sscanf(query[strings.LastIndex(query, "LIMIT"):], "LIMIT %d", &limit)
+	for limit > 0 && len(r.rows) > 0 {
+		if err := fn(r.rows[0]); err != nil {
+			return err
+		}
+		r.rows = r.rows[1:]
+		limit--
+	}
+	return nil
+}
+
+func logRow(ts int64, id string, method string) *v3.Row {
+	attributes := map[string]string{"method": method}
+	return &v3.Row{
+		Timestamp: time.Unix(0, ts).UTC(),
+		Data:      map[string]interface{}{"id": &id, "attributes_string": &attributes},
+	}
+}
+
+func TestExportList(t *testing.T) {
+	defer func(size int) { exportPageSize = size }(exportPageSize)
+	exportPageSize = 2
+
+	rows := []*v3.Row{
+		logRow(5000, "e", "GET"),
+		logRow(4000, "d", "GET"),
+		logRow(3000, "c", "POST"),
+		logRow(3000, "b", "GET"),
+		logRow(1000, "a", "PUT"),
+	}
+	query := &v3.BuilderQuery{
+		QueryName:         "A",
+		StepInterval:      60,
+		DataSource:        v3.DataSourceLogs,
+		AggregateOperator: v3.AggregateOperatorNoOp,
+		Expression:        "A",
+		Filters:           &v3.FilterSet{Operator: "AND"},
+	}
+	params := &v3.QueryRangeParamsV3{
+		Start: 1675115596722,
+		End:   1675115596722 + 120*60*1000,
+		CompositeQuery: &v3.CompositeQuery{
+			QueryType:      v3.QueryTypeBuilder,
+			PanelType:      v3.PanelTypeList,
+			BuilderQueries: map[string]*v3.BuilderQuery{"A": query},
+		},
+	}
+
+	q := NewQuerier(QuerierOptions{
+		Reader:        &pagesReader{rows: rows},
+		FeatureLookup: featureManager.StartManager(),
+	})
+	var exported []*v3.Row
+	err := q.ExportList(context.Background(), params, nil, func(row *v3.Row) error {
+		exported = append(exported, row)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, rows, exported)
+
+	queries := q.QueriesExecuted()
+	require.Len(t, queries, 3)
+	assert.Contains(t, queries[0], fmt.Sprintf("(timestamp, id) < (%d, '')", (params.End+1)*1000000))
+	assert.Contains(t, queries[1], "(timestamp, id) < (4000, 'd') order by timestamp DESC, id DESC LIMIT 2")
+	assert.Contains(t, queries[2], "(timestamp, id) < (3000, 'b')")
+
+	// the limit of the query caps the export
+	query.Limit = 3
+	q = NewQuerier(QuerierOptions{
+		Reader:        &pagesReader{rows: rows},
+		FeatureLookup: featureManager.StartManager(),
+	})
+	exported = nil
+	err = q.ExportList(context.Background(), params, nil, func(row *v3.Row) error {
+		exported = append(exported, row)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, rows[:3], exported)
+	require.Len(t, q.QueriesExecuted(), 2)
+	assert.True(t, strings.HasSuffix(q.QueriesExecuted()[1], "LIMIT 1"))
+
+	// the direction of the order is case insensitive
+	query.OrderBy = []v3.OrderBy{{ColumnName: "timestamp", Order: "ASC"}}
+	q = NewQuerier(QuerierOptions{
+		Reader:        &pagesReader{rows: rows},
+		FeatureLookup: featureManager.StartManager(),
+	})
+	err = q.ExportList(context.Background(), params, nil, func(row *v3.Row) error { return nil })
+	require.NoError(t, err)
+	assert.Contains(t, q.QueriesExecuted()[0], fmt.Sprintf("(timestamp, id) > (%d, '')", params.Start*1000000))
+
+	// the lists ordered by other columns are not exported
+	query.OrderBy = []v3.OrderBy{{ColumnName: "method", Order: "asc"}}
+	err = q.ExportList(context.Background(), params, nil, func(row *v3.Row) error { return nil })
+	assert.Error(t, err)
+
+	// aggregations are not exported
+	query.OrderBy = nil
+	query.AggregateOperator = v3.AggregateOperatorCount
+	err = q.ExportList(context.Background(), params, nil, func(row *v3.Row) error { return nil })
+	assert.Error(t, err)
+}
+
+func TestExportWriter(t *testing.T) {
+	rows := []*v3.Row{
+		logRow(1000000000, "a", "GET"),
+		logRow(2000000000, "b", "POST, PUT"),
+	}
+	columns := []v3.AttributeKey{{Key: "id"}, {Key: "method", Type: v3.AttributeKeyTypeTag}}
+
+	var buf bytes.Buffer
+	w := NewExportWriter(&buf, v3.ExportFormatCSV, columns)
+	for _, row := range rows {
+		require.NoError(t, w.Write(row))
+	}
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "timestamp,id,method\n"+
+		"1970-01-01T00:00:01Z,a,GET\n"+
+		"1970-01-01T00:00:02Z,b,\"POST, PUT\"\n", buf.String())
+
+	buf.Reset()
+	w = NewExportWriter(&buf, v3.ExportFormatNDJSON, columns)
+	for _, row := range rows {
+		require.NoError(t, w.Write(row))
+	}
+	require.NoError(t, w.Flush())
+	assert.Equal(t, `{"id":"a","method":"GET","timestamp":"1970-01-01T00:00:01Z"}`+"\n"+
+		`{"id":"b","method":"POST, PUT","timestamp":"1970-01-01T00:00:02Z"}`+"\n", buf.String())
+
+	// the header is written without rows
+	buf.Reset()
+	w = NewExportWriter(&buf, v3.ExportFormatCSV, columns)
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "timestamp,id,method\n", buf.String())
+}
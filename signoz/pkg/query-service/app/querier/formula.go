@@ -0,0 +1,206 @@
+package querier
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/SigNoz/govaluate"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func unaryFunc(name string, f func(float64) float64) govaluate.ExpressionFunction {
+	return func(args ...interface{}) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s takes one argument", name)
+		}
+		v, ok := args[0].(float64)
+		if !ok {
+			return nil, fmt.Errorf("%s takes a number", name)
+		}
+		return f(v), nil
+	}
+}
+
+// formulaFuncs implements the functions of queryBuilder.SupportedFunctions for
+// the formulas evaluated over series
+var formulaFuncs = map[string]govaluate.ExpressionFunction{
+	"exp":     unaryFunc("exp", math.Exp),
+	"log":     unaryFunc("log", math.Log),
+	"ln":      unaryFunc("ln", math.Log),
+	"exp2":    unaryFunc("exp2", math.Exp2),
+	"log2":    unaryFunc("log2", math.Log2),
+	"exp10":   unaryFunc("exp10", func(v float64) float64 { return math.Pow(10, v) }),
+	"log10":   unaryFunc("log10", math.Log10),
+	"sqrt":    unaryFunc("sqrt", math.Sqrt),
+	"cbrt":    unaryFunc("cbrt", math.Cbrt),
+	"erf":     unaryFunc("erf", math.Erf),
+	"erfc":    unaryFunc("erfc", math.Erfc),
+	"lgamma":  unaryFunc("lgamma", func(v float64) float64 { r, _ := math.Lgamma(v); return r }),
+	"tgamma":  unaryFunc("tgamma", math.Gamma),
+	"sin":     unaryFunc("sin", math.Sin),
+	"cos":     unaryFunc("cos", math.Cos),
+	"tan":     unaryFunc("tan", math.Tan),
+	"asin":    unaryFunc("asin", math.Asin),
+	"acos":    unaryFunc("acos", math.Acos),
+	"atan":    unaryFunc("atan", math.Atan),
+	"degrees": unaryFunc("degrees", func(v float64) float64 { return v * 180 / math.Pi }),
+	"radians": unaryFunc("radians", func(v float64) float64 { return v * math.Pi / 180 }),
+	"now": func(args ...interface{}) (interface{}, error) {
+		return float64(time.Now().Unix()), nil
+	},
+	// the operands are numbers already, there is no date to convert
+	"toUnixTimestamp": unaryFunc("toUnixTimestamp", func(v float64) float64 { return v }),
+}
+
+// formulaRow is a combination of the series of the operands, with matching
+// labels, and their values at the timestamps they all have a point at
+type formulaRow struct {
+	labels map[string]string
+	values map[int64]map[string]interface{}
+}
+
+// joinOperand combines the rows with the series of the operand that agree
+// with them on the labels they have in common, like the inner join of the
+// operand queries would
+func joinOperand(rows []formulaRow, operand string, series []*v3.Series, formula *v3.BuilderQuery) []formulaRow {
+	joined := []formulaRow{}
+	for _, s := range series {
+		lbls := make(map[string]string, len(s.Labels))
+		for k, v := range s.Labels {
+			lbls[formula.OperandLabel(operand, k)] = v
+		}
+
+		if rows == nil {
+			row := formulaRow{labels: lbls, values: make(map[int64]map[string]interface{}, len(s.Points))}
+			for _, p := range s.Points {
+				row.values[p.Timestamp] = map[string]interface{}{operand: p.Value}
+			}
+			joined = append(joined, row)
+			continue
+		}
+
+		for _, row := range rows {
+			matches := true
+			for k, v := range lbls {
+				if rv, ok := row.labels[k]; ok && rv != v {
+					matches = false
+					break
+				}
+			}
+			if !matches {
+				continue
+			}
+
+			merged := formulaRow{labels: make(map[string]string, len(row.labels)+len(lbls)), values: make(map[int64]map[string]interface{})}
+			for k, v := range row.labels {
+				merged.labels[k] = v
+			}
+			for k, v := range lbls {
+				merged.labels[k] = v
+			}
+			for _, p := range s.Points {
+				values, ok := row.values[p.Timestamp]
+				if !ok {
+					continue
+				}
+				combined := make(map[string]interface{}, len(values)+1)
+				for k, v := range values {
+					combined[k] = v
+				}
+				combined[operand] = p.Value
+				merged.values[p.Timestamp] = combined
+			}
+			joined = append(joined, merged)
+		}
+	}
+	return joined
+}
+
+// evaluateFormula evaluates the formula at every timestamp of the series of
+// its operands that match each other
+func evaluateFormula(formula *v3.BuilderQuery, operands []string, seriesByName map[string][]*v3.Series) ([]*v3.Series, error) {
+	expression, err := govaluate.NewEvaluableExpressionWithFunctions(formula.Expression, formulaFuncs)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []formulaRow
+	for _, operand := range operands {
+		rows = joinOperand(rows, operand, seriesByName[operand], formula)
+	}
+
+	result := make([]*v3.Series, 0, len(rows))
+	for _, row := range rows {
+		series := &v3.Series{Labels: row.labels, Points: make([]v3.Point, 0, len(row.values))}
+		for ts, values := range row.values {
+			v, err := expression.Evaluate(values)
+			if err != nil {
+				return nil, err
+			}
+			value, ok := v.(float64)
+			// the points the formula is not defined at are skipped
+			if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+			keep, err := matchesHaving(formula.Having, value)
+			if err != nil {
+				return nil, err
+			}
+			if keep {
+				series.Points = append(series.Points, v3.Point{Timestamp: ts, Value: value})
+			}
+		}
+		if len(series.Points) == 0 {
+			continue
+		}
+		series.SortPoints()
+		names := make([]string, 0, len(series.Labels))
+		for k := range series.Labels {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			series.LabelsArray = append(series.LabelsArray, map[string]string{k: series.Labels[k]})
+		}
+		result = append(result, series)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return labelsToString(result[i].Labels) < labelsToString(result[j].Labels)
+	})
+	return result, nil
+}
+
+// matchesHaving tells if the value of the formula satisfies its having conditions
+func matchesHaving(having []v3.Having, value float64) (bool, error) {
+	for _, h := range having {
+		target, err := strconv.ParseFloat(fmt.Sprintf("%v", h.Value), 64)
+		if err != nil {
+			return false, fmt.Errorf("having value %v is not a number", h.Value)
+		}
+		var ok bool
+		switch h.Operator {
+		case "=":
+			ok = value == target
+		case "!=":
+			ok = value != target
+		case ">":
+			ok = value > target
+		case ">=":
+			ok = value >= target
+		case "<":
+			ok = value < target
+		case "<=":
+			ok = value <= target
+		default:
+			return false, fmt.Errorf("unsupported having operator %s", h.Operator)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,104 @@
+package querier
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/app/queryBuilder"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func TestEvaluateFormula(t *testing.T) {
+	seriesByName := map[string][]*v3.Series{
+		// error logs by service
+		"A": {
+			{Labels: map[string]string{"service_name": "frontend"}, Points: pointsOf(0, 60000, 2, 4, 6)},
+			{Labels: map[string]string{"service_name": "cart"}, Points: pointsOf(0, 60000, 1)},
+		},
+		// request spans by service, named differently
+		"B": {
+			{Labels: map[string]string{"serviceName": "frontend"}, Points: pointsOf(60000, 60000, 10, 0)},
+			{Labels: map[string]string{"serviceName": "cart"}, Points: pointsOf(0, 60000, 5)},
+			{Labels: map[string]string{"serviceName": "redis"}, Points: pointsOf(0, 60000, 5)},
+		},
+		// the total requests
+		"C": {
+			{Labels: map[string]string{}, Points: pointsOf(0, 60000, 100, 200, 300)},
+		},
+	}
+
+	formula := &v3.BuilderQuery{
+		QueryName:    "F1",
+		Expression:   "A / B",
+		LabelMapping: map[string]map[string]string{"B": {"serviceName": "service_name"}},
+	}
+	result, err := evaluateFormula(formula, []string{"A", "B"}, seriesByName)
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+	assert.Equal(t, map[string]string{"service_name": "cart"}, result[0].Labels)
+	assert.Equal(t, pointsOf(0, 60000, 0.2), result[0].Points)
+	// the division by zero at the last point is skipped
+	assert.Equal(t, map[string]string{"service_name": "frontend"}, result[1].Labels)
+	assert.Equal(t, pointsOf(60000, 60000, 0.4), result[1].Points)
+
+	// the series without labels match every series
+	formula = &v3.BuilderQuery{
+		QueryName:  "F2",
+		Expression: "sqrt(A * C)",
+		Having:     []v3.Having{{Operator: ">", Value: 10}},
+	}
+	result, err = evaluateFormula(formula, []string{"A", "C"}, seriesByName)
+	require.NoError(t, err)
+	// sqrt(1 * 100) of cart is not above 10
+	require.Len(t, result, 1)
+	assert.Equal(t, map[string]string{"service_name": "frontend"}, result[0].Labels)
+	assert.InDeltaSlice(t, []float64{14.142135, 28.284271, 42.426406}, valuesOf(result[0].Points), 1e-6)
+}
+
+func TestRunNestedFormulas(t *testing.T) {
+	metricQuery := func(name string) *v3.BuilderQuery {
+		return &v3.BuilderQuery{
+			QueryName:          name,
+			StepInterval:       60,
+			DataSource:         v3.DataSourceMetrics,
+			AggregateOperator:  v3.AggregateOperatorSumRate,
+			AggregateAttribute: v3.AttributeKey{Key: "signoz_calls_total"},
+			Expression:         name,
+		}
+	}
+	params := &v3.QueryRangeParamsV3{
+		Start: 0,
+		End:   120000,
+		CompositeQuery: &v3.CompositeQuery{
+			QueryType: v3.QueryTypeBuilder,
+			PanelType: v3.PanelTypeGraph,
+			BuilderQueries: map[string]*v3.BuilderQuery{
+				"A": metricQuery("A"),
+				"B": metricQuery("B"),
+				// F2 uses F1, which is disabled and evaluated in the querier too
+				"F1": {QueryName: "F1", StepInterval: 60, Expression: "A + B", EvaluateIn: v3.FormulaEvaluationQuerier, Disabled: true},
+				"F2": {QueryName: "F2", StepInterval: 60, Expression: "F1 * 2", EvaluateIn: v3.FormulaEvaluationQuerier},
+			},
+		},
+	}
+	q := NewQuerier(QuerierOptions{
+		KeyGenerator:   queryBuilder.NewKeyGenerator(),
+		TestingMode:    true,
+		ReturnedSeries: []*v3.Series{{Labels: map[string]string{}, Points: pointsOf(0, 60000, 1, 2, 3)}},
+	}).(*querier)
+
+	results, err, errByName := q.runBuilderQueries(context.Background(), params, nil)
+	require.NoError(t, err)
+	require.Empty(t, errByName)
+
+	byName := make(map[string]*v3.Result)
+	for _, result := range results {
+		byName[result.QueryName] = result
+	}
+	require.Len(t, byName, 3)
+	assert.NotContains(t, byName, "F1")
+	require.Len(t, byName["F2"].Series, 1)
+	assert.Equal(t, []float64{4, 8, 12}, valuesOf(byName["F2"].Series[0].Points))
+}
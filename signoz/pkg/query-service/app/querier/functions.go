@@ -0,0 +1,279 @@
+package querier
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+// applyFunctions runs the functions of the query in order on its series. The
+// series are expected to cover the range from start to end (in ms) at the step
+// interval (in seconds), which the fill functions fill the gaps of on graphs.
+func applyFunctions(functions []v3.Function, series []*v3.Series, panelType v3.PanelType, start, end, step int64) ([]*v3.Series, error) {
+	if len(functions) == 0 {
+		return series, nil
+	}
+
+	// the series may be shared with the cache, the functions work on copies
+	copied := make([]*v3.Series, 0, len(series))
+	for _, s := range series {
+		c := *s
+		c.Points = append([]v3.Point(nil), s.Points...)
+		c.SortPoints()
+		copied = append(copied, &c)
+	}
+	series = copied
+
+	for idx := range functions {
+		function := &functions[idx]
+		// the series of the other panels have a single point
+		if (function.Name == v3.FunctionNameFillZero || function.Name == v3.FunctionNameFillPrevious) && panelType != v3.PanelTypeGraph {
+			continue
+		}
+		var err error
+		switch function.Name {
+		case v3.FunctionNameTopK, v3.FunctionNameBottomK:
+			series, err = topKSeries(function, series)
+		default:
+			for _, s := range series {
+				if s.Points, err = applyFunction(function, s.Points, start, end, step); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return series, nil
+}
+
+// applyFunction runs a function that works on every series by itself
+func applyFunction(function *v3.Function, points []v3.Point, start, end, step int64) ([]v3.Point, error) {
+	switch function.Name {
+	case v3.FunctionNameMovingAvg:
+		window, err := function.NumberArg(0)
+		if err != nil {
+			return nil, err
+		}
+		return movingAvg(points, int(window)), nil
+	case v3.FunctionNameEWMA:
+		alpha, err := function.NumberArg(0)
+		if err != nil {
+			return nil, err
+		}
+		return ewma(points, alpha), nil
+	case v3.FunctionNameCumSum:
+		return cumSum(points), nil
+	case v3.FunctionNameClampMin:
+		min, err := function.NumberArg(0)
+		if err != nil {
+			return nil, err
+		}
+		return mapValues(points, func(v float64) float64 { return math.Max(v, min) }), nil
+	case v3.FunctionNameClampMax:
+		max, err := function.NumberArg(0)
+		if err != nil {
+			return nil, err
+		}
+		return mapValues(points, func(v float64) float64 { return math.Min(v, max) }), nil
+	case v3.FunctionNameAbsolute:
+		return mapValues(points, math.Abs), nil
+	case v3.FunctionNameDerivative:
+		return derivative(points), nil
+	case v3.FunctionNameFillZero:
+		return fillGaps(points, start, end, step, false), nil
+	case v3.FunctionNameFillPrevious:
+		return fillGaps(points, start, end, step, true), nil
+	case v3.FunctionNameTimeShift:
+		shift, err := function.NumberArg(0)
+		if err != nil {
+			return nil, err
+		}
+		return timeShift(points, int64(shift*1000)), nil
+	default:
+		return nil, fmt.Errorf("invalid function: %s", function.Name)
+	}
+}
+
+func mapValues(points []v3.Point, f func(float64) float64) []v3.Point {
+	for idx := range points {
+		points[idx].Value = f(points[idx].Value)
+	}
+	return points
+}
+
+// movingAvg replaces every point with the average of the window of points
+// ending with it
+func movingAvg(points []v3.Point, window int) []v3.Point {
+	result := make([]v3.Point, len(points))
+	var sum float64
+	for idx := range points {
+		sum += points[idx].Value
+		if idx >= window {
+			sum -= points[idx-window].Value
+		}
+		n := idx + 1
+		if n > window {
+			n = window
+		}
+		result[idx] = v3.Point{Timestamp: points[idx].Timestamp, Value: sum / float64(n)}
+	}
+	return result
+}
+
+// ewma smooths the points with an exponentially weighted moving average
+func ewma(points []v3.Point, alpha float64) []v3.Point {
+	for idx := range points {
+		if idx > 0 {
+			points[idx].Value = alpha*points[idx].Value + (1-alpha)*points[idx-1].Value
+		}
+	}
+	return points
+}
+
+func cumSum(points []v3.Point) []v3.Point {
+	for idx := range points {
+		if idx > 0 {
+			points[idx].Value += points[idx-1].Value
+		}
+	}
+	return points
+}
+
+// derivative returns the per second change between consecutive points, the
+// first point has no previous point and is dropped
+func derivative(points []v3.Point) []v3.Point {
+	if len(points) < 2 {
+		return []v3.Point{}
+	}
+	result := make([]v3.Point, 0, len(points)-1)
+	for idx := 1; idx < len(points); idx++ {
+		seconds := float64(points[idx].Timestamp-points[idx-1].Timestamp) / 1000
+		if seconds <= 0 {
+			continue
+		}
+		result = append(result, v3.Point{
+			Timestamp: points[idx].Timestamp,
+			Value:     (points[idx].Value - points[idx-1].Value) / seconds,
+		})
+	}
+	return result
+}
+
+// fillGaps adds a point at every step from start to end that has none, with
+// zero or with the value of the previous point. There is no previous value
+// before the first point, those gaps are left as they are.
+func fillGaps(points []v3.Point, start, end, step int64, previous bool) []v3.Point {
+	stepMs := step * 1000
+	if stepMs <= 0 {
+		return points
+	}
+	start = start - (start % stepMs)
+	end = end - (end % stepMs)
+
+	existing := make(map[int64]float64, len(points))
+	for _, p := range points {
+		existing[p.Timestamp] = p.Value
+	}
+
+	result := make([]v3.Point, 0, (end-start)/stepMs+1)
+	var last float64
+	var seen bool
+	idx := 0
+	for ts := start; ts <= end; ts += stepMs {
+		// keep the points that are not aligned with the steps
+		for idx < len(points) && points[idx].Timestamp < ts {
+			result = append(result, points[idx])
+			last, seen = points[idx].Value, true
+			idx++
+		}
+		if v, ok := existing[ts]; ok {
+			result = append(result, v3.Point{Timestamp: ts, Value: v})
+			last, seen = v, true
+			idx++
+			continue
+		}
+		if !previous {
+			result = append(result, v3.Point{Timestamp: ts, Value: 0})
+		} else if seen {
+			result = append(result, v3.Point{Timestamp: ts, Value: last})
+		}
+	}
+	return append(result, points[idx:]...)
+}
+
+func timeShift(points []v3.Point, shift int64) []v3.Point {
+	for idx := range points {
+		points[idx].Timestamp += shift
+	}
+	return points
+}
+
+// reduceSeries reduces the points of the series to a single value
+func reduceSeries(points []v3.Point, reduceTo v3.ReduceToOperator) float64 {
+	if len(points) == 0 {
+		return math.NaN()
+	}
+	switch reduceTo {
+	case v3.ReduceToOperatorLast:
+		return points[len(points)-1].Value
+	case v3.ReduceToOperatorMin:
+		min := points[0].Value
+		for _, p := range points {
+			min = math.Min(min, p.Value)
+		}
+		return min
+	case v3.ReduceToOperatorMax:
+		max := points[0].Value
+		for _, p := range points {
+			max = math.Max(max, p.Value)
+		}
+		return max
+	default:
+		var sum float64
+		for _, p := range points {
+			sum += p.Value
+		}
+		if reduceTo == v3.ReduceToOperatorSum {
+			return sum
+		}
+		return sum / float64(len(points))
+	}
+}
+
+// topKSeries keeps the k series with the highest (or lowest for bottomK)
+// reduced values. The series without points are ranked last.
+func topKSeries(function *v3.Function, series []*v3.Series) ([]*v3.Series, error) {
+	k, err := function.NumberArg(0)
+	if err != nil {
+		return nil, err
+	}
+	reduceTo := v3.ReduceToOperatorAvg
+	if len(function.Args) > 1 {
+		reduceTo = v3.ReduceToOperator(fmt.Sprintf("%v", function.Args[1]))
+	}
+
+	reduced := make(map[*v3.Series]float64, len(series))
+	for _, s := range series {
+		reduced[s] = reduceSeries(s.Points, reduceTo)
+	}
+	bottom := function.Name == v3.FunctionNameBottomK
+	sort.SliceStable(series, func(i, j int) bool {
+		vi, vj := reduced[series[i]], reduced[series[j]]
+		if math.IsNaN(vi) || math.IsNaN(vj) {
+			return !math.IsNaN(vi)
+		}
+		if bottom {
+			return vi < vj
+		}
+		return vi > vj
+	})
+
+	if int(k) < len(series) {
+		series = series[:int(k)]
+	}
+	return series, nil
+}
@@ -0,0 +1,169 @@
+package querier
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func pointsOf(start, step int64, values ...float64) []v3.Point {
+	points := make([]v3.Point, 0, len(values))
+	for idx, v := range values {
+		points = append(points, v3.Point{Timestamp: start + int64(idx)*step, Value: v})
+	}
+	return points
+}
+
+func valuesOf(points []v3.Point) []float64 {
+	values := make([]float64, 0, len(points))
+	for _, p := range points {
+		values = append(values, p.Value)
+	}
+	return values
+}
+
+func TestApplyFunctions(t *testing.T) {
+	testCases := []struct {
+		name      string
+		functions []v3.Function
+		values    []float64
+		expected  []float64
+	}{
+		{
+			name:      "moving average",
+			functions: []v3.Function{{Name: v3.FunctionNameMovingAvg, Args: []interface{}{2}}},
+			values:    []float64{2, 4, 6, 8},
+			expected:  []float64{2, 3, 5, 7},
+		},
+		{
+			name:      "ewma",
+			functions: []v3.Function{{Name: v3.FunctionNameEWMA, Args: []interface{}{0.5}}},
+			values:    []float64{2, 4, 8},
+			expected:  []float64{2, 3, 5.5},
+		},
+		{
+			name:      "cumulative sum",
+			functions: []v3.Function{{Name: v3.FunctionNameCumSum}},
+			values:    []float64{1, 2, 3},
+			expected:  []float64{1, 3, 6},
+		},
+		{
+			name: "clamp",
+			functions: []v3.Function{
+				{Name: v3.FunctionNameClampMin, Args: []interface{}{0}},
+				{Name: v3.FunctionNameClampMax, Args: []interface{}{"5"}},
+			},
+			values:   []float64{-1, 3, 10},
+			expected: []float64{0, 3, 5},
+		},
+		{
+			name:      "absolute",
+			functions: []v3.Function{{Name: v3.FunctionNameAbsolute}},
+			values:    []float64{-1, 2},
+			expected:  []float64{1, 2},
+		},
+		{
+			name:      "derivative",
+			functions: []v3.Function{{Name: v3.FunctionNameDerivative}},
+			values:    []float64{0, 60, 30},
+			expected:  []float64{1, -0.5},
+		},
+		{
+			name: "functions are applied in order",
+			functions: []v3.Function{
+				{Name: v3.FunctionNameCumSum},
+				{Name: v3.FunctionNameDerivative},
+			},
+			values:   []float64{1, 2, 3},
+			expected: []float64{2.0 / 60, 3.0 / 60},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			series := []*v3.Series{{Points: pointsOf(0, 60000, tc.values...)}}
+			result, err := applyFunctions(tc.functions, series, v3.PanelTypeGraph, 0, 0, 60)
+			require.NoError(t, err)
+			require.Len(t, result, 1)
+			assert.InDeltaSlice(t, tc.expected, valuesOf(result[0].Points), 1e-9)
+			// the given series are left untouched
+			assert.Equal(t, tc.values, valuesOf(series[0].Points))
+		})
+	}
+}
+
+func TestApplyFunctionsFillGaps(t *testing.T) {
+	points := []v3.Point{
+		{Timestamp: 120000, Value: 2},
+		{Timestamp: 240000, Value: 4},
+	}
+
+	result, err := applyFunctions([]v3.Function{{Name: v3.FunctionNameFillZero}},
+		[]*v3.Series{{Points: points}}, v3.PanelTypeGraph, 0, 300000, 60)
+	require.NoError(t, err)
+	assert.Equal(t, pointsOf(0, 60000, 0, 0, 2, 0, 4, 0), result[0].Points)
+
+	result, err = applyFunctions([]v3.Function{{Name: v3.FunctionNameFillPrevious}},
+		[]*v3.Series{{Points: points}}, v3.PanelTypeGraph, 0, 300000, 60)
+	require.NoError(t, err)
+	assert.Equal(t, pointsOf(120000, 60000, 2, 2, 4, 4), result[0].Points)
+
+	// the gaps are only filled on graphs
+	result, err = applyFunctions([]v3.Function{{Name: v3.FunctionNameFillZero}},
+		[]*v3.Series{{Points: points}}, v3.PanelTypeValue, 0, 300000, 60)
+	require.NoError(t, err)
+	assert.Equal(t, points, result[0].Points)
+}
+
+func TestApplyFunctionsTimeShift(t *testing.T) {
+	result, err := applyFunctions([]v3.Function{{Name: v3.FunctionNameTimeShift, Args: []interface{}{3600}}},
+		[]*v3.Series{{Points: pointsOf(0, 60000, 1, 2)}}, v3.PanelTypeGraph, 0, 0, 60)
+	require.NoError(t, err)
+	assert.Equal(t, pointsOf(3600000, 60000, 1, 2), result[0].Points)
+}
+
+func TestApplyFunctionsTopK(t *testing.T) {
+	newSeries := func(service string, values ...float64) *v3.Series {
+		return &v3.Series{
+			Labels: map[string]string{"service_name": service},
+			Points: pointsOf(0, 60000, values...),
+		}
+	}
+	series := []*v3.Series{
+		newSeries("frontend", 1, 1),
+		newSeries("cart", 5, 1),
+		newSeries("empty"),
+		newSeries("redis", 2, 2),
+	}
+
+	services := func(series []*v3.Series) []string {
+		names := []string{}
+		for _, s := range series {
+			names = append(names, s.Labels["service_name"])
+		}
+		return names
+	}
+
+	result, err := applyFunctions([]v3.Function{{Name: v3.FunctionNameTopK, Args: []interface{}{2}}}, series, v3.PanelTypeGraph, 0, 0, 60)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cart", "redis"}, services(result))
+
+	result, err = applyFunctions([]v3.Function{{Name: v3.FunctionNameTopK, Args: []interface{}{1, "last"}}}, series, v3.PanelTypeGraph, 0, 0, 60)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"redis"}, services(result))
+
+	result, err = applyFunctions([]v3.Function{{Name: v3.FunctionNameBottomK, Args: []interface{}{10}}}, series, v3.PanelTypeGraph, 0, 0, 60)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"frontend", "redis", "cart", "empty"}, services(result))
+}
+
+func TestFunctionValidate(t *testing.T) {
+	assert.NoError(t, (&v3.Function{Name: v3.FunctionNameMovingAvg, Args: []interface{}{5}}).Validate())
+	assert.NoError(t, (&v3.Function{Name: v3.FunctionNameFillZero}).Validate())
+	assert.Error(t, (&v3.Function{Name: v3.FunctionNameMovingAvg}).Validate())
+	assert.Error(t, (&v3.Function{Name: v3.FunctionNameEWMA, Args: []interface{}{2}}).Validate())
+	assert.Error(t, (&v3.Function{Name: v3.FunctionNameTopK, Args: []interface{}{"a"}}).Validate())
+	assert.Error(t, (&v3.Function{Name: "unknown"}).Validate())
+}
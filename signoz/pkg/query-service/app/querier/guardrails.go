@@ -0,0 +1,184 @@
+package querier
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/app/queryBuilder"
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+// QueryLimits caps the data a query range request can read, the zero values
+// are no limits
+type QueryLimits struct {
+	MaxRowsScanned  uint64
+	MaxBytesScanned uint64
+	// MaxTimeRange is the longest time range that can be read by data source
+	MaxTimeRange map[v3.DataSource]time.Duration
+}
+
+// DefaultQueryLimits returns the limits configured in the environment
+func DefaultQueryLimits() QueryLimits {
+	return QueryLimits{
+		MaxRowsScanned:  constants.QueryMaxRowsScanned,
+		MaxBytesScanned: constants.QueryMaxBytesScanned,
+		MaxTimeRange: map[v3.DataSource]time.Duration{
+			v3.DataSourceMetrics: constants.QueryMaxTimeRangeMetrics,
+			v3.DataSourceLogs:    constants.QueryMaxTimeRangeLogs,
+			v3.DataSourceTraces:  constants.QueryMaxTimeRangeTraces,
+		},
+	}
+}
+
+func limitExceeded(format string, args ...interface{}) *model.ApiError {
+	return &model.ApiError{Typ: model.ErrorLimitExceeded, Err: fmt.Errorf(format, args...)}
+}
+
+// checkTimeRange checks the time range of the request against the limit of
+// every data source it reads
+func (q *querier) checkTimeRange(params *v3.QueryRangeParamsV3) *model.ApiError {
+	timeRange := time.Duration(params.End-params.Start) * time.Millisecond
+
+	sources := map[v3.DataSource]bool{}
+	switch params.CompositeQuery.QueryType {
+	case v3.QueryTypeBuilder:
+		for _, query := range params.CompositeQuery.BuilderQueries {
+			if query.Expression == query.QueryName {
+				sources[query.DataSource] = true
+			}
+		}
+	case v3.QueryTypePromQL:
+		sources[v3.DataSourceMetrics] = true
+	}
+
+	for source := range sources {
+		maxTimeRange := q.limits.MaxTimeRange[source]
+		if maxTimeRange > 0 && timeRange > maxTimeRange {
+			return limitExceeded("the time range of %s exceeds the limit of %s for %s", timeRange, maxTimeRange, source)
+		}
+	}
+	return nil
+}
+
+// queriesToEstimate returns the clickhouse queries of the request by name. The
+// formulas read nothing but the queries of their operands, so the builder
+// queries read by the request are estimated rather than the formulas, once
+// each and even when they are disabled.
+func (q *querier) queriesToEstimate(params *v3.QueryRangeParamsV3, keys map[string]v3.AttributeKey) (map[string]string, error) {
+	queries := make(map[string]string)
+	switch params.CompositeQuery.QueryType {
+	case v3.QueryTypeBuilder:
+		builderQueries := params.CompositeQuery.BuilderQueries
+		read := make(map[string]bool)
+		var readQuery func(name string) error
+		readQuery = func(name string) error {
+			query, ok := builderQueries[name]
+			if !ok || read[name] {
+				return nil
+			}
+			read[name] = true
+			if query.Expression == name {
+				return nil
+			}
+			operands, err := queryBuilder.FormulaOperands(query)
+			if err != nil {
+				return err
+			}
+			for _, operand := range operands {
+				if err := readQuery(operand); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		for name, query := range builderQueries {
+			if query.Disabled {
+				continue
+			}
+			if err := readQuery(name); err != nil {
+				return nil, err
+			}
+		}
+
+		compositeQuery := *params.CompositeQuery
+		compositeQuery.BuilderQueries = make(map[string]*v3.BuilderQuery)
+		for name := range read {
+			query := builderQueries[name]
+			if query.Expression != name {
+				continue
+			}
+			if query.Disabled {
+				operand := *query
+				operand.Disabled = false
+				query = &operand
+			}
+			compositeQuery.BuilderQueries[name] = query
+		}
+		estimated := *params
+		estimated.CompositeQuery = &compositeQuery
+		return q.builder.PrepareQueries(&estimated, keys)
+	case v3.QueryTypeClickHouseSQL:
+		for name, query := range params.CompositeQuery.ClickHouseQueries {
+			if !query.Disabled {
+				queries[name] = query.Query
+			}
+		}
+	}
+	return queries, nil
+}
+
+// EstimateQueryRange checks the request against the limits of the querier.
+// The queries are estimated for a dry run, or when the rows or the bytes read
+// are limited, and the estimates are returned.
+func (q *querier) EstimateQueryRange(ctx context.Context, params *v3.QueryRangeParamsV3, keys map[string]v3.AttributeKey) ([]*v3.QueryEstimate, *model.ApiError) {
+	if params.CompositeQuery == nil {
+		return nil, nil
+	}
+	if apiErr := q.checkTimeRange(params); apiErr != nil {
+		return nil, apiErr
+	}
+	if !params.DryRun && q.limits.MaxRowsScanned == 0 && q.limits.MaxBytesScanned == 0 {
+		return nil, nil
+	}
+
+	queries, err := q.queriesToEstimate(params, keys)
+	if err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+
+	estimates := make([]*v3.QueryEstimate, 0, len(queries))
+	var rows, bytes uint64
+	for name, query := range queries {
+		estimate := &v3.QueryEstimate{Query: query}
+		if q.reader != nil {
+			estimate, err = q.reader.EstimateQueryV3(ctx, query)
+			if err != nil {
+				return nil, &model.ApiError{Typ: model.ErrorExec, Err: fmt.Errorf("error estimating query %s: %w", name, err)}
+			}
+		}
+		estimate.QueryName = name
+		rows += estimate.Rows
+		bytes += estimate.Bytes
+		estimates = append(estimates, estimate)
+	}
+	for name, query := range params.CompositeQuery.PromQueries {
+		if params.CompositeQuery.QueryType == v3.QueryTypePromQL && !query.Disabled {
+			estimates = append(estimates, &v3.QueryEstimate{QueryName: name, Query: query.Query})
+		}
+	}
+	sort.Slice(estimates, func(i, j int) bool {
+		return estimates[i].QueryName < estimates[j].QueryName
+	})
+
+	if q.limits.MaxRowsScanned > 0 && rows > q.limits.MaxRowsScanned {
+		return estimates, limitExceeded("the queries would read about %d rows, which exceeds the limit of %d rows", rows, q.limits.MaxRowsScanned)
+	}
+	if q.limits.MaxBytesScanned > 0 && bytes > q.limits.MaxBytesScanned {
+		return estimates, limitExceeded("the queries would read about %d bytes, which exceeds the limit of %d bytes", bytes, q.limits.MaxBytesScanned)
+	}
+	return estimates, nil
+}
@@ -0,0 +1,177 @@
+package querier
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/featureManager"
+	"go.signoz.io/signoz/pkg/query-service/interfaces"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+// estimateReader estimates the queries reading logs to read 1000 rows of
+// 100 bytes and the other queries to read 10 rows
+type estimateReader struct {
+	interfaces.Reader
+}
+
+func (r *estimateReader) EstimateQueryV3(ctx context.Context, query string) (*v3.QueryEstimate, error) {
+	if strings.Contains(query, "signoz_logs") {
+		return &v3.QueryEstimate{Query: query, Estimated: true, Rows: 1000, Bytes: 100000}, nil
+	}
+	return &v3.QueryEstimate{Query: query, Estimated: true, Rows: 10, Bytes: 1000}, nil
+}
+
+func TestEstimateQueryRange(t *testing.T) {
+	end := time.Now().UnixMilli()
+	params := &v3.QueryRangeParamsV3{
+		Start: end - 2*time.Hour.Milliseconds(),
+		End:   end,
+		CompositeQuery: &v3.CompositeQuery{
+			QueryType: v3.QueryTypeBuilder,
+			PanelType: v3.PanelTypeGraph,
+			BuilderQueries: map[string]*v3.BuilderQuery{
+				"A": {
+					QueryName:         "A",
+					StepInterval:      60,
+					DataSource:        v3.DataSourceLogs,
+					AggregateOperator: v3.AggregateOperatorCount,
+					Expression:        "A",
+				},
+				"B": {
+					QueryName:          "B",
+					StepInterval:       60,
+					DataSource:         v3.DataSourceMetrics,
+					AggregateOperator:  v3.AggregateOperatorSumRate,
+					AggregateAttribute: v3.AttributeKey{Key: "signoz_calls_total"},
+					Expression:         "B",
+				},
+			},
+		},
+	}
+
+	newQuerier := func(limits QueryLimits) interfaces.Querier {
+		return NewQuerier(QuerierOptions{
+			Reader:        &estimateReader{},
+			FeatureLookup: featureManager.StartManager(),
+			Limits:        limits,
+		})
+	}
+
+	// nothing is estimated without limits
+	estimates, apiErr := newQuerier(QueryLimits{}).EstimateQueryRange(context.Background(), params, nil)
+	require.Nil(t, apiErr)
+	assert.Empty(t, estimates)
+
+	// a dry run estimates every query
+	params.DryRun = true
+	estimates, apiErr = newQuerier(QueryLimits{}).EstimateQueryRange(context.Background(), params, nil)
+	require.Nil(t, apiErr)
+	require.Len(t, estimates, 2)
+	assert.Equal(t, "A", estimates[0].QueryName)
+	assert.Contains(t, estimates[0].Query, "signoz_logs")
+	assert.Equal(t, uint64(1000), estimates[0].Rows)
+	assert.Equal(t, "B", estimates[1].QueryName)
+	params.DryRun = false
+
+	_, apiErr = newQuerier(QueryLimits{MaxRowsScanned: 2000, MaxBytesScanned: 200000}).EstimateQueryRange(context.Background(), params, nil)
+	assert.Nil(t, apiErr)
+
+	estimates, apiErr = newQuerier(QueryLimits{MaxRowsScanned: 500}).EstimateQueryRange(context.Background(), params, nil)
+	require.NotNil(t, apiErr)
+	assert.Equal(t, model.ErrorLimitExceeded, apiErr.Typ)
+	assert.Contains(t, apiErr.Err.Error(), "1010 rows")
+	assert.Len(t, estimates, 2)
+
+	_, apiErr = newQuerier(QueryLimits{MaxBytesScanned: 50000}).EstimateQueryRange(context.Background(), params, nil)
+	require.NotNil(t, apiErr)
+	assert.Equal(t, model.ErrorLimitExceeded, apiErr.Typ)
+
+	// the time range is limited by data source
+	limits := QueryLimits{MaxTimeRange: map[v3.DataSource]time.Duration{v3.DataSourceMetrics: 24 * time.Hour, v3.DataSourceLogs: time.Hour}}
+	_, apiErr = newQuerier(limits).EstimateQueryRange(context.Background(), params, nil)
+	require.NotNil(t, apiErr)
+	assert.Equal(t, model.ErrorLimitExceeded, apiErr.Typ)
+	assert.Contains(t, apiErr.Err.Error(), "for logs")
+
+	params.CompositeQuery.BuilderQueries["A"].Disabled = true
+	params.CompositeQuery.BuilderQueries["A"].Expression = "B * 2"
+	_, apiErr = newQuerier(limits).EstimateQueryRange(context.Background(), params, nil)
+	assert.Nil(t, apiErr)
+}
+
+func TestEstimateFormula(t *testing.T) {
+	end := time.Now().UnixMilli()
+	params := &v3.QueryRangeParamsV3{
+		Start: end - time.Hour.Milliseconds(),
+		End:   end,
+		CompositeQuery: &v3.CompositeQuery{
+			QueryType: v3.QueryTypeBuilder,
+			PanelType: v3.PanelTypeGraph,
+			BuilderQueries: map[string]*v3.BuilderQuery{
+				"A": {
+					QueryName:         "A",
+					StepInterval:      60,
+					DataSource:        v3.DataSourceLogs,
+					AggregateOperator: v3.AggregateOperatorCount,
+					Expression:        "A",
+				},
+				"B": {
+					QueryName:          "B",
+					StepInterval:       60,
+					DataSource:         v3.DataSourceMetrics,
+					AggregateOperator:  v3.AggregateOperatorSumRate,
+					AggregateAttribute: v3.AttributeKey{Key: "signoz_calls_total"},
+					Expression:         "B",
+				},
+				"F1": {
+					QueryName:    "F1",
+					StepInterval: 60,
+					Expression:   "A + B",
+				},
+			},
+		},
+	}
+	q := NewQuerier(QuerierOptions{
+		Reader:        &estimateReader{},
+		FeatureLookup: featureManager.StartManager(),
+		Limits:        QueryLimits{MaxRowsScanned: 1500},
+	})
+
+	// the formula reads the rows of its operands, which are counted once
+	estimates, apiErr := q.EstimateQueryRange(context.Background(), params, nil)
+	require.Nil(t, apiErr)
+	require.Len(t, estimates, 2)
+	assert.Equal(t, "A", estimates[0].QueryName)
+	assert.Equal(t, "B", estimates[1].QueryName)
+
+	// the disabled operands are still read by the formula
+	params.CompositeQuery.BuilderQueries["A"].Disabled = true
+	params.CompositeQuery.BuilderQueries["B"].Disabled = true
+	estimates, apiErr = q.EstimateQueryRange(context.Background(), params, nil)
+	require.Nil(t, apiErr)
+	assert.Len(t, estimates, 2)
+
+	params.CompositeQuery.BuilderQueries["F1"].Disabled = true
+	params.CompositeQuery.BuilderQueries["F2"] = &v3.BuilderQuery{QueryName: "F2", StepInterval: 60, Expression: "B * 2"}
+	estimates, apiErr = q.EstimateQueryRange(context.Background(), params, nil)
+	require.Nil(t, apiErr)
+	require.Len(t, estimates, 1)
+	assert.Equal(t, "B", estimates[0].QueryName)
+
+	// the limit is exceeded by the operands
+	params.CompositeQuery.BuilderQueries["F1"].Disabled = false
+	q = NewQuerier(QuerierOptions{
+		Reader:        &estimateReader{},
+		FeatureLookup: featureManager.StartManager(),
+		Limits:        QueryLimits{MaxRowsScanned: 1000},
+	})
+	_, apiErr = q.EstimateQueryRange(context.Background(), params, nil)
+	require.NotNil(t, apiErr)
+	assert.Contains(t, apiErr.Err.Error(), "1010 rows")
+}
@@ -2,21 +2,106 @@ package querier
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"strings"
 	"sync"
-	"time"
 
 	logsV3 "go.signoz.io/signoz/pkg/query-service/app/logs/v3"
 	metricsV3 "go.signoz.io/signoz/pkg/query-service/app/metrics/v3"
 	tracesV3 "go.signoz.io/signoz/pkg/query-service/app/traces/v3"
-	"go.signoz.io/signoz/pkg/query-service/cache/status"
 	"go.signoz.io/signoz/pkg/query-service/constants"
 	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
-	"go.uber.org/zap"
 )
 
+func prepareLogsQuery(
+	start,
+	end int64,
+	params *v3.QueryRangeParamsV3,
+	builderQuery *v3.BuilderQuery,
+	preferRPM bool,
+) (string, error) {
+	// for ts query with limit replace it as it is already formed
+	if params.CompositeQuery.PanelType == v3.PanelTypeGraph && builderQuery.Limit > 0 && len(builderQuery.GroupBy) > 0 {
+		limitQuery, err := logsV3.PrepareLogsQuery(
+			start,
+			end,
+			params.CompositeQuery.QueryType,
+			params.CompositeQuery.PanelType,
+			builderQuery,
+			logsV3.Options{GraphLimitQtype: constants.FirstQueryGraphLimit, PreferRPM: preferRPM},
+		)
+		if err != nil {
+			return limitQuery, err
+		}
+		placeholderQuery, err := logsV3.PrepareLogsQuery(
+			start,
+			end,
+			params.CompositeQuery.QueryType,
+			params.CompositeQuery.PanelType,
+			builderQuery,
+			logsV3.Options{GraphLimitQtype: constants.SecondQueryGraphLimit, PreferRPM: preferRPM},
+		)
+		if err != nil {
+			return placeholderQuery, err
+		}
+		return strings.Replace(placeholderQuery, "#LIMIT_PLACEHOLDER", limitQuery, 1), nil
+	}
+
+	return logsV3.PrepareLogsQuery(
+		start,
+		end,
+		params.CompositeQuery.QueryType,
+		params.CompositeQuery.PanelType,
+		builderQuery,
+		logsV3.Options{PreferRPM: preferRPM},
+	)
+}
+
+func prepareTracesQuery(
+	start,
+	end int64,
+	params *v3.QueryRangeParamsV3,
+	builderQuery *v3.BuilderQuery,
+	keys map[string]v3.AttributeKey,
+	preferRPM bool,
+) (string, error) {
+	// for ts query with group by and limit form two queries
+	if params.CompositeQuery.PanelType == v3.PanelTypeGraph && builderQuery.Limit > 0 && len(builderQuery.GroupBy) > 0 {
+		limitQuery, err := tracesV3.PrepareTracesQuery(
+			start,
+			end,
+			params.CompositeQuery.PanelType,
+			builderQuery,
+			keys,
+			tracesV3.Options{GraphLimitQtype: constants.FirstQueryGraphLimit, PreferRPM: preferRPM},
+		)
+		if err != nil {
+			return limitQuery, err
+		}
+		placeholderQuery, err := tracesV3.PrepareTracesQuery(
+			start,
+			end,
+			params.CompositeQuery.PanelType,
+			builderQuery,
+			keys,
+			tracesV3.Options{GraphLimitQtype: constants.SecondQueryGraphLimit, PreferRPM: preferRPM},
+		)
+		if err != nil {
+			return limitQuery, err
+		}
+		return fmt.Sprintf(placeholderQuery, limitQuery), nil
+	}
+
+	return tracesV3.PrepareTracesQuery(
+		start,
+		end,
+		params.CompositeQuery.PanelType,
+		builderQuery,
+		keys,
+		tracesV3.Options{PreferRPM: preferRPM},
+	)
+}
+
 func (q *querier) runBuilderQuery(
 	ctx context.Context,
 	builderQuery *v3.BuilderQuery,
@@ -28,6 +113,7 @@ func (q *querier) runBuilderQuery(
 ) {
 	defer wg.Done()
 	queryName := builderQuery.QueryName
+	ctx, stats := withQueryStats(ctx, params)
 
 	var preferRPM bool
 
@@ -35,192 +121,47 @@ func (q *querier) runBuilderQuery(
 		preferRPM = q.featureLookUp.CheckFeature(constants.PreferRPM) == nil
 	}
 
-	// TODO: handle other data sources
-	if builderQuery.DataSource == v3.DataSourceLogs {
-		var query string
-		var err error
-		// for ts query with limit replace it as it is already formed
-		if params.CompositeQuery.PanelType == v3.PanelTypeGraph && builderQuery.Limit > 0 && len(builderQuery.GroupBy) > 0 {
-			limitQuery, err := logsV3.PrepareLogsQuery(
-				params.Start,
-				params.End,
-				params.CompositeQuery.QueryType,
-				params.CompositeQuery.PanelType,
-				builderQuery,
-				logsV3.Options{GraphLimitQtype: constants.FirstQueryGraphLimit, PreferRPM: preferRPM},
-			)
-			if err != nil {
-				ch <- channelResult{Err: err, Name: queryName, Query: limitQuery, Series: nil}
-				return
-			}
-			placeholderQuery, err := logsV3.PrepareLogsQuery(
-				params.Start,
-				params.End,
-				params.CompositeQuery.QueryType,
-				params.CompositeQuery.PanelType,
-				builderQuery,
-				logsV3.Options{GraphLimitQtype: constants.SecondQueryGraphLimit, PreferRPM: preferRPM},
-			)
-			if err != nil {
-				ch <- channelResult{Err: err, Name: queryName, Query: placeholderQuery, Series: nil}
-				return
-			}
-			query = strings.Replace(placeholderQuery, "#LIMIT_PLACEHOLDER", limitQuery, 1)
-		} else {
-			query, err = logsV3.PrepareLogsQuery(
-				params.Start,
-				params.End,
-				params.CompositeQuery.QueryType,
-				params.CompositeQuery.PanelType,
-				builderQuery,
-				logsV3.Options{PreferRPM: preferRPM},
-			)
-			if err != nil {
-				ch <- channelResult{Err: err, Name: queryName, Query: query, Series: nil}
-				return
-			}
+	// prepareQuery builds the query for the given time range; it is called once
+	// for the whole range when the query is not cached and once per miss otherwise
+	var prepareQuery func(start, end int64) (string, error)
+	switch builderQuery.DataSource {
+	case v3.DataSourceLogs:
+		prepareQuery = func(start, end int64) (string, error) {
+			return prepareLogsQuery(start, end, params, builderQuery, preferRPM)
 		}
-
-		if err != nil {
-			ch <- channelResult{Err: err, Name: queryName, Query: query, Series: nil}
-			return
+	case v3.DataSourceTraces:
+		prepareQuery = func(start, end int64) (string, error) {
+			return prepareTracesQuery(start, end, params, builderQuery, keys, preferRPM)
 		}
-		series, err := q.execClickHouseQuery(ctx, query)
-		ch <- channelResult{Err: err, Name: queryName, Query: query, Series: series}
-		return
-	}
-
-	if builderQuery.DataSource == v3.DataSourceTraces {
-
-		var query string
-		var err error
-		// for ts query with group by and limit form two queries
-		if params.CompositeQuery.PanelType == v3.PanelTypeGraph && builderQuery.Limit > 0 && len(builderQuery.GroupBy) > 0 {
-			limitQuery, err := tracesV3.PrepareTracesQuery(
-				params.Start,
-				params.End,
-				params.CompositeQuery.PanelType,
-				builderQuery,
-				keys,
-				tracesV3.Options{GraphLimitQtype: constants.FirstQueryGraphLimit, PreferRPM: preferRPM},
-			)
-			if err != nil {
-				ch <- channelResult{Err: err, Name: queryName, Query: limitQuery, Series: nil}
-				return
-			}
-			placeholderQuery, err := tracesV3.PrepareTracesQuery(
-				params.Start,
-				params.End,
-				params.CompositeQuery.PanelType,
-				builderQuery,
-				keys,
-				tracesV3.Options{GraphLimitQtype: constants.SecondQueryGraphLimit, PreferRPM: preferRPM},
-			)
-			if err != nil {
-				ch <- channelResult{Err: err, Name: queryName, Query: limitQuery, Series: nil}
-				return
-			}
-			query = fmt.Sprintf(placeholderQuery, limitQuery)
-		} else {
-			query, err = tracesV3.PrepareTracesQuery(
-				params.Start,
-				params.End,
+	default:
+		prepareQuery = func(start, end int64) (string, error) {
+			return metricsV3.PrepareMetricQuery(
+				start,
+				end,
+				params.CompositeQuery.QueryType,
 				params.CompositeQuery.PanelType,
 				builderQuery,
-				keys,
-				tracesV3.Options{PreferRPM: preferRPM},
+				metricsV3.Options{PreferRPM: preferRPM},
 			)
-			if err != nil {
-				ch <- channelResult{Err: err, Name: queryName, Query: query, Series: nil}
-				return
-			}
 		}
-
-		series, err := q.execClickHouseQuery(ctx, query)
-		ch <- channelResult{Err: err, Name: queryName, Query: query, Series: series}
-		return
 	}
 
 	// What is happening here?
 	// We are only caching the graph panel queries. A non-existant cache key means that the query is not cached.
-	// If the query is not cached, we execute the query and return the result without caching it.
-	if _, ok := cacheKeys[queryName]; !ok {
-		query, err := metricsV3.PrepareMetricQuery(params.Start, params.End, params.CompositeQuery.QueryType, params.CompositeQuery.PanelType, builderQuery, metricsV3.Options{PreferRPM: preferRPM})
-		if err != nil {
-			ch <- channelResult{Err: err, Name: queryName, Query: query, Series: nil}
-			return
-		}
-		series, err := q.execClickHouseQuery(ctx, query)
-		ch <- channelResult{Err: err, Name: queryName, Query: query, Series: series}
-		return
-	}
-
-	cacheKey := cacheKeys[queryName]
-	var cachedData []byte
-	if !params.NoCache && q.cache != nil {
-		var retrieveStatus status.RetrieveStatus
-		data, retrieveStatus, err := q.cache.Retrieve(cacheKey, true)
-		zap.S().Debug("cache retrieve status", zap.String("status", retrieveStatus.String()))
-		if err == nil {
-			cachedData = data
-		}
-	}
-	misses := q.findMissingTimeRanges(params.Start, params.End, params.Step, cachedData)
-	missedSeries := make([]*v3.Series, 0)
-	cachedSeries := make([]*v3.Series, 0)
-	for _, miss := range misses {
-		query, err := metricsV3.PrepareMetricQuery(
-			miss.start,
-			miss.end,
-			params.CompositeQuery.QueryType,
-			params.CompositeQuery.PanelType,
-			builderQuery,
-			metricsV3.Options{},
-		)
-		if err != nil {
-			ch <- channelResult{
-				Err:    err,
-				Name:   queryName,
-				Query:  query,
-				Series: nil,
-			}
-			return
-		}
-		series, err := q.execClickHouseQuery(ctx, query)
-		if err != nil {
-			ch <- channelResult{
-				Err:    err,
-				Name:   queryName,
-				Query:  query,
-				Series: nil,
-			}
-			return
-		}
-		missedSeries = append(missedSeries, series...)
-	}
-	if err := json.Unmarshal(cachedData, &cachedSeries); err != nil && cachedData != nil {
-		zap.S().Error("error unmarshalling cached data", zap.Error(err))
-	}
-	mergedSeries := mergeSerieses(cachedSeries, missedSeries)
-
-	ch <- channelResult{
-		Err:    nil,
-		Name:   queryName,
-		Series: mergedSeries,
-	}
-	// Cache the seriesList for future queries
-	if len(missedSeries) > 0 && !params.NoCache && q.cache != nil {
-		mergedSeriesData, err := json.Marshal(mergedSeries)
-		if err != nil {
-			zap.S().Error("error marshalling merged series", zap.Error(err))
-			return
-		}
-		err = q.cache.Store(cacheKey, mergedSeriesData, time.Hour)
+	// If the query is not cached, we execute the query for the whole range and return the result without caching it.
+	var query string
+	series, err := q.queryWithCache(ctx, params, queryName, cacheKeys[queryName], builderQuery.StepInterval*1000, func(start, end int64) ([]*v3.Series, error) {
+		var err error
+		query, err = prepareQuery(start, end)
 		if err != nil {
-			zap.S().Error("error storing merged series", zap.Error(err))
-			return
+			return nil, err
 		}
+		return q.execClickHouseQuery(ctx, query)
+	})
+	if err == nil {
+		series, err = applyFunctions(builderQuery.Functions, series, params.CompositeQuery.PanelType, params.Start, params.End, builderQuery.StepInterval)
 	}
+	ch <- channelResult{Err: err, Name: queryName, Query: query, Series: series, Stats: stats}
 }
 
 func (q *querier) runBuilderExpression(
@@ -235,71 +176,26 @@ func (q *querier) runBuilderExpression(
 	defer wg.Done()
 
 	queryName := builderQuery.QueryName
+	ctx, stats := withQueryStats(ctx, params)
 
-	queries, err := q.builder.PrepareQueries(params, keys)
-	if err != nil {
-		ch <- channelResult{Err: err, Name: queryName, Query: "", Series: nil}
-		return
-	}
-
-	if _, ok := cacheKeys[queryName]; !ok {
-		query := queries[queryName]
-		series, err := q.execClickHouseQuery(ctx, query)
-		ch <- channelResult{Err: err, Name: queryName, Query: query, Series: series}
-		return
-	}
-
-	cacheKey := cacheKeys[queryName]
-	var cachedData []byte
-	if !params.NoCache && q.cache != nil {
-		var retrieveStatus status.RetrieveStatus
-		data, retrieveStatus, err := q.cache.Retrieve(cacheKey, true)
-		zap.S().Debug("cache retrieve status", zap.String("status", retrieveStatus.String()))
-		if err == nil {
-			cachedData = data
-		}
-	}
-	misses := q.findMissingTimeRanges(params.Start, params.End, params.Step, cachedData)
-	missedSeries := make([]*v3.Series, 0)
-	cachedSeries := make([]*v3.Series, 0)
-	for _, miss := range misses {
-		missQueries, _ := q.builder.PrepareQueries(&v3.QueryRangeParamsV3{
-			Start:          miss.start,
-			End:            miss.end,
+	var query string
+	series, err := q.queryWithCache(ctx, params, queryName, cacheKeys[queryName], builderQuery.StepInterval*1000, func(start, end int64) ([]*v3.Series, error) {
+		queries, err := q.builder.PrepareQueries(&v3.QueryRangeParamsV3{
+			Start:          start,
+			End:            end,
 			Step:           params.Step,
 			NoCache:        params.NoCache,
 			CompositeQuery: params.CompositeQuery,
 			Variables:      params.Variables,
 		}, keys)
-		query := missQueries[queryName]
-		series, err := q.execClickHouseQuery(ctx, query)
-		if err != nil {
-			ch <- channelResult{Err: err, Name: queryName, Query: query, Series: nil}
-			return
-		}
-		missedSeries = append(missedSeries, series...)
-	}
-	if err := json.Unmarshal(cachedData, &cachedSeries); err != nil && cachedData != nil {
-		zap.S().Error("error unmarshalling cached data", zap.Error(err))
-	}
-	mergedSeries := mergeSerieses(cachedSeries, missedSeries)
-
-	ch <- channelResult{
-		Err:    nil,
-		Name:   queryName,
-		Series: mergedSeries,
-	}
-	// Cache the seriesList for future queries
-	if len(missedSeries) > 0 && !params.NoCache && q.cache != nil {
-		mergedSeriesData, err := json.Marshal(mergedSeries)
-		if err != nil {
-			zap.S().Error("error marshalling merged series", zap.Error(err))
-			return
-		}
-		err = q.cache.Store(cacheKey, mergedSeriesData, time.Hour)
 		if err != nil {
-			zap.S().Error("error storing merged series", zap.Error(err))
-			return
+			return nil, err
 		}
+		query = queries[queryName]
+		return q.execClickHouseQuery(ctx, query)
+	})
+	if err == nil {
+		series, err = applyFunctions(builderQuery.Functions, series, params.CompositeQuery.PanelType, params.Start, params.End, builderQuery.StepInterval)
 	}
+	ch <- channelResult{Err: err, Name: queryName, Query: query, Series: series, Stats: stats}
 }
@@ -0,0 +1,55 @@
+package querier
+
+import (
+	"fmt"
+
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+// rowPosition returns the position of the row in the list
+func rowPosition(source v3.DataSource, row *v3.Row, order string) (*v3.ListPosition, error) {
+	idColumn := "id"
+	if source == v3.DataSourceTraces {
+		idColumn = "spanID"
+	}
+	id, ok := row.Data[idColumn].(*string)
+	if !ok {
+		return nil, fmt.Errorf("the list rows have no %s column", idColumn)
+	}
+	return &v3.ListPosition{Timestamp: uint64(row.Timestamp.UnixNano()), ID: *id, Order: order}, nil
+}
+
+// listCursor returns the cursor of the page of logs following the rows, it is
+// empty when the page is not full or the logs are not ordered by timestamp
+func listCursor(query *v3.BuilderQuery, rows []*v3.Row) string {
+	if query.DataSource != v3.DataSourceLogs || query.AggregateOperator != v3.AggregateOperatorNoOp || len(rows) == 0 {
+		return ""
+	}
+
+	pageSize := query.PageSize
+	if query.Limit > 0 && (pageSize == 0 || query.Offset+pageSize > query.Limit) {
+		pageSize = query.Limit - query.Offset
+	}
+	if uint64(len(rows)) < pageSize || pageSize == 0 {
+		return ""
+	}
+
+	var order string
+	if query.Cursor != "" {
+		position, err := v3.ParseListCursor(query.Cursor)
+		if err != nil {
+			return ""
+		}
+		order = position.Order
+	} else if listOrder, ok := query.ListOrder(); ok {
+		order = listOrder
+	} else {
+		return ""
+	}
+
+	position, err := rowPosition(query.DataSource, rows[len(rows)-1], order)
+	if err != nil {
+		return ""
+	}
+	return position.Cursor()
+}
@@ -0,0 +1,155 @@
+package querier
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	"go.signoz.io/signoz/pkg/query-service/featureManager"
+	"go.signoz.io/signoz/pkg/query-service/interfaces"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func TestListCursor(t *testing.T) {
+	rows := []*v3.Row{
+		logRow(3000, "c", "GET"),
+		logRow(2000, "b", "GET"),
+	}
+	query := &v3.BuilderQuery{
+		QueryName:         "A",
+		DataSource:        v3.DataSourceLogs,
+		AggregateOperator: v3.AggregateOperatorNoOp,
+		Expression:        "A",
+		PageSize:          2,
+	}
+
+	// the cursor continues after the last row of the page
+	cursor := listCursor(query, rows)
+	position, err := v3.ParseListCursor(cursor)
+	require.NoError(t, err)
+	assert.Equal(t, &v3.ListPosition{Timestamp: 2000, ID: "b", Order: "desc"}, position)
+
+	// the next page keeps the direction of the cursor
+	query.Cursor = (&v3.ListPosition{Timestamp: 1000, ID: "a", Order: "asc"}).Cursor()
+	position, err = v3.ParseListCursor(listCursor(query, rows))
+	require.NoError(t, err)
+	assert.Equal(t, "asc", position.Order)
+	query.Cursor = ""
+
+	query.OrderBy = []v3.OrderBy{{ColumnName: constants.TIMESTAMP, Order: "asc"}}
+	position, err = v3.ParseListCursor(listCursor(query, rows))
+	require.NoError(t, err)
+	assert.Equal(t, "asc", position.Order)
+
+	// there is no next page after a page that is not full
+	assert.Empty(t, listCursor(query, rows[:1]))
+
+	// the logs ordered by an attribute cannot be continued
+	query.OrderBy = []v3.OrderBy{{ColumnName: "method", Order: "asc"}}
+	assert.Empty(t, listCursor(query, rows))
+
+	_, err = v3.ParseListCursor("not a cursor")
+	assert.Error(t, err)
+}
+
+var (
+	positionRegexp = regexp.MustCompile(`\(timestamp, id\) < \((\d+), '(\w+)'\)`)
+	limitRegexp    = regexp.MustCompile(`LIMIT (\d+)`)
+)
+
+// logsTable runs the list queries on its rows ordered by descending timestamp,
+// the rows with the same timestamp are ordered by id only when the query is
+// ordered by id too
+type logsTable struct {
+	interfaces.Reader
+	rows []*v3.Row
+}
+
+func (r *logsTable) GetListResultV3(ctx context.Context, query string) ([]*v3.Row, error) {
+	id := func(row *v3.Row) string {
+		return *row.Data["id"].(*string)
+	}
+
+	var rows []*v3.Row
+	for _, row := range r.rows {
+		if m := positionRegexp.FindStringSubmatch(query); m != nil {
+			position := fmt.Sprintf("%020s/%s", m[1], m[2])
+			if fmt.Sprintf("%020d/%s", row.Timestamp.UnixNano(), id(row)) >= position {
+				continue
+			}
+		}
+		rows = append(rows, row)
+	}
+	byID := strings.Contains(query, "order by timestamp DESC, id DESC")
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !rows[i].Timestamp.Equal(rows[j].Timestamp) {
+			return rows[i].Timestamp.After(rows[j].Timestamp)
+		}
+		return byID && id(rows[i]) > id(rows[j])
+	})
+
+	var limit int
+	fmt.Sscanf(limitRegexp.FindString(query), "LIMIT %d", &limit)
+	if limit < len(rows) {
+		rows = rows[:limit]
+	}
+	return rows, nil
+}
+
+func TestListPagesWithSameTimestamp(t *testing.T) {
+	reader := &logsTable{rows: []*v3.Row{
+		logRow(3000, "e", "GET"),
+		// the rows at 2000 span the first and the second pages
+		logRow(2000, "b", "GET"),
+		logRow(2000, "d", "GET"),
+		logRow(2000, "a", "GET"),
+		logRow(2000, "c", "GET"),
+		logRow(1000, "f", "GET"),
+	}}
+	q := NewQuerier(QuerierOptions{
+		Reader:        reader,
+		FeatureLookup: featureManager.StartManager(),
+	})
+
+	params := &v3.QueryRangeParamsV3{
+		Start: 1675115596722,
+		End:   1675115596722 + 120*60*1000,
+		CompositeQuery: &v3.CompositeQuery{
+			QueryType: v3.QueryTypeBuilder,
+			PanelType: v3.PanelTypeList,
+			BuilderQueries: map[string]*v3.BuilderQuery{
+				"A": {
+					QueryName:         "A",
+					StepInterval:      60,
+					DataSource:        v3.DataSourceLogs,
+					AggregateOperator: v3.AggregateOperatorNoOp,
+					Expression:        "A",
+					Filters:           &v3.FilterSet{Operator: "AND"},
+					PageSize:          3,
+				},
+			},
+		},
+	}
+
+	var ids []string
+	for page := 0; page < 3; page++ {
+		results, err, _ := q.QueryRange(context.Background(), params, nil)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		for _, row := range results[0].List {
+			ids = append(ids, *row.Data["id"].(*string))
+		}
+		if results[0].Cursor == "" {
+			break
+		}
+		params.CompositeQuery.BuilderQueries["A"].Cursor = results[0].Cursor
+	}
+	// every row is listed once
+	assert.Equal(t, []string{"e", "d", "c", "b", "a", "f"}, ids)
+}
@@ -0,0 +1,107 @@
+package querier
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	logsV3 "go.signoz.io/signoz/pkg/query-service/app/logs/v3"
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.uber.org/multierr"
+)
+
+// patternsSampleSize is the most logs of a query grouped into patterns, the
+// most recent logs of the time range
+const patternsSampleSize = 10000
+
+// minePatterns groups the bodies of the rows of a logs list into patterns
+func minePatterns(rows []*v3.Row, filters *v3.FilterSet) []*v3.LogPattern {
+	miner := logsV3.NewPatternMiner()
+	for _, row := range rows {
+		body, _ := row.Data["body"].(*string)
+		id, _ := row.Data["id"].(*string)
+		if body == nil || id == nil {
+			continue
+		}
+		miner.Add(*body, *id, row.Timestamp)
+	}
+
+	patterns := miner.Patterns()
+	for _, pattern := range patterns {
+		pattern.Filters = logsV3.PatternFilterSet(pattern.Pattern, filters)
+	}
+	return patterns
+}
+
+// runBuilderPatternQueries groups the logs of the builder queries into
+// patterns, over a sample of the most recent logs of every query
+func (q *querier) runBuilderPatternQueries(ctx context.Context, params *v3.QueryRangeParamsV3) ([]*v3.Result, error, map[string]string) {
+	// the queries are all prepared before any of them runs, so that an
+	// invalid query does not leave the others running
+	queries := make(map[string]string)
+	for name, builderQuery := range params.CompositeQuery.BuilderQueries {
+		if builderQuery.Disabled {
+			continue
+		}
+		if builderQuery.DataSource != v3.DataSourceLogs || builderQuery.AggregateOperator != v3.AggregateOperatorNoOp {
+			return nil, fmt.Errorf("patterns are only supported for logs list queries"), map[string]string{name: builderQuery.Expression}
+		}
+
+		sample := *builderQuery
+		sample.Limit = patternsSampleSize
+		if builderQuery.Limit > 0 && builderQuery.Limit < sample.Limit {
+			sample.Limit = builderQuery.Limit
+		}
+		sample.Offset = 0
+		sample.PageSize = 0
+		sample.OrderBy = []v3.OrderBy{{ColumnName: constants.TIMESTAMP, Order: "desc"}}
+		query, err := logsV3.PrepareLogsQuery(params.Start, params.End, params.CompositeQuery.QueryType, v3.PanelTypeList, &sample, logsV3.Options{})
+		if err != nil {
+			return nil, err, map[string]string{name: err.Error()}
+		}
+		queries[name] = query
+	}
+
+	ch := make(chan channelResult, len(queries))
+	var wg sync.WaitGroup
+
+	for name, query := range queries {
+		q.recordQuery(query)
+
+		wg.Add(1)
+		go func(name, query string, filters *v3.FilterSet) {
+			defer wg.Done()
+			ctx, stats := withQueryStats(ctx, params)
+			rows, err := q.reader.GetListResultV3(ctx, query)
+			if err != nil {
+				ch <- channelResult{Err: fmt.Errorf("error in query-%s: %v", name, err), Name: name, Query: query}
+				return
+			}
+			ch <- channelResult{Patterns: minePatterns(rows, filters), Name: name, Query: query, Stats: stats}
+		}(name, query, params.CompositeQuery.BuilderQueries[name].Filters)
+	}
+
+	wg.Wait()
+	close(ch)
+
+	var errs []error
+	errQuriesByName := make(map[string]string)
+	res := make([]*v3.Result, 0)
+	for r := range ch {
+		if r.Err != nil {
+			errs = append(errs, r.Err)
+			errQuriesByName[r.Name] = r.Query
+			continue
+		}
+		res = append(res, &v3.Result{
+			QueryName: r.Name,
+			Patterns:  r.Patterns,
+			Stats:     r.Stats,
+		})
+	}
+	if len(errs) != 0 {
+		return nil, fmt.Errorf("encountered multiple errors: %s", multierr.Combine(errs...)), errQuriesByName
+	}
+	return res, nil, nil
+}
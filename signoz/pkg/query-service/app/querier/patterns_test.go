@@ -0,0 +1,81 @@
+package querier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/featureManager"
+	"go.signoz.io/signoz/pkg/query-service/interfaces"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+// listReader returns the rows for every list query
+type listReader struct {
+	interfaces.Reader
+	rows []*v3.Row
+}
+
+func (r *listReader) GetListResultV3(ctx context.Context, query string) ([]*v3.Row, error) {
+	return r.rows, nil
+}
+
+func bodyRow(ts int64, id, body string) *v3.Row {
+	return &v3.Row{Timestamp: time.Unix(0, ts).UTC(), Data: map[string]interface{}{"id": &id, "body": &body}}
+}
+
+func TestRunBuilderPatternQueries(t *testing.T) {
+	reader := &listReader{rows: []*v3.Row{
+		bodyRow(4000, "d", "GET /api/orders/42 200"),
+		bodyRow(3000, "c", "GET /api/orders/43 200"),
+		bodyRow(2000, "b", "cache miss for key 9f1c2a"),
+		bodyRow(1000, "a", "GET /api/orders/44 200"),
+	}}
+	q := NewQuerier(QuerierOptions{
+		Reader:        reader,
+		FeatureLookup: featureManager.StartManager(),
+	})
+
+	params := &v3.QueryRangeParamsV3{
+		Start: 1675115596722,
+		End:   1675115596722 + 120*60*1000,
+		CompositeQuery: &v3.CompositeQuery{
+			QueryType: v3.QueryTypeBuilder,
+			PanelType: v3.PanelTypePatterns,
+			BuilderQueries: map[string]*v3.BuilderQuery{
+				"A": {
+					QueryName:         "A",
+					StepInterval:      60,
+					DataSource:        v3.DataSourceLogs,
+					AggregateOperator: v3.AggregateOperatorNoOp,
+					Expression:        "A",
+					Filters:           &v3.FilterSet{Operator: "AND"},
+					PageSize:          10,
+				},
+			},
+		},
+	}
+
+	results, err, _ := q.QueryRange(context.Background(), params, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	patterns := results[0].Patterns
+	require.Len(t, patterns, 2)
+	assert.Equal(t, "GET /api/orders/<*> <*>", patterns[0].Pattern)
+	assert.Equal(t, uint64(3), patterns[0].Count)
+	assert.Equal(t, []string{"d", "c", "a"}, patterns[0].SampleIDs)
+	assert.Equal(t, time.Unix(0, 1000).UTC(), patterns[0].FirstSeen)
+	assert.Equal(t, time.Unix(0, 4000).UTC(), patterns[0].LastSeen)
+	require.Len(t, patterns[0].Filters.Items, 1)
+	assert.Equal(t, "%GET%/api/orders/%%%%", patterns[0].Filters.Items[0].Value)
+
+	// the most recent logs are sampled
+	require.Len(t, q.QueriesExecuted(), 1)
+	assert.Contains(t, q.QueriesExecuted()[0], "order by timestamp DESC, id DESC LIMIT 10000")
+
+	params.CompositeQuery.BuilderQueries["A"].AggregateOperator = v3.AggregateOperatorCount
+	_, err, _ = q.QueryRange(context.Background(), params, nil)
+	assert.Error(t, err)
+}
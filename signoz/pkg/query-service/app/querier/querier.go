@@ -2,9 +2,7 @@ package querier
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"math"
 	"sort"
 	"strings"
 	"sync"
@@ -16,6 +14,7 @@ import (
 	tracesV3 "go.signoz.io/signoz/pkg/query-service/app/traces/v3"
 
 	"go.signoz.io/signoz/pkg/query-service/cache"
+	"go.signoz.io/signoz/pkg/query-service/common"
 	"go.signoz.io/signoz/pkg/query-service/interfaces"
 	"go.signoz.io/signoz/pkg/query-service/model"
 	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
@@ -29,6 +28,21 @@ type channelResult struct {
 	Err    error
 	Name   string
 	Query  string
+	Stats  *v3.QueryStats
+	// Patterns are the patterns of the logs of the query
+	Patterns []*v3.LogPattern
+	// CallGraph is the call graph of the traces of the query
+	CallGraph []*v3.CallGraphEdge
+}
+
+// withQueryStats returns the context the stats of a query are collected in
+// when the request asks for them
+func withQueryStats(ctx context.Context, params *v3.QueryRangeParamsV3) (context.Context, *v3.QueryStats) {
+	if !params.CollectStats {
+		return ctx, nil
+	}
+	stats := &v3.QueryStats{}
+	return common.NewQueryStatsContext(ctx, stats), stats
 }
 
 type missInterval struct {
@@ -37,6 +51,8 @@ type missInterval struct {
 
 type querier struct {
 	cache        cache.Cache
+	cacheTTL     time.Duration
+	cacheIndex   *cacheIndex
 	reader       interfaces.Reader
 	keyGenerator cache.KeyGenerator
 
@@ -44,10 +60,12 @@ type querier struct {
 
 	builder       *queryBuilder.QueryBuilder
 	featureLookUp interfaces.FeatureLookup
+	limits        QueryLimits
 
 	// used for testing
 	// TODO(srikanthccv): remove this once we have a proper mock
 	testingMode     bool
+	queriesMtx      sync.Mutex
 	queriesExecuted []string
 	returnedSeries  []*v3.Series
 	returnedErr     error
@@ -56,9 +74,11 @@ type querier struct {
 type QuerierOptions struct {
 	Reader        interfaces.Reader
 	Cache         cache.Cache
+	CacheTTL      time.Duration
 	KeyGenerator  cache.KeyGenerator
 	FluxInterval  time.Duration
 	FeatureLookup interfaces.FeatureLookup
+	Limits        QueryLimits
 
 	// used for testing
 	TestingMode    bool
@@ -67,8 +87,15 @@ type QuerierOptions struct {
 }
 
 func NewQuerier(opts QuerierOptions) interfaces.Querier {
+	cacheTTL := opts.CacheTTL
+	if cacheTTL == 0 {
+		cacheTTL = defaultCacheTTL
+	}
+
 	return &querier{
 		cache:        opts.Cache,
+		cacheTTL:     cacheTTL,
+		cacheIndex:   newCacheIndex(opts.Cache),
 		reader:       opts.Reader,
 		keyGenerator: opts.KeyGenerator,
 		fluxInterval: opts.FluxInterval,
@@ -79,6 +106,7 @@ func NewQuerier(opts QuerierOptions) interfaces.Querier {
 			BuildMetricQuery: metricsV3.PrepareMetricQuery,
 		}, opts.FeatureLookup),
 		featureLookUp: opts.FeatureLookup,
+		limits:        opts.Limits,
 
 		testingMode:    opts.TestingMode,
 		returnedSeries: opts.ReturnedSeries,
@@ -86,8 +114,15 @@ func NewQuerier(opts QuerierOptions) interfaces.Querier {
 	}
 }
 
-func (q *querier) execClickHouseQuery(ctx context.Context, query string) ([]*v3.Series, error) {
+// recordQuery keeps the query for the tests, the queries run concurrently
+func (q *querier) recordQuery(query string) {
+	q.queriesMtx.Lock()
+	defer q.queriesMtx.Unlock()
 	q.queriesExecuted = append(q.queriesExecuted, query)
+}
+
+func (q *querier) execClickHouseQuery(ctx context.Context, query string) ([]*v3.Series, error) {
+	q.recordQuery(query)
 	if q.testingMode && q.reader == nil {
 		return q.returnedSeries, q.returnedErr
 	}
@@ -114,10 +149,16 @@ func (q *querier) execClickHouseQuery(ctx context.Context, query string) ([]*v3.
 }
 
 func (q *querier) execPromQuery(ctx context.Context, params *model.QueryRangeParams) ([]*v3.Series, error) {
-	q.queriesExecuted = append(q.queriesExecuted, params.Query)
+	q.recordQuery(params.Query)
 	if q.testingMode && q.reader == nil {
 		return q.returnedSeries, q.returnedErr
 	}
+	// the promql engine reads through the remote storage, only the time is known
+	if stats := common.GetQueryStatsFromContext(ctx); stats != nil {
+		defer func(start time.Time) {
+			stats.AddQuery(params.Query, time.Since(start))
+		}(time.Now())
+	}
 	promResult, _, err := q.reader.GetQueryRangeResult(ctx, params)
 	if err != nil {
 		return nil, err
@@ -139,85 +180,6 @@ func (q *querier) execPromQuery(ctx context.Context, params *model.QueryRangePar
 	return seriesList, nil
 }
 
-// findMissingTimeRanges finds the missing time ranges in the seriesList
-// and returns a list of miss structs, It takes the fluxInterval into
-// account to find the missing time ranges.
-//
-// The [End - fluxInterval, End] is always added to the list of misses, because
-// the data might still be in flux and not yet available in the database.
-func findMissingTimeRanges(start, end int64, seriesList []*v3.Series, fluxInterval time.Duration) (misses []missInterval) {
-	var cachedStart, cachedEnd int64
-	for idx := range seriesList {
-		series := seriesList[idx]
-		for pointIdx := range series.Points {
-			point := series.Points[pointIdx]
-			if cachedStart == 0 || point.Timestamp < cachedStart {
-				cachedStart = point.Timestamp
-			}
-			if cachedEnd == 0 || point.Timestamp > cachedEnd {
-				cachedEnd = point.Timestamp
-			}
-		}
-	}
-
-	// Exclude the flux interval from the cached end time
-	cachedEnd = int64(
-		math.Min(
-			float64(cachedEnd),
-			float64(time.Now().UnixMilli()-fluxInterval.Milliseconds()),
-		),
-	)
-
-	// There are five cases to consider
-	// 1. Cached time range is a subset of the requested time range
-	// 2. Cached time range is a superset of the requested time range
-	// 3. Cached time range is a left overlap of the requested time range
-	// 4. Cached time range is a right overlap of the requested time range
-	// 5. Cached time range is a disjoint of the requested time range
-	if cachedStart >= start && cachedEnd <= end {
-		// Case 1: Cached time range is a subset of the requested time range
-		// Add misses for the left and right sides of the cached time range
-		misses = append(misses, missInterval{start: start, end: cachedStart - 1})
-		misses = append(misses, missInterval{start: cachedEnd + 1, end: end})
-	} else if cachedStart <= start && cachedEnd >= end {
-		// Case 2: Cached time range is a superset of the requested time range
-		// No misses
-	} else if cachedStart <= start && cachedEnd >= start {
-		// Case 3: Cached time range is a left overlap of the requested time range
-		// Add a miss for the left side of the cached time range
-		misses = append(misses, missInterval{start: cachedEnd + 1, end: end})
-	} else if cachedStart <= end && cachedEnd >= end {
-		// Case 4: Cached time range is a right overlap of the requested time range
-		// Add a miss for the right side of the cached time range
-		misses = append(misses, missInterval{start: start, end: cachedStart - 1})
-	} else {
-		// Case 5: Cached time range is a disjoint of the requested time range
-		// Add a miss for the entire requested time range
-		misses = append(misses, missInterval{start: start, end: end})
-	}
-
-	// remove the struts with start > end
-	var validMisses []missInterval
-	for idx := range misses {
-		miss := misses[idx]
-		if miss.start < miss.end {
-			validMisses = append(validMisses, miss)
-		}
-	}
-	return validMisses
-}
-
-// findMissingTimeRanges finds the missing time ranges in the cached data
-// and returns them as a list of misses
-func (q *querier) findMissingTimeRanges(start, end, step int64, cachedData []byte) (misses []missInterval) {
-	var cachedSeriesList []*v3.Series
-	if err := json.Unmarshal(cachedData, &cachedSeriesList); err != nil {
-		// In case of error, we return the entire range as a miss
-		return []missInterval{{start: start, end: end}}
-	}
-	return findMissingTimeRanges(start, end, cachedSeriesList, q.fluxInterval)
-}
-
 func labelsToString(labels map[string]string) string {
 	type label struct {
 		Key   string
@@ -267,11 +229,55 @@ func (q *querier) runBuilderQueries(ctx context.Context, params *v3.QueryRangePa
 
 	cacheKeys := q.keyGenerator.GenerateKeys(params)
 
+	// the formulas evaluated over the series of their operands wait for the
+	// operands, which are read even when they are disabled. The operands
+	// evaluated in the querier too are evaluated first.
+	formulas := make(map[string][]string)
+	operands := make(map[string]bool)
+	var addFormula func(queryName string) error
+	addFormula = func(queryName string) error {
+		if _, ok := formulas[queryName]; ok {
+			return nil
+		}
+		names, err := queryBuilder.FormulaOperands(params.CompositeQuery.BuilderQueries[queryName])
+		if err != nil {
+			return err
+		}
+		formulas[queryName] = names
+		for _, name := range names {
+			operands[name] = true
+			operand, ok := params.CompositeQuery.BuilderQueries[name]
+			if !ok || name == operand.Expression || !queryBuilder.EvaluateInQuerier(params.CompositeQuery, operand) {
+				continue
+			}
+			if err := addFormula(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for queryName, builderQuery := range params.CompositeQuery.BuilderQueries {
+		if builderQuery.Disabled || queryName == builderQuery.Expression ||
+			!queryBuilder.EvaluateInQuerier(params.CompositeQuery, builderQuery) {
+			continue
+		}
+		if err := addFormula(queryName); err != nil {
+			return nil, err, nil
+		}
+	}
+	order, err := queryBuilder.FormulaOrder(formulas)
+	if err != nil {
+		return nil, err, nil
+	}
+
 	ch := make(chan channelResult, len(params.CompositeQuery.BuilderQueries))
 	var wg sync.WaitGroup
 
 	for queryName, builderQuery := range params.CompositeQuery.BuilderQueries {
-		if builderQuery.Disabled {
+		if _, ok := formulas[queryName]; ok {
+			continue
+		}
+		if builderQuery.Disabled && !operands[queryName] {
 			continue
 		}
 		wg.Add(1)
@@ -289,19 +295,73 @@ func (q *querier) runBuilderQueries(ctx context.Context, params *v3.QueryRangePa
 	errQueriesByName := make(map[string]string)
 	var errs []error
 
+	seriesByName := make(map[string][]*v3.Series)
+	statsByName := make(map[string]*v3.QueryStats)
 	for result := range ch {
 		if result.Err != nil {
 			errs = append(errs, result.Err)
 			errQueriesByName[result.Name] = result.Err.Error()
 			continue
 		}
+		seriesByName[result.Name] = result.Series
+		statsByName[result.Name] = result.Stats
+		if params.CompositeQuery.BuilderQueries[result.Name].Disabled {
+			continue
+		}
 		results = append(results, &v3.Result{
 			QueryName: result.Name,
 			Series:    result.Series,
+			Stats:     result.Stats,
+		})
+	}
+
+	// the formulas with a failed operand are not evaluated
+	failed := make(map[string]bool)
+	for _, queryName := range order {
+		names := formulas[queryName]
+		for _, name := range names {
+			_, ok := errQueriesByName[name]
+			failed[queryName] = failed[queryName] || failed[name] || ok
+		}
+		if failed[queryName] {
+			continue
+		}
+		builderQuery := params.CompositeQuery.BuilderQueries[queryName]
+		series, err := evaluateFormula(builderQuery, names, seriesByName)
+		if err == nil {
+			series, err = applyFunctions(builderQuery.Functions, series, params.CompositeQuery.PanelType, params.Start, params.End, builderQuery.StepInterval)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			errQueriesByName[queryName] = err.Error()
+			failed[queryName] = true
+			continue
+		}
+		var stats *v3.QueryStats
+		if params.CollectStats {
+			// the formula reads nothing itself, it is as expensive as its operands
+			stats = &v3.QueryStats{}
+			for _, name := range names {
+				operandStats := statsByName[name]
+				stats.AddRead(operandStats.RowsRead, operandStats.BytesRead)
+				for _, query := range operandStats.Queries {
+					stats.AddQuery(query, 0)
+				}
+				stats.ElapsedMs += operandStats.ElapsedMs
+			}
+		}
+		seriesByName[queryName] = series
+		statsByName[queryName] = stats
+		if builderQuery.Disabled {
+			continue
+		}
+		results = append(results, &v3.Result{
+			QueryName: queryName,
+			Series:    series,
+			Stats:     stats,
 		})
 	}
 
-	var err error
 	if len(errs) > 0 {
 		err = fmt.Errorf("error in builder queries")
 	}
@@ -321,49 +381,11 @@ func (q *querier) runPromQueries(ctx context.Context, params *v3.QueryRangeParam
 		wg.Add(1)
 		go func(queryName string, promQuery *v3.PromQuery) {
 			defer wg.Done()
-			cacheKey := cacheKeys[queryName]
-			var cachedData []byte
-			// Ensure NoCache is not set and cache is not nil
-			if !params.NoCache && q.cache != nil {
-				data, retrieveStatus, err := q.cache.Retrieve(cacheKey, true)
-				zap.S().Debug("cache retrieve status", zap.String("status", retrieveStatus.String()))
-				if err == nil {
-					cachedData = data
-				}
-			}
-			misses := q.findMissingTimeRanges(params.Start, params.End, params.Step, cachedData)
-			missedSeries := make([]*v3.Series, 0)
-			cachedSeries := make([]*v3.Series, 0)
-			for _, miss := range misses {
-				query := metricsV3.BuildPromQuery(promQuery, params.Step, miss.start, miss.end)
-				series, err := q.execPromQuery(ctx, query)
-				if err != nil {
-					channelResults <- channelResult{Err: err, Name: queryName, Query: query.Query, Series: nil}
-					return
-				}
-				missedSeries = append(missedSeries, series...)
-			}
-			if err := json.Unmarshal(cachedData, &cachedSeries); err != nil && cachedData != nil {
-				// ideally we should not be getting an error here
-				zap.S().Error("error unmarshalling cached data", zap.Error(err))
-			}
-			mergedSeries := mergeSerieses(cachedSeries, missedSeries)
-
-			channelResults <- channelResult{Err: nil, Name: queryName, Query: promQuery.Query, Series: mergedSeries}
-
-			// Cache the seriesList for future queries
-			if len(missedSeries) > 0 && !params.NoCache && q.cache != nil {
-				mergedSeriesData, err := json.Marshal(mergedSeries)
-				if err != nil {
-					zap.S().Error("error marshalling merged series", zap.Error(err))
-					return
-				}
-				err = q.cache.Store(cacheKey, mergedSeriesData, time.Hour)
-				if err != nil {
-					zap.S().Error("error storing merged series", zap.Error(err))
-					return
-				}
-			}
+			ctx, stats := withQueryStats(ctx, params)
+			series, err := q.queryWithCache(ctx, params, queryName, cacheKeys[queryName], params.Step*1000, func(start, end int64) ([]*v3.Series, error) {
+				return q.execPromQuery(ctx, metricsV3.BuildPromQuery(promQuery, params.Step, start, end))
+			})
+			channelResults <- channelResult{Err: err, Name: queryName, Query: promQuery.Query, Series: series, Stats: stats}
 		}(queryName, promQuery)
 	}
 	wg.Wait()
@@ -382,6 +404,7 @@ func (q *querier) runPromQueries(ctx context.Context, params *v3.QueryRangeParam
 		results = append(results, &v3.Result{
 			QueryName: result.Name,
 			Series:    result.Series,
+			Stats:     result.Stats,
 		})
 	}
 
@@ -403,8 +426,9 @@ func (q *querier) runClickHouseQueries(ctx context.Context, params *v3.QueryRang
 		wg.Add(1)
 		go func(queryName string, clickHouseQuery *v3.ClickHouseQuery) {
 			defer wg.Done()
+			ctx, stats := withQueryStats(ctx, params)
 			series, err := q.execClickHouseQuery(ctx, clickHouseQuery.Query)
-			channelResults <- channelResult{Err: err, Name: queryName, Query: clickHouseQuery.Query, Series: series}
+			channelResults <- channelResult{Err: err, Name: queryName, Query: clickHouseQuery.Query, Series: series, Stats: stats}
 		}(queryName, clickHouseQuery)
 	}
 	wg.Wait()
@@ -423,6 +447,7 @@ func (q *querier) runClickHouseQueries(ctx context.Context, params *v3.QueryRang
 		results = append(results, &v3.Result{
 			QueryName: result.Name,
 			Series:    result.Series,
+			Stats:     result.Stats,
 		})
 	}
 
@@ -448,13 +473,14 @@ func (q *querier) runBuilderListQueries(ctx context.Context, params *v3.QueryRan
 		wg.Add(1)
 		go func(name, query string) {
 			defer wg.Done()
+			ctx, stats := withQueryStats(ctx, params)
 			rowList, err := q.reader.GetListResultV3(ctx, query)
 
 			if err != nil {
 				ch <- channelResult{Err: fmt.Errorf("error in query-%s: %v", name, err), Name: name, Query: query}
 				return
 			}
-			ch <- channelResult{List: rowList, Name: name, Query: query}
+			ch <- channelResult{List: rowList, Name: name, Query: query, Stats: stats}
 		}(name, query)
 	}
 
@@ -474,6 +500,8 @@ func (q *querier) runBuilderListQueries(ctx context.Context, params *v3.QueryRan
 		res = append(res, &v3.Result{
 			QueryName: r.Name,
 			List:      r.List,
+			Stats:     r.Stats,
+			Cursor:    listCursor(params.CompositeQuery.BuilderQueries[r.Name], r.List),
 		})
 	}
 	if len(errs) != 0 {
@@ -491,6 +519,10 @@ func (q *querier) QueryRange(ctx context.Context, params *v3.QueryRangeParamsV3,
 		case v3.QueryTypeBuilder:
 			if params.CompositeQuery.PanelType == v3.PanelTypeList || params.CompositeQuery.PanelType == v3.PanelTypeTrace {
 				results, err, errQueriesByName = q.runBuilderListQueries(ctx, params, keys)
+			} else if params.CompositeQuery.PanelType == v3.PanelTypePatterns {
+				results, err, errQueriesByName = q.runBuilderPatternQueries(ctx, params)
+			} else if params.CompositeQuery.PanelType == v3.PanelTypeCallGraph {
+				results, err, errQueriesByName = q.runBuilderCallGraphQueries(ctx, params, keys)
 			} else {
 				results, err, errQueriesByName = q.runBuilderQueries(ctx, params, keys)
 			}
@@ -506,5 +538,7 @@ func (q *querier) QueryRange(ctx context.Context, params *v3.QueryRangeParamsV3,
 }
 
 func (q *querier) QueriesExecuted() []string {
+	q.queriesMtx.Lock()
+	defer q.queriesMtx.Unlock()
 	return q.queriesExecuted
 }
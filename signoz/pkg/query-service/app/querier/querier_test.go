@@ -12,380 +12,90 @@ import (
 	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
 )
 
-func TestFindMissingTimeRangesZeroFreshNess(t *testing.T) {
-	// There are five scenarios:
-	// 1. Cached time range is a subset of the requested time range
-	// 2. Cached time range is a superset of the requested time range
-	// 3. Cached time range is a left overlap of the requested time range
-	// 4. Cached time range is a right overlap of the requested time range
-	// 5. Cached time range is a disjoint of the requested time range
+func TestFindMissingTimeRanges(t *testing.T) {
+	// buckets of one hour starting at 2023-01-30T21:00:00Z
+	var window int64 = 60 * 60 * 1000
+	var base int64 = 1675112400000
+
 	testCases := []struct {
 		name           string
 		requestedStart int64 // in milliseconds
 		requestedEnd   int64 // in milliseconds
-		cachedSeries   []*v3.Series
+		cachedBuckets  map[int64]*cachedBucket
 		expectedMiss   []missInterval
 	}{
 		{
-			name:           "cached time range is a subset of the requested time range",
-			requestedStart: 1675115596722,
-			requestedEnd:   1675115596722 + 180*60*1000,
-			cachedSeries: []*v3.Series{
-				{
-					Labels: map[string]string{
-						"__name__": "http_server_requests_seconds_count",
-					},
-					Points: []v3.Point{
-						{
-							Timestamp: 1675115596722 + 60*60*1000,
-							Value:     1,
-						},
-						{
-							Timestamp: 1675115596722 + 120*60*1000,
-							Value:     1,
-						},
-					},
-				},
-			},
+			name:           "nothing is cached",
+			requestedStart: base + 30*60*1000,
+			requestedEnd:   base + 3*window - 1,
+			cachedBuckets:  map[int64]*cachedBucket{},
 			expectedMiss: []missInterval{
-				{
-					start: 1675115596722,
-					end:   1675115596722 + 60*60*1000 - 1,
-				},
-				{
-					start: 1675115596722 + 120*60*1000 + 1,
-					end:   1675115596722 + 180*60*1000,
-				},
+				{start: base + 30*60*1000, end: base + 3*window - 1},
 			},
 		},
 		{
-			name:           "cached time range is a superset of the requested time range",
-			requestedStart: 1675115596722,
-			requestedEnd:   1675115596722 + 180*60*1000,
-			cachedSeries: []*v3.Series{
-				{
-					Labels: map[string]string{
-						"__name__": "http_server_requests_seconds_count",
-					},
-					Points: []v3.Point{
-						{
-							Timestamp: 1675115596722,
-							Value:     1,
-						},
-						{
-							Timestamp: 1675115596722 + 60*60*1000,
-							Value:     1,
-						},
-						{
-							Timestamp: 1675115596722 + 120*60*1000,
-							Value:     1,
-						},
-						{
-							Timestamp: 1675115596722 + 180*60*1000,
-							Value:     1,
-						},
-					},
-				},
+			name:           "requested time range is fully cached",
+			requestedStart: base + 30*60*1000,
+			requestedEnd:   base + 2*window - 1,
+			cachedBuckets: map[int64]*cachedBucket{
+				base:          {Start: base, End: base + window - 1},
+				base + window: {Start: base + window, End: base + 2*window - 1},
 			},
-			expectedMiss: []missInterval{},
+			expectedMiss: nil,
 		},
 		{
 			name:           "cached time range is a left overlap of the requested time range",
-			requestedStart: 1675115596722,
-			requestedEnd:   1675115596722 + 180*60*1000,
-			cachedSeries: []*v3.Series{
-				{
-					Labels: map[string]string{
-						"__name__": "http_server_requests_seconds_count",
-					},
-					Points: []v3.Point{
-						{
-							Timestamp: 1675115596722,
-							Value:     1,
-						},
-						{
-							Timestamp: 1675115596722 + 60*60*1000,
-							Value:     1,
-						},
-						{
-							Timestamp: 1675115596722 + 120*60*1000,
-							Value:     1,
-						},
-					},
-				},
+			requestedStart: base,
+			requestedEnd:   base + 2*window - 1,
+			cachedBuckets: map[int64]*cachedBucket{
+				base: {Start: base, End: base + 30*60*1000 - 1},
 			},
 			expectedMiss: []missInterval{
-				{
-					start: 1675115596722 + 120*60*1000 + 1,
-					end:   1675115596722 + 180*60*1000,
-				},
+				{start: base + 30*60*1000, end: base + 2*window - 1},
 			},
 		},
 		{
 			name:           "cached time range is a right overlap of the requested time range",
-			requestedStart: 1675115596722,
-			requestedEnd:   1675115596722 + 180*60*1000,
-			cachedSeries: []*v3.Series{
-				{
-					Labels: map[string]string{
-						"__name__": "http_server_requests_seconds_count",
-					},
-					Points: []v3.Point{
-						{
-							Timestamp: 1675115596722 + 60*60*1000,
-							Value:     1,
-						},
-						{
-							Timestamp: 1675115596722 + 120*60*1000,
-							Value:     1,
-						},
-						{
-							Timestamp: 1675115596722 + 180*60*1000,
-							Value:     1,
-						},
-					},
-				},
-			},
-			expectedMiss: []missInterval{
-				{
-					start: 1675115596722,
-					end:   1675115596722 + 60*60*1000 - 1,
-				},
-			},
-		},
-		{
-			name:           "cached time range is a disjoint of the requested time range",
-			requestedStart: 1675115596722,
-			requestedEnd:   1675115596722 + 180*60*1000,
-			cachedSeries: []*v3.Series{
-				{
-					Labels: map[string]string{
-						"__name__": "http_server_requests_seconds_count",
-					},
-					Points: []v3.Point{
-						{
-							Timestamp: 1675115596722 + 240*60*1000,
-							Value:     1,
-						},
-						{
-							Timestamp: 1675115596722 + 300*60*1000,
-							Value:     1,
-						},
-						{
-							Timestamp: 1675115596722 + 360*60*1000,
-							Value:     1,
-						},
-					},
-				},
+			requestedStart: base,
+			requestedEnd:   base + 2*window - 1,
+			cachedBuckets: map[int64]*cachedBucket{
+				base + window: {Start: base + window, End: base + 2*window - 1},
 			},
 			expectedMiss: []missInterval{
-				{
-					start: 1675115596722,
-					end:   1675115596722 + 180*60*1000,
-				},
+				{start: base, end: base + window - 1},
 			},
 		},
-	}
-
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			misses := findMissingTimeRanges(tc.requestedStart, tc.requestedEnd, tc.cachedSeries, 0*time.Minute)
-			if len(misses) != len(tc.expectedMiss) {
-				t.Errorf("expected %d misses, got %d", len(tc.expectedMiss), len(misses))
-			}
-			for i, miss := range misses {
-				if miss.start != tc.expectedMiss[i].start {
-					t.Errorf("expected start %d, got %d", tc.expectedMiss[i].start, miss.start)
-				}
-				if miss.end != tc.expectedMiss[i].end {
-					t.Errorf("expected end %d, got %d", tc.expectedMiss[i].end, miss.end)
-				}
-			}
-		})
-	}
-}
-
-func TestFindMissingTimeRangesWithFluxInterval(t *testing.T) {
-
-	testCases := []struct {
-		name           string
-		requestedStart int64
-		requestedEnd   int64
-		cachedSeries   []*v3.Series
-		fluxInterval   time.Duration
-		expectedMiss   []missInterval
-	}{
 		{
 			name:           "cached time range is a subset of the requested time range",
-			requestedStart: 1675115596722,
-			requestedEnd:   1675115596722 + 180*60*1000,
-			cachedSeries: []*v3.Series{
-				{
-					Labels: map[string]string{
-						"__name__": "http_server_requests_seconds_count",
-					},
-					Points: []v3.Point{
-						{
-							Timestamp: 1675115596722 + 60*60*1000,
-							Value:     1,
-						},
-						{
-							Timestamp: 1675115596722 + 120*60*1000,
-							Value:     1,
-						},
-					},
-				},
-			},
-			fluxInterval: 5 * time.Minute,
-			expectedMiss: []missInterval{
-				{
-					start: 1675115596722,
-					end:   1675115596722 + 60*60*1000 - 1,
-				},
-				{
-					start: 1675115596722 + 120*60*1000 + 1,
-					end:   1675115596722 + 180*60*1000,
-				},
-			},
-		},
-		{
-			name:           "cached time range is a superset of the requested time range",
-			requestedStart: 1675115596722,
-			requestedEnd:   1675115596722 + 180*60*1000,
-			cachedSeries: []*v3.Series{
-				{
-					Labels: map[string]string{
-						"__name__": "http_server_requests_seconds_count",
-					},
-					Points: []v3.Point{
-						{
-							Timestamp: 1675115596722,
-							Value:     1,
-						},
-						{
-							Timestamp: 1675115596722 + 60*60*1000,
-							Value:     1,
-						},
-						{
-							Timestamp: 1675115596722 + 120*60*1000,
-							Value:     1,
-						},
-						{
-							Timestamp: 1675115596722 + 180*60*1000,
-							Value:     1,
-						},
-					},
-				},
-			},
-			fluxInterval: 5 * time.Minute,
-			expectedMiss: []missInterval{},
-		},
-		{
-			name:           "cache time range is a left overlap of the requested time range",
-			requestedStart: 1675115596722,
-			requestedEnd:   1675115596722 + 180*60*1000,
-			cachedSeries: []*v3.Series{
-				{
-					Labels: map[string]string{
-						"__name__": "http_server_requests_seconds_count",
-					},
-					Points: []v3.Point{
-						{
-							Timestamp: 1675115596722,
-							Value:     1,
-						},
-						{
-							Timestamp: 1675115596722 + 60*60*1000,
-							Value:     1,
-						},
-						{
-							Timestamp: 1675115596722 + 120*60*1000,
-							Value:     1,
-						},
-					},
-				},
-			},
-			fluxInterval: 5 * time.Minute,
-			expectedMiss: []missInterval{
-				{
-					start: 1675115596722 + 120*60*1000 + 1,
-					end:   1675115596722 + 180*60*1000,
-				},
-			},
-		},
-		{
-			name:           "cache time range is a right overlap of the requested time range",
-			requestedStart: 1675115596722,
-			requestedEnd:   1675115596722 + 180*60*1000,
-			cachedSeries: []*v3.Series{
-				{
-					Labels: map[string]string{
-						"__name__": "http_server_requests_seconds_count",
-					},
-					Points: []v3.Point{
-						{
-							Timestamp: 1675115596722 + 60*60*1000,
-							Value:     1,
-						},
-						{
-							Timestamp: 1675115596722 + 120*60*1000,
-							Value:     1,
-						},
-						{
-							Timestamp: 1675115596722 + 180*60*1000,
-							Value:     1,
-						},
-					},
-				},
+			requestedStart: base,
+			requestedEnd:   base + 3*window - 1,
+			cachedBuckets: map[int64]*cachedBucket{
+				base + window: {Start: base + window, End: base + 2*window - 1},
 			},
-			fluxInterval: 5 * time.Minute,
 			expectedMiss: []missInterval{
-				{
-					start: 1675115596722,
-					end:   1675115596722 + 60*60*1000 - 1,
-				},
+				{start: base, end: base + window - 1},
+				{start: base + 2*window, end: base + 3*window - 1},
 			},
 		},
 		{
-			name:           "cache time range is a disjoint of the requested time range",
-			requestedStart: 1675115596722,
-			requestedEnd:   1675115596722 + 180*60*1000,
-			cachedSeries: []*v3.Series{
-				{
-					Labels: map[string]string{
-						"__name__": "http_server_requests_seconds_count",
-					},
-					Points: []v3.Point{
-						{
-							Timestamp: 1675115596722 + 240*60*1000,
-							Value:     1,
-						},
-						{
-							Timestamp: 1675115596722 + 300*60*1000,
-							Value:     1,
-						},
-						{
-							Timestamp: 1675115596722 + 360*60*1000,
-							Value:     1,
-						},
-					},
-				},
+			name:           "cached time range is disjoint from the requested time range in the same bucket",
+			requestedStart: base,
+			requestedEnd:   base + 10*60*1000 - 1,
+			cachedBuckets: map[int64]*cachedBucket{
+				base: {Start: base + 50*60*1000, End: base + window - 1},
 			},
-			fluxInterval: 5 * time.Minute,
+			// the miss is extended up to the cached part to keep the bucket contiguous
 			expectedMiss: []missInterval{
-				{
-					start: 1675115596722,
-					end:   1675115596722 + 180*60*1000,
-				},
+				{start: base, end: base + 50*60*1000 - 1},
 			},
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			misses := findMissingTimeRanges(tc.requestedStart, tc.requestedEnd, tc.cachedSeries, tc.fluxInterval)
+			misses := findMissingTimeRanges(tc.requestedStart, tc.requestedEnd, window, tc.cachedBuckets)
 			if len(misses) != len(tc.expectedMiss) {
-				t.Errorf("expected %d misses, got %d", len(tc.expectedMiss), len(misses))
+				t.Fatalf("expected %d misses, got %d", len(tc.expectedMiss), len(misses))
 			}
 			for i, miss := range misses {
 				if miss.start != tc.expectedMiss[i].start {
@@ -399,6 +109,61 @@ func TestFindMissingTimeRangesWithFluxInterval(t *testing.T) {
 	}
 }
 
+func TestQueryWithCacheFluxInterval(t *testing.T) {
+	var step int64 = 60 * 1000
+	fluxInterval := 5 * time.Minute
+	end := time.Now().UnixMilli()
+	start := end - 2*time.Hour.Milliseconds()
+	params := &v3.QueryRangeParamsV3{Start: start, End: end}
+
+	q := NewQuerier(QuerierOptions{
+		Cache:        inmemory.New(&inmemory.Options{TTL: 5 * time.Minute, CleanupInterval: 10 * time.Minute}),
+		FluxInterval: fluxInterval,
+		KeyGenerator: queryBuilder.NewKeyGenerator(),
+		TestingMode:  true,
+	}).(*querier)
+
+	var fetched []missInterval
+	fetch := func(start, end int64) ([]*v3.Series, error) {
+		fetched = append(fetched, missInterval{start: start, end: end})
+		var points []v3.Point
+		for ts := start; ts <= end; ts += step {
+			points = append(points, v3.Point{Timestamp: ts, Value: 1})
+		}
+		return []*v3.Series{{Labels: map[string]string{"service_name": "test"}, Points: points}}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		series, err := q.queryWithCache(context.Background(), params, "A", "test-key", step, fetch)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		if len(series) != 1 || len(series[0].Points) != 121 {
+			t.Fatalf("expected 1 series with 121 points, got %v", series)
+		}
+	}
+
+	if len(fetched) != 2 {
+		t.Fatalf("expected 2 fetches, got %d", len(fetched))
+	}
+	// the second query only fetches the data that was in flux
+	fluxStart := alignDown(end-fluxInterval.Milliseconds(), step)
+	if fetched[1].start < fluxStart-step || fetched[1].start > fluxStart+step {
+		t.Errorf("expected the second fetch to start around %d, got %d", fluxStart, fetched[1].start)
+	}
+
+	entries := q.ListCachedQueries(v3.QueryCacheFilter{QueryName: "A"})
+	if len(entries) != 1 || entries[0].QueryHash != queryHash("test-key") {
+		t.Fatalf("expected the query to be listed, got %v", entries)
+	}
+	if evicted := q.EvictCachedQueries(v3.QueryCacheFilter{QueryHash: queryHash("test-key")}); evicted == 0 {
+		t.Errorf("expected cache entries to be evicted")
+	}
+	if entries := q.ListCachedQueries(v3.QueryCacheFilter{}); len(entries) != 0 {
+		t.Errorf("expected no cache entries, got %v", entries)
+	}
+}
+
 func TestQueryRange(t *testing.T) {
 	params := []*v3.QueryRangeParamsV3{
 		{
@@ -465,7 +230,6 @@ func TestQueryRange(t *testing.T) {
 				},
 			},
 		},
-		// No caching for traces & logs yet
 		{
 			Start: 1675115596722,
 			End:   1675115596722 + 120*60*1000,
@@ -532,6 +296,66 @@ func TestQueryRange(t *testing.T) {
 				},
 			},
 		},
+		{
+			Start: 1675115596722,
+			End:   1675115596722 + 120*60*1000,
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType: v3.QueryTypeBuilder,
+				PanelType: v3.PanelTypeGraph,
+				BuilderQueries: map[string]*v3.BuilderQuery{
+					"A": {
+						QueryName:    "A",
+						StepInterval: 60,
+						DataSource:   v3.DataSourceLogs,
+						Filters: &v3.FilterSet{
+							Operator: "AND",
+							Items: []v3.FilterItem{
+								{
+									Key:      v3.AttributeKey{Key: "method", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag},
+									Operator: "=",
+									Value:    "GET",
+								},
+							},
+						},
+						GroupBy: []v3.AttributeKey{
+							{Key: "service_name", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeResource},
+						},
+						AggregateOperator: v3.AggregateOperatorCount,
+						Expression:        "A",
+					},
+				},
+			},
+		},
+		{
+			Start: 1675115596722 + 60*60*1000,
+			End:   1675115596722 + 180*60*1000,
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType: v3.QueryTypeBuilder,
+				PanelType: v3.PanelTypeGraph,
+				BuilderQueries: map[string]*v3.BuilderQuery{
+					"A": {
+						QueryName:    "A",
+						StepInterval: 60,
+						DataSource:   v3.DataSourceLogs,
+						Filters: &v3.FilterSet{
+							Operator: "AND",
+							Items: []v3.FilterItem{
+								{
+									Key:      v3.AttributeKey{Key: "method", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag},
+									Operator: "=",
+									Value:    "GET",
+								},
+							},
+						},
+						GroupBy: []v3.AttributeKey{
+							{Key: "service_name", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeResource},
+						},
+						AggregateOperator: v3.AggregateOperatorCount,
+						Expression:        "A",
+					},
+				},
+			},
+		},
 	}
 	cache := inmemory.New(&inmemory.Options{TTL: 5 * time.Minute, CleanupInterval: 10 * time.Minute})
 	opts := QuerierOptions{
@@ -559,9 +383,11 @@ func TestQueryRange(t *testing.T) {
 	q := NewQuerier(opts)
 	expectedTimeRangeInQueryString := []string{
 		fmt.Sprintf("timestamp_ms >= %d AND timestamp_ms <= %d", 1675115580000, 1675115580000+120*60*1000),
-		fmt.Sprintf("timestamp_ms >= %d AND timestamp_ms <= %d", 1675115580000+120*60*1000, 1675115580000+180*60*1000),
+		fmt.Sprintf("timestamp_ms >= %d AND timestamp_ms <= %d", 1675115580000+121*60*1000, 1675115580000+180*60*1000),
 		fmt.Sprintf("timestamp >= '%d' AND timestamp <= '%d'", 1675115580000*1000000, (1675115580000+120*60*1000)*int64(1000000)),
-		fmt.Sprintf("timestamp >= '%d' AND timestamp <= '%d'", (1675115580000+60*60*1000)*int64(1000000), (1675115580000+180*60*1000)*int64(1000000)),
+		fmt.Sprintf("timestamp >= '%d' AND timestamp <= '%d'", (1675115580000+121*60*1000)*int64(1000000), (1675115580000+180*60*1000)*int64(1000000)),
+		fmt.Sprintf("timestamp >= %d AND timestamp <= %d", 1675115580000*1000000, (1675115580000+120*60*1000)*int64(1000000)),
+		fmt.Sprintf("timestamp >= %d AND timestamp <= %d", (1675115580000+121*60*1000)*int64(1000000), (1675115580000+180*60*1000)*int64(1000000)),
 	}
 
 	for i, param := range params {
@@ -687,3 +513,95 @@ func TestQueryRangeValueType(t *testing.T) {
 		}
 	}
 }
+
+func TestQueryWithCacheStats(t *testing.T) {
+	var step int64 = 60 * 1000
+	end := alignDown(time.Now().Add(-time.Hour).UnixMilli(), step)
+	start := end - 2*time.Hour.Milliseconds()
+
+	q := NewQuerier(QuerierOptions{
+		Cache:        inmemory.New(&inmemory.Options{TTL: 5 * time.Minute, CleanupInterval: 10 * time.Minute}),
+		KeyGenerator: queryBuilder.NewKeyGenerator(),
+		TestingMode:  true,
+	}).(*querier)
+
+	fetch := func(start, end int64) ([]*v3.Series, error) {
+		return []*v3.Series{{Labels: map[string]string{"service_name": "test"}, Points: []v3.Point{{Timestamp: start, Value: 1}}}}, nil
+	}
+
+	expectedStatus := []v3.CacheStatus{v3.CacheStatusMiss, v3.CacheStatusHit, v3.CacheStatusPartial}
+	ranges := [][2]int64{{start, end}, {start, end}, {start, end + time.Hour.Milliseconds()}}
+	for idx, r := range ranges {
+		params := &v3.QueryRangeParamsV3{Start: r[0], End: r[1], CollectStats: true}
+		ctx, stats := withQueryStats(context.Background(), params)
+		if _, err := q.queryWithCache(ctx, params, "A", "test-key", step, fetch); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		if stats.CacheStatus != expectedStatus[idx] {
+			t.Errorf("expected cache status %s for query %d, got %s", expectedStatus[idx], idx, stats.CacheStatus)
+		}
+	}
+
+	params := &v3.QueryRangeParamsV3{Start: start, End: end + time.Hour.Milliseconds(), CollectStats: true}
+	ctx, stats := withQueryStats(context.Background(), params)
+	if _, err := q.queryWithCache(ctx, params, "A", "test-key", step, fetch); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if len(stats.CachedRanges) != 1 || stats.CachedRanges[0].Start != start || stats.CachedRanges[0].End < end {
+		t.Errorf("expected the first two hours to be cached, got %v", stats.CachedRanges)
+	}
+
+	// the stats are only collected when asked for
+	if _, stats := withQueryStats(context.Background(), &v3.QueryRangeParamsV3{}); stats != nil {
+		t.Errorf("expected no stats, got %v", stats)
+	}
+}
+
+func TestCacheIndexShared(t *testing.T) {
+	var step int64 = 60 * 1000
+	end := alignDown(time.Now().Add(-time.Hour).UnixMilli(), step)
+	start := end - 2*time.Hour.Milliseconds()
+
+	// the query services sharing a cache share its index
+	c := inmemory.New(&inmemory.Options{TTL: 5 * time.Minute, CleanupInterval: 10 * time.Minute})
+	newQuerier := func() *querier {
+		return NewQuerier(QuerierOptions{
+			Cache:        c,
+			KeyGenerator: queryBuilder.NewKeyGenerator(),
+			TestingMode:  true,
+		}).(*querier)
+	}
+	first, second := newQuerier(), newQuerier()
+
+	fetch := func(start, end int64) ([]*v3.Series, error) {
+		return []*v3.Series{{Labels: map[string]string{"service_name": "test"}, Points: []v3.Point{{Timestamp: start, Value: 1}}}}, nil
+	}
+	params := &v3.QueryRangeParamsV3{Start: start, End: end, DashboardID: "dashboard"}
+	if _, err := first.queryWithCache(context.Background(), params, "A", "dashboard-key", step, fetch); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	params = &v3.QueryRangeParamsV3{Start: start, End: end}
+	if _, err := second.queryWithCache(context.Background(), params, "B", "other-key", step, fetch); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	if entries := first.ListCachedQueries(v3.QueryCacheFilter{}); len(entries) != 2 {
+		t.Fatalf("expected both queries to be listed, got %v", entries)
+	}
+	entries := second.ListCachedQueries(v3.QueryCacheFilter{DashboardID: "dashboard"})
+	if len(entries) != 1 || entries[0].QueryHash != queryHash("dashboard-key") {
+		t.Fatalf("expected the dashboard query to be listed, got %v", entries)
+	}
+
+	if evicted := second.EvictCachedQueries(v3.QueryCacheFilter{DashboardID: "dashboard"}); evicted == 0 {
+		t.Errorf("expected cache entries to be evicted")
+	}
+	bucketStart := start - start%bucketWindow(step)
+	if data, _, _ := c.Retrieve(bucketCacheKey(queryHash("dashboard-key"), bucketStart), true); data != nil {
+		t.Errorf("expected the buckets of the dashboard query to be removed")
+	}
+	entries = first.ListCachedQueries(v3.QueryCacheFilter{})
+	if len(entries) != 1 || entries[0].QueryHash != queryHash("other-key") {
+		t.Errorf("expected the other query to be left, got %v", entries)
+	}
+}
@@ -1,7 +1,9 @@
 package queryBuilder
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/SigNoz/govaluate"
@@ -93,7 +95,6 @@ func expressionToQuery(
 	expression *govaluate.EvaluableExpression,
 	queryName string,
 ) (string, error) {
-	var formulaQuery string
 	variables := unique(expression.Vars())
 
 	var modified []govaluate.ExpressionToken
@@ -109,19 +110,27 @@ func expressionToQuery(
 	// err should be nil here since the expression is already validated
 	formula, _ := govaluate.NewEvaluableExpressionFromTokens(modified)
 
+	builderQuery := qp.CompositeQuery.BuilderQueries[queryName]
+
 	var formulaSubQuery string
 	var joinUsing string
 	var prevVar string
+	var prevColumns map[string]string
 	for idx, variable := range variables {
 		query := varToQuery[variable]
+		// the labels of the operand are matched by their name in the formula,
+		// columns maps them back to the columns of the operand query
 		groupTags := []string{}
+		columns := map[string]string{"ts": "ts"}
 		for _, tag := range qp.CompositeQuery.BuilderQueries[variable].GroupBy {
-			groupTags = append(groupTags, tag.Key)
+			label := builderQuery.OperandLabel(variable, tag.Key)
+			groupTags = append(groupTags, label)
+			columns[label] = tag.Key
 		}
 		groupTags = append(groupTags, "ts")
 		if joinUsing == "" {
 			for _, tag := range groupTags {
-				joinUsing += fmt.Sprintf("%s.%s as %s, ", variable, tag, tag)
+				joinUsing += fmt.Sprintf("%s.%s as %s, ", variable, columns[tag], tag)
 			}
 			joinUsing = strings.TrimSuffix(joinUsing, ", ")
 		}
@@ -129,7 +138,11 @@ func expressionToQuery(
 		if idx > 0 {
 			formulaSubQuery += " ON "
 			for _, tag := range groupTags {
-				formulaSubQuery += fmt.Sprintf("%s.%s = %s.%s AND ", prevVar, tag, variable, tag)
+				prevColumn, ok := prevColumns[tag]
+				if !ok {
+					prevColumn = tag
+				}
+				formulaSubQuery += fmt.Sprintf("%s.%s = %s.%s AND ", prevVar, prevColumn, variable, columns[tag])
 			}
 			formulaSubQuery = strings.TrimSuffix(formulaSubQuery, " AND ")
 		}
@@ -137,40 +150,166 @@ func expressionToQuery(
 			formulaSubQuery += " INNER JOIN "
 		}
 		prevVar = variable
+		prevColumns = columns
 	}
-	formulaQuery = fmt.Sprintf("SELECT %s, %s as value FROM ", joinUsing, formula.ExpressionString()) + formulaSubQuery
-	if len(qp.CompositeQuery.BuilderQueries[queryName].Having) > 0 {
+	sql := fmt.Sprintf("SELECT %s, %s as value FROM ", joinUsing, formula.ExpressionString()) + formulaSubQuery
+	if len(builderQuery.Having) > 0 {
 		conditions := []string{}
-		for _, having := range qp.CompositeQuery.BuilderQueries[queryName].Having {
+		for _, having := range builderQuery.Having {
 			conditions = append(conditions, fmt.Sprintf("%s %s %v", "value", having.Operator, having.Value))
 		}
 		havingClause := " HAVING " + strings.Join(conditions, " AND ")
-		formulaQuery += havingClause
+		sql += havingClause
 	}
-	return formulaQuery, nil
+	return sql, nil
 }
 
-func (qb *QueryBuilder) PrepareLiveTailQuery(params *v3.QueryRangeParamsV3) (string, error) {
-	var queryStr string
-	var err error
-	compositeQuery := params.CompositeQuery
+// FormulaOperands returns the names of the queries the formula is made of
+func FormulaOperands(formula *v3.BuilderQuery) ([]string, error) {
+	expression, err := govaluate.NewEvaluableExpressionWithFunctions(formula.Expression, EvalFuncs)
+	if err != nil {
+		return nil, err
+	}
+	return unique(expression.Vars()), nil
+}
 
-	if compositeQuery != nil {
-		// There can only be a signle query and there is no concept of disabling queries
-		if len(compositeQuery.BuilderQueries) != 1 {
-			return "", fmt.Errorf("live tail is only supported for single query")
+// FormulaOrder returns the formulas in an order where the operands evaluated
+// as formulas come before the formulas using them
+func FormulaOrder(formulas map[string][]string) ([]string, error) {
+	names := make([]string, 0, len(formulas))
+	for name := range formulas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	order := make([]string, 0, len(formulas))
+	// visiting is true while the operands of the formula are visited and
+	// false once the formula is ordered
+	visiting := make(map[string]bool)
+	var visit func(name string) error
+	visit = func(name string) error {
+		operands, ok := formulas[name]
+		if !ok {
+			return nil
 		}
-		for queryName, query := range compositeQuery.BuilderQueries {
-			if query.Expression == queryName {
-				queryStr, err = qb.options.BuildLogQuery(params.Start, params.End, compositeQuery.QueryType, compositeQuery.PanelType, query, logsV3.Options{IsLivetailQuery: true})
-				if err != nil {
-					return "", err
-				}
+		if inProgress, seen := visiting[name]; seen {
+			if inProgress {
+				return fmt.Errorf("formula %s depends on itself", name)
+			}
+			return nil
+		}
+		visiting[name] = true
+		for _, operand := range operands {
+			if err := visit(operand); err != nil {
+				return err
+			}
+		}
+		visiting[name] = false
+		order = append(order, name)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// ValidateFormula checks that the operands of the formula can be matched with
+// each other, the operands are expected to exist
+func ValidateFormula(cq *v3.CompositeQuery, formula *v3.BuilderQuery) error {
+	operands, err := FormulaOperands(formula)
+	if err != nil {
+		return err
+	}
+
+	isOperand := make(map[string]bool, len(operands))
+	for _, operand := range operands {
+		isOperand[operand] = true
+	}
+	for operand, mapping := range formula.LabelMapping {
+		query, ok := cq.BuilderQueries[operand]
+		if !ok || !isOperand[operand] {
+			return fmt.Errorf("label mapping of %s, which is not an operand of %s", operand, formula.QueryName)
+		}
+		for label := range mapping {
+			var grouped bool
+			for _, groupBy := range query.GroupBy {
+				grouped = grouped || groupBy.Key == label
+			}
+			if !grouped {
+				return fmt.Errorf("label mapping of %s: %s is not grouped by", operand, label)
 			}
 		}
+	}
+
+	// the formulas using formulas are evaluated over their series
+	for _, operand := range operands {
+		if query, ok := cq.BuilderQueries[operand]; ok && query.Expression != operand && formula.EvaluateIn == v3.FormulaEvaluationClickHouse {
+			return fmt.Errorf("%s uses the formula %s and cannot be evaluated in clickhouse", formula.QueryName, operand)
+		}
+	}
+
+	// the points of the operands are matched by their timestamps, the
+	// operands of the formulas used as operands included
+	var first *v3.BuilderQuery
+	for _, query := range baseOperands(cq, formula, map[string]bool{}) {
+		if first == nil {
+			first = query
+			continue
+		}
+		if query.StepInterval != first.StepInterval {
+			return fmt.Errorf("operands of %s must have the same step interval", formula.QueryName)
+		}
+	}
+	return nil
+}
+
+// baseOperands returns the queries the formula is made of, following the
+// formulas used as operands
+func baseOperands(cq *v3.CompositeQuery, formula *v3.BuilderQuery, seen map[string]bool) []*v3.BuilderQuery {
+	operands, err := FormulaOperands(formula)
+	if err != nil {
+		return nil
+	}
+	var queries []*v3.BuilderQuery
+	for _, operand := range operands {
+		query, ok := cq.BuilderQueries[operand]
+		if !ok || seen[operand] {
+			continue
+		}
+		seen[operand] = true
+		if query.Expression == operand {
+			queries = append(queries, query)
+			continue
+		}
+		queries = append(queries, baseOperands(cq, query, seen)...)
+	}
+	return queries
+}
 
+// EvaluateInQuerier tells if the formula is evaluated over the series of its
+// operands by the querier rather than by clickhouse. The functions of the
+// operands are applied on their series, so the formula cannot be joined in
+// clickhouse when an operand has functions or is a formula.
+func EvaluateInQuerier(cq *v3.CompositeQuery, formula *v3.BuilderQuery) bool {
+	switch formula.EvaluateIn {
+	case v3.FormulaEvaluationQuerier:
+		return true
+	case v3.FormulaEvaluationClickHouse:
+		return false
+	}
+	operands, err := FormulaOperands(formula)
+	if err != nil {
+		return false
+	}
+	for _, operand := range operands {
+		if query, ok := cq.BuilderQueries[operand]; ok && (len(query.Functions) > 0 || query.Expression != operand) {
+			return true
+		}
 	}
-	return queryStr, nil
+	return false
 }
 
 func (qb *QueryBuilder) PrepareQueries(params *v3.QueryRangeParamsV3, args ...interface{}) (map[string]string, error) {
@@ -292,16 +431,33 @@ func expressionToKey(expression *govaluate.EvaluableExpression, keys map[string]
 	return formula.ExpressionString()
 }
 
-func isMetricExpression(expression *govaluate.EvaluableExpression, params *v3.QueryRangeParamsV3) bool {
+// isCacheableExpression returns true if every query referenced by the expression
+// has a cache key, i.e the expression result can be cached and extended
+// in the same way as its sub-queries
+func isCacheableExpression(expression *govaluate.EvaluableExpression, keys map[string]string) bool {
 	variables := unique(expression.Vars())
 	for _, variable := range variables {
-		if params.CompositeQuery.BuilderQueries[variable].DataSource != v3.DataSourceMetrics {
+		if _, ok := keys[variable]; !ok {
 			return false
 		}
 	}
 	return true
 }
 
+// isCacheableQuery returns true if the result of the builder query over a time range
+// is the union of its results over sub ranges. Logs and traces queries with a group by limit
+// pick the top groups for the requested range, which changes as the range moves.
+func isCacheableQuery(query *v3.BuilderQuery) bool {
+	switch query.DataSource {
+	case v3.DataSourceMetrics:
+		return true
+	case v3.DataSourceLogs, v3.DataSourceTraces:
+		return !(query.Limit > 0 && len(query.GroupBy) > 0)
+	default:
+		return false
+	}
+}
+
 func (c *cacheKeyGenerator) GenerateKeys(params *v3.QueryRangeParamsV3) map[string]string {
 	keys := make(map[string]string)
 
@@ -320,7 +476,7 @@ func (c *cacheKeyGenerator) GenerateKeys(params *v3.QueryRangeParamsV3) map[stri
 
 	// Build keys for each builder query
 	for queryName, query := range params.CompositeQuery.BuilderQueries {
-		if query.Expression == queryName && query.DataSource == v3.DataSourceMetrics {
+		if query.Expression == queryName && isCacheableQuery(query) {
 			var parts []string
 
 			// We need to build uniqe cache query for BuilderQuery
@@ -351,6 +507,24 @@ func (c *cacheKeyGenerator) GenerateKeys(params *v3.QueryRangeParamsV3) map[stri
 				}
 			}
 
+			if len(query.OrderBy) > 0 {
+				for idx, orderBy := range query.OrderBy {
+					parts = append(parts, fmt.Sprintf("orderBy-%d=%s", idx, orderBy.CacheKey()))
+				}
+			}
+
+			if query.ReduceTo != "" {
+				parts = append(parts, fmt.Sprintf("reduceTo=%s", query.ReduceTo))
+			}
+
+			if query.Limit > 0 {
+				parts = append(parts, fmt.Sprintf("limit=%d", query.Limit))
+			}
+
+			if query.TimeShift != 0 {
+				parts = append(parts, fmt.Sprintf("timeShift=%d", query.TimeShift))
+			}
+
 			key := strings.Join(parts, "&")
 			keys[queryName] = key
 		}
@@ -361,11 +535,16 @@ func (c *cacheKeyGenerator) GenerateKeys(params *v3.QueryRangeParamsV3) map[stri
 		if query.Expression != query.QueryName {
 			expression, _ := govaluate.NewEvaluableExpressionWithFunctions(query.Expression, EvalFuncs)
 
-			if !isMetricExpression(expression, params) {
+			if !isCacheableExpression(expression, keys) {
 				continue
 			}
 
 			expressionCacheKey := expressionToKey(expression, keys)
+			// the label mapping renames the labels of the formula series
+			if len(query.LabelMapping) > 0 {
+				mapping, _ := json.Marshal(query.LabelMapping)
+				expressionCacheKey += fmt.Sprintf("&labelMapping=%s", mapping)
+			}
 			keys[query.QueryName] = expressionCacheKey
 		}
 	}
@@ -5,7 +5,9 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	logsV3 "go.signoz.io/signoz/pkg/query-service/app/logs/v3"
 	metricsv3 "go.signoz.io/signoz/pkg/query-service/app/metrics/v3"
+	tracesV3 "go.signoz.io/signoz/pkg/query-service/app/traces/v3"
 	"go.signoz.io/signoz/pkg/query-service/featureManager"
 	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
 )
@@ -371,3 +373,179 @@ func TestDeltaQueryBuilder(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildQueryWithTimeShift(t *testing.T) {
+	q := &v3.QueryRangeParamsV3{
+		Start: 1651078382000,
+		End:   1651081982000,
+		CompositeQuery: &v3.CompositeQuery{
+			QueryType: v3.QueryTypeBuilder,
+			PanelType: v3.PanelTypeGraph,
+			BuilderQueries: map[string]*v3.BuilderQuery{
+				"A": {
+					QueryName:          "A",
+					StepInterval:       60,
+					DataSource:         v3.DataSourceMetrics,
+					AggregateAttribute: v3.AttributeKey{Key: "signoz_calls_total"},
+					AggregateOperator:  v3.AggregateOperatorSumRate,
+					Expression:         "A",
+				},
+				"A_1w": {
+					QueryName:          "A_1w",
+					StepInterval:       60,
+					DataSource:         v3.DataSourceMetrics,
+					AggregateAttribute: v3.AttributeKey{Key: "signoz_calls_total"},
+					AggregateOperator:  v3.AggregateOperatorSumRate,
+					Expression:         "A_1w",
+					TimeShift:          7 * 24 * 3600,
+				},
+				"F1": {
+					QueryName:  "F1",
+					Expression: "A / A_1w",
+				},
+			},
+		},
+	}
+	qbOptions := QueryBuilderOptions{
+		BuildMetricQuery: metricsv3.PrepareMetricQuery,
+	}
+	qb := NewQueryBuilder(qbOptions, featureManager.StartManager())
+
+	queries, err := qb.PrepareQueries(q)
+	require.NoError(t, err)
+
+	require.Contains(t, queries["A"], "timestamp_ms >= 1651078380000 AND timestamp_ms <= 1651081980000")
+	require.NotContains(t, queries["A"], "REPLACE")
+
+	// the shifted query reads the week before and moves the points forward
+	require.Contains(t, queries["A_1w"], "timestamp_ms >= 1650473580000 AND timestamp_ms <= 1650477180000")
+	require.True(t, strings.HasPrefix(queries["A_1w"], "SELECT * REPLACE (toStartOfInterval(ts + INTERVAL 604800 SECOND, INTERVAL 60 SECOND) AS ts) FROM ("))
+
+	require.Contains(t, queries["F1"], "SELECT A.ts as ts, A.value / A_1w.value")
+	require.Contains(t, queries["F1"], "ON A.ts = A_1w.ts")
+
+	keys := NewKeyGenerator().GenerateKeys(q)
+	require.NotEqual(t, keys["A"], keys["A_1w"])
+}
+
+func TestBuildCrossSignalFormula(t *testing.T) {
+	q := &v3.QueryRangeParamsV3{
+		Start: 1651078382000,
+		End:   1651081982000,
+		CompositeQuery: &v3.CompositeQuery{
+			QueryType: v3.QueryTypeBuilder,
+			PanelType: v3.PanelTypeGraph,
+			BuilderQueries: map[string]*v3.BuilderQuery{
+				"A": {
+					QueryName:         "A",
+					StepInterval:      60,
+					DataSource:        v3.DataSourceLogs,
+					AggregateOperator: v3.AggregateOperatorCount,
+					GroupBy:           []v3.AttributeKey{{Key: "service_name", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeResource}},
+					Expression:        "A",
+				},
+				"B": {
+					QueryName:         "B",
+					StepInterval:      60,
+					DataSource:        v3.DataSourceTraces,
+					AggregateOperator: v3.AggregateOperatorCount,
+					GroupBy:           []v3.AttributeKey{{Key: "serviceName", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag, IsColumn: true}},
+					Expression:        "B",
+				},
+				"F1": {
+					QueryName:    "F1",
+					Expression:   "A / B",
+					LabelMapping: map[string]map[string]string{"B": {"serviceName": "service_name"}},
+				},
+			},
+		},
+	}
+	qbOptions := QueryBuilderOptions{
+		BuildLogQuery:   logsV3.PrepareLogsQuery,
+		BuildTraceQuery: tracesV3.PrepareTracesQuery,
+	}
+	qb := NewQueryBuilder(qbOptions, featureManager.StartManager())
+
+	queries, err := qb.PrepareQueries(q, map[string]v3.AttributeKey{})
+	require.NoError(t, err)
+	require.Contains(t, queries["F1"], "SELECT A.service_name as service_name, A.ts as ts, A.value / B.value as value")
+	require.Contains(t, queries["F1"], "ON A.service_name = B.serviceName AND A.ts = B.ts")
+
+	require.NoError(t, ValidateFormula(q.CompositeQuery, q.CompositeQuery.BuilderQueries["F1"]))
+	require.False(t, EvaluateInQuerier(q.CompositeQuery, q.CompositeQuery.BuilderQueries["F1"]))
+
+	keys := NewKeyGenerator().GenerateKeys(q)
+	require.Contains(t, keys["F1"], "labelMapping")
+
+	// the operands are matched by their timestamps
+	q.CompositeQuery.BuilderQueries["B"].StepInterval = 120
+	require.Error(t, ValidateFormula(q.CompositeQuery, q.CompositeQuery.BuilderQueries["F1"]))
+	q.CompositeQuery.BuilderQueries["B"].StepInterval = 60
+	q.CompositeQuery.BuilderQueries["C"] = &v3.BuilderQuery{QueryName: "C", StepInterval: 120, DataSource: v3.DataSourceLogs, Expression: "C"}
+	q.CompositeQuery.BuilderQueries["F2"] = &v3.BuilderQuery{QueryName: "F2", Expression: "A + C"}
+	require.Error(t, ValidateFormula(q.CompositeQuery, q.CompositeQuery.BuilderQueries["F2"]))
+	// the operands of the formulas used as operands are matched too
+	q.CompositeQuery.BuilderQueries["F2"].Expression = "F1 + C"
+	require.Error(t, ValidateFormula(q.CompositeQuery, q.CompositeQuery.BuilderQueries["F2"]))
+	require.True(t, EvaluateInQuerier(q.CompositeQuery, q.CompositeQuery.BuilderQueries["F2"]))
+	delete(q.CompositeQuery.BuilderQueries, "C")
+	delete(q.CompositeQuery.BuilderQueries, "F2")
+
+	// only the group by labels of the operands can be mapped
+	q.CompositeQuery.BuilderQueries["F1"].LabelMapping = map[string]map[string]string{"B": {"name": "service_name"}}
+	require.Error(t, ValidateFormula(q.CompositeQuery, q.CompositeQuery.BuilderQueries["F1"]))
+
+	// the functions of the operands are applied by the querier
+	q.CompositeQuery.BuilderQueries["B"].Functions = []v3.Function{{Name: v3.FunctionNameFillZero}}
+	require.True(t, EvaluateInQuerier(q.CompositeQuery, q.CompositeQuery.BuilderQueries["F1"]))
+}
+
+func TestFormulaOrder(t *testing.T) {
+	order, err := FormulaOrder(map[string][]string{
+		"F3": {"F2", "A"},
+		"F1": {"A", "B"},
+		"F2": {"F1", "C"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"F1", "F2", "F3"}, order)
+
+	_, err = FormulaOrder(map[string][]string{"F1": {"F2"}, "F2": {"F1"}})
+	require.Error(t, err)
+}
+
+func TestGenerateKeysLimitOrderAndReduce(t *testing.T) {
+	query := func() *v3.BuilderQuery {
+		return &v3.BuilderQuery{
+			QueryName:          "A",
+			StepInterval:       60,
+			DataSource:         v3.DataSourceMetrics,
+			AggregateAttribute: v3.AttributeKey{Key: "signoz_calls_total"},
+			AggregateOperator:  v3.AggregateOperatorSumRate,
+			GroupBy:            []v3.AttributeKey{{Key: "service_name"}},
+			Expression:         "A",
+		}
+	}
+	keyOf := func(q *v3.BuilderQuery) string {
+		return NewKeyGenerator().GenerateKeys(&v3.QueryRangeParamsV3{
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType:      v3.QueryTypeBuilder,
+				PanelType:      v3.PanelTypeGraph,
+				BuilderQueries: map[string]*v3.BuilderQuery{"A": q},
+			},
+		})["A"]
+	}
+	base := keyOf(query())
+
+	// the queries returning other series do not share the cache
+	limited := query()
+	limited.Limit = 5
+	require.NotEqual(t, base, keyOf(limited))
+
+	ordered := query()
+	ordered.OrderBy = []v3.OrderBy{{ColumnName: "#SIGNOZ_VALUE", Order: "desc"}}
+	require.NotEqual(t, base, keyOf(ordered))
+
+	reduced := query()
+	reduced.ReduceTo = v3.ReduceToOperatorAvg
+	require.NotEqual(t, base, keyOf(reduced))
+}
@@ -21,6 +21,8 @@ import (
 	"go.signoz.io/signoz/pkg/query-service/agentConf"
 	"go.signoz.io/signoz/pkg/query-service/app/clickhouseReader"
 	"go.signoz.io/signoz/pkg/query-service/app/dashboards"
+	"go.signoz.io/signoz/pkg/query-service/app/derivedmetrics"
+	"go.signoz.io/signoz/pkg/query-service/app/errorgroups"
 	"go.signoz.io/signoz/pkg/query-service/app/logparsingpipeline"
 	"go.signoz.io/signoz/pkg/query-service/app/opamp"
 	opAmpModel "go.signoz.io/signoz/pkg/query-service/app/opamp/model"
@@ -70,6 +72,9 @@ type Server struct {
 	ruleManager   *rules.Manager
 	separatePorts bool
 
+	// derivedMetrics materializes the metrics derived from the logs
+	derivedMetrics *derivedmetrics.Materializer
+
 	// public http router
 	httpConn   net.Listener
 	httpServer *http.Server
@@ -97,6 +102,8 @@ func NewServer(serverOptions *ServerOptions) (*Server, error) {
 
 	localDB, err := dashboards.InitDB(constants.RELATIONAL_DATASOURCE_PATH)
 	explorer.InitWithDSN(constants.RELATIONAL_DATASOURCE_PATH)
+	derivedmetrics.InitWithDSN(constants.RELATIONAL_DATASOURCE_PATH)
+	errorgroups.InitWithDSN(constants.RELATIONAL_DATASOURCE_PATH)
 
 	if err != nil {
 		return nil, err
@@ -137,18 +144,28 @@ func NewServer(serverOptions *ServerOptions) (*Server, error) {
 	}
 
 	<-readerReady
+	if constants.IsEmbeddedAlertManager() {
+		channels, apiErr := reader.GetChannels()
+		if apiErr != nil {
+			return nil, apiErr.Err
+		}
+		am.LoadChannels(*channels)
+	}
+
 	rm, err := makeRulesManager(serverOptions.PromConfigPath, constants.GetAlertManagerApiPrefix(), serverOptions.RuleRepoURL, localDB, reader, serverOptions.DisableRules, fm)
 	if err != nil {
 		return nil, err
 	}
 
 	var c cache.Cache
+	var cacheTTL time.Duration
 	if serverOptions.CacheConfigPath != "" {
 		cacheOpts, err := cache.LoadFromYAMLCacheConfigFile(serverOptions.CacheConfigPath)
 		if err != nil {
 			return nil, err
 		}
 		c = cache.NewCache(cacheOpts)
+		cacheTTL = cacheOpts.TTL
 	}
 
 	fluxInterval, err := time.ParseDuration(serverOptions.FluxInterval)
@@ -175,16 +192,23 @@ func NewServer(serverOptions *ServerOptions) (*Server, error) {
 		FeatureFlags:                  fm,
 		LogsParsingPipelineController: logParsingPipelineController,
 		Cache:                         c,
+		CacheTTL:                      cacheTTL,
 		FluxInterval:                  fluxInterval,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	derivedMetrics := derivedmetrics.NewMaterializer(reader, derivedmetrics.Options{
+		Interval: constants.DerivedMetricsInterval,
+		Delay:    constants.DerivedMetricsDelay,
+	})
+
 	s := &Server{
 		// logger: logger,
 		// tracer: tracer,
 		ruleManager:        rm,
+		derivedMetrics:     derivedMetrics,
 		serverOptions:      serverOptions,
 		unavailableChannel: make(chan healthcheck.Status),
 	}
@@ -502,6 +526,8 @@ func (s *Server) Start() error {
 		zap.S().Info("msg: Rules disabled as rules.disable is set to TRUE")
 	}
 
+	s.derivedMetrics.Start()
+
 	err := s.initListeners()
 	if err != nil {
 		return err
@@ -584,6 +610,10 @@ func (s *Server) Stop() error {
 		s.ruleManager.Stop()
 	}
 
+	if s.derivedMetrics != nil {
+		s.derivedMetrics.Stop()
+	}
+
 	return nil
 }
 
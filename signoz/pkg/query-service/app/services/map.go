@@ -2,6 +2,7 @@ package services
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
@@ -16,6 +17,17 @@ var (
 	}
 )
 
+// UsesDependencyGraphTable returns true when the tags filter on the columns of
+// the dependency graph table only, the other tags are filtered on the spans
+func UsesDependencyGraphTable(tags []model.TagQuery) bool {
+	for _, tag := range tags {
+		if _, ok := columns[strings.ReplaceAll(tag.GetKey(), ".", "_")]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
 func BuildServiceMapQuery(tags []model.TagQuery) (string, []interface{}) {
 	var filterQuery string
 	var namedArgs []interface{}
@@ -61,3 +73,55 @@ func BuildServiceMapQuery(tags []model.TagQuery) (string, []interface{}) {
 	}
 	return filterQuery, namedArgs
 }
+
+type edgeKey struct {
+	parent string
+	child  string
+}
+
+// CompareDependencyGraph sets the comparison of the edges of the dependency
+// graph with the edges of the comparison window. The edges of the comparison
+// window only are returned too, with no calls in the current window.
+func CompareDependencyGraph(current, comparison []model.ServiceMapDependencyResponseItem) []model.ServiceMapDependencyResponseItem {
+	previous := make(map[edgeKey]model.ServiceMapDependencyResponseItem, len(comparison))
+	for _, edge := range comparison {
+		previous[edgeKey{parent: edge.Parent, child: edge.Child}] = edge
+	}
+
+	result := make([]model.ServiceMapDependencyResponseItem, 0, len(current)+len(comparison))
+	for _, edge := range current {
+		key := edgeKey{parent: edge.Parent, child: edge.Child}
+		edge.Comparison = compareEdge(edge, previous[key])
+		delete(previous, key)
+		result = append(result, edge)
+	}
+
+	var removed []model.ServiceMapDependencyResponseItem
+	for _, edge := range previous {
+		removed = append(removed, model.ServiceMapDependencyResponseItem{
+			Parent:     edge.Parent,
+			Child:      edge.Child,
+			Comparison: compareEdge(model.ServiceMapDependencyResponseItem{}, edge),
+		})
+	}
+	sort.Slice(removed, func(i, j int) bool {
+		if removed[i].Parent != removed[j].Parent {
+			return removed[i].Parent < removed[j].Parent
+		}
+		return removed[i].Child < removed[j].Child
+	})
+	return append(result, removed...)
+}
+
+func compareEdge(edge, previous model.ServiceMapDependencyResponseItem) *model.ServiceMapDependencyComparison {
+	return &model.ServiceMapDependencyComparison{
+		CallCount:      previous.CallCount,
+		CallRate:       previous.CallRate,
+		ErrorRate:      previous.ErrorRate,
+		P99:            previous.P99,
+		CallRateDelta:  edge.CallRate - previous.CallRate,
+		ErrorRateDelta: edge.ErrorRate - previous.ErrorRate,
+		P99Delta:       edge.P99 - previous.P99,
+		Sampled:        previous.Sampled,
+	}
+}
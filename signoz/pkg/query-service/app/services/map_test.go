@@ -0,0 +1,71 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+func TestUsesDependencyGraphTable(t *testing.T) {
+	environment := model.NewTagQueryString(model.TagQueryParam{
+		Key:          "deployment.environment",
+		TagType:      model.ResourceAttributeTagType,
+		StringValues: []string{"production"},
+		Operator:     model.EqualOperator,
+	})
+	tenant := model.NewTagQueryString(model.TagQueryParam{
+		Key:          "tenant.id",
+		TagType:      model.ResourceAttributeTagType,
+		StringValues: []string{"acme"},
+		Operator:     model.EqualOperator,
+	})
+
+	assert.True(t, UsesDependencyGraphTable(nil))
+	assert.True(t, UsesDependencyGraphTable([]model.TagQuery{environment}))
+	assert.False(t, UsesDependencyGraphTable([]model.TagQuery{environment, tenant}))
+}
+
+func TestCompareDependencyGraph(t *testing.T) {
+	current := []model.ServiceMapDependencyResponseItem{
+		{Parent: "frontend", Child: "customer", CallCount: 600, CallRate: 10, ErrorRate: 5, P99: 300},
+		{Parent: "frontend", Child: "route", CallCount: 120, CallRate: 2, P99: 50},
+	}
+	comparison := []model.ServiceMapDependencyResponseItem{
+		{Parent: "frontend", Child: "driver", CallCount: 60, CallRate: 1, ErrorRate: 1, P99: 80},
+		{Parent: "frontend", Child: "customer", CallCount: 480, CallRate: 8, ErrorRate: 1, P99: 200},
+	}
+
+	result := CompareDependencyGraph(current, comparison)
+	require.Len(t, result, 3)
+
+	assert.Equal(t, "customer", result[0].Child)
+	assert.Equal(t, uint64(600), result[0].CallCount)
+	assert.Equal(t, &model.ServiceMapDependencyComparison{
+		CallCount:      480,
+		CallRate:       8,
+		ErrorRate:      1,
+		P99:            200,
+		CallRateDelta:  2,
+		ErrorRateDelta: 4,
+		P99Delta:       100,
+	}, result[0].Comparison)
+
+	// the edge is new in the current window
+	assert.Equal(t, "route", result[1].Child)
+	assert.Equal(t, &model.ServiceMapDependencyComparison{CallRateDelta: 2, P99Delta: 50}, result[1].Comparison)
+
+	// the edge has no calls in the current window
+	assert.Equal(t, "driver", result[2].Child)
+	assert.Zero(t, result[2].CallCount)
+	assert.Equal(t, &model.ServiceMapDependencyComparison{
+		CallCount:      60,
+		CallRate:       1,
+		ErrorRate:      1,
+		P99:            80,
+		CallRateDelta:  -1,
+		ErrorRateDelta: -1,
+		P99Delta:       -80,
+	}, result[2].Comparison)
+}
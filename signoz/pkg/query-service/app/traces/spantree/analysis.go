@@ -0,0 +1,161 @@
+package spantree
+
+import (
+	"sort"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+type interval struct {
+	start, end int64
+}
+
+// clip returns the part of the child within the interval of the parent, the
+// ends of the async children are clipped to the end of their parent
+func clip(child *Node, start, end int64) (interval, bool) {
+	i := interval{start: child.Start(), end: child.End()}
+	if i.start < start {
+		i.start = start
+	}
+	if i.end > end {
+		i.end = end
+	}
+	return i, i.start < i.end
+}
+
+// SelfTime returns the duration of the span minus the time covered by any of
+// its children, the overlapping children are counted once
+func SelfTime(n *Node) int64 {
+	intervals := make([]interval, 0, len(n.Children))
+	for _, child := range n.Children {
+		if i, ok := clip(child, n.Start(), n.End()); ok {
+			intervals = append(intervals, i)
+		}
+	}
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i].start < intervals[j].start
+	})
+
+	var covered int64
+	var current *interval
+	for i := range intervals {
+		if current != nil && intervals[i].start <= current.end {
+			if intervals[i].end > current.end {
+				current.end = intervals[i].end
+			}
+			continue
+		}
+		if current != nil {
+			covered += current.end - current.start
+		}
+		current = &intervals[i]
+	}
+	if current != nil {
+		covered += current.end - current.start
+	}
+	return n.Span.DurationNano - covered
+}
+
+// criticalPath appends the segments of the critical path of the span within
+// start and end, latest first. Going back from the end, the parent waited on
+// the child finishing last, then on the child finishing last before that
+// child started, and so on; the time between these children is spent in the
+// parent itself. The children are clipped to the parent, so that the async
+// children finishing after their parent are waited on up to its end only.
+func criticalPath(n *Node, start, end int64, path []model.CriticalPathSegment) []model.CriticalPathSegment {
+	cursor := end
+	for {
+		var last *Node
+		var lastInterval interval
+		for _, child := range n.Children {
+			i, ok := clip(child, start, end)
+			// the children finishing after the cursor ran in parallel with
+			// the child already on the path
+			if !ok || i.end > cursor {
+				continue
+			}
+			if last == nil || i.end > lastInterval.end {
+				last, lastInterval = child, i
+			}
+		}
+		if last == nil {
+			if start < cursor {
+				path = append(path, segment(n, start, cursor))
+			}
+			return path
+		}
+		if lastInterval.end < cursor {
+			path = append(path, segment(n, lastInterval.end, cursor))
+		}
+		path = criticalPath(last, lastInterval.start, lastInterval.end, path)
+		cursor = lastInterval.start
+	}
+}
+
+func segment(n *Node, start, end int64) model.CriticalPathSegment {
+	return model.CriticalPathSegment{
+		SpanID:       n.Span.SpanID,
+		ServiceName:  n.Span.ServiceName,
+		Name:         n.Span.Name,
+		StartNano:    start,
+		EndNano:      end,
+		DurationNano: end - start,
+	}
+}
+
+// Analyze computes the self time of the spans of the trace and its critical
+// path. The critical path starts from the longest root, the spans of the
+// other roots are not on it.
+func Analyze(spans []model.SearchSpanResponseItem) *model.TraceAnalysis {
+	analysis := &model.TraceAnalysis{
+		SelfTimeNano: make(map[string]int64, len(spans)),
+		CriticalPath: []model.CriticalPathSegment{},
+		Services:     []model.ServiceCriticalPathTime{},
+	}
+
+	roots := Build(spans)
+	var root *Node
+	var walk func(*Node)
+	walk = func(n *Node) {
+		analysis.SelfTimeNano[n.Span.SpanID] = SelfTime(n)
+		for _, child := range n.Children {
+			walk(child)
+		}
+	}
+	for _, r := range roots {
+		walk(r)
+		if root == nil || r.Span.DurationNano > root.Span.DurationNano {
+			root = r
+		}
+	}
+	if root == nil {
+		return analysis
+	}
+
+	path := criticalPath(root, root.Start(), root.End(), nil)
+	byService := make(map[string]int64)
+	for i := len(path) - 1; i >= 0; i-- {
+		analysis.CriticalPath = append(analysis.CriticalPath, path[i])
+		analysis.CriticalPathDurationNano += path[i].DurationNano
+		byService[path[i].ServiceName] += path[i].DurationNano
+	}
+
+	for service, duration := range byService {
+		percent := 0.0
+		if analysis.CriticalPathDurationNano > 0 {
+			percent = float64(duration) * 100 / float64(analysis.CriticalPathDurationNano)
+		}
+		analysis.Services = append(analysis.Services, model.ServiceCriticalPathTime{
+			ServiceName:  service,
+			DurationNano: duration,
+			Percent:      percent,
+		})
+	}
+	sort.Slice(analysis.Services, func(i, j int) bool {
+		if analysis.Services[i].DurationNano != analysis.Services[j].DurationNano {
+			return analysis.Services[i].DurationNano > analysis.Services[j].DurationNano
+		}
+		return analysis.Services[i].ServiceName < analysis.Services[j].ServiceName
+	})
+	return analysis
+}
@@ -0,0 +1,61 @@
+package spantree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+func TestAnalyze(t *testing.T) {
+	analysis := Analyze([]model.SearchSpanResponseItem{
+		span("a", "", "frontend", "HTTP GET /dispatch", 0, 100),
+		span("b", "a", "redis", "GET", 10, 20),
+		// runs in parallel with b and finishes later
+		span("c", "a", "driver", "FindDriver", 20, 40),
+		span("d", "c", "mysql", "SELECT", 25, 30),
+		// async, finishes after its parent
+		span("e", "a", "route", "HTTP GET /route", 70, 80),
+	})
+
+	ms := int64(1000000)
+	assert.Equal(t, map[string]int64{
+		"a": 20 * ms,
+		"b": 20 * ms,
+		"c": 10 * ms,
+		"d": 30 * ms,
+		"e": 80 * ms,
+	}, analysis.SelfTimeNano)
+
+	type step struct {
+		spanID     string
+		start, end int64
+	}
+	var path []step
+	for _, segment := range analysis.CriticalPath {
+		path = append(path, step{segment.SpanID, segment.StartNano / ms, segment.EndNano / ms})
+	}
+	assert.Equal(t, []step{
+		{"a", 0, 20},
+		{"c", 20, 25},
+		{"d", 25, 55},
+		{"c", 55, 60},
+		{"a", 60, 70},
+		{"e", 70, 100},
+	}, path)
+	assert.Equal(t, 100*ms, analysis.CriticalPathDurationNano)
+
+	require.Len(t, analysis.Services, 4)
+	assert.Equal(t, model.ServiceCriticalPathTime{ServiceName: "frontend", DurationNano: 30 * ms, Percent: 30}, analysis.Services[0])
+	assert.Equal(t, "mysql", analysis.Services[1].ServiceName)
+	assert.Equal(t, "route", analysis.Services[2].ServiceName)
+	assert.Equal(t, model.ServiceCriticalPathTime{ServiceName: "driver", DurationNano: 10 * ms, Percent: 10}, analysis.Services[3])
+}
+
+func TestAnalyzeEmpty(t *testing.T) {
+	analysis := Analyze(nil)
+	assert.Empty(t, analysis.SelfTimeNano)
+	assert.Empty(t, analysis.CriticalPath)
+	assert.Empty(t, analysis.Services)
+}
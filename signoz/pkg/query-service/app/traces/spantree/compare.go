@@ -0,0 +1,128 @@
+package spantree
+
+import (
+	"sort"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+type operationKey struct {
+	serviceName string
+	name        string
+}
+
+func keyOf(n *Node) operationKey {
+	return operationKey{serviceName: n.Span.ServiceName, name: n.Span.Name}
+}
+
+// Compare aligns the span trees of the base and the comparison traces and
+// returns their differences. The spans are aligned level by level: among the
+// children of aligned spans, the spans of the same service and operation are
+// paired in the order they start, the spans left over are added or missing.
+func Compare(baseTraceID string, base []model.SearchSpanResponseItem, comparisonTraceID string, comparison []model.SearchSpanResponseItem) *model.TraceComparison {
+	baseRoots, comparisonRoots := Build(base), Build(comparison)
+	result := &model.TraceComparison{
+		BaseTraceID:            baseTraceID,
+		ComparisonTraceID:      comparisonTraceID,
+		BaseDurationNano:       Duration(baseRoots),
+		ComparisonDurationNano: Duration(comparisonRoots),
+	}
+	result.DurationDeltaNano = result.ComparisonDurationNano - result.BaseDurationNano
+	result.Roots = alignNodes(baseRoots, comparisonRoots, result)
+	return result
+}
+
+// alignNodes pairs the nodes of the same service and operation, the nodes
+// are returned in the order of the base trace with the added nodes last
+func alignNodes(base, comparison []*Node, result *model.TraceComparison) []*model.SpanComparisonNode {
+	unpaired := make(map[operationKey][]*Node)
+	for _, n := range comparison {
+		unpaired[keyOf(n)] = append(unpaired[keyOf(n)], n)
+	}
+
+	nodes := make([]*model.SpanComparisonNode, 0, len(base))
+	for _, b := range base {
+		candidates := unpaired[keyOf(b)]
+		if len(candidates) == 0 {
+			nodes = append(nodes, singleNode(b, model.SpanComparisonMissing, result))
+			continue
+		}
+		c := candidates[0]
+		unpaired[keyOf(b)] = candidates[1:]
+		nodes = append(nodes, pairNodes(b, c, result))
+	}
+
+	for _, c := range comparison {
+		candidates := unpaired[keyOf(c)]
+		if len(candidates) > 0 && candidates[0] == c {
+			unpaired[keyOf(c)] = candidates[1:]
+			nodes = append(nodes, singleNode(c, model.SpanComparisonAdded, result))
+		}
+	}
+	return nodes
+}
+
+func pairNodes(b, c *Node, result *model.TraceComparison) *model.SpanComparisonNode {
+	node := &model.SpanComparisonNode{
+		ServiceName:            b.Span.ServiceName,
+		Name:                   b.Span.Name,
+		Status:                 model.SpanComparisonMatched,
+		BaseSpanID:             b.Span.SpanID,
+		ComparisonSpanID:       c.Span.SpanID,
+		BaseDurationNano:       b.Span.DurationNano,
+		ComparisonDurationNano: c.Span.DurationNano,
+		DurationDeltaNano:      c.Span.DurationNano - b.Span.DurationNano,
+		BaseHasError:           b.Span.HasError,
+		ComparisonHasError:     c.Span.HasError,
+		AttributeDiffs:         attributeDiffs(b.Span.TagMap, c.Span.TagMap),
+	}
+	node.Children = alignNodes(b.Children, c.Children, result)
+	return node
+}
+
+// singleNode returns the node of a span in a single trace, its descendants
+// are in that trace only too
+func singleNode(n *Node, status model.SpanComparisonStatus, result *model.TraceComparison) *model.SpanComparisonNode {
+	node := &model.SpanComparisonNode{
+		ServiceName: n.Span.ServiceName,
+		Name:        n.Span.Name,
+		Status:      status,
+		Children:    make([]*model.SpanComparisonNode, 0, len(n.Children)),
+	}
+	if status == model.SpanComparisonAdded {
+		result.Added++
+		node.ComparisonSpanID = n.Span.SpanID
+		node.ComparisonDurationNano = n.Span.DurationNano
+		node.ComparisonHasError = n.Span.HasError
+		node.DurationDeltaNano = n.Span.DurationNano
+	} else {
+		result.Missing++
+		node.BaseSpanID = n.Span.SpanID
+		node.BaseDurationNano = n.Span.DurationNano
+		node.BaseHasError = n.Span.HasError
+		node.DurationDeltaNano = -n.Span.DurationNano
+	}
+	for _, child := range n.Children {
+		node.Children = append(node.Children, singleNode(child, status, result))
+	}
+	return node
+}
+
+// attributeDiffs returns the attributes with different values, ordered by key
+func attributeDiffs(base, comparison map[string]string) []model.SpanAttributeDiff {
+	var diffs []model.SpanAttributeDiff
+	for key, value := range base {
+		if other, ok := comparison[key]; !ok || other != value {
+			diffs = append(diffs, model.SpanAttributeDiff{Key: key, BaseValue: value, ComparisonValue: other})
+		}
+	}
+	for key, value := range comparison {
+		if _, ok := base[key]; !ok {
+			diffs = append(diffs, model.SpanAttributeDiff{Key: key, ComparisonValue: value})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Key < diffs[j].Key
+	})
+	return diffs
+}
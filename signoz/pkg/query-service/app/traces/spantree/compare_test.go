@@ -0,0 +1,109 @@
+package spantree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+// span returns a span of the service and operation starting at start
+// milliseconds and lasting duration milliseconds
+func span(id, parent, service, name string, start, duration int64) model.SearchSpanResponseItem {
+	s := model.SearchSpanResponseItem{
+		SpanID:       id,
+		TraceID:      "trace",
+		ServiceName:  service,
+		Name:         name,
+		TimeUnixNano: uint64(start * 1000000),
+		DurationNano: duration * 1000000,
+	}
+	if parent != "" {
+		s.References = []model.OtelSpanRef{{SpanId: parent, RefType: "CHILD_OF"}}
+	}
+	return s
+}
+
+func TestBuild(t *testing.T) {
+	roots := Build([]model.SearchSpanResponseItem{
+		span("c", "a", "driver", "FindDriver", 20, 10),
+		span("b", "a", "redis", "GET", 10, 5),
+		span("a", "", "frontend", "HTTP GET /dispatch", 0, 100),
+		// the parent of the orphan is not in the trace
+		span("d", "x", "route", "HTTP GET /route", 50, 10),
+	})
+
+	require.Len(t, roots, 2)
+	assert.Equal(t, "a", roots[0].Span.SpanID)
+	assert.Equal(t, "d", roots[1].Span.SpanID)
+	require.Len(t, roots[0].Children, 2)
+	assert.Equal(t, "b", roots[0].Children[0].Span.SpanID)
+	assert.Equal(t, "c", roots[0].Children[1].Span.SpanID)
+	assert.Equal(t, roots[0], roots[0].Children[0].Parent)
+	assert.Equal(t, int64(100000000), Duration(roots))
+}
+
+func TestCompare(t *testing.T) {
+	base := []model.SearchSpanResponseItem{
+		span("a1", "", "frontend", "HTTP GET /dispatch", 0, 100),
+		span("b1", "a1", "customer", "SQL SELECT", 10, 30),
+		span("c1", "a1", "redis", "GET", 50, 5),
+		span("d1", "a1", "route", "HTTP GET /route", 60, 20),
+	}
+	base[1].TagMap = map[string]string{"db.statement": "SELECT * FROM customer", "db.system": "mysql"}
+
+	comparison := []model.SearchSpanResponseItem{
+		span("a2", "", "frontend", "HTTP GET /dispatch", 0, 400),
+		span("b2", "a2", "customer", "SQL SELECT", 10, 300),
+		span("c2", "a2", "redis", "GET", 320, 5),
+		span("c3", "a2", "redis", "GET", 330, 6),
+		span("e2", "c3", "redis", "AUTH", 331, 1),
+	}
+	comparison[1].TagMap = map[string]string{"db.statement": "SELECT * FROM customer WHERE id = ?", "db.rows": "1000"}
+	comparison[1].HasError = true
+
+	result := Compare("base", base, "slow", comparison)
+	assert.Equal(t, int64(100000000), result.BaseDurationNano)
+	assert.Equal(t, int64(400000000), result.ComparisonDurationNano)
+	assert.Equal(t, int64(300000000), result.DurationDeltaNano)
+	assert.Equal(t, 2, result.Added)
+	assert.Equal(t, 1, result.Missing)
+
+	require.Len(t, result.Roots, 1)
+	root := result.Roots[0]
+	assert.Equal(t, model.SpanComparisonMatched, root.Status)
+	assert.Equal(t, "a1", root.BaseSpanID)
+	assert.Equal(t, "a2", root.ComparisonSpanID)
+	assert.Empty(t, root.AttributeDiffs)
+
+	require.Len(t, root.Children, 4)
+	sql := root.Children[0]
+	assert.Equal(t, model.SpanComparisonMatched, sql.Status)
+	assert.Equal(t, int64(270000000), sql.DurationDeltaNano)
+	assert.False(t, sql.BaseHasError)
+	assert.True(t, sql.ComparisonHasError)
+	assert.Equal(t, []model.SpanAttributeDiff{
+		{Key: "db.rows", ComparisonValue: "1000"},
+		{Key: "db.statement", BaseValue: "SELECT * FROM customer", ComparisonValue: "SELECT * FROM customer WHERE id = ?"},
+		{Key: "db.system", BaseValue: "mysql"},
+	}, sql.AttributeDiffs)
+
+	// the redis calls are paired in the order they start
+	redis := root.Children[1]
+	assert.Equal(t, model.SpanComparisonMatched, redis.Status)
+	assert.Equal(t, "c1", redis.BaseSpanID)
+	assert.Equal(t, "c2", redis.ComparisonSpanID)
+
+	route := root.Children[2]
+	assert.Equal(t, model.SpanComparisonMissing, route.Status)
+	assert.Equal(t, "d1", route.BaseSpanID)
+	assert.Equal(t, int64(-20000000), route.DurationDeltaNano)
+
+	added := root.Children[3]
+	assert.Equal(t, model.SpanComparisonAdded, added.Status)
+	assert.Equal(t, "c3", added.ComparisonSpanID)
+	require.Len(t, added.Children, 1)
+	assert.Equal(t, model.SpanComparisonAdded, added.Children[0].Status)
+	assert.Equal(t, "AUTH", added.Children[0].Name)
+}
@@ -0,0 +1,98 @@
+package spantree
+
+import (
+	"sort"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+// Node is a span in the tree of its trace
+type Node struct {
+	Span     *model.SearchSpanResponseItem
+	Parent   *Node
+	Children []*Node
+}
+
+// Start returns the start of the span in nanoseconds
+func (n *Node) Start() int64 {
+	return int64(n.Span.TimeUnixNano)
+}
+
+// End returns the end of the span in nanoseconds
+func (n *Node) End() int64 {
+	return int64(n.Span.TimeUnixNano) + n.Span.DurationNano
+}
+
+// parentID returns the id of the parent of the span, the parent is the first
+// reference when it is a CHILD_OF reference
+func parentID(span *model.SearchSpanResponseItem) string {
+	if len(span.References) > 0 && span.References[0].RefType == "CHILD_OF" {
+		return span.References[0].SpanId
+	}
+	return ""
+}
+
+func sortNodes(nodes []*Node) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].Start() != nodes[j].Start() {
+			return nodes[i].Start() < nodes[j].Start()
+		}
+		return nodes[i].Span.SpanID < nodes[j].Span.SpanID
+	})
+}
+
+// Build builds the trees of the spans of a trace and returns their roots. The
+// spans whose parent is not in the trace are roots too. The roots and the
+// children are ordered by start time.
+func Build(spans []model.SearchSpanResponseItem) []*Node {
+	nodes := make(map[string]*Node, len(spans))
+	ordered := make([]*Node, 0, len(spans))
+	for i := range spans {
+		node := &Node{Span: &spans[i]}
+		nodes[spans[i].SpanID] = node
+		ordered = append(ordered, node)
+	}
+
+	var roots []*Node
+	for _, node := range ordered {
+		parent := nodes[parentID(node.Span)]
+		// a span referencing itself would make a cycle
+		if parent == nil || parent == node {
+			roots = append(roots, node)
+			continue
+		}
+		node.Parent = parent
+		parent.Children = append(parent.Children, node)
+	}
+
+	sortNodes(roots)
+	for _, node := range ordered {
+		sortNodes(node.Children)
+	}
+	return roots
+}
+
+// Duration returns the time from the start of the first span to the end of
+// the last span of the trees
+func Duration(roots []*Node) int64 {
+	if len(roots) == 0 {
+		return 0
+	}
+	start, end := roots[0].Start(), roots[0].End()
+	var walk func(*Node)
+	walk = func(n *Node) {
+		if n.Start() < start {
+			start = n.Start()
+		}
+		if n.End() > end {
+			end = n.End()
+		}
+		for _, child := range n.Children {
+			walk(child)
+		}
+	}
+	for _, root := range roots {
+		walk(root)
+	}
+	return end - start
+}
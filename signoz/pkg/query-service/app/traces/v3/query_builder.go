@@ -371,6 +371,10 @@ func buildTracesQuery(start, end, step int64, mq *v3.BuilderQuery, tableName str
 				return "", fmt.Errorf("select columns cannot be empty for panelType %s", panelType)
 			}
 			selectColumns := getSelectColumns(mq.SelectColumns, keys)
+			if mq.After != nil {
+				filterSubQuery += fmt.Sprintf(" AND (timestamp, spanID) %s (fromUnixTimestamp64Nano(toInt64(%d)), %s)", mq.After.Operator(), mq.After.Timestamp, utils.ClickHouseFormattedValue(mq.After.ID))
+				orderBy = fmt.Sprintf(" order by timestamp %s, spanID %s", strings.ToUpper(mq.After.Order), strings.ToUpper(mq.After.Order))
+			}
 			queryNoOpTmpl := fmt.Sprintf("SELECT timestamp as timestamp_datetime, spanID, traceID, "+"%s ", selectColumns) + "from " + constants.SIGNOZ_TRACE_DBNAME + "." + constants.SIGNOZ_SPAN_INDEX_TABLENAME + " where %s %s" + "%s"
 			query = fmt.Sprintf(queryNoOpTmpl, spanIndexTableTimeFilter, filterSubQuery, orderBy)
 		} else {
@@ -506,6 +510,8 @@ func addOffsetToQuery(query string, offset uint64) string {
 // start and end are in epoch millisecond
 // step is in seconds
 func PrepareTracesQuery(start, end int64, panelType v3.PanelType, mq *v3.BuilderQuery, keys map[string]v3.AttributeKey, options Options) (string, error) {
+	start, end = mq.ShiftTimeRange(start, end)
+
 	// adjust the start and end time to the step interval
 	start = start - (start % (mq.StepInterval * 1000))
 	end = end - (end % (mq.StepInterval * 1000))
@@ -523,13 +529,16 @@ func PrepareTracesQuery(start, end int64, panelType v3.PanelType, mq *v3.Builder
 		if err != nil {
 			return "", err
 		}
-		return query, nil
+		return utils.AddTimeShiftToQuery(query, mq.TimeShift, mq.StepInterval), nil
 	}
 
 	query, err := buildTracesQuery(start, end, mq.StepInterval, mq, constants.SIGNOZ_SPAN_INDEX_TABLENAME, keys, panelType, options)
 	if err != nil {
 		return "", err
 	}
+	if panelType == v3.PanelTypeGraph {
+		query = utils.AddTimeShiftToQuery(query, mq.TimeShift, mq.StepInterval)
+	}
 	if panelType == v3.PanelTypeValue {
 		query, err = reduceToQuery(query, mq.ReduceTo, mq.AggregateOperator)
 	}
@@ -542,3 +551,38 @@ func PrepareTracesQuery(start, end int64, panelType v3.PanelType, mq *v3.Builder
 	}
 	return query, err
 }
+
+// PrepareCallGraphQuery returns the query merging the span trees of the
+// traces matching the filters of the query into the calls between operations.
+// The calls are read from up to traceLimit traces with a span in the time
+// range, start and end are in epoch millisecond.
+func PrepareCallGraphQuery(start, end int64, mq *v3.BuilderQuery, keys map[string]v3.AttributeKey, traceLimit uint64) (string, error) {
+	filterSubQuery, err := buildTracesFilterQuery(mq.Filters, keys)
+	if err != nil {
+		return "", err
+	}
+	timeFilter := fmt.Sprintf("(timestamp >= '%d' AND timestamp <= '%d')", start*getZerosForEpochNano(start), end*getZerosForEpochNano(end))
+	table := constants.SIGNOZ_TRACE_DBNAME + "." + constants.SIGNOZ_SPAN_INDEX_TABLENAME
+
+	traces := fmt.Sprintf("SELECT DISTINCT traceID FROM %s WHERE %s%s LIMIT %d", table, timeFilter, filterSubQuery, traceLimit)
+	spans := fmt.Sprintf("(SELECT traceID, spanID, parentSpanID, serviceName, name, durationNano, hasError FROM %s WHERE %s AND traceID GLOBAL IN (%s))",
+		table, timeFilter, traces)
+
+	// the calls are first counted by parent span, to get the fan out
+	calls := "SELECT child.traceID AS traceID, parent.spanID AS parentID, " +
+		"parent.serviceName AS parentServiceName, parent.name AS parentName, child.serviceName AS serviceName, child.name AS name, " +
+		"count() AS spanCalls, countIf(child.hasError) AS spanErrors, " +
+		"quantileState(0.5)(child.durationNano) AS p50State, quantileState(0.95)(child.durationNano) AS p95State " +
+		"FROM " + spans + " AS child INNER JOIN " + spans + " AS parent " +
+		"ON child.traceID = parent.traceID AND child.parentSpanID = parent.spanID " +
+		"GROUP BY traceID, parentID, parentServiceName, parentName, serviceName, name"
+
+	query := "SELECT parentServiceName, parentName, serviceName, name, " +
+		"sum(spanCalls) AS calls, uniq(traceID) AS traces, calls / traces AS callsPerTrace, " +
+		"quantileMerge(0.5)(p50State) AS p50, quantileMerge(0.95)(p95State) AS p95, " +
+		"sum(spanErrors) * 100 / calls AS errorRate, avg(spanCalls) AS fanOut, max(spanCalls) AS maxFanOut " +
+		"FROM (" + calls + ") " +
+		"GROUP BY parentServiceName, parentName, serviceName, name " +
+		"ORDER BY calls DESC"
+	return query, nil
+}
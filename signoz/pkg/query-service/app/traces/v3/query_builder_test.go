@@ -1379,3 +1379,39 @@ func TestPrepareTracesQuery(t *testing.T) {
 		})
 	}
 }
+
+func TestPrepareCallGraphQuery(t *testing.T) {
+	Convey("TestPrepareCallGraphQuery", t, func() {
+		mq := &v3.BuilderQuery{
+			QueryName:         "A",
+			DataSource:        v3.DataSourceTraces,
+			AggregateOperator: v3.AggregateOperatorNoOp,
+			Expression:        "A",
+			Filters: &v3.FilterSet{Operator: "AND", Items: []v3.FilterItem{
+				{Key: v3.AttributeKey{Key: "serviceName", DataType: v3.AttributeKeyDataTypeString, IsColumn: true}, Value: "frontend", Operator: "="},
+			}},
+		}
+		query, err := PrepareCallGraphQuery(1680066360726, 1680066458000, mq, map[string]v3.AttributeKey{
+			"serviceName": {Key: "serviceName", DataType: v3.AttributeKeyDataTypeString, IsColumn: true},
+		}, 100)
+		So(err, ShouldBeNil)
+
+		spans := "(SELECT traceID, spanID, parentSpanID, serviceName, name, durationNano, hasError FROM signoz_traces.distributed_signoz_index_v2" +
+			" WHERE (timestamp >= '1680066360726000000' AND timestamp <= '1680066458000000000') AND traceID GLOBAL IN (" +
+			"SELECT DISTINCT traceID FROM signoz_traces.distributed_signoz_index_v2 WHERE (timestamp >= '1680066360726000000'" +
+			" AND timestamp <= '1680066458000000000') AND serviceName = 'frontend' LIMIT 100))"
+		So(query, ShouldEqual, "SELECT parentServiceName, parentName, serviceName, name, "+
+			"sum(spanCalls) AS calls, uniq(traceID) AS traces, calls / traces AS callsPerTrace, "+
+			"quantileMerge(0.5)(p50State) AS p50, quantileMerge(0.95)(p95State) AS p95, "+
+			"sum(spanErrors) * 100 / calls AS errorRate, avg(spanCalls) AS fanOut, max(spanCalls) AS maxFanOut "+
+			"FROM (SELECT child.traceID AS traceID, parent.spanID AS parentID, "+
+			"parent.serviceName AS parentServiceName, parent.name AS parentName, child.serviceName AS serviceName, child.name AS name, "+
+			"count() AS spanCalls, countIf(child.hasError) AS spanErrors, "+
+			"quantileState(0.5)(child.durationNano) AS p50State, quantileState(0.95)(child.durationNano) AS p95State "+
+			"FROM "+spans+" AS child INNER JOIN "+spans+" AS parent "+
+			"ON child.traceID = parent.traceID AND child.parentSpanID = parent.spanID "+
+			"GROUP BY traceID, parentID, parentServiceName, parentName, serviceName, name) "+
+			"GROUP BY parentServiceName, parentName, serviceName, name "+
+			"ORDER BY calls DESC")
+	})
+}
@@ -14,6 +14,7 @@ import (
 type Options struct {
 	Name     string            `yaml:"-"`
 	Provider string            `yaml:"provider"`
+	TTL      time.Duration     `yaml:"ttl,omitempty"`
 	Redis    *redis.Options    `yaml:"redis,omitempty"`
 	InMemory *inmemory.Options `yaml:"inmemory,omitempty"`
 }
@@ -26,6 +27,13 @@ type Cache interface {
 	SetTTL(cacheKey string, ttl time.Duration)
 	Remove(cacheKey string)
 	BulkRemove(cacheKeys []string)
+	// AddToSet adds the member to the set stored at the key, the member expires
+	// at expiresAt and the set expires with the last member added
+	AddToSet(cacheKey string, member string, expiresAt time.Time) error
+	// SetMembers returns the unexpired members of the set with their expiry
+	SetMembers(cacheKey string) (map[string]time.Time, error)
+	// RemoveFromSet removes the members from the set
+	RemoveFromSet(cacheKey string, members ...string) error
 	Close() error
 }
 
@@ -1,6 +1,9 @@
 package cache
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestNewCacheUnKnownProvider(t *testing.T) {
 	c := NewCache(&Options{
@@ -50,3 +53,16 @@ func TestLoadFromYAMLCacheConfigFile(t *testing.T) {
 		t.Fatalf("unexpected error: %s", err)
 	}
 }
+
+func TestLoadFromYAMLCacheConfigTTL(t *testing.T) {
+	opts, err := LoadFromYAMLCacheConfig([]byte(`
+provider: inmemory
+ttl: 30m
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if opts.TTL != 30*time.Minute {
+		t.Fatalf("expected ttl 30m, got %s", opts.TTL)
+	}
+}
@@ -1,6 +1,7 @@
 package inmemory
 
 import (
+	"sync"
 	"time"
 
 	go_cache "github.com/patrickmn/go-cache"
@@ -10,6 +11,10 @@ import (
 // cache implements the Cache interface
 type cache struct {
 	cc *go_cache.Cache
+
+	// sets holds the expiry of the members of the sets by key
+	sets   map[string]map[string]time.Time
+	setsMu sync.Mutex
 }
 
 // New creates a new in-memory cache
@@ -17,7 +22,7 @@ func New(opts *Options) *cache {
 	if opts == nil {
 		opts = defaultOptions()
 	}
-	return &cache{cc: go_cache.New(opts.TTL, opts.CleanupInterval)}
+	return &cache{cc: go_cache.New(opts.TTL, opts.CleanupInterval), sets: make(map[string]map[string]time.Time)}
 }
 
 // Connect does nothing
@@ -53,13 +58,74 @@ func (c *cache) SetTTL(cacheKey string, ttl time.Duration) {
 // Remove removes the cache entry
 func (c *cache) Remove(cacheKey string) {
 	c.cc.Delete(cacheKey)
+	c.setsMu.Lock()
+	delete(c.sets, cacheKey)
+	c.setsMu.Unlock()
 }
 
 // BulkRemove removes the cache entries
 func (c *cache) BulkRemove(cacheKeys []string) {
 	for _, cacheKey := range cacheKeys {
-		c.cc.Delete(cacheKey)
+		c.Remove(cacheKey)
+	}
+}
+
+// unexpiredSet returns the set without its expired members, it is called
+// with the sets locked
+func (c *cache) unexpiredSet(cacheKey string) map[string]time.Time {
+	set, ok := c.sets[cacheKey]
+	if !ok {
+		return nil
+	}
+	now := time.Now()
+	for member, expiresAt := range set {
+		if now.After(expiresAt) {
+			delete(set, member)
+		}
+	}
+	if len(set) == 0 {
+		delete(c.sets, cacheKey)
+		return nil
+	}
+	return set
+}
+
+// AddToSet adds the member to the set
+func (c *cache) AddToSet(cacheKey string, member string, expiresAt time.Time) error {
+	c.setsMu.Lock()
+	defer c.setsMu.Unlock()
+	set := c.unexpiredSet(cacheKey)
+	if set == nil {
+		set = make(map[string]time.Time)
+		c.sets[cacheKey] = set
 	}
+	set[member] = expiresAt
+	return nil
+}
+
+// SetMembers returns the unexpired members of the set
+func (c *cache) SetMembers(cacheKey string) (map[string]time.Time, error) {
+	c.setsMu.Lock()
+	defer c.setsMu.Unlock()
+	members := make(map[string]time.Time)
+	for member, expiresAt := range c.unexpiredSet(cacheKey) {
+		members[member] = expiresAt
+	}
+	return members, nil
+}
+
+// RemoveFromSet removes the members from the set
+func (c *cache) RemoveFromSet(cacheKey string, members ...string) error {
+	c.setsMu.Lock()
+	defer c.setsMu.Unlock()
+	set := c.unexpiredSet(cacheKey)
+	for _, member := range members {
+		delete(set, member)
+	}
+	if len(set) == 0 {
+		delete(c.sets, cacheKey)
+	}
+	return nil
 }
 
 // Close does nothing
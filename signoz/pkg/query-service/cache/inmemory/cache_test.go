@@ -100,3 +100,26 @@ func TestCache(t *testing.T) {
 	assert.Equal(t, data, []byte("value"))
 	c.Remove("key")
 }
+
+// TestSet tests the set functions
+func TestSet(t *testing.T) {
+	c := New(nil)
+	expiresAt := time.Now().Add(10 * time.Second)
+	assert.NoError(t, c.AddToSet("set", "a", expiresAt))
+	assert.NoError(t, c.AddToSet("set", "b", expiresAt))
+	assert.NoError(t, c.AddToSet("set", "expired", time.Now().Add(-time.Second)))
+
+	members, err := c.SetMembers("set")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]time.Time{"a": expiresAt, "b": expiresAt}, members)
+
+	assert.NoError(t, c.RemoveFromSet("set", "a"))
+	members, err = c.SetMembers("set")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]time.Time{"b": expiresAt}, members)
+
+	c.Remove("set")
+	members, err = c.SetMembers("set")
+	assert.NoError(t, err)
+	assert.Empty(t, members)
+}
@@ -3,6 +3,7 @@ package redis
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -78,6 +79,48 @@ func (c *cache) BulkRemove(cacheKeys []string) {
 	}
 }
 
+// AddToSet adds the member to the sorted set stored at the key, scored by
+// its expiry in milliseconds, and drops the expired members
+func (c *cache) AddToSet(cacheKey string, member string, expiresAt time.Time) error {
+	ctx := context.Background()
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, cacheKey, "-inf", strconv.FormatInt(time.Now().UnixMilli(), 10))
+		pipe.ZAdd(ctx, cacheKey, &redis.Z{Score: float64(expiresAt.UnixMilli()), Member: member})
+		pipe.ExpireAt(ctx, cacheKey, expiresAt)
+		return nil
+	})
+	return err
+}
+
+// SetMembers returns the members of the sorted set scored after now
+func (c *cache) SetMembers(cacheKey string) (map[string]time.Time, error) {
+	zs, err := c.client.ZRangeByScoreWithScores(context.Background(), cacheKey, &redis.ZRangeBy{
+		Min: strconv.FormatInt(time.Now().UnixMilli(), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	members := make(map[string]time.Time, len(zs))
+	for _, z := range zs {
+		member, _ := z.Member.(string)
+		members[member] = time.UnixMilli(int64(z.Score))
+	}
+	return members, nil
+}
+
+// RemoveFromSet removes the members from the sorted set
+func (c *cache) RemoveFromSet(cacheKey string, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	values := make([]interface{}, 0, len(members))
+	for _, member := range members {
+		values = append(values, member)
+	}
+	return c.client.ZRem(context.Background(), cacheKey, values...).Err()
+}
+
 // Close closes the connection to the redis server
 func (c *cache) Close() error {
 	return c.client.Close()
@@ -90,3 +90,17 @@ func TestBulkRemove(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+func TestRemoveFromSet(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	c := WithClient(db)
+
+	mock.ExpectZRem("set", "a", "b").SetVal(2)
+	if err := c.RemoveFromSet("set", "a", "b"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
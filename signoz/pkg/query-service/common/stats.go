@@ -0,0 +1,23 @@
+package common
+
+import (
+	"context"
+
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+type queryStatsKey struct{}
+
+// NewQueryStatsContext returns a context the execution statistics of the
+// queries run with it are collected in
+func NewQueryStatsContext(ctx context.Context, stats *v3.QueryStats) context.Context {
+	return context.WithValue(ctx, queryStatsKey{}, stats)
+}
+
+func GetQueryStatsFromContext(ctx context.Context) *v3.QueryStats {
+	stats, ok := ctx.Value(queryStatsKey{}).(*v3.QueryStats)
+	if !ok {
+		return nil
+	}
+	return stats
+}
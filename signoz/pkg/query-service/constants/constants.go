@@ -56,6 +56,16 @@ func GetAlertManagerApiPrefix() string {
 // Alert manager channel subpath
 var AmChannelApiPath = GetOrDefaultEnv("ALERTMANAGER_API_CHANNEL_PATH", "v1/routes")
 
+// AlertManagerModeEmbedded delivers the notifications from query service
+// itself instead of forwarding the alerts to an external alert manager
+const AlertManagerModeEmbedded = "embedded"
+
+var AlertManagerMode = GetOrDefaultEnv("ALERTMANAGER_MODE", "external")
+
+func IsEmbeddedAlertManager() bool {
+	return AlertManagerMode == AlertManagerModeEmbedded
+}
+
 var OTLPTarget = GetOrDefaultEnv("OTLP_TARGET", "")
 var LogExportBatchSize = GetOrDefaultEnv("LOG_EXPORT_BATCH_SIZE", "1000")
 
@@ -146,6 +156,67 @@ func GetContextTimeoutMaxAllowed() time.Duration {
 
 var ContextTimeoutMaxAllowed = GetContextTimeoutMaxAllowed()
 
+// GetQueryLimit reads the limit on the data a query range request can read,
+// zero (the default) is no limit
+func GetQueryLimit(key string) uint64 {
+	limit, err := strconv.ParseUint(GetOrDefaultEnv(key, "0"), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return limit
+}
+
+// GetQueryMaxTimeRange reads the longest time range a query range request
+// can read of a data source, zero (the default) is no limit
+func GetQueryMaxTimeRange(key string) time.Duration {
+	maxTimeRange, err := time.ParseDuration(GetOrDefaultEnv(key, "0s"))
+	if err != nil {
+		return 0
+	}
+	return maxTimeRange
+}
+
+var QueryMaxRowsScanned = GetQueryLimit("QUERY_MAX_ROWS_SCANNED")
+var QueryMaxBytesScanned = GetQueryLimit("QUERY_MAX_BYTES_SCANNED")
+
+var QueryMaxTimeRangeMetrics = GetQueryMaxTimeRange("QUERY_MAX_TIME_RANGE_METRICS")
+var QueryMaxTimeRangeLogs = GetQueryMaxTimeRange("QUERY_MAX_TIME_RANGE_LOGS")
+var QueryMaxTimeRangeTraces = GetQueryMaxTimeRange("QUERY_MAX_TIME_RANGE_TRACES")
+
+// GetLiveTailLimit reads a limit of the live tail
+func GetLiveTailLimit(key string, fallback int) int {
+	limit, err := strconv.Atoi(GetOrDefaultEnv(key, strconv.Itoa(fallback)))
+	if err != nil {
+		return fallback
+	}
+	return limit
+}
+
+// LiveTailMaxEventsPerSecond is the most logs sent to a live tail connection
+// every second, zero is no limit
+var LiveTailMaxEventsPerSecond = GetLiveTailLimit("LIVE_TAIL_MAX_EVENTS_PER_SECOND", 100)
+
+// LiveTailMaxLogsPerPoll is the most logs read for all the live tail
+// connections at every refresh
+var LiveTailMaxLogsPerPoll = GetLiveTailLimit("LIVE_TAIL_MAX_LOGS_PER_POLL", 1000)
+
+// GetDerivedMetricsDuration returns the duration set in the env var key, or
+// the fallback when it is not set or invalid
+func GetDerivedMetricsDuration(key string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(GetOrDefaultEnv(key, fallback.String()))
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+// DerivedMetricsInterval is how often the derived metrics are materialized
+var DerivedMetricsInterval = GetDerivedMetricsDuration("DERIVED_METRICS_INTERVAL", time.Minute)
+
+// DerivedMetricsDelay is how long the logs of an interval are waited for
+// before the interval is materialized, the logs arriving later are missed
+var DerivedMetricsDelay = GetDerivedMetricsDuration("DERIVED_METRICS_DELAY", 2*time.Minute)
+
 const (
 	TraceID                        = "traceID"
 	ServiceName                    = "serviceName"
@@ -210,8 +281,9 @@ const (
 )
 
 var TimeoutExcludedRoutes = map[string]bool{
-	"/api/v1/logs/tail":     true,
-	"/api/v3/logs/livetail": true,
+	"/api/v1/logs/tail":          true,
+	"/api/v3/logs/livetail":      true,
+	"/api/v3/query_range/export": true,
 }
 
 // alert related constants
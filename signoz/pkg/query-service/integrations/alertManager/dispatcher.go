@@ -0,0 +1,502 @@
+package alertManager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+	"go.uber.org/zap"
+)
+
+// DispatcherOptions are the grouping and delivery settings of the embedded dispatcher
+type DispatcherOptions struct {
+	// GroupBy is the list of labels the alerts of a notification are grouped by
+	GroupBy []string
+	// GroupWait is how long to wait before sending the first notification of a new group
+	GroupWait time.Duration
+	// GroupInterval is how long to wait before notifying about the changes in a group
+	GroupInterval time.Duration
+	// RepeatInterval is how long to wait before notifying again about the same firing alerts
+	RepeatInterval time.Duration
+	// MaxRetries is the number of times a failed delivery is retried
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry, it doubles with every retry
+	RetryBackoff time.Duration
+	// Timeout limits every delivery attempt
+	Timeout time.Duration
+	// DeliveryLogSize is the number of deliveries kept in the delivery log
+	DeliveryLogSize int
+}
+
+func defaultDispatcherOptions(o *DispatcherOptions) *DispatcherOptions {
+	if o == nil {
+		o = &DispatcherOptions{}
+	}
+	if o.GroupBy == nil {
+		o.GroupBy = []string{labels.AlertNameLabel}
+	}
+	if o.GroupWait == 0 {
+		o.GroupWait = 30 * time.Second
+	}
+	if o.GroupInterval == 0 {
+		o.GroupInterval = 5 * time.Minute
+	}
+	if o.RepeatInterval == 0 {
+		o.RepeatInterval = 4 * time.Hour
+	}
+	if o.MaxRetries == 0 {
+		o.MaxRetries = 3
+	}
+	if o.RetryBackoff == 0 {
+		o.RetryBackoff = time.Second
+	}
+	if o.Timeout == 0 {
+		o.Timeout = 10 * time.Second
+	}
+	if o.DeliveryLogSize == 0 {
+		o.DeliveryLogSize = 500
+	}
+	return o
+}
+
+// maximum wait between two delivery attempts
+const maxRetryBackoff = time.Minute
+
+// Delivery is an entry of the delivery log of the embedded dispatcher
+type Delivery struct {
+	Time        time.Time `json:"time"`
+	Receiver    string    `json:"receiver"`
+	Integration string    `json:"integration"`
+	GroupKey    string    `json:"groupKey"`
+	Firing      int       `json:"firing"`
+	Resolved    int       `json:"resolved"`
+	Attempts    int       `json:"attempts"`
+	Success     bool      `json:"success"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// DispatchedAlert is an alert held by the embedded dispatcher
+type DispatchedAlert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+	Receivers    []string          `json:"receivers"`
+}
+
+// alertGroup holds the alerts of a receiver that are notified together
+type alertGroup struct {
+	key      string
+	receiver string
+	labels   map[string]string
+	alerts   map[uint64]*Alert
+
+	createdAt time.Time
+	// flushedAt is the time of the last notification, zero until the first one
+	flushedAt time.Time
+	// notified has the alerts of the last notification mapped to whether they were resolved
+	notified map[uint64]bool
+}
+
+// Dispatcher is a Manager that delivers the notifications itself, to the
+// webhook, slack and pagerduty channels, instead of forwarding the alerts
+// to an external alert manager. The alerts are grouped by labels for every
+// receiver and the failed deliveries are retried with a backoff.
+type Dispatcher struct {
+	opts      *DispatcherOptions
+	client    *http.Client
+	parsedURL *neturl.URL
+
+	mtx       sync.Mutex
+	receivers map[string][]integration
+	groups    map[string]*alertGroup
+
+	logMtx     sync.RWMutex
+	deliveries []*Delivery
+
+	// wg tracks the notifications being delivered
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel func()
+}
+
+func NewDispatcher(o *DispatcherOptions) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		opts:      defaultDispatcherOptions(o),
+		client:    &http.Client{},
+		parsedURL: &neturl.URL{Scheme: "embedded", Path: "/"},
+		receivers: make(map[string][]integration),
+		groups:    make(map[string]*alertGroup),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+var embedded struct {
+	once       sync.Once
+	dispatcher *Dispatcher
+}
+
+// Embedded returns the dispatcher shared by query service when the
+// embedded alert manager is enabled, it is started on first use
+func Embedded() *Dispatcher {
+	embedded.once.Do(func() {
+		embedded.dispatcher = NewDispatcher(nil)
+		go embedded.dispatcher.Run()
+	})
+	return embedded.dispatcher
+}
+
+// LoadChannels registers the stored notification channels with the embedded dispatcher
+func LoadChannels(channels []model.ChannelItem) {
+	d := Embedded()
+	for _, channel := range channels {
+		receiver := &Receiver{}
+		if err := json.Unmarshal([]byte(channel.Data), receiver); err != nil {
+			zap.S().Errorf("msg: failed to parse channel\t name: %s\t error: %v", channel.Name, err)
+			continue
+		}
+		if apiErr := d.AddRoute(receiver); apiErr != nil {
+			zap.S().Errorf("msg: failed to load channel\t name: %s\t error: %v", channel.Name, apiErr.Err)
+		}
+	}
+}
+
+// Run flushes the alert groups as they become due, until Stop is called
+func (d *Dispatcher) Run() {
+	zap.S().Info("msg: Initiating embedded notification dispatcher...")
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+			d.flush(time.Now())
+		}
+	}
+}
+
+// Stop shuts down the dispatcher, the pending deliveries are abandoned
+func (d *Dispatcher) Stop() {
+	d.cancel()
+}
+
+func (d *Dispatcher) groupKey(receiver string, a *Alert) (string, map[string]string) {
+	groupLabels := make(map[string]string, len(d.opts.GroupBy))
+	parts := make([]string, 0, len(d.opts.GroupBy)+1)
+	parts = append(parts, receiver)
+	for _, name := range d.opts.GroupBy {
+		if v := a.Labels.Get(name); v != "" {
+			groupLabels[name] = v
+			parts = append(parts, fmt.Sprintf("%s=%q", name, v))
+		}
+	}
+	return strings.Join(parts, ":"), groupLabels
+}
+
+// Dispatch adds the alerts to the groups of their receivers, the alerts
+// without preferred receivers are sent to all the receivers
+func (d *Dispatcher) Dispatch(alerts ...*Alert) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	now := time.Now()
+	for _, a := range alerts {
+		receivers := a.Receivers
+		if len(receivers) == 0 {
+			for name := range d.receivers {
+				receivers = append(receivers, name)
+			}
+		}
+
+		for _, name := range receivers {
+			if _, ok := d.receivers[name]; !ok {
+				zap.S().Warnf("msg: alert for unknown receiver\t receiver: %s\t alert: %s", name, a.String())
+				continue
+			}
+			key, groupLabels := d.groupKey(name, a)
+			g, ok := d.groups[key]
+			if !ok {
+				// nothing to notify for an alert that resolved before it was seen
+				if a.ResolvedAt(now) {
+					continue
+				}
+				g = &alertGroup{
+					key:       key,
+					receiver:  name,
+					labels:    groupLabels,
+					alerts:    make(map[uint64]*Alert),
+					createdAt: now,
+					notified:  make(map[uint64]bool),
+				}
+				d.groups[key] = g
+			}
+			g.alerts[a.Hash()] = a
+		}
+	}
+}
+
+// flush sends the notifications of the groups that are due at ts
+func (d *Dispatcher) flush(ts time.Time) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	for key, g := range d.groups {
+		var firing, resolved []*Alert
+		changed := false
+		for fp, a := range g.alerts {
+			wasResolved, notified := g.notified[fp]
+			if a.ResolvedAt(ts) {
+				// only the alerts notified as firing are notified as resolved
+				if notified && !wasResolved {
+					resolved = append(resolved, a)
+					changed = true
+				}
+				continue
+			}
+			firing = append(firing, a)
+			if !notified || wasResolved {
+				changed = true
+			}
+		}
+
+		due := false
+		switch {
+		case g.flushedAt.IsZero():
+			due = ts.Sub(g.createdAt) >= d.opts.GroupWait
+		case changed:
+			due = ts.Sub(g.flushedAt) >= d.opts.GroupInterval
+		case len(firing) > 0:
+			due = ts.Sub(g.flushedAt) >= d.opts.RepeatInterval
+		}
+		if !due {
+			continue
+		}
+
+		if len(firing) > 0 || len(resolved) > 0 {
+			n := newNotification(g, firing, resolved)
+			integrations := d.receivers[g.receiver]
+			d.wg.Add(1)
+			go func() {
+				defer d.wg.Done()
+				d.notify(n, integrations)
+			}()
+		}
+
+		g.flushedAt = ts
+		g.notified = make(map[uint64]bool, len(g.alerts))
+		for fp, a := range g.alerts {
+			if a.ResolvedAt(ts) {
+				delete(g.alerts, fp)
+				continue
+			}
+			g.notified[fp] = false
+		}
+		if len(g.alerts) == 0 {
+			delete(d.groups, key)
+		}
+	}
+}
+
+func (d *Dispatcher) notify(n *notification, integrations []integration) {
+	for _, i := range integrations {
+		data := n
+		if !i.sendResolved() {
+			if len(n.Alerts.Firing()) == 0 {
+				continue
+			}
+			data = n.withoutResolved()
+		}
+
+		attempts, err := d.deliver(i, data)
+		d.logDelivery(data, i, attempts, err)
+		if err != nil {
+			zap.S().Errorf("msg: failed to deliver notification\t receiver: %s\t integration: %s\t attempts: %d\t error: %v", n.Receiver, i.name(), attempts, err)
+		}
+	}
+}
+
+// deliver sends the notification, retrying with a backoff on failures
+func (d *Dispatcher) deliver(i integration, n *notification) (int, error) {
+	backoff := d.opts.RetryBackoff
+	for attempts := 1; ; attempts++ {
+		ctx, cancel := context.WithTimeout(d.ctx, d.opts.Timeout)
+		err := i.notify(ctx, d.client, n)
+		cancel()
+		if err == nil || attempts > d.opts.MaxRetries || !isRetryable(err) {
+			return attempts, err
+		}
+
+		select {
+		case <-d.ctx.Done():
+			return attempts, err
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxRetryBackoff)
+	}
+}
+
+func (d *Dispatcher) logDelivery(n *notification, i integration, attempts int, err error) {
+	entry := &Delivery{
+		Time:        time.Now(),
+		Receiver:    n.Receiver,
+		Integration: i.name(),
+		GroupKey:    n.GroupKey,
+		Firing:      len(n.Alerts.Firing()),
+		Resolved:    len(n.Alerts.Resolved()),
+		Attempts:    attempts,
+		Success:     err == nil,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	d.logMtx.Lock()
+	defer d.logMtx.Unlock()
+	d.deliveries = append(d.deliveries, entry)
+	if drop := len(d.deliveries) - d.opts.DeliveryLogSize; drop > 0 {
+		// drop the oldest entries
+		d.deliveries = d.deliveries[drop:]
+	}
+}
+
+// DeliveryLog returns the latest deliveries, the most recent first
+func (d *Dispatcher) DeliveryLog() []*Delivery {
+	d.logMtx.RLock()
+	defer d.logMtx.RUnlock()
+
+	deliveries := make([]*Delivery, 0, len(d.deliveries))
+	for i := len(d.deliveries) - 1; i >= 0; i-- {
+		deliveries = append(deliveries, d.deliveries[i])
+	}
+	return deliveries
+}
+
+// Alerts returns the alerts held by the dispatcher that are still firing
+func (d *Dispatcher) Alerts() []*DispatchedAlert {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	now := time.Now()
+	byFingerprint := make(map[uint64]*DispatchedAlert)
+	for _, g := range d.groups {
+		for fp, a := range g.alerts {
+			if a.ResolvedAt(now) {
+				continue
+			}
+			da, ok := byFingerprint[fp]
+			if !ok {
+				da = &DispatchedAlert{
+					Labels:       a.Labels.Map(),
+					Annotations:  a.Annotations.Map(),
+					StartsAt:     a.StartsAt,
+					EndsAt:       a.EndsAt,
+					GeneratorURL: a.GeneratorURL,
+					Fingerprint:  fmt.Sprintf("%016x", fp),
+				}
+				byFingerprint[fp] = da
+			}
+			da.Receivers = append(da.Receivers, g.receiver)
+		}
+	}
+
+	alerts := make([]*DispatchedAlert, 0, len(byFingerprint))
+	for _, da := range byFingerprint {
+		sort.Strings(da.Receivers)
+		alerts = append(alerts, da)
+	}
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].StartsAt.Before(alerts[j].StartsAt) })
+	return alerts
+}
+
+func (d *Dispatcher) URL() *neturl.URL {
+	return d.parsedURL
+}
+
+func (d *Dispatcher) URLPath(path string) *neturl.URL {
+	upath, err := neturl.Parse(path)
+	if err != nil {
+		return nil
+	}
+	return d.parsedURL.ResolveReference(upath)
+}
+
+func (d *Dispatcher) AddRoute(receiver *Receiver) *model.ApiError {
+	integrations, err := parseIntegrations(receiver)
+	if err != nil {
+		return &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.receivers[receiver.Name] = integrations
+	return nil
+}
+
+func (d *Dispatcher) EditRoute(receiver *Receiver) *model.ApiError {
+	return d.AddRoute(receiver)
+}
+
+func (d *Dispatcher) DeleteRoute(name string) *model.ApiError {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	delete(d.receivers, name)
+	for key, g := range d.groups {
+		if g.receiver == name {
+			delete(d.groups, key)
+		}
+	}
+	return nil
+}
+
+// TestReceiver sends a test notification to the receiver right away
+func (d *Dispatcher) TestReceiver(receiver *Receiver) *model.ApiError {
+	integrations, err := parseIntegrations(receiver)
+	if err != nil {
+		return &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+
+	now := time.Now()
+	alert := &Alert{
+		Labels: labels.FromMap(map[string]string{
+			labels.AlertNameLabel: "Test Alert",
+			"severity":            "critical",
+		}),
+		Annotations: labels.FromMap(map[string]string{
+			"summary":     "Test alert sent from SigNoz",
+			"description": "This is a test alert to check the notification channel",
+		}),
+		StartsAt: now,
+		EndsAt:   now.Add(5 * time.Minute),
+	}
+	g := &alertGroup{
+		key:      receiver.Name + ":test",
+		receiver: receiver.Name,
+		labels:   map[string]string{labels.AlertNameLabel: "Test Alert"},
+	}
+	n := newNotification(g, []*Alert{alert}, nil)
+
+	for _, i := range integrations {
+		ctx, cancel := context.WithTimeout(d.ctx, d.opts.Timeout)
+		err := i.notify(ctx, d.client, n)
+		cancel()
+		d.logDelivery(n, i, 1, err)
+		if err != nil {
+			return &model.ApiError{Typ: model.ErrorInternal, Err: fmt.Errorf("failed to send test notification to %s: %v", i.name(), err)}
+		}
+	}
+	return nil
+}
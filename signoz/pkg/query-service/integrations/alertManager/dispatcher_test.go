@@ -0,0 +1,169 @@
+package alertManager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+type webhookRecorder struct {
+	mtx           sync.Mutex
+	notifications []map[string]interface{}
+	// failures is the number of requests to fail before accepting
+	failures int
+	status   int
+}
+
+func (rec *webhookRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rec.mtx.Lock()
+	defer rec.mtx.Unlock()
+	if rec.failures > 0 {
+		rec.failures--
+		w.WriteHeader(rec.status)
+		return
+	}
+	payload := map[string]interface{}{}
+	json.NewDecoder(r.Body).Decode(&payload)
+	rec.notifications = append(rec.notifications, payload)
+}
+
+func newTestAlert(name, instance string, endsAt time.Time) *Alert {
+	return &Alert{
+		Labels:      labels.FromMap(map[string]string{labels.AlertNameLabel: name, "instance": instance}),
+		Annotations: labels.FromMap(map[string]string{"summary": name + " on " + instance}),
+		StartsAt:    time.Now(),
+		EndsAt:      endsAt,
+	}
+}
+
+func TestDispatcherGroupsAlerts(t *testing.T) {
+	rec := &webhookRecorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	d := NewDispatcher(&DispatcherOptions{GroupWait: time.Minute, GroupInterval: 5 * time.Minute, RepeatInterval: time.Hour})
+	apiErr := d.AddRoute(&Receiver{Name: "webhook", WebhookConfigs: []map[string]interface{}{{"url": server.URL}}})
+	assert.Nil(t, apiErr)
+
+	validUntil := time.Now().Add(3 * time.Hour)
+	d.Dispatch(newTestAlert("HighLatency", "a", validUntil), newTestAlert("HighLatency", "b", validUntil), newTestAlert("ErrorRate", "a", validUntil))
+	now := time.Now()
+
+	// nothing is sent before the group wait
+	d.flush(now.Add(30 * time.Second))
+	d.wg.Wait()
+	assert.Empty(t, rec.notifications)
+
+	// one notification for every alert name
+	d.flush(now.Add(time.Minute))
+	d.wg.Wait()
+	assert.Len(t, rec.notifications, 2)
+	for _, n := range rec.notifications {
+		assert.Equal(t, "firing", n["status"])
+		assert.Equal(t, "webhook", n["receiver"])
+		if n["groupLabels"].(map[string]interface{})["alertname"] == "HighLatency" {
+			assert.Len(t, n["alerts"], 2)
+		} else {
+			assert.Len(t, n["alerts"], 1)
+		}
+	}
+
+	// no changes, nothing is sent until the repeat interval
+	d.flush(now.Add(10 * time.Minute))
+	d.wg.Wait()
+	assert.Len(t, rec.notifications, 2)
+
+	// a resolved alert is notified after the group interval
+	d.Dispatch(newTestAlert("ErrorRate", "a", now))
+	d.flush(now.Add(10 * time.Minute))
+	d.wg.Wait()
+	assert.Len(t, rec.notifications, 3)
+	assert.Equal(t, "resolved", rec.notifications[2]["status"])
+
+	// the firing alerts are notified again after the repeat interval
+	d.flush(now.Add(time.Minute + time.Hour))
+	d.wg.Wait()
+	assert.Len(t, rec.notifications, 4)
+	assert.Equal(t, "firing", rec.notifications[3]["status"])
+	assert.Len(t, rec.notifications[3]["alerts"], 2)
+
+	log := d.DeliveryLog()
+	assert.Len(t, log, 4)
+	for _, entry := range log {
+		assert.True(t, entry.Success)
+		assert.Equal(t, "webhook", entry.Integration)
+	}
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	cases := []struct {
+		name             string
+		failures         int
+		status           int
+		expectedAttempts int
+		expectedSuccess  bool
+	}{
+		{name: "server errors are retried", failures: 2, status: http.StatusServiceUnavailable, expectedAttempts: 3, expectedSuccess: true},
+		{name: "retries are limited", failures: 5, status: http.StatusInternalServerError, expectedAttempts: 3, expectedSuccess: false},
+		{name: "client errors are not retried", failures: 1, status: http.StatusBadRequest, expectedAttempts: 1, expectedSuccess: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rec := &webhookRecorder{failures: c.failures, status: c.status}
+			server := httptest.NewServer(rec)
+			defer server.Close()
+
+			d := NewDispatcher(&DispatcherOptions{MaxRetries: 2, RetryBackoff: time.Millisecond})
+			d.AddRoute(&Receiver{Name: "webhook", WebhookConfigs: []map[string]interface{}{{"url": server.URL}}})
+
+			now := time.Now()
+			d.Dispatch(newTestAlert("HighLatency", "a", now.Add(time.Hour)))
+			d.flush(now.Add(time.Minute))
+			d.wg.Wait()
+
+			log := d.DeliveryLog()
+			assert.Len(t, log, 1)
+			assert.Equal(t, c.expectedAttempts, log[0].Attempts)
+			assert.Equal(t, c.expectedSuccess, log[0].Success)
+		})
+	}
+}
+
+func TestParseIntegrations(t *testing.T) {
+	_, err := parseIntegrations(&Receiver{Name: "email", EmailConfigs: []map[string]interface{}{{"to": "a@b.c"}}})
+	assert.Error(t, err)
+
+	_, err = parseIntegrations(&Receiver{Name: "slack", SlackConfigs: []map[string]interface{}{{"channel": "#alerts"}}})
+	assert.Error(t, err)
+
+	integrations, err := parseIntegrations(&Receiver{
+		Name:             "all",
+		SlackConfigs:     []map[string]interface{}{{"api_url": "http://slack", "send_resolved": true}},
+		PagerdutyConfigs: []map[string]interface{}{{"routing_key": "key"}},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, integrations, 2)
+	assert.True(t, integrations[0].sendResolved())
+}
+
+func TestNotificationRender(t *testing.T) {
+	g := &alertGroup{key: "slack", receiver: "slack", labels: map[string]string{labels.AlertNameLabel: "HighLatency"}}
+	n := newNotification(g, []*Alert{newTestAlert("HighLatency", "a", time.Now().Add(time.Hour))}, nil)
+
+	title := `[{{ .Status | toUpper }}{{ if eq .Status "firing" }}:{{ .Alerts.Firing | len }}{{ end }}] {{ .CommonLabels.alertname }}`
+	assert.Equal(t, "[FIRING:1] HighLatency", n.render(title, n.defaultTitle))
+
+	// the alert manager templates use the methods of the labels
+	text := `{{ range .Alerts }}{{ range .Labels.SortedPairs }}{{ .Name }}={{ .Value }} {{ end }}{{ end }}| {{ join "," (.CommonLabels.Remove .GroupLabels.Names).Names }}`
+	assert.Equal(t, "alertname=HighLatency instance=a | instance", n.render(text, n.defaultText))
+
+	// invalid templates fall back to the default text
+	assert.Equal(t, "[FIRING:1] HighLatency", n.render("{{ .Unknown", n.defaultTitle))
+}
@@ -0,0 +1,529 @@
+package alertManager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/cespare/xxhash"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+	"go.uber.org/zap"
+)
+
+const (
+	notificationStatusFiring   = "firing"
+	notificationStatusResolved = "resolved"
+
+	defaultPagerdutyURL = "https://events.pagerduty.com/v2/enqueue"
+)
+
+// integration delivers the notifications of a receiver to an endpoint
+type integration interface {
+	name() string
+	sendResolved() bool
+	notify(ctx context.Context, client *http.Client, n *notification) error
+}
+
+// Pair is a label or annotation of the notification template data
+type Pair struct {
+	Name, Value string
+}
+
+// Pairs is a list of pairs, in the order of the names
+type Pairs []Pair
+
+// Names returns the names of the pairs
+func (ps Pairs) Names() []string {
+	ns := make([]string, 0, len(ps))
+	for _, p := range ps {
+		ns = append(ns, p.Name)
+	}
+	return ns
+}
+
+// Values returns the values of the pairs
+func (ps Pairs) Values() []string {
+	vs := make([]string, 0, len(ps))
+	for _, p := range ps {
+		vs = append(vs, p.Value)
+	}
+	return vs
+}
+
+// KV is the labels or the annotations of the notification template data. It
+// has the methods of the alert manager template.KV, so that the templates
+// written for the alert manager, e.g. using .Labels.SortedPairs or
+// .CommonLabels.Remove .GroupLabels.Names, keep working
+type KV map[string]string
+
+// SortedPairs returns the pairs sorted by name, with the alert name first
+func (kv KV) SortedPairs() Pairs {
+	pairs := make(Pairs, 0, len(kv))
+	for k, v := range kv {
+		pairs = append(pairs, Pair{Name: k, Value: v})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Name == labels.AlertNameLabel || pairs[j].Name == labels.AlertNameLabel {
+			return pairs[i].Name == labels.AlertNameLabel
+		}
+		return pairs[i].Name < pairs[j].Name
+	})
+	return pairs
+}
+
+// Remove returns a copy without the given names
+func (kv KV) Remove(names []string) KV {
+	res := make(KV, len(kv))
+	for k, v := range kv {
+		res[k] = v
+	}
+	for _, k := range names {
+		delete(res, k)
+	}
+	return res
+}
+
+// Names returns the sorted names
+func (kv KV) Names() []string {
+	return kv.SortedPairs().Names()
+}
+
+// Values returns the values sorted by name
+func (kv KV) Values() []string {
+	return kv.SortedPairs().Values()
+}
+
+// notificationAlert is an alert as sent in the notifications, it follows
+// the alert manager webhook payload and template.Alert so that the channel
+// templates keep working
+type notificationAlert struct {
+	Status       string    `json:"status"`
+	Labels       KV        `json:"labels"`
+	Annotations  KV        `json:"annotations"`
+	StartsAt     time.Time `json:"startsAt"`
+	EndsAt       time.Time `json:"endsAt"`
+	GeneratorURL string    `json:"generatorURL"`
+	Fingerprint  string    `json:"fingerprint"`
+}
+
+type notificationAlerts []notificationAlert
+
+func (as notificationAlerts) Firing() notificationAlerts {
+	return as.withStatus(notificationStatusFiring)
+}
+
+func (as notificationAlerts) Resolved() notificationAlerts {
+	return as.withStatus(notificationStatusResolved)
+}
+
+func (as notificationAlerts) withStatus(status string) notificationAlerts {
+	res := notificationAlerts{}
+	for _, a := range as {
+		if a.Status == status {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+// notification is the data of a notification of an alert group, it is the
+// payload of the webhooks and, like the alert manager template.Data, the data
+// of the channel templates
+type notification struct {
+	Receiver          string             `json:"receiver"`
+	Status            string             `json:"status"`
+	Alerts            notificationAlerts `json:"alerts"`
+	GroupLabels       KV                 `json:"groupLabels"`
+	CommonLabels      KV                 `json:"commonLabels"`
+	CommonAnnotations KV                 `json:"commonAnnotations"`
+	ExternalURL       string             `json:"externalURL"`
+	GroupKey          string             `json:"groupKey"`
+}
+
+func newNotification(g *alertGroup, firing, resolved []*Alert) *notification {
+	n := &notification{
+		Receiver:    g.receiver,
+		Status:      notificationStatusResolved,
+		GroupLabels: KV(g.labels),
+		GroupKey:    g.key,
+	}
+	if len(firing) > 0 {
+		n.Status = notificationStatusFiring
+	}
+
+	add := func(alerts []*Alert, status string) {
+		for _, a := range alerts {
+			n.Alerts = append(n.Alerts, notificationAlert{
+				Status:       status,
+				Labels:       a.Labels.Map(),
+				Annotations:  a.Annotations.Map(),
+				StartsAt:     a.StartsAt,
+				EndsAt:       a.EndsAt,
+				GeneratorURL: a.GeneratorURL,
+				Fingerprint:  fmt.Sprintf("%016x", a.Hash()),
+			})
+		}
+	}
+	add(firing, notificationStatusFiring)
+	add(resolved, notificationStatusResolved)
+	sort.SliceStable(n.Alerts, func(i, j int) bool { return n.Alerts[i].StartsAt.Before(n.Alerts[j].StartsAt) })
+
+	n.CommonLabels = commonValues(n.Alerts, func(a notificationAlert) KV { return a.Labels })
+	n.CommonAnnotations = commonValues(n.Alerts, func(a notificationAlert) KV { return a.Annotations })
+	if len(n.Alerts) > 0 {
+		n.ExternalURL = n.Alerts[0].GeneratorURL
+	}
+	return n
+}
+
+// withoutResolved returns a copy of the notification with only the firing alerts
+func (n *notification) withoutResolved() *notification {
+	firing := *n
+	firing.Alerts = n.Alerts.Firing()
+	return &firing
+}
+
+func commonValues(alerts notificationAlerts, values func(notificationAlert) KV) KV {
+	common := KV{}
+	if len(alerts) == 0 {
+		return common
+	}
+	for k, v := range values(alerts[0]) {
+		common[k] = v
+	}
+	for _, a := range alerts[1:] {
+		kv := values(a)
+		for k, v := range common {
+			if kv[k] != v {
+				delete(common, k)
+			}
+		}
+	}
+	return common
+}
+
+var templateFuncs = template.FuncMap{
+	"toUpper": strings.ToUpper,
+	"toLower": strings.ToLower,
+	"title": func(s string) string {
+		if s == "" {
+			return s
+		}
+		return strings.ToUpper(s[:1]) + s[1:]
+	},
+	"join": func(sep string, s []string) string {
+		return strings.Join(s, sep)
+	},
+	"match": regexp.MatchString,
+	"reReplaceAll": func(pattern, repl, text string) string {
+		re := regexp.MustCompile(pattern)
+		return re.ReplaceAllString(text, repl)
+	},
+	"stringSlice": func(s ...string) []string {
+		return s
+	},
+}
+
+// render executes the channel template with the notification,
+// the fallback is used when the template is empty or invalid
+func (n *notification) render(text string, fallback func() string) string {
+	if strings.TrimSpace(text) == "" {
+		return fallback()
+	}
+	tmpl, err := template.New("").Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		zap.S().Warnf("msg: invalid notification template, using the default text\t error: %v", err)
+		return fallback()
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, n); err != nil {
+		zap.S().Warnf("msg: failed to execute notification template, using the default text\t error: %v", err)
+		return fallback()
+	}
+	return buf.String()
+}
+
+func (n *notification) defaultTitle() string {
+	title := fmt.Sprintf("[%s", strings.ToUpper(n.Status))
+	if n.Status == notificationStatusFiring {
+		title += fmt.Sprintf(":%d", len(n.Alerts.Firing()))
+	}
+	return title + "] " + n.CommonLabels["alertname"]
+}
+
+func (n *notification) defaultText() string {
+	var lines []string
+	for _, a := range n.Alerts {
+		summary := a.Annotations["summary"]
+		if summary == "" {
+			summary = a.Labels["alertname"]
+		}
+		line := fmt.Sprintf("*%s* %s", strings.ToUpper(a.Status), summary)
+		if description := a.Annotations["description"]; description != "" {
+			line += "\n" + description
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n\n")
+}
+
+// deliveryError is returned when the endpoint responds with an error status
+type deliveryError struct {
+	statusCode int
+	body       string
+}
+
+func (e *deliveryError) Error() string {
+	return fmt.Sprintf("unexpected response status %d: %s", e.statusCode, e.body)
+}
+
+// isRetryable returns false for the errors that would fail again, i.e. the
+// client errors other than rate limiting
+func isRetryable(err error) bool {
+	if de, ok := err.(*deliveryError); ok {
+		return de.statusCode >= 500 || de.statusCode == http.StatusTooManyRequests
+	}
+	return true
+}
+
+type basicAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}, auth *basicAuth) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentTypeJSON)
+	if auth != nil && auth.Username != "" {
+		req.SetBasicAuth(auth.Username, auth.Password)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &deliveryError{statusCode: resp.StatusCode, body: string(respBody)}
+	}
+	return nil
+}
+
+type webhookConfig struct {
+	SendResolved *bool  `json:"send_resolved,omitempty"`
+	URL          string `json:"url"`
+	HTTPConfig   *struct {
+		BasicAuth *basicAuth `json:"basic_auth,omitempty"`
+	} `json:"http_config,omitempty"`
+}
+
+func (c *webhookConfig) name() string { return "webhook" }
+
+func (c *webhookConfig) sendResolved() bool { return c.SendResolved == nil || *c.SendResolved }
+
+func (c *webhookConfig) notify(ctx context.Context, client *http.Client, n *notification) error {
+	payload := struct {
+		Version string `json:"version"`
+		*notification
+	}{Version: "4", notification: n}
+
+	var auth *basicAuth
+	if c.HTTPConfig != nil {
+		auth = c.HTTPConfig.BasicAuth
+	}
+	return postJSON(ctx, client, c.URL, payload, auth)
+}
+
+type slackConfig struct {
+	SendResolved *bool  `json:"send_resolved,omitempty"`
+	APIURL       string `json:"api_url"`
+	Channel      string `json:"channel,omitempty"`
+	Username     string `json:"username,omitempty"`
+	Title        string `json:"title,omitempty"`
+	TitleLink    string `json:"title_link,omitempty"`
+	Text         string `json:"text,omitempty"`
+}
+
+func (c *slackConfig) name() string { return "slack" }
+
+func (c *slackConfig) sendResolved() bool { return c.SendResolved != nil && *c.SendResolved }
+
+func (c *slackConfig) notify(ctx context.Context, client *http.Client, n *notification) error {
+	color := "danger"
+	if n.Status == notificationStatusResolved {
+		color = "good"
+	}
+	attachment := map[string]string{
+		"title":      n.render(c.Title, n.defaultTitle),
+		"title_link": n.render(c.TitleLink, func() string { return n.ExternalURL }),
+		"text":       n.render(c.Text, n.defaultText),
+		"color":      color,
+		"fallback":   n.defaultTitle(),
+	}
+	payload := map[string]interface{}{
+		"attachments": []map[string]string{attachment},
+	}
+	if c.Channel != "" {
+		payload["channel"] = c.Channel
+	}
+	if c.Username != "" {
+		payload["username"] = c.Username
+	}
+	return postJSON(ctx, client, c.APIURL, payload, nil)
+}
+
+type pagerdutyConfig struct {
+	SendResolved *bool             `json:"send_resolved,omitempty"`
+	RoutingKey   string            `json:"routing_key,omitempty"`
+	ServiceKey   string            `json:"service_key,omitempty"`
+	URL          string            `json:"url,omitempty"`
+	Client       string            `json:"client,omitempty"`
+	ClientURL    string            `json:"client_url,omitempty"`
+	Description  string            `json:"description,omitempty"`
+	Severity     string            `json:"severity,omitempty"`
+	Class        string            `json:"class,omitempty"`
+	Component    string            `json:"component,omitempty"`
+	Group        string            `json:"group,omitempty"`
+	Details      map[string]string `json:"details,omitempty"`
+}
+
+func (c *pagerdutyConfig) name() string { return "pagerduty" }
+
+func (c *pagerdutyConfig) sendResolved() bool { return c.SendResolved == nil || *c.SendResolved }
+
+func (c *pagerdutyConfig) notify(ctx context.Context, client *http.Client, n *notification) error {
+	routingKey := c.RoutingKey
+	if routingKey == "" {
+		routingKey = c.ServiceKey
+	}
+	url := c.URL
+	if url == "" {
+		url = defaultPagerdutyURL
+	}
+
+	action := "trigger"
+	if n.Status == notificationStatusResolved {
+		action = "resolve"
+	}
+	severity := n.render(c.Severity, func() string { return "" })
+	if severity == "" {
+		severity = "critical"
+	}
+
+	details := make(map[string]string, len(c.Details))
+	for k, v := range c.Details {
+		details[k] = n.render(v, func() string { return v })
+	}
+	details["firing"] = fmt.Sprintf("%d", len(n.Alerts.Firing()))
+	details["resolved"] = fmt.Sprintf("%d", len(n.Alerts.Resolved()))
+
+	payload := map[string]interface{}{
+		"routing_key":  routingKey,
+		"event_action": action,
+		// the same group is deduplicated into a single incident
+		"dedup_key":  fmt.Sprintf("%016x", xxhash.Sum64String(n.GroupKey)),
+		"client":     n.render(c.Client, func() string { return "SigNoz" }),
+		"client_url": n.render(c.ClientURL, func() string { return n.ExternalURL }),
+		"payload": map[string]interface{}{
+			"summary":        n.render(c.Description, n.defaultTitle),
+			"source":         n.render(c.Client, func() string { return "SigNoz" }),
+			"severity":       severity,
+			"class":          n.render(c.Class, func() string { return "" }),
+			"component":      n.render(c.Component, func() string { return "" }),
+			"group":          n.render(c.Group, func() string { return "" }),
+			"custom_details": details,
+		},
+	}
+	return postJSON(ctx, client, url, payload, nil)
+}
+
+// decodeConfigs decodes the untyped channel configs of a receiver
+func decodeConfigs(configs interface{}, into interface{}) error {
+	b, err := json.Marshal(configs)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, into)
+}
+
+// parseIntegrations returns the integrations of the receiver supported by
+// the embedded dispatcher
+func parseIntegrations(r *Receiver) ([]integration, error) {
+	if r.Name == "" {
+		return nil, fmt.Errorf("channel name is required")
+	}
+
+	unsupported := map[string]interface{}{
+		"email":     r.EmailConfigs,
+		"opsgenie":  r.OpsGenieConfigs,
+		"wechat":    r.WechatConfigs,
+		"pushover":  r.PushoverConfigs,
+		"victorops": r.VictorOpsConfigs,
+		"sns":       r.SNSConfigs,
+		"msteams":   r.MSTeamsConfigs,
+	}
+	for channelType, configs := range unsupported {
+		if configs != nil {
+			return nil, fmt.Errorf("%s channels are not supported by the embedded alert manager", channelType)
+		}
+	}
+
+	var integrations []integration
+	if r.WebhookConfigs != nil {
+		var configs []*webhookConfig
+		if err := decodeConfigs(r.WebhookConfigs, &configs); err != nil {
+			return nil, fmt.Errorf("invalid webhook config: %v", err)
+		}
+		for _, c := range configs {
+			if c.URL == "" {
+				return nil, fmt.Errorf("webhook url is required")
+			}
+			integrations = append(integrations, c)
+		}
+	}
+	if r.SlackConfigs != nil {
+		var configs []*slackConfig
+		if err := decodeConfigs(r.SlackConfigs, &configs); err != nil {
+			return nil, fmt.Errorf("invalid slack config: %v", err)
+		}
+		for _, c := range configs {
+			if c.APIURL == "" {
+				return nil, fmt.Errorf("slack api_url is required")
+			}
+			integrations = append(integrations, c)
+		}
+	}
+	if r.PagerdutyConfigs != nil {
+		var configs []*pagerdutyConfig
+		if err := decodeConfigs(r.PagerdutyConfigs, &configs); err != nil {
+			return nil, fmt.Errorf("invalid pagerduty config: %v", err)
+		}
+		for _, c := range configs {
+			if c.RoutingKey == "" && c.ServiceKey == "" {
+				return nil, fmt.Errorf("pagerduty routing_key is required")
+			}
+			integrations = append(integrations, c)
+		}
+	}
+	if len(integrations) == 0 {
+		return nil, fmt.Errorf("channel %s has no configs", r.Name)
+	}
+	return integrations, nil
+}
@@ -25,6 +25,9 @@ type Manager interface {
 }
 
 func New(url string) (Manager, error) {
+	if constants.IsEmbeddedAlertManager() {
+		return Embedded(), nil
+	}
 
 	if url == "" {
 		url = constants.GetAlertManagerApiPrefix()
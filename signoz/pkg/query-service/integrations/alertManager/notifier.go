@@ -221,6 +221,13 @@ func (n *Notifier) sendAll(alerts ...*Alert) bool {
 	ams.mtx.RLock()
 
 	for _, am := range ams.ams {
+		// the embedded dispatcher takes the alerts directly
+		if d, ok := am.(*Dispatcher); ok {
+			d.Dispatch(alerts...)
+			atomic.AddUint64(&numSuccess, 1)
+			continue
+		}
+
 		wg.Add(1)
 
 		ctx, cancel := context.WithTimeout(n.ctx, time.Duration(ams.timeout))
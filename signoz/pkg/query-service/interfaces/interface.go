@@ -46,12 +46,14 @@ type Reader interface {
 
 	ListErrors(ctx context.Context, params *model.ListErrorsParams) (*[]model.Error, *model.ApiError)
 	CountErrors(ctx context.Context, params *model.CountErrorsParams) (uint64, *model.ApiError)
+	GetErrorGroupRows(ctx context.Context, params *model.ListErrorsParams, frames int, limit int) ([]model.ErrorGroupRow, *model.ApiError)
 	GetErrorFromErrorID(ctx context.Context, params *model.GetErrorParams) (*model.ErrorWithSpan, *model.ApiError)
 	GetErrorFromGroupID(ctx context.Context, params *model.GetErrorParams) (*model.ErrorWithSpan, *model.ApiError)
 	GetNextPrevErrorIDs(ctx context.Context, params *model.GetErrorParams) (*model.NextPrevErrorIDs, *model.ApiError)
 
 	// Search Interfaces
 	SearchTraces(ctx context.Context, traceID string, spanId string, levelUp int, levelDown int, spanLimit int, smartTraceAlgorithm func(payload []model.SearchSpanResponseItem, targetSpanId string, levelUp int, levelDown int, spanLimit int) ([]model.SearchSpansResult, error)) (*[]model.SearchSpansResult, error)
+	GetTraceSpans(ctx context.Context, traceID string) ([]model.SearchSpanResponseItem, error)
 
 	// Setter Interfaces
 	SetTTL(ctx context.Context, ttlParams *model.TTLParams) (*model.SetTTLResponseItem, *model.ApiError)
@@ -69,7 +71,13 @@ type Reader interface {
 	// QB V3 metrics/traces/logs
 	GetTimeSeriesResultV3(ctx context.Context, query string) ([]*v3.Series, error)
 	GetListResultV3(ctx context.Context, query string) ([]*v3.Row, error)
-	LiveTailLogsV3(ctx context.Context, query string, timestampStart uint64, idStart string, client *v3.LogsLiveTailClient)
+	StreamListResultV3(ctx context.Context, query string, fn func(*v3.Row) error) error
+	GetCallGraphV3(ctx context.Context, query string) ([]*v3.CallGraphEdge, error)
+	EstimateQueryV3(ctx context.Context, query string) (*v3.QueryEstimate, error)
+	TailLogsV3(ctx context.Context, query string, timestampStart uint64, idStart string, limit int) ([]model.SignozLog, error)
+	LastLogsV3(ctx context.Context, query string, timestampEnd uint64, idEnd string, limit int) ([]model.SignozLog, error)
+	WriteMetricSamplesV3(ctx context.Context, metricName string, series []*v3.Series) error
+	MetricNameExists(ctx context.Context, metricName string) (bool, error)
 
 	GetTotalSpans(ctx context.Context) (uint64, error)
 	GetSpansInLastHeartBeatInterval(ctx context.Context) (uint64, error)
@@ -101,6 +109,12 @@ type Reader interface {
 
 type Querier interface {
 	QueryRange(context.Context, *v3.QueryRangeParamsV3, map[string]v3.AttributeKey) ([]*v3.Result, error, map[string]string)
+	EstimateQueryRange(context.Context, *v3.QueryRangeParamsV3, map[string]v3.AttributeKey) ([]*v3.QueryEstimate, *model.ApiError)
+	ExportList(ctx context.Context, params *v3.QueryRangeParamsV3, keys map[string]v3.AttributeKey, fn func(*v3.Row) error) error
+
+	// cache management
+	ListCachedQueries(v3.QueryCacheFilter) []*v3.QueryCacheEntry
+	EvictCachedQueries(v3.QueryCacheFilter) int
 
 	// test helpers
 	QueriesExecuted() []string
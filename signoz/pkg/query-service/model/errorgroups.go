@@ -0,0 +1,168 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+type ErrorGroupState string
+
+const (
+	ErrorGroupUnresolved ErrorGroupState = "unresolved"
+	ErrorGroupResolved   ErrorGroupState = "resolved"
+	ErrorGroupIgnored    ErrorGroupState = "ignored"
+	// ErrorGroupRegressed is set on the resolved groups seen again after they
+	// were resolved
+	ErrorGroupRegressed ErrorGroupState = "regressed"
+)
+
+func (s ErrorGroupState) Validate() error {
+	switch s {
+	case ErrorGroupUnresolved, ErrorGroupResolved, ErrorGroupIgnored, ErrorGroupRegressed:
+		return nil
+	default:
+		return fmt.Errorf("invalid error group state: %s", s)
+	}
+}
+
+// ErrorGroupStatus is the state of the error group of the fingerprint
+type ErrorGroupStatus struct {
+	Fingerprint string          `json:"fingerprint"`
+	State       ErrorGroupState `json:"state"`
+	// ResolvedInRelease is the release fixing the error. The group regresses
+	// when the error is seen again after it was resolved, unless it is seen in
+	// a release older than the fixing release, which is still deployed.
+	ResolvedInRelease string `json:"resolvedInRelease,omitempty"`
+	// GroupIDs are the ingested groups of the error group when the state was
+	// set. The fingerprint changes with the grouping rules, the state then
+	// applies to the error groups made of these ingested groups.
+	GroupIDs  []string  `json:"groupIds,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	UpdatedBy string    `json:"updatedBy"`
+}
+
+func (s *ErrorGroupStatus) Validate() error {
+	if s.Fingerprint == "" {
+		return fmt.Errorf("fingerprint is required")
+	}
+	if s.State == ErrorGroupRegressed {
+		return fmt.Errorf("the state %s is set when a resolved error is seen again", ErrorGroupRegressed)
+	}
+	if s.ResolvedInRelease != "" && s.State != ErrorGroupResolved {
+		return fmt.Errorf("resolvedInRelease is only allowed for the %s state", ErrorGroupResolved)
+	}
+	return s.State.Validate()
+}
+
+type ErrorGroupingRuleType string
+
+const (
+	// ErrorGroupingRuleNormalize replaces the matches of the pattern in the
+	// messages of the errors
+	ErrorGroupingRuleNormalize ErrorGroupingRuleType = "normalize"
+	// ErrorGroupingRuleFingerprint groups the errors by the fields
+	ErrorGroupingRuleFingerprint ErrorGroupingRuleType = "fingerprint"
+	// ErrorGroupingRuleMerge merges the error groups into one
+	ErrorGroupingRuleMerge ErrorGroupingRuleType = "merge"
+)
+
+type ErrorFingerprintField string
+
+const (
+	ErrorFingerprintServiceName   ErrorFingerprintField = "serviceName"
+	ErrorFingerprintExceptionType ErrorFingerprintField = "exceptionType"
+	ErrorFingerprintMessage       ErrorFingerprintField = "message"
+	ErrorFingerprintFrames        ErrorFingerprintField = "frames"
+)
+
+// MaxErrorFingerprintFrames is the most frames of the stack trace a
+// fingerprint rule can group by
+const MaxErrorFingerprintFrames = 20
+
+// ErrorGroupingRule changes how the errors are grouped. The rules are applied
+// in the order they were created, the messages are normalized by all the
+// normalize rules matching the error, then the error is grouped by the first
+// merge or fingerprint rule matching it. The errors matching no rule are
+// grouped by service, exception type and normalized message.
+type ErrorGroupingRule struct {
+	ID   string                `json:"id"`
+	Name string                `json:"name"`
+	Type ErrorGroupingRuleType `json:"type"`
+	// ServiceName and ExceptionType limit the rule to the errors of the
+	// service and of the exception type when set
+	ServiceName   string `json:"serviceName,omitempty"`
+	ExceptionType string `json:"exceptionType,omitempty"`
+	// Pattern and Replacement are the regular expression and its literal
+	// replacement of the normalize rules
+	Pattern     string `json:"pattern,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+	// Fields and Frames are the fields and the number of top frames of the
+	// stack trace the fingerprint rules group by
+	Fields []ErrorFingerprintField `json:"fields,omitempty"`
+	Frames int                     `json:"frames,omitempty"`
+	// GroupIDs are the groups merged by the merge rules. The fingerprint rules
+	// with GroupIDs split these groups by the fields.
+	GroupIDs  []string  `json:"groupIDs,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	CreatedBy string    `json:"createdBy"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	UpdatedBy string    `json:"updatedBy"`
+}
+
+func (r *ErrorGroupingRule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch r.Type {
+	case ErrorGroupingRuleNormalize:
+		if r.Pattern == "" {
+			return fmt.Errorf("pattern is required for the %s rules", r.Type)
+		}
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %s", err.Error())
+		}
+	case ErrorGroupingRuleFingerprint:
+		if len(r.Fields) == 0 {
+			return fmt.Errorf("fields are required for the %s rules", r.Type)
+		}
+		hasFrames := false
+		for _, field := range r.Fields {
+			switch field {
+			case ErrorFingerprintServiceName, ErrorFingerprintExceptionType, ErrorFingerprintMessage:
+			case ErrorFingerprintFrames:
+				hasFrames = true
+			default:
+				return fmt.Errorf("invalid fingerprint field: %s", field)
+			}
+		}
+		if hasFrames && (r.Frames <= 0 || r.Frames > MaxErrorFingerprintFrames) {
+			return fmt.Errorf("frames must be between 1 and %d", MaxErrorFingerprintFrames)
+		}
+		if !hasFrames && r.Frames != 0 {
+			return fmt.Errorf("frames is only allowed with the %s field", ErrorFingerprintFrames)
+		}
+	case ErrorGroupingRuleMerge:
+		if len(r.GroupIDs) < 2 {
+			return fmt.Errorf("at least two groupIDs are required for the %s rules", r.Type)
+		}
+	default:
+		return fmt.Errorf("invalid error grouping rule type: %s", r.Type)
+	}
+	return nil
+}
+
+// ErrorGroupRow is the errors of an ingested group with the same top frames,
+// they are grouped again by the grouping rules
+type ErrorGroupRow struct {
+	GroupID        string    `ch:"groupID"`
+	ServiceName    string    `ch:"serviceName"`
+	ExceptionType  string    `ch:"exceptionType"`
+	ExceptionMsg   string    `ch:"exceptionMessage"`
+	Frames         string    `ch:"frames"`
+	ExceptionCount uint64    `ch:"exceptionCount"`
+	FirstSeen      time.Time `ch:"firstSeen"`
+	LastSeen       time.Time `ch:"lastSeen"`
+	// LastRelease is the release of the service of the last error
+	LastRelease string `ch:"lastRelease"`
+}
@@ -181,6 +181,12 @@ type GetServicesParams struct {
 	Start     *time.Time
 	End       *time.Time
 	Tags      []TagQueryParam `json:"tags"`
+	// ComparisonStartTime and ComparisonEndTime are the optional window the
+	// dependency graph is compared with
+	ComparisonStartTime string `json:"comparisonStart,omitempty"`
+	ComparisonEndTime   string `json:"comparisonEnd,omitempty"`
+	ComparisonStart     *time.Time
+	ComparisonEnd       *time.Time
 }
 
 type GetServiceOverviewParams struct {
@@ -503,6 +509,8 @@ type ListErrorsParams struct {
 	ServiceName   string          `json:"serviceName"`
 	ExceptionType string          `json:"exceptionType"`
 	Tags          []TagQueryParam `json:"tags"`
+	// States filters the error groups by state when set
+	States []ErrorGroupState `json:"states"`
 }
 
 type CountErrorsParams struct {
@@ -513,12 +521,16 @@ type CountErrorsParams struct {
 	ServiceName   string          `json:"serviceName"`
 	ExceptionType string          `json:"exceptionType"`
 	Tags          []TagQueryParam `json:"tags"`
+	// States filters the error groups by state when set
+	States []ErrorGroupState `json:"states"`
 }
 
 type GetErrorParams struct {
 	GroupID   string
 	ErrorID   string
 	Timestamp *time.Time
+	// Fingerprint is the group made by the grouping rules the error is in
+	Fingerprint string
 }
 
 type FilterItem struct {
@@ -541,6 +553,11 @@ type UpdateField struct {
 	IndexGranularity int    `json:"indexGranularity"`
 }
 
+type CompareTracesParams struct {
+	BaseTraceID       string `json:"baseTraceId"`
+	ComparisonTraceID string `json:"comparisonTraceId"`
+}
+
 type LogsFilterParams struct {
 	Limit          int    `json:"limit"`
 	OrderBy        string `json:"orderBy"`
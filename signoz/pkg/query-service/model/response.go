@@ -65,6 +65,7 @@ const (
 	ErrorConflict                 ErrorType = "conflict"
 	ErrorStreamingNotSupported    ErrorType = "streaming is not supported"
 	ErrorStatusServiceUnavailable ErrorType = "service unavailable"
+	ErrorLimitExceeded            ErrorType = "limit_exceeded"
 )
 
 // BadRequest returns a ApiError object of bad request
@@ -202,6 +203,86 @@ type ServiceOverviewItem struct {
 type SearchSpansResult struct {
 	Columns []string        `json:"columns"`
 	Events  [][]interface{} `json:"events"`
+	// Analysis is the analysis of the whole trace, even when the events are
+	// a part of it
+	Analysis *TraceAnalysis `json:"analysis,omitempty"`
+}
+
+// TraceAnalysis is where the time of a trace is spent
+type TraceAnalysis struct {
+	// SelfTimeNano is the time of every span not covered by its children
+	SelfTimeNano map[string]int64 `json:"selfTimeNano"`
+	// CriticalPath is the chain of span segments the end of the trace
+	// waited on, in time order. The children running in parallel with the
+	// child the parent waited on last are off the path.
+	CriticalPath             []CriticalPathSegment `json:"criticalPath"`
+	CriticalPathDurationNano int64                 `json:"criticalPathDurationNano"`
+	// Services is the time of every service on the critical path, the
+	// longest first
+	Services []ServiceCriticalPathTime `json:"services"`
+}
+
+type CriticalPathSegment struct {
+	SpanID       string `json:"spanId"`
+	ServiceName  string `json:"serviceName"`
+	Name         string `json:"name"`
+	StartNano    int64  `json:"startNano"`
+	EndNano      int64  `json:"endNano"`
+	DurationNano int64  `json:"durationNano"`
+}
+
+type ServiceCriticalPathTime struct {
+	ServiceName  string  `json:"serviceName"`
+	DurationNano int64   `json:"durationNano"`
+	Percent      float64 `json:"percent"`
+}
+
+// TraceComparison is the difference between the span trees of two traces,
+// the spans are aligned by the path of services and operations from the root
+type TraceComparison struct {
+	BaseTraceID            string `json:"baseTraceId"`
+	ComparisonTraceID      string `json:"comparisonTraceId"`
+	BaseDurationNano       int64  `json:"baseDurationNano"`
+	ComparisonDurationNano int64  `json:"comparisonDurationNano"`
+	DurationDeltaNano      int64  `json:"durationDeltaNano"`
+	// Added and Missing count the spans of the comparison trace missing from
+	// the base trace and the spans of the base trace missing from the
+	// comparison trace
+	Added   int                   `json:"added"`
+	Missing int                   `json:"missing"`
+	Roots   []*SpanComparisonNode `json:"roots"`
+}
+
+type SpanComparisonStatus string
+
+const (
+	SpanComparisonMatched SpanComparisonStatus = "matched"
+	SpanComparisonAdded   SpanComparisonStatus = "added"
+	SpanComparisonMissing SpanComparisonStatus = "missing"
+)
+
+// SpanComparisonNode is a span of either trace or a pair of aligned spans
+type SpanComparisonNode struct {
+	ServiceName            string                `json:"serviceName"`
+	Name                   string                `json:"name"`
+	Status                 SpanComparisonStatus  `json:"status"`
+	BaseSpanID             string                `json:"baseSpanId,omitempty"`
+	ComparisonSpanID       string                `json:"comparisonSpanId,omitempty"`
+	BaseDurationNano       int64                 `json:"baseDurationNano"`
+	ComparisonDurationNano int64                 `json:"comparisonDurationNano"`
+	DurationDeltaNano      int64                 `json:"durationDeltaNano"`
+	BaseHasError           bool                  `json:"baseHasError"`
+	ComparisonHasError     bool                  `json:"comparisonHasError"`
+	AttributeDiffs         []SpanAttributeDiff   `json:"attributeDiffs,omitempty"`
+	Children               []*SpanComparisonNode `json:"children"`
+}
+
+// SpanAttributeDiff is an attribute with different values in the aligned
+// spans, the value is empty in the span without the attribute
+type SpanAttributeDiff struct {
+	Key             string `json:"key"`
+	BaseValue       string `json:"baseValue"`
+	ComparisonValue string `json:"comparisonValue"`
 }
 
 type GetFilterSpansResponseItem struct {
@@ -329,6 +410,25 @@ type ServiceMapDependencyResponseItem struct {
 	P90       float64 `json:"p90" ch:"p90"`
 	P75       float64 `json:"p75" ch:"p75"`
 	P50       float64 `json:"p50" ch:"p50"`
+	// Sampled is set when the edge is read from a sample of the traces, the
+	// counts and the rates are scaled to all the traces
+	Sampled bool `json:"sampled,omitempty" ch:"sampled"`
+	// Comparison is set when the dependency graph is compared with another
+	// window
+	Comparison *ServiceMapDependencyComparison `json:"comparison,omitempty"`
+}
+
+// ServiceMapDependencyComparison is the edge in the comparison window and the
+// change of its metrics from the comparison window
+type ServiceMapDependencyComparison struct {
+	CallCount      uint64  `json:"callCount"`
+	CallRate       float64 `json:"callRate"`
+	ErrorRate      float64 `json:"errorRate"`
+	P99            float64 `json:"p99"`
+	CallRateDelta  float64 `json:"callRateDelta"`
+	ErrorRateDelta float64 `json:"errorRateDelta"`
+	P99Delta       float64 `json:"p99Delta"`
+	Sampled        bool    `json:"sampled,omitempty"`
 }
 
 type GetFilteredSpansAggregatesResponse struct {
@@ -458,19 +558,25 @@ type Error struct {
 	FirstSeen      time.Time `json:"firstSeen" ch:"firstSeen"`
 	ServiceName    string    `json:"serviceName" ch:"serviceName"`
 	GroupID        string    `json:"groupID" ch:"groupID"`
+	// Fingerprint identifies the group made by the grouping rules, it is the
+	// GroupID of the ingested group unless the rules merged or split it
+	Fingerprint string          `json:"fingerprint,omitempty"`
+	GroupIDs    []string        `json:"groupIDs,omitempty"`
+	State       ErrorGroupState `json:"state,omitempty"`
 }
 
 type ErrorWithSpan struct {
-	ErrorID             string    `json:"errorId" ch:"errorID"`
-	ExceptionType       string    `json:"exceptionType" ch:"exceptionType"`
-	ExceptionStacktrace string    `json:"exceptionStacktrace" ch:"exceptionStacktrace"`
-	ExceptionEscaped    bool      `json:"exceptionEscaped" ch:"exceptionEscaped"`
-	ExceptionMsg        string    `json:"exceptionMessage" ch:"exceptionMessage"`
-	Timestamp           time.Time `json:"timestamp" ch:"timestamp"`
-	SpanID              string    `json:"spanID" ch:"spanID"`
-	TraceID             string    `json:"traceID" ch:"traceID"`
-	ServiceName         string    `json:"serviceName" ch:"serviceName"`
-	GroupID             string    `json:"groupID" ch:"groupID"`
+	ErrorID             string          `json:"errorId" ch:"errorID"`
+	ExceptionType       string          `json:"exceptionType" ch:"exceptionType"`
+	ExceptionStacktrace string          `json:"exceptionStacktrace" ch:"exceptionStacktrace"`
+	ExceptionEscaped    bool            `json:"exceptionEscaped" ch:"exceptionEscaped"`
+	ExceptionMsg        string          `json:"exceptionMessage" ch:"exceptionMessage"`
+	Timestamp           time.Time       `json:"timestamp" ch:"timestamp"`
+	SpanID              string          `json:"spanID" ch:"spanID"`
+	TraceID             string          `json:"traceID" ch:"traceID"`
+	ServiceName         string          `json:"serviceName" ch:"serviceName"`
+	GroupID             string          `json:"groupID" ch:"groupID"`
+	State               ErrorGroupState `json:"state,omitempty"`
 }
 
 type NextPrevErrorIDsDBResponse struct {
@@ -2,15 +2,19 @@ package v3
 
 import (
 	"database/sql/driver"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"math"
+	"regexp"
 	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
-	"go.signoz.io/signoz/pkg/query-service/model"
 )
 
 type DataSource string
@@ -175,6 +179,127 @@ func (r ReduceToOperator) Validate() error {
 	}
 }
 
+// FunctionName is a function applied on the series of a builder query
+// after they are read
+type FunctionName string
+
+const (
+	FunctionNameMovingAvg    FunctionName = "movingAvg"
+	FunctionNameEWMA         FunctionName = "ewma"
+	FunctionNameCumSum       FunctionName = "cumSum"
+	FunctionNameTopK         FunctionName = "topK"
+	FunctionNameBottomK      FunctionName = "bottomK"
+	FunctionNameClampMin     FunctionName = "clampMin"
+	FunctionNameClampMax     FunctionName = "clampMax"
+	FunctionNameAbsolute     FunctionName = "absolute"
+	FunctionNameDerivative   FunctionName = "derivative"
+	FunctionNameFillZero     FunctionName = "fillZero"
+	FunctionNameFillPrevious FunctionName = "fillPrevious"
+	FunctionNameTimeShift    FunctionName = "timeShift"
+)
+
+// Function is a series function with its arguments:
+//   - movingAvg(window), the window is the number of points
+//   - ewma(alpha), alpha is the smoothing factor in (0, 1]
+//   - topK(k, reduceTo) and bottomK(k, reduceTo), the series are ranked by
+//     the value they reduce to, avg by default
+//   - clampMin(min) and clampMax(max)
+//   - timeShift(seconds) moves the points forward by the given seconds
+//   - cumSum, absolute, derivative (per second), fillZero and fillPrevious
+//     take no arguments
+type Function struct {
+	Name FunctionName  `json:"name"`
+	Args []interface{} `json:"args,omitempty"`
+}
+
+func (f *Function) Validate() error {
+	switch f.Name {
+	case FunctionNameCumSum, FunctionNameAbsolute, FunctionNameDerivative, FunctionNameFillZero, FunctionNameFillPrevious:
+		if len(f.Args) != 0 {
+			return fmt.Errorf("function %s takes no arguments", f.Name)
+		}
+		return nil
+	case FunctionNameMovingAvg, FunctionNameEWMA, FunctionNameClampMin, FunctionNameClampMax, FunctionNameTimeShift:
+		if len(f.Args) != 1 {
+			return fmt.Errorf("function %s takes one argument", f.Name)
+		}
+	case FunctionNameTopK, FunctionNameBottomK:
+		if len(f.Args) != 1 && len(f.Args) != 2 {
+			return fmt.Errorf("function %s takes the number of series and optionally the reduce to operator", f.Name)
+		}
+		if len(f.Args) == 2 {
+			if err := ReduceToOperator(fmt.Sprintf("%v", f.Args[1])).Validate(); err != nil {
+				return fmt.Errorf("function %s: %w", f.Name, err)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid function: %s", f.Name)
+	}
+
+	arg, err := f.NumberArg(0)
+	if err != nil {
+		return err
+	}
+	switch f.Name {
+	case FunctionNameMovingAvg, FunctionNameTopK, FunctionNameBottomK:
+		if arg < 1 || arg != math.Trunc(arg) {
+			return fmt.Errorf("function %s takes a positive whole number", f.Name)
+		}
+	case FunctionNameEWMA:
+		if arg <= 0 || arg > 1 {
+			return fmt.Errorf("function %s takes an alpha between 0 and 1", f.Name)
+		}
+	}
+	return nil
+}
+
+// NumberArg returns the argument at the index as a number
+func (f *Function) NumberArg(idx int) (float64, error) {
+	if idx >= len(f.Args) {
+		return 0, fmt.Errorf("function %s is missing argument %d", f.Name, idx+1)
+	}
+	switch v := f.Args[idx].(type) {
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("function %s takes a number as argument %d", f.Name, idx+1)
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("function %s takes a number as argument %d", f.Name, idx+1)
+	}
+}
+
+// FormulaEvaluation is where the formula of a builder query is evaluated
+type FormulaEvaluation string
+
+const (
+	// FormulaEvaluationAuto evaluates the formula in the querier when an
+	// operand has functions or is a formula and in clickhouse otherwise
+	FormulaEvaluationAuto FormulaEvaluation = ""
+	// FormulaEvaluationClickHouse joins the queries of the operands in a
+	// single clickhouse query
+	FormulaEvaluationClickHouse FormulaEvaluation = "clickhouse"
+	// FormulaEvaluationQuerier evaluates the formula over the series of the
+	// operands once they are read
+	FormulaEvaluationQuerier FormulaEvaluation = "querier"
+)
+
+func (f FormulaEvaluation) Validate() error {
+	switch f {
+	case FormulaEvaluationAuto, FormulaEvaluationClickHouse, FormulaEvaluationQuerier:
+		return nil
+	default:
+		return fmt.Errorf("invalid formula evaluation: %s", f)
+	}
+}
+
 type QueryType string
 
 const (
@@ -201,11 +326,16 @@ const (
 	PanelTypeTable PanelType = "table"
 	PanelTypeList  PanelType = "list"
 	PanelTypeTrace PanelType = "trace"
+	// PanelTypePatterns groups the logs of the list of a query into patterns
+	PanelTypePatterns PanelType = "patterns"
+	// PanelTypeCallGraph merges the span trees of the traces of a query into
+	// a graph of the calls between operations
+	PanelTypeCallGraph PanelType = "call_graph"
 )
 
 func (p PanelType) Validate() error {
 	switch p {
-	case PanelTypeValue, PanelTypeGraph, PanelTypeTable, PanelTypeList, PanelTypeTrace:
+	case PanelTypeValue, PanelTypeGraph, PanelTypeTable, PanelTypeList, PanelTypeTrace, PanelTypePatterns, PanelTypeCallGraph:
 		return nil
 	default:
 		return fmt.Errorf("invalid panel type: %s", p)
@@ -353,6 +483,14 @@ type QueryRangeParamsV3 struct {
 	CompositeQuery *CompositeQuery        `json:"compositeQuery"`
 	Variables      map[string]interface{} `json:"variables,omitempty"`
 	NoCache        bool                   `json:"noCache"`
+	// DashboardID is optionally sent by the dashboard panels, it is only used
+	// to group the cache entries so they can be evicted per dashboard
+	DashboardID string `json:"dashboardId,omitempty"`
+	// DryRun returns the queries of the request and their estimates
+	// without running them
+	DryRun bool `json:"dryRun,omitempty"`
+	// CollectStats adds the execution statistics of every query to its result
+	CollectStats bool `json:"collectStats,omitempty"`
 }
 
 type PromQuery struct {
@@ -432,6 +570,14 @@ func (c *CompositeQuery) Validate() error {
 		return fmt.Errorf("panel type is invalid: %w", err)
 	}
 
+	if c.PanelType == PanelTypePatterns && c.QueryType != QueryTypeBuilder {
+		return fmt.Errorf("patterns are only supported for builder queries")
+	}
+
+	if c.PanelType == PanelTypeCallGraph && c.QueryType != QueryTypeBuilder {
+		return fmt.Errorf("call graphs are only supported for builder queries")
+	}
+
 	if err := c.QueryType.Validate(); err != nil {
 		return fmt.Errorf("query type is invalid: %w", err)
 	}
@@ -466,6 +612,38 @@ type BuilderQuery struct {
 	OrderBy            []OrderBy         `json:"orderBy,omitempty"`
 	ReduceTo           ReduceToOperator  `json:"reduceTo,omitempty"`
 	SelectColumns      []AttributeKey    `json:"selectColumns,omitempty"`
+	// TimeShift moves the time range of the query back by the given seconds,
+	// the points are moved forward again to line up with the requested range
+	TimeShift int64 `json:"timeShift,omitempty"`
+	// Functions are applied in order on the series of the query
+	Functions []Function `json:"functions,omitempty"`
+	// EvaluateIn tells where the formula is evaluated
+	EvaluateIn FormulaEvaluation `json:"evaluateIn,omitempty"`
+	// After limits a list query to the rows following the position, the rows
+	// are ordered by timestamp and id in the direction of the position
+	After *ListPosition `json:"-"`
+	// Cursor is the cursor of the previous page of a logs list query, the
+	// query returns the logs following it
+	Cursor string `json:"cursor,omitempty"`
+	// LabelMapping renames the labels of the operands of the formula (by
+	// query name) so that the series of different data sources, which name
+	// the same attribute differently, are matched, e.g. {"B": {"serviceName": "service_name"}}
+	LabelMapping map[string]map[string]string `json:"labelMapping,omitempty"`
+}
+
+// OperandLabel returns the name of the label of the operand in the formula
+func (b *BuilderQuery) OperandLabel(operand, label string) string {
+	if mapped, ok := b.LabelMapping[operand][label]; ok {
+		return mapped
+	}
+	return label
+}
+
+// ShiftTimeRange returns the time range (in ms) read by the query for the
+// requested time range
+func (b *BuilderQuery) ShiftTimeRange(start, end int64) (int64, int64) {
+	shift := b.TimeShift * 1000
+	return start - shift, end - shift
 }
 
 func (b *BuilderQuery) Validate() error {
@@ -515,6 +693,34 @@ func (b *BuilderQuery) Validate() error {
 		}
 	}
 
+	if b.TimeShift < 0 {
+		return fmt.Errorf("time shift cannot be negative")
+	}
+	// the shifted points are realigned to the step, they would not line up
+	// with the requested range otherwise
+	if b.TimeShift > 0 && b.StepInterval > 0 && b.TimeShift%b.StepInterval != 0 {
+		return fmt.Errorf("time shift must be a multiple of the step interval %d", b.StepInterval)
+	}
+
+	for _, function := range b.Functions {
+		if err := function.Validate(); err != nil {
+			return fmt.Errorf("function is invalid: %w", err)
+		}
+	}
+
+	if err := b.EvaluateIn.Validate(); err != nil {
+		return err
+	}
+
+	if b.Cursor != "" {
+		if b.DataSource != DataSourceLogs || b.AggregateOperator != AggregateOperatorNoOp {
+			return fmt.Errorf("cursor is only supported for logs list queries")
+		}
+		if _, err := ParseListCursor(b.Cursor); err != nil {
+			return err
+		}
+	}
+
 	if b.Expression == "" {
 		return fmt.Errorf("expression is required")
 	}
@@ -602,6 +808,10 @@ type OrderBy struct {
 	IsColumn   bool                 `json:"-"`
 }
 
+func (o *OrderBy) CacheKey() string {
+	return fmt.Sprintf("column:%s,order:%s", o.ColumnName, o.Order)
+}
+
 type Having struct {
 	ColumnName string      `json:"columnName"`
 	Operator   string      `json:"op"`
@@ -620,16 +830,113 @@ type QueryRangeResponse struct {
 }
 
 type Result struct {
-	QueryName string    `json:"queryName"`
-	Series    []*Series `json:"series"`
-	List      []*Row    `json:"list"`
+	QueryName string      `json:"queryName"`
+	Series    []*Series   `json:"series"`
+	List      []*Row      `json:"list"`
+	Stats     *QueryStats `json:"stats,omitempty"`
+	// Cursor is set when the list may continue, it is passed on the query to
+	// get the next page
+	Cursor    string           `json:"cursor,omitempty"`
+	Patterns  []*LogPattern    `json:"patterns,omitempty"`
+	CallGraph []*CallGraphEdge `json:"callGraph,omitempty"`
+}
+
+// CallGraphEdge is the calls from the spans of an operation to the spans of
+// another operation, over the traces of a query
+type CallGraphEdge struct {
+	ParentServiceName string `json:"parentServiceName" ch:"parentServiceName"`
+	ParentName        string `json:"parentName" ch:"parentName"`
+	ServiceName       string `json:"serviceName" ch:"serviceName"`
+	Name              string `json:"name" ch:"name"`
+	Calls             uint64 `json:"calls" ch:"calls"`
+	// Traces is the number of traces with the call
+	Traces        uint64  `json:"traces" ch:"traces"`
+	CallsPerTrace float64 `json:"callsPerTrace" ch:"callsPerTrace"`
+	// P50 and P95 are the percentiles of the duration of the called spans
+	P50       float64 `json:"p50" ch:"p50"`
+	P95       float64 `json:"p95" ch:"p95"`
+	ErrorRate float64 `json:"errorRate" ch:"errorRate"`
+	// FanOut is the average number of calls by a span of the parent
+	// operation, MaxFanOut the most calls by a single span
+	FanOut    float64 `json:"fanOut" ch:"fanOut"`
+	MaxFanOut uint64  `json:"maxFanOut" ch:"maxFanOut"`
+}
+
+// LogPattern is a template of log bodies, the parts varying between the
+// bodies are replaced by a wildcard
+type LogPattern struct {
+	Pattern   string    `json:"pattern"`
+	Count     uint64    `json:"count"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	SampleIDs []string  `json:"sampleIds"`
+	// Filters selects the logs of the pattern, the filters of the query
+	// and a filter on the body
+	Filters *FilterSet `json:"filters"`
+}
+
+type CacheStatus string
+
+const (
+	CacheStatusHit     CacheStatus = "hit"
+	CacheStatusPartial CacheStatus = "partial"
+	CacheStatusMiss    CacheStatus = "miss"
+)
+
+// TimeRange is a time range in milliseconds, both ends included
+type TimeRange struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// QueryStats are the execution statistics of a query of a query range
+// request, they are collected when the request asks for them
+type QueryStats struct {
+	mu sync.Mutex
+
+	// Queries are the queries run, a cached query runs one query per miss
+	Queries   []string `json:"queries"`
+	RowsRead  uint64   `json:"rowsRead"`
+	BytesRead uint64   `json:"bytesRead"`
+	// ElapsedMs is the time spent running the queries and reading their results
+	ElapsedMs int64 `json:"elapsedMs"`
+	// CacheStatus is empty for the queries that are not cached
+	CacheStatus  CacheStatus `json:"cacheStatus,omitempty"`
+	CachedRanges []TimeRange `json:"cachedRanges,omitempty"`
+	MissedRanges []TimeRange `json:"missedRanges,omitempty"`
 }
 
-type LogsLiveTailClient struct {
-	Name  string
-	Logs  chan *model.SignozLog
-	Done  chan *bool
-	Error chan error
+// AddQuery records a query run and the time it took
+func (s *QueryStats) AddQuery(query string, elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Queries = append(s.Queries, query)
+	s.ElapsedMs += elapsed.Milliseconds()
+}
+
+// AddRead records the progress of a running query
+func (s *QueryStats) AddRead(rows, bytes uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.RowsRead += rows
+	s.BytesRead += bytes
+}
+
+// SetCacheRanges records the ranges read from the cache and the ones that
+// were missing from it
+func (s *QueryStats) SetCacheRanges(cached, missed []TimeRange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.CachedRanges = cached
+	s.MissedRanges = missed
+	switch {
+	case len(missed) == 0:
+		s.CacheStatus = CacheStatusHit
+	case len(cached) == 0:
+		s.CacheStatus = CacheStatusMiss
+	default:
+		s.CacheStatus = CacheStatusPartial
+	}
 }
 
 type Series struct {
@@ -650,6 +957,79 @@ type Row struct {
 	Data      map[string]interface{} `json:"data"`
 }
 
+// ListOrder returns the direction of the list when it is ordered by timestamp
+// only, such a list is ordered by timestamp and id so that it can be continued
+// from the position of its last row. It returns false when the list is ordered
+// by other columns.
+func (b *BuilderQuery) ListOrder() (string, bool) {
+	if len(b.OrderBy) == 0 {
+		return "desc", true
+	}
+	if len(b.OrderBy) == 1 && b.OrderBy[0].ColumnName == "timestamp" {
+		if strings.ToLower(b.OrderBy[0].Order) == "asc" {
+			return "asc", true
+		}
+		return "desc", true
+	}
+	return "", false
+}
+
+// ListPosition is the position of a row in a list of logs or spans ordered by
+// timestamp and id (the span id for spans)
+type ListPosition struct {
+	// Timestamp is in nanoseconds
+	Timestamp uint64 `json:"timestamp"`
+	ID        string `json:"id"`
+	// Order is the direction of the list, asc or desc
+	Order string `json:"order"`
+}
+
+// Operator returns the comparison operator selecting the rows after the position
+func (p *ListPosition) Operator() string {
+	if strings.ToLower(p.Order) == "asc" {
+		return ">"
+	}
+	return "<"
+}
+
+// Cursor returns the opaque cursor of the position
+func (p *ListPosition) Cursor() string {
+	b, _ := json.Marshal(p)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// ParseListCursor returns the position of the cursor
+func ParseListCursor(cursor string) (*ListPosition, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("cursor is invalid: %w", err)
+	}
+	var position ListPosition
+	if err := json.Unmarshal(b, &position); err != nil {
+		return nil, fmt.Errorf("cursor is invalid: %w", err)
+	}
+	if position.Order != "asc" && position.Order != "desc" {
+		return nil, fmt.Errorf("cursor is invalid: order must be asc or desc")
+	}
+	return &position, nil
+}
+
+type ExportFormat string
+
+const (
+	ExportFormatNDJSON ExportFormat = "ndjson"
+	ExportFormatCSV    ExportFormat = "csv"
+)
+
+func (f ExportFormat) Validate() error {
+	switch f {
+	case ExportFormatNDJSON, ExportFormatCSV:
+		return nil
+	default:
+		return fmt.Errorf("invalid export format: %s", f)
+	}
+}
+
 type Point struct {
 	Timestamp int64
 	Value     float64
@@ -707,7 +1087,103 @@ func (eq *SavedView) Validate() error {
 	return eq.CompositeQuery.Validate()
 }
 
+var metricNameRegexp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// DerivedMetric is a metric materialized from a logs aggregation, the points
+// of the query are written periodically as the samples of the metric with the
+// group by values as labels
+type DerivedMetric struct {
+	ID          string        `json:"id,omitempty"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Query       *BuilderQuery `json:"query"`
+	// MaterializedUntil is the end of the last materialized interval, in
+	// milliseconds
+	MaterializedUntil int64     `json:"materializedUntil"`
+	CreatedAt         time.Time `json:"createdAt"`
+	CreatedBy         string    `json:"createdBy"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+	UpdatedBy         string    `json:"updatedBy"`
+}
+
+// Validate checks the derived metric, metricExists reports whether a metric of
+// the name is already written so that the samples are not mixed with its samples
+func (m *DerivedMetric) Validate(metricExists func(name string) (bool, error)) error {
+	if !metricNameRegexp.MatchString(m.Name) {
+		return fmt.Errorf("metric name %q is invalid, it must match %s", m.Name, metricNameRegexp.String())
+	}
+	if metricExists != nil {
+		exists, err := metricExists(m.Name)
+		if err != nil {
+			return fmt.Errorf("error while checking the metric name: %v", err)
+		}
+		if exists {
+			return fmt.Errorf("metric name %q is already used by a metric", m.Name)
+		}
+	}
+	if m.Query == nil {
+		return fmt.Errorf("query is required")
+	}
+	if m.Query.DataSource != DataSourceLogs {
+		return fmt.Errorf("only logs queries can be materialized")
+	}
+	if m.Query.QueryName != m.Query.Expression {
+		return fmt.Errorf("formulas cannot be materialized")
+	}
+	if m.Query.AggregateOperator == AggregateOperatorNoOp {
+		return fmt.Errorf("the query must aggregate the logs")
+	}
+	if m.Query.StepInterval <= 0 {
+		return fmt.Errorf("step interval must be greater than zero")
+	}
+	// the series must not depend on the rest of the time range
+	if m.Query.Limit > 0 || m.Query.TimeShift > 0 || len(m.Query.Functions) > 0 {
+		return fmt.Errorf("limit, time shift and functions are not supported on materialized queries")
+	}
+	return m.Query.Validate()
+}
+
 type LatencyMetricMetadataResponse struct {
 	Delta bool      `json:"delta"`
 	Le    []float64 `json:"le"`
 }
+
+// QueryCacheEntry describes the cached series of a query, the series are stored
+// in buckets of aligned time windows identified by their start time
+type QueryCacheEntry struct {
+	QueryHash    string    `json:"queryHash"`
+	QueryNames   []string  `json:"queryNames"`
+	DashboardIDs []string  `json:"dashboardIds"`
+	Buckets      []int64   `json:"buckets"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// QueryCacheFilter selects the cache entries to list or evict, empty fields match all entries
+type QueryCacheFilter struct {
+	QueryHash   string `json:"queryHash"`
+	QueryName   string `json:"queryName"`
+	DashboardID string `json:"dashboardId"`
+}
+
+func (f QueryCacheFilter) IsEmpty() bool {
+	return f.QueryHash == "" && f.QueryName == "" && f.DashboardID == ""
+}
+
+// QueryEstimate is the estimate of the data clickhouse reads to run a query,
+// the promql queries are not estimated
+type QueryEstimate struct {
+	QueryName string `json:"queryName"`
+	Query     string `json:"query"`
+	Estimated bool   `json:"estimated"`
+	Parts     uint64 `json:"parts"`
+	Marks     uint64 `json:"marks"`
+	Rows      uint64 `json:"rows"`
+	// Bytes is the estimated rows times the average (uncompressed) size of
+	// the rows of the tables read
+	Bytes uint64 `json:"bytes"`
+}
+
+// QueryRangeDryRunResponse is the response to a dry run query range request
+type QueryRangeDryRunResponse struct {
+	Queries []*QueryEstimate `json:"queries"`
+}
@@ -115,3 +115,24 @@ func (p *PqlEngine) RunAlertQuery(ctx context.Context, qs string, t time.Time) (
 		return nil, fmt.Errorf("rule result is not a vector or scalar")
 	}
 }
+
+func (p *PqlEngine) RunRangeQuery(ctx context.Context, qs string, start, end time.Time, step time.Duration) (pql.Matrix, error) {
+	q, err := p.engine.NewRangeQuery(ctx, p.fanoutStorage, nil, qs, start, end, step)
+	if err != nil {
+		return nil, err
+	}
+	defer q.Close()
+
+	res := q.Exec(ctx)
+
+	if res.Err != nil {
+		return nil, res.Err
+	}
+
+	switch v := res.Value.(type) {
+	case pql.Matrix:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("rule result is not a matrix")
+	}
+}
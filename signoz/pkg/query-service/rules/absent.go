@@ -0,0 +1,149 @@
+package rules
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+const (
+	defaultAbsentEvaluations = 1
+	defaultAbsentExpiry      = 24 * time.Hour
+)
+
+func setAbsentDefaults(a *AbsentCondition) {
+	if a.Evaluations == 0 {
+		a.Evaluations = defaultAbsentEvaluations
+	}
+	if a.Expiry == 0 {
+		a.Expiry = Duration(defaultAbsentExpiry)
+	}
+}
+
+func validateAbsentCondition(r *PostableRule) (errs []error) {
+	a := r.RuleCondition.Absent
+	if r.RuleType != RuleTypeThreshold {
+		errs = append(errs, errors.Errorf("no data alerts are supported only by threshold rules"))
+	}
+	if !a.AlertOnNoData && !a.AlertOnAbsentSeries {
+		errs = append(errs, errors.Errorf("absent condition must alert on no data or on absent series"))
+	}
+	if a.Evaluations < 0 {
+		errs = append(errs, errors.Errorf("absent condition evaluations cannot be negative"))
+	}
+	if a.Expiry < 0 {
+		errs = append(errs, errors.Errorf("absent condition expiry cannot be negative"))
+	}
+	return errs
+}
+
+// absentSeries is a series returned by the query of a rule and the number of
+// consecutive evaluations it has been missing from the result since
+type absentSeries struct {
+	labels   labels.Labels
+	lastSeen time.Time
+	missing  int
+}
+
+// absentTracker keeps count of the evaluations the query of a rule returned
+// no data and of the series that went missing from its result
+type absentTracker struct {
+	noData int
+	series map[uint64]*absentSeries
+}
+
+func newAbsentTracker() *absentTracker {
+	return &absentTracker{series: make(map[uint64]*absentSeries)}
+}
+
+// observe takes in the series returned by the query at ts. It tells if the
+// no data alert fires and returns the labels of the absent series to alert
+// on. The absent series are not alerted on while the no data alert fires.
+func (t *absentTracker) observe(cond *AbsentCondition, observed map[uint64]labels.Labels, ts time.Time) (bool, []labels.Labels) {
+	if len(observed) == 0 {
+		t.noData++
+	} else {
+		t.noData = 0
+	}
+	noData := cond.AlertOnNoData && t.noData >= cond.Evaluations
+
+	if !cond.AlertOnAbsentSeries {
+		return noData, nil
+	}
+
+	for fp, lbls := range observed {
+		t.series[fp] = &absentSeries{labels: lbls, lastSeen: ts}
+	}
+
+	var absent []labels.Labels
+	for fp, s := range t.series {
+		if _, ok := observed[fp]; ok {
+			continue
+		}
+		if ts.Sub(s.lastSeen) > time.Duration(cond.Expiry) {
+			delete(t.series, fp)
+			continue
+		}
+		s.missing++
+		if s.missing >= cond.Evaluations && !noData {
+			absent = append(absent, s.labels)
+		}
+	}
+	sort.Slice(absent, func(i, j int) bool {
+		return absent[i].String() < absent[j].String()
+	})
+	return noData, absent
+}
+
+// absentAlerts returns the no data and the absent alerts of the rule at ts
+// from the series of the last query. It is called with the rule lock held.
+func (r *ThresholdRule) absentAlerts(ctx context.Context, ts time.Time) []*Alert {
+	noData, absent := r.absentTracker.observe(r.ruleCondition.Absent, r.observedSeries, ts)
+
+	var alerts []*Alert
+	if noData {
+		alerts = append(alerts, r.newAbsentAlert(ctx, ts, labels.Labels{}, labels.AlertNoDataLabel, "The query of the rule returned no data."))
+	}
+	for _, lbls := range absent {
+		alerts = append(alerts, r.newAbsentAlert(ctx, ts, lbls, labels.AlertAbsentLabel, "The series is no longer returned by the query of the rule."))
+	}
+	return alerts
+}
+
+// newAbsentAlert creates an alert for the missing series (or the whole query
+// when no series is given) marked by the given label
+func (r *ThresholdRule) newAbsentAlert(ctx context.Context, ts time.Time, metric labels.Labels, marker, summary string) *Alert {
+	tmplData := AlertTemplateData(metric.Map(), "", "")
+
+	lb := labels.NewBuilder(metric).Del(labels.MetricNameLabel)
+	for _, l := range r.labels {
+		lb.Set(l.Name, r.expandTemplate(ctx, ts, tmplData, l.Value))
+	}
+	lb.Set(labels.AlertNameLabel, r.Name())
+	lb.Set(labels.AlertRuleIdLabel, r.ID())
+	lb.Set(labels.RuleSourceLabel, r.GeneratorURL())
+	lb.Set(marker, "true")
+
+	annotations := make(labels.Labels, 0, len(r.annotations))
+	for _, a := range r.annotations {
+		annotations = append(annotations, labels.Label{Name: a.Name, Value: r.expandTemplate(ctx, ts, tmplData, a.Value)})
+	}
+	annotations = labels.NewBuilder(annotations).Set(labels.AlertSummaryLabel, summary).Labels()
+
+	receivers := r.preferredChannels
+	if len(r.ruleCondition.Absent.PreferredChannels) > 0 {
+		receivers = r.ruleCondition.Absent.PreferredChannels
+	}
+
+	return &Alert{
+		Labels:       lb.Labels(),
+		Annotations:  annotations,
+		ActiveAt:     ts,
+		State:        StatePending,
+		GeneratorURL: r.GeneratorURL(),
+		Receivers:    receivers,
+	}
+}
@@ -0,0 +1,147 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.signoz.io/signoz/pkg/query-service/featureManager"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+func observedSeries(services ...string) map[uint64]labels.Labels {
+	observed := make(map[uint64]labels.Labels, len(services))
+	for _, s := range services {
+		lbls := labels.FromStrings("service_name", s)
+		observed[lbls.Hash()] = lbls
+	}
+	return observed
+}
+
+func TestAbsentTracker(t *testing.T) {
+	cond := &AbsentCondition{AlertOnNoData: true, AlertOnAbsentSeries: true, Evaluations: 2}
+	setAbsentDefaults(cond)
+
+	cases := []struct {
+		observed     map[uint64]labels.Labels
+		expectNoData bool
+		expectAbsent []string
+	}{
+		{observed: observedSeries("frontend", "cart")},
+		// cart is missing for a single evaluation
+		{observed: observedSeries("frontend")},
+		{observed: observedSeries("frontend"), expectAbsent: []string{"cart"}},
+		{observed: observedSeries("frontend", "cart")},
+		// the whole query is empty, only the no data alert fires
+		{observed: observedSeries()},
+		{observed: observedSeries(), expectNoData: true},
+		// cart does not come back after the outage
+		{observed: observedSeries("frontend"), expectAbsent: []string{"cart"}},
+	}
+
+	tracker := newAbsentTracker()
+	ts := time.Now()
+	for idx, c := range cases {
+		noData, absent := tracker.observe(cond, c.observed, ts.Add(time.Duration(idx)*time.Minute))
+		assert.Equal(t, c.expectNoData, noData, "case %d", idx)
+
+		services := []string{}
+		for _, lbls := range absent {
+			services = append(services, lbls.Get("service_name"))
+		}
+		if c.expectAbsent == nil {
+			c.expectAbsent = []string{}
+		}
+		assert.Equal(t, c.expectAbsent, services, "case %d", idx)
+	}
+
+	// absent series are forgotten after the expiry
+	_, absent := tracker.observe(cond, observedSeries("frontend"), ts.Add(defaultAbsentExpiry+time.Hour))
+	assert.Empty(t, absent)
+	assert.Len(t, tracker.series, 1)
+}
+
+func TestThresholdRuleAbsentAlerts(t *testing.T) {
+	target := 100.0
+	postableRule := PostableRule{
+		Alert:      "Absent Tests",
+		AlertType:  "METRICS_BASED_ALERT",
+		RuleType:   RuleTypeThreshold,
+		EvalWindow: Duration(5 * time.Minute),
+		Frequency:  Duration(1 * time.Minute),
+		RuleCondition: &RuleCondition{
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType: v3.QueryTypeBuilder,
+				BuilderQueries: map[string]*v3.BuilderQuery{
+					"A": {
+						QueryName:    "A",
+						StepInterval: 60,
+						AggregateAttribute: v3.AttributeKey{
+							Key: "signoz_calls_total",
+						},
+						AggregateOperator: v3.AggregateOperatorSumRate,
+						DataSource:        v3.DataSourceMetrics,
+						Expression:        "A",
+					},
+				},
+			},
+			CompareOp: ValueIsAbove,
+			MatchType: AtleastOnce,
+			Target:    &target,
+			Absent: &AbsentCondition{
+				AlertOnNoData:       true,
+				AlertOnAbsentSeries: true,
+				PreferredChannels:   []string{"pagerduty"},
+			},
+		},
+		Labels:            map[string]string{"team": "{{$labels.service_name}}"},
+		PreferredChannels: []string{"slack"},
+	}
+	assert.Empty(t, postableRule.Validate())
+	setAbsentDefaults(postableRule.RuleCondition.Absent)
+
+	rule, err := NewThresholdRule("69", &postableRule, ThresholdRuleOpts{}, featureManager.StartManager())
+	assert.NoError(t, err)
+
+	formatValue := func(v float64) string { return fmt.Sprintf("%f", v) }
+	ts := time.Now()
+
+	// the series is below the target, no alert
+	rule.observedSeries = observedSeries("frontend")
+	_, err = rule.updateAlerts(context.Background(), ts, Vector{}, formatValue, "")
+	assert.NoError(t, err)
+	assert.Empty(t, rule.ActiveAlerts())
+
+	// the series vanishes
+	rule.observedSeries = observedSeries()
+	_, err = rule.updateAlerts(context.Background(), ts.Add(time.Minute), Vector{}, formatValue, "")
+	assert.NoError(t, err)
+	alerts := rule.ActiveAlerts()
+	assert.Len(t, alerts, 1)
+	assert.Equal(t, "true", alerts[0].Labels.Get(labels.AlertNoDataLabel))
+	assert.Equal(t, []string{"pagerduty"}, alerts[0].Receivers)
+	assert.Equal(t, StateFiring, alerts[0].State)
+
+	// the series is back, the no data alert resolves
+	rule.observedSeries = observedSeries("frontend")
+	_, err = rule.updateAlerts(context.Background(), ts.Add(2*time.Minute), Vector{}, formatValue, "")
+	assert.NoError(t, err)
+	assert.Empty(t, rule.ActiveAlerts())
+
+	// another series shows up and the first one vanishes
+	rule.observedSeries = observedSeries("cart")
+	_, err = rule.updateAlerts(context.Background(), ts.Add(3*time.Minute), Vector{}, formatValue, "")
+	assert.NoError(t, err)
+	alerts = rule.ActiveAlerts()
+	assert.Len(t, alerts, 1)
+	assert.Equal(t, "true", alerts[0].Labels.Get(labels.AlertAbsentLabel))
+	assert.Equal(t, "frontend", alerts[0].Labels.Get("service_name"))
+	assert.Equal(t, "frontend", alerts[0].Labels.Get("team"))
+	assert.Equal(t, "Absent Tests", alerts[0].Labels.Get(labels.AlertNameLabel))
+
+	postableRule.RuleType = RuleTypeProm
+	assert.NotEmpty(t, validateAbsentCondition(&postableRule))
+}
@@ -3,11 +3,13 @@ package rules
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/url"
 	"strings"
 	"time"
 
 	"github.com/pkg/errors"
+	"go.signoz.io/signoz/pkg/query-service/converter"
 	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
 	"go.signoz.io/signoz/pkg/query-service/utils/labels"
 )
@@ -33,6 +35,7 @@ type RuleType string
 const (
 	RuleTypeThreshold = "threshold_rule"
 	RuleTypeProm      = "promql_rule"
+	RuleTypeAnomaly   = "anomaly_rule"
 )
 
 type RuleHealth string
@@ -99,6 +102,19 @@ func (a *Alert) needsSending(ts time.Time, resendDelay time.Duration) bool {
 	return a.LastSentAt.Add(resendDelay).Before(ts)
 }
 
+// update refreshes the active alert with the result of the latest evaluation.
+// The labels only change when the alert moves to another severity, in which
+// case the alert keeps its state and is sent again with the new severity.
+func (a *Alert) update(latest *Alert) {
+	a.Value = latest.Value
+	a.Annotations = latest.Annotations
+	a.Receivers = latest.Receivers
+	if a.Labels.Hash() != latest.Labels.Hash() {
+		a.Labels = latest.Labels
+		a.LastSentAt = time.Time{}
+	}
+}
+
 type NamedAlert struct {
 	Name string
 	*Alert
@@ -138,13 +154,65 @@ const (
 	InTotal       MatchType = "4"
 )
 
+type AnomalyAlgorithm string
+
+const (
+	// AnomalyAlgorithmRolling learns the baseline from the window
+	// right before the evaluation window
+	AnomalyAlgorithmRolling AnomalyAlgorithm = "rolling"
+	// AnomalyAlgorithmSeasonal learns the baseline from the same
+	// window one season (e.g. a week) ago
+	AnomalyAlgorithmSeasonal AnomalyAlgorithm = "seasonal"
+)
+
+// AnomalyCondition configures the baseline of an anomaly rule and
+// how far the series may deviate from it before the rule fires
+type AnomalyCondition struct {
+	Algorithm AnomalyAlgorithm `yaml:"algorithm,omitempty" json:"algorithm,omitempty"`
+	// BaselineWindow is the length of the window the mean and the
+	// standard deviation are learnt from
+	BaselineWindow Duration `yaml:"baselineWindow,omitempty" json:"baselineWindow,omitempty"`
+	// Seasonality is the period of the series, used by the seasonal algorithm
+	Seasonality Duration `yaml:"seasonality,omitempty" json:"seasonality,omitempty"`
+	// Deviation is the z-score beyond which the series is considered anomalous
+	Deviation float64 `yaml:"deviation,omitempty" json:"deviation,omitempty"`
+}
+
+// RuleThreshold is one severity level of a rule, e.g. warning or critical.
+// All the levels of a rule share the compare op, match type and target unit.
+type RuleThreshold struct {
+	Severity          string   `yaml:"severity" json:"severity"`
+	Target            *float64 `yaml:"target" json:"target"`
+	PreferredChannels []string `yaml:"preferredChannels,omitempty" json:"preferredChannels,omitempty"`
+}
+
+// AbsentCondition configures the alerts of a threshold rule for the data
+// that goes missing. The alerts carry the nodata or the absent label so that
+// they can be routed apart from the alerts of the rule condition.
+type AbsentCondition struct {
+	// AlertOnNoData fires a no data alert when the query returns no series
+	AlertOnNoData bool `yaml:"alertOnNoData,omitempty" json:"alertOnNoData,omitempty"`
+	// AlertOnAbsentSeries fires an absent alert for every series seen
+	// before that the query does not return anymore
+	AlertOnAbsentSeries bool `yaml:"alertOnAbsentSeries,omitempty" json:"alertOnAbsentSeries,omitempty"`
+	// Evaluations is the number of consecutive evaluations without the
+	// data before the alerts fire
+	Evaluations int `yaml:"evaluations,omitempty" json:"evaluations,omitempty"`
+	// Expiry is how long an absent series is alerted on before it is forgotten
+	Expiry            Duration `yaml:"expiry,omitempty" json:"expiry,omitempty"`
+	PreferredChannels []string `yaml:"preferredChannels,omitempty" json:"preferredChannels,omitempty"`
+}
+
 type RuleCondition struct {
 	CompositeQuery *v3.CompositeQuery `json:"compositeQuery,omitempty" yaml:"compositeQuery,omitempty"`
 	CompareOp      CompareOp          `yaml:"op,omitempty" json:"op,omitempty"`
 	Target         *float64           `yaml:"target,omitempty" json:"target,omitempty"`
 	MatchType      `json:"matchType,omitempty"`
-	TargetUnit     string `json:"targetUnit,omitempty"`
-	SelectedQuery  string `json:"selectedQueryName,omitempty"`
+	TargetUnit     string            `json:"targetUnit,omitempty"`
+	SelectedQuery  string            `json:"selectedQueryName,omitempty"`
+	Anomaly        *AnomalyCondition `yaml:"anomaly,omitempty" json:"anomaly,omitempty"`
+	Thresholds     []*RuleThreshold  `yaml:"thresholds,omitempty" json:"thresholds,omitempty"`
+	Absent         *AbsentCondition  `yaml:"absent,omitempty" json:"absent,omitempty"`
 }
 
 func (rc *RuleCondition) IsValid() bool {
@@ -153,8 +221,9 @@ func (rc *RuleCondition) IsValid() bool {
 		return false
 	}
 
-	if rc.QueryType() == v3.QueryTypeBuilder {
-		if rc.Target == nil {
+	// anomaly rules compare against a learnt baseline instead of a target
+	if rc.QueryType() == v3.QueryTypeBuilder && rc.Anomaly == nil {
+		if rc.Target == nil && len(rc.Thresholds) == 0 {
 			return false
 		}
 		if rc.CompareOp == "" {
@@ -170,6 +239,48 @@ func (rc *RuleCondition) IsValid() bool {
 	return true
 }
 
+// HasThresholds tells if the rule has multiple severity levels
+// instead of a single target
+func (rc *RuleCondition) HasThresholds() bool {
+	return rc != nil && len(rc.Thresholds) > 0
+}
+
+// convertTarget converts the target from the target unit to the unit of the query
+func (rc *RuleCondition) convertTarget(target float64, unit string) float64 {
+	unitConverter := converter.FromUnit(converter.Unit(rc.TargetUnit))
+	value := unitConverter.Convert(converter.Value{F: target, U: converter.Unit(rc.TargetUnit)}, converter.Unit(unit))
+	return value.F
+}
+
+// matchThreshold returns the most severe threshold crossed by the value,
+// i.e. the highest target for ValueIsAbove and the lowest for ValueIsBelow.
+// unit is the unit of the value, it returns nil when no threshold is crossed.
+func (rc *RuleCondition) matchThreshold(v float64, unit string) *RuleThreshold {
+	if math.IsNaN(v) {
+		return nil
+	}
+
+	var matched *RuleThreshold
+	var matchedTarget float64
+	for _, t := range rc.Thresholds {
+		if t.Target == nil {
+			continue
+		}
+		target := rc.convertTarget(*t.Target, unit)
+		switch rc.CompareOp {
+		case ValueIsAbove:
+			if v > target && (matched == nil || target > matchedTarget) {
+				matched, matchedTarget = t, target
+			}
+		case ValueIsBelow:
+			if v < target && (matched == nil || target < matchedTarget) {
+				matched, matchedTarget = t, target
+			}
+		}
+	}
+	return matched
+}
+
 // QueryType is a short hand method to get query type
 func (rc *RuleCondition) QueryType() v3.QueryType {
 	if rc.CompositeQuery != nil {
@@ -0,0 +1,377 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"go.uber.org/zap"
+
+	"go.signoz.io/signoz/pkg/query-service/interfaces"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	pqle "go.signoz.io/signoz/pkg/query-service/pqlEngine"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+const (
+	defaultAnomalyBaselineWindow = 1 * time.Hour
+	defaultAnomalySeasonality    = 7 * 24 * time.Hour
+
+	// minBaselinePoints is the least number of points needed
+	// to learn the spread of a series
+	minBaselinePoints = 2
+)
+
+// AnomalyRule evaluates the series of the selected query against a baseline
+// learnt from the history of the same series and fires when the z-score
+// goes beyond the configured deviation. The alerts and their state are
+// managed by the embedded threshold rule.
+type AnomalyRule struct {
+	*ThresholdRule
+}
+
+// anomalySeries holds the values of a single series in a time range
+type anomalySeries struct {
+	labels labels.Labels
+	values []float64
+}
+
+func NewAnomalyRule(
+	id string,
+	p *PostableRule,
+	opts ThresholdRuleOpts,
+	featureFlags interfaces.FeatureLookup,
+) (*AnomalyRule, error) {
+
+	if p.RuleCondition == nil {
+		return nil, fmt.Errorf("no rule condition")
+	}
+
+	if errs := validateAnomalyCondition(p.RuleCondition); len(errs) > 0 {
+		return nil, errs[0]
+	}
+	setAnomalyDefaults(p.RuleCondition.Anomaly)
+
+	t, err := NewThresholdRule(id, p, opts, featureFlags)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AnomalyRule{ThresholdRule: t}, nil
+}
+
+func setAnomalyDefaults(a *AnomalyCondition) {
+	if a.Algorithm == "" {
+		a.Algorithm = AnomalyAlgorithmRolling
+	}
+	if a.BaselineWindow == 0 {
+		a.BaselineWindow = Duration(defaultAnomalyBaselineWindow)
+	}
+	if a.Seasonality == 0 {
+		a.Seasonality = Duration(defaultAnomalySeasonality)
+	}
+}
+
+func validateAnomalyCondition(rc *RuleCondition) (errs []error) {
+	queryType := rc.QueryType()
+	if queryType != v3.QueryTypeBuilder && queryType != v3.QueryTypePromQL {
+		errs = append(errs, fmt.Errorf("anomaly rules support only query builder and promql queries"))
+	}
+
+	if rc.Anomaly == nil {
+		errs = append(errs, fmt.Errorf("rule condition missing the anomaly settings"))
+		return errs
+	}
+
+	if rc.HasThresholds() {
+		errs = append(errs, fmt.Errorf("anomaly rules do not support multiple thresholds"))
+	}
+
+	if rc.Anomaly.Deviation <= 0 {
+		errs = append(errs, fmt.Errorf("rule condition missing a positive deviation"))
+	}
+
+	switch rc.Anomaly.Algorithm {
+	case "", AnomalyAlgorithmRolling, AnomalyAlgorithmSeasonal:
+	default:
+		errs = append(errs, fmt.Errorf("unsupported anomaly algorithm: %s", rc.Anomaly.Algorithm))
+	}
+
+	if rc.Anomaly.BaselineWindow < 0 || rc.Anomaly.Seasonality < 0 {
+		errs = append(errs, fmt.Errorf("anomaly baseline window and seasonality can not be negative"))
+	}
+	return errs
+}
+
+func (r *AnomalyRule) Type() RuleType {
+	return RuleTypeAnomaly
+}
+
+func (r *AnomalyRule) GetSelectedQuery() string {
+	if r.ruleCondition.QueryType() == v3.QueryTypePromQL {
+		if r.ruleCondition.SelectedQuery != "" {
+			return r.ruleCondition.SelectedQuery
+		}
+		return "A"
+	}
+	return r.ThresholdRule.GetSelectedQuery()
+}
+
+// baselineRange returns the time range (in ms) the baseline is learnt from for
+// the given evaluation range. The rolling baseline is the window right before
+// the evaluation range, the seasonal baseline is the evaluation range one season
+// ago widened by the baseline window so there are enough points to learn from.
+func (r *AnomalyRule) baselineRange(start, end int64) (int64, int64) {
+	anomaly := r.ruleCondition.Anomaly
+	window := time.Duration(anomaly.BaselineWindow).Milliseconds()
+
+	if anomaly.Algorithm == AnomalyAlgorithmSeasonal {
+		season := time.Duration(anomaly.Seasonality).Milliseconds()
+		return start - season - window/2, end - season + window/2
+	}
+	return start - window, start
+}
+
+// fetchSeries runs the selected query for the given time range (in ms)
+// and returns the values of every series by label hash
+func (r *AnomalyRule) fetchSeries(ctx context.Context, ts time.Time, queriers *Queriers, start, end int64) (map[uint64]*anomalySeries, error) {
+	switch r.ruleCondition.QueryType() {
+	case v3.QueryTypeBuilder:
+		return r.fetchBuilderSeries(ctx, queriers.Ch, ts, start, end)
+	case v3.QueryTypePromQL:
+		return r.fetchPromSeries(ctx, queriers.PqlEngine, start, end)
+	}
+	return nil, fmt.Errorf("unexpected rule condition - query type is not supported by anomaly rules")
+}
+
+func (r *AnomalyRule) fetchBuilderSeries(ctx context.Context, db clickhouse.Conn, ts time.Time, start, end int64) (map[uint64]*anomalySeries, error) {
+	params := r.prepareQueryRange(ts)
+	params.Start, params.End = start, end
+
+	queries, err := r.queryBuilder.PrepareQueries(params)
+	if err != nil {
+		zap.S().Errorf("ruleid:", r.ID(), "\t msg: failed to prepare metric queries", zap.Error(err))
+		return nil, fmt.Errorf("failed to prepare metric queries")
+	}
+
+	queryLabel := r.GetSelectedQuery()
+	query, ok := queries[queryLabel]
+	if !ok {
+		zap.S().Errorf("ruleId: ", r.ID(), "\t invalid query label:", queryLabel, "\t queries:", queries)
+		return nil, fmt.Errorf("this is unexpected, invalid query label")
+	}
+
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		zap.S().Errorf("rule:", r.Name(), "\t failed to get anomaly query result")
+		return nil, err
+	}
+	defer rows.Close()
+
+	columnTypes := rows.ColumnTypes()
+	columnNames := rows.Columns()
+	vars := make([]interface{}, len(columnTypes))
+	for i := range columnTypes {
+		vars[i] = reflect.New(columnTypes[i].ScanType()).Interface()
+	}
+
+	skipFirstRecord := r.shouldSkipFirstRecord()
+	series := make(map[uint64]*anomalySeries)
+
+	for rows.Next() {
+		if err := rows.Scan(vars...); err != nil {
+			return nil, err
+		}
+
+		sample, lbls := r.readSample(vars, columnNames)
+		if math.IsNaN(sample.Point.V) {
+			continue
+		}
+
+		metric := lbls.Labels()
+		labelHash := metric.Hash()
+		s, ok := series[labelHash]
+		if !ok {
+			s = &anomalySeries{labels: metric}
+			series[labelHash] = s
+			// same as the threshold rule, the first record
+			// of rate queries is skipped
+			if skipFirstRecord {
+				continue
+			}
+		}
+		s.values = append(s.values, sample.Point.V)
+	}
+	return series, rows.Err()
+}
+
+func (r *AnomalyRule) fetchPromSeries(ctx context.Context, engine *pqle.PqlEngine, start, end int64) (map[uint64]*anomalySeries, error) {
+	promQuery, ok := r.ruleCondition.CompositeQuery.PromQueries[r.GetSelectedQuery()]
+	if !ok || promQuery.Query == "" {
+		return nil, fmt.Errorf("a promquery needs to be set for this rule to function")
+	}
+
+	matrix, err := engine.RunRangeQuery(ctx, promQuery.Query, time.UnixMilli(start), time.UnixMilli(end), time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	series := make(map[uint64]*anomalySeries, len(matrix))
+	for _, s := range matrix {
+		metric := labels.FromMap(s.Metric.Map())
+		values := make([]float64, 0, len(s.Floats))
+		for _, p := range s.Floats {
+			if !math.IsNaN(p.F) {
+				values = append(values, p.F)
+			}
+		}
+		series[metric.Hash()] = &anomalySeries{labels: metric, values: values}
+	}
+	return series, nil
+}
+
+// detectAnomalies returns a sample for every series that deviates from its
+// baseline, the value of the sample is the z-score of the series
+func (r *AnomalyRule) detectAnomalies(ctx context.Context, ts time.Time, queriers *Queriers) (Vector, error) {
+	if r.ruleCondition == nil || r.ruleCondition.CompositeQuery == nil || r.ruleCondition.Anomaly == nil {
+		r.SetHealth(HealthBad)
+		return nil, fmt.Errorf("invalid rule condition")
+	}
+
+	params := r.prepareQueryRange(ts)
+	baselineStart, baselineEnd := r.baselineRange(params.Start, params.End)
+
+	current, err := r.fetchSeries(ctx, ts, queriers, params.Start, params.End)
+	if err != nil {
+		return nil, err
+	}
+	baseline, err := r.fetchSeries(ctx, ts, queriers, baselineStart, baselineEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	deviation := r.ruleCondition.Anomaly.Deviation
+
+	var result Vector
+	for labelHash, s := range current {
+		// series without history can not be compared with a baseline
+		b, ok := baseline[labelHash]
+		if !ok {
+			continue
+		}
+		score, ok := anomalyScore(b.values, s.values, r.matchType(), r.compareOp())
+		if !ok {
+			zap.S().Debugf("ruleid:", r.ID(), "\t msg: not enough baseline data for series", s.labels)
+			continue
+		}
+		if r.opts.SendUnmatched || isAnomalous(score, deviation, r.compareOp()) {
+			result = append(result, Sample{Point: Point{T: ts.UnixMilli(), V: score}, Metric: s.labels})
+		}
+	}
+
+	if len(result) != 0 {
+		zap.S().Infof("For rule %s, found %d anomalous series", r.ID(), len(result))
+	}
+	return result, nil
+}
+
+func (r *AnomalyRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (interface{}, error) {
+
+	res, err := r.detectAnomalies(ctx, ts, queriers)
+
+	if err != nil {
+		r.SetHealth(HealthBad)
+		r.SetLastError(err)
+		zap.S().Debugf("ruleid:", r.ID(), "\t failure in detectAnomalies:", err)
+		return nil, err
+	}
+
+	// the value and the threshold of anomaly alerts are z-scores
+	formatScore := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	}
+	return r.updateAlerts(ctx, ts, res, formatScore, formatScore(r.ruleCondition.Anomaly.Deviation))
+}
+
+// baselineStats returns the mean and the standard deviation of the values
+func baselineStats(values []float64) (float64, float64) {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)))
+}
+
+// anomalyScore reduces the z-scores of the values against the baseline to a
+// single score according to the match type. The compare op tells whether
+// deviations above, below or in either direction are of interest. It returns
+// false when the baseline has too few points or no spread to learn from.
+func anomalyScore(baseline, values []float64, matchType MatchType, op CompareOp) (float64, bool) {
+	if len(baseline) < minBaselinePoints || len(values) == 0 {
+		return 0, false
+	}
+
+	mean, stddev := baselineStats(baseline)
+	if stddev == 0 || math.IsNaN(stddev) {
+		return 0, false
+	}
+
+	// magnitude orders the z-scores by how far they are
+	// from the baseline in the direction of interest
+	magnitude := func(z float64) float64 {
+		switch op {
+		case ValueIsAbove:
+			return z
+		case ValueIsBelow:
+			return -z
+		default:
+			return math.Abs(z)
+		}
+	}
+
+	switch matchType {
+	case OnAverage, InTotal:
+		// a sum can not be compared with a per point baseline,
+		// so in total is treated same as on average
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		return (sum/float64(len(values)) - mean) / stddev, true
+	}
+
+	score := (values[0] - mean) / stddev
+	for _, v := range values[1:] {
+		z := (v - mean) / stddev
+		if matchType == AllTheTimes {
+			if magnitude(z) < magnitude(score) {
+				score = z
+			}
+		} else if magnitude(z) > magnitude(score) {
+			score = z
+		}
+	}
+	return score, true
+}
+
+// isAnomalous checks the z-score against the deviation in the direction set by the compare op
+func isAnomalous(score, deviation float64, op CompareOp) bool {
+	switch op {
+	case ValueIsAbove:
+		return score > deviation
+	case ValueIsBelow:
+		return score < -deviation
+	default:
+		return math.Abs(score) > deviation
+	}
+}
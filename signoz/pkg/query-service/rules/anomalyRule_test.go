@@ -0,0 +1,174 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.signoz.io/signoz/pkg/query-service/featureManager"
+)
+
+func TestAnomalyScore(t *testing.T) {
+	// mean 10, standard deviation 2
+	baseline := []float64{8, 12, 8, 12}
+
+	cases := []struct {
+		name       string
+		values     []float64
+		matchType  MatchType
+		compareOp  CompareOp
+		deviation  float64
+		expectOk   bool
+		expectZ    float64
+		expectFire bool
+	}{
+		{
+			name:       "spike above at least once",
+			values:     []float64{10, 18, 11},
+			matchType:  AtleastOnce,
+			compareOp:  ValueIsAbove,
+			deviation:  3,
+			expectOk:   true,
+			expectZ:    4,
+			expectFire: true,
+		},
+		{
+			name:       "spike above not sustained all the times",
+			values:     []float64{10, 18, 11},
+			matchType:  AllTheTimes,
+			compareOp:  ValueIsAbove,
+			deviation:  3,
+			expectOk:   true,
+			expectZ:    0,
+			expectFire: false,
+		},
+		{
+			name:       "drop below at least once",
+			values:     []float64{10, 2, 11},
+			matchType:  AtleastOnce,
+			compareOp:  ValueIsBelow,
+			deviation:  3,
+			expectOk:   true,
+			expectZ:    -4,
+			expectFire: true,
+		},
+		{
+			name:       "spike above does not fire below",
+			values:     []float64{10, 18, 11},
+			matchType:  AtleastOnce,
+			compareOp:  ValueIsBelow,
+			deviation:  3,
+			expectOk:   true,
+			expectZ:    0,
+			expectFire: false,
+		},
+		{
+			name:       "either direction picks the largest deviation",
+			values:     []float64{4, 17},
+			matchType:  AtleastOnce,
+			compareOp:  CompareOpNone,
+			deviation:  3,
+			expectOk:   true,
+			expectZ:    3.5,
+			expectFire: true,
+		},
+		{
+			name:       "on average",
+			values:     []float64{14, 16},
+			matchType:  OnAverage,
+			compareOp:  ValueIsAbove,
+			deviation:  3,
+			expectOk:   true,
+			expectZ:    2.5,
+			expectFire: false,
+		},
+		{
+			name:      "too few baseline points",
+			values:    []float64{10},
+			matchType: AtleastOnce,
+			compareOp: ValueIsAbove,
+			deviation: 3,
+			expectOk:  false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			b := baseline
+			if !c.expectOk {
+				b = baseline[:1]
+			}
+			z, ok := anomalyScore(b, c.values, c.matchType, c.compareOp)
+			assert.Equal(t, c.expectOk, ok)
+			if !ok {
+				return
+			}
+			assert.InDelta(t, c.expectZ, z, 1e-9)
+			assert.Equal(t, c.expectFire, isAnomalous(z, c.deviation, c.compareOp))
+		})
+	}
+}
+
+func TestAnomalyScoreFlatBaseline(t *testing.T) {
+	_, ok := anomalyScore([]float64{5, 5, 5}, []float64{100}, AtleastOnce, ValueIsAbove)
+	assert.False(t, ok)
+}
+
+func TestParseAnomalyRule(t *testing.T) {
+	content := []byte(`{
+		"alert": "request rate anomaly",
+		"ruleType": "anomaly_rule",
+		"condition": {
+			"compositeQuery": {
+				"queryType": "builder",
+				"builderQueries": {
+					"A": {
+						"queryName": "A",
+						"stepInterval": 60,
+						"dataSource": "metrics",
+						"aggregateOperator": "sum_rate",
+						"aggregateAttribute": {"key": "signoz_calls_total"},
+						"expression": "A"
+					}
+				}
+			},
+			"op": "1",
+			"matchType": "1",
+			"anomaly": {"algorithm": "seasonal", "deviation": 3}
+		}
+	}`)
+
+	rule, errs := ParsePostableRule(content)
+	assert.Empty(t, errs)
+	assert.Equal(t, RuleType(RuleTypeAnomaly), rule.RuleType)
+	assert.Equal(t, AnomalyAlgorithmSeasonal, rule.RuleCondition.Anomaly.Algorithm)
+	assert.Equal(t, Duration(defaultAnomalySeasonality), rule.RuleCondition.Anomaly.Seasonality)
+	assert.Equal(t, Duration(defaultAnomalyBaselineWindow), rule.RuleCondition.Anomaly.BaselineWindow)
+
+	ar, err := NewAnomalyRule("1", rule, ThresholdRuleOpts{}, featureManager.StartManager())
+	assert.NoError(t, err)
+
+	// seasonal baseline is the same window a week ago, widened by the baseline window
+	start := time.Date(2023, 1, 10, 10, 0, 0, 0, time.UTC).UnixMilli()
+	end := start + 5*time.Minute.Milliseconds()
+	baselineStart, baselineEnd := ar.baselineRange(start, end)
+	week := (7 * 24 * time.Hour).Milliseconds()
+	assert.Equal(t, start-week-30*time.Minute.Milliseconds(), baselineStart)
+	assert.Equal(t, end-week+30*time.Minute.Milliseconds(), baselineEnd)
+}
+
+func TestParseAnomalyRuleWithoutDeviation(t *testing.T) {
+	content := []byte(`{
+		"alert": "request rate anomaly",
+		"ruleType": "anomaly_rule",
+		"condition": {
+			"compositeQuery": {
+				"queryType": "promql",
+				"promQueries": {"A": {"query": "sum(rate(signoz_calls_total[5m]))"}}
+			}
+		}
+	}`)
+
+	_, errs := ParsePostableRule(content)
+	assert.NotEmpty(t, errs)
+}
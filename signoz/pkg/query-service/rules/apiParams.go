@@ -111,12 +111,23 @@ func parseIntoRule(initRule PostableRule, content []byte, kind string) (*Postabl
 	}
 
 	if rule.RuleCondition != nil {
-		if rule.RuleCondition.CompositeQuery.QueryType == v3.QueryTypeBuilder {
+		// anomaly rules can be built on either query type, so
+		// the rule type is only derived for the other rules
+		if rule.RuleType == RuleTypeAnomaly {
+			if rule.RuleCondition.Anomaly == nil {
+				rule.RuleCondition.Anomaly = &AnomalyCondition{}
+			}
+			setAnomalyDefaults(rule.RuleCondition.Anomaly)
+		} else if rule.RuleCondition.CompositeQuery.QueryType == v3.QueryTypeBuilder {
 			rule.RuleType = RuleTypeThreshold
 		} else if rule.RuleCondition.CompositeQuery.QueryType == v3.QueryTypePromQL {
 			rule.RuleType = RuleTypeProm
 		}
 
+		if rule.RuleCondition.Absent != nil {
+			setAbsentDefaults(rule.RuleCondition.Absent)
+		}
+
 		for qLabel, q := range rule.RuleCondition.CompositeQuery.BuilderQueries {
 			if q.AggregateAttribute.Key != "" && q.Expression == "" {
 				q.Expression = qLabel
@@ -160,7 +171,7 @@ func (r *PostableRule) Validate() (errs []error) {
 	}
 
 	if r.RuleType == RuleTypeThreshold {
-		if r.RuleCondition.Target == nil {
+		if r.RuleCondition.Target == nil && !r.RuleCondition.HasThresholds() {
 			errs = append(errs, errors.Errorf("rule condition missing the threshold"))
 		}
 		if r.RuleCondition.CompareOp == "" {
@@ -171,6 +182,18 @@ func (r *PostableRule) Validate() (errs []error) {
 		}
 	}
 
+	if r.RuleType == RuleTypeAnomaly && r.RuleCondition != nil {
+		errs = append(errs, validateAnomalyCondition(r.RuleCondition)...)
+	}
+
+	if r.RuleCondition.HasThresholds() {
+		errs = append(errs, validateThresholds(r.RuleCondition)...)
+	}
+
+	if r.RuleCondition != nil && r.RuleCondition.Absent != nil {
+		errs = append(errs, validateAbsentCondition(r)...)
+	}
+
 	for k, v := range r.Labels {
 		if !isValidLabelName(k) {
 			errs = append(errs, errors.Errorf("invalid label name: %s", k))
@@ -191,6 +214,30 @@ func (r *PostableRule) Validate() (errs []error) {
 	return errs
 }
 
+// validateThresholds checks the severity levels of a rule with multiple thresholds
+func validateThresholds(rc *RuleCondition) (errs []error) {
+	if rc.CompareOp != ValueIsAbove && rc.CompareOp != ValueIsBelow {
+		errs = append(errs, errors.Errorf("multiple thresholds support only above and below compare ops"))
+	}
+
+	severities := make(map[string]struct{}, len(rc.Thresholds))
+	for _, t := range rc.Thresholds {
+		if t == nil || t.Target == nil {
+			errs = append(errs, errors.Errorf("rule threshold missing the target"))
+			continue
+		}
+		if t.Severity == "" {
+			errs = append(errs, errors.Errorf("rule threshold missing the severity"))
+			continue
+		}
+		if _, ok := severities[t.Severity]; ok {
+			errs = append(errs, errors.Errorf("duplicate rule threshold severity: %s", t.Severity))
+		}
+		severities[t.Severity] = struct{}{}
+	}
+	return errs
+}
+
 func testTemplateParsing(rl *PostableRule) (errs []error) {
 	if rl.Alert == "" {
 		// Not an alerting rule.
@@ -0,0 +1,121 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+	"go.uber.org/zap"
+)
+
+// maxBacktestEvaluations limits the evaluations of a backtest so that a
+// small frequency over a long range does not flood the query backends
+const maxBacktestEvaluations = 2000
+
+// BacktestPoint is the value and state of an alert at an evaluation
+type BacktestPoint struct {
+	UnixMilli int64   `json:"unixMilli"`
+	Value     float64 `json:"value"`
+	State     string  `json:"state"`
+}
+
+// BacktestSeries has the points of an alert (label set) at the evaluations
+// it was pending or firing. The fingerprint is the one of the state history.
+type BacktestSeries struct {
+	Fingerprint string            `json:"fingerprint"`
+	Labels      map[string]string `json:"labels"`
+	Points      []*BacktestPoint  `json:"points"`
+}
+
+// RuleBacktest is the simulated state timeline of a rule replayed over a
+// past time range. No notifications are sent for the simulated alerts.
+type RuleBacktest struct {
+	RuleStateTimeline
+	// Frequency is the time (in ms) between the evaluations
+	Frequency   int64             `json:"frequency"`
+	Evaluations int               `json:"evaluations"`
+	Failures    int               `json:"failures"`
+	LastError   string            `json:"lastError,omitempty"`
+	Series      []*BacktestSeries `json:"series"`
+}
+
+// validateBacktestRange checks the range can be replayed at the frequency
+func validateBacktestRange(start, end time.Time, frequency time.Duration) error {
+	if !start.Before(end) {
+		return fmt.Errorf("start time must be before end time")
+	}
+	if end.After(time.Now()) {
+		return fmt.Errorf("end time cannot be in the future")
+	}
+	if frequency <= 0 {
+		return fmt.Errorf("rule frequency must be positive")
+	}
+	if evaluations := int64(end.Sub(start)/frequency) + 1; evaluations > maxBacktestEvaluations {
+		return fmt.Errorf("the range needs %d evaluations at the rule frequency of %s, at most %d are allowed", evaluations, frequency, maxBacktestEvaluations)
+	}
+	return nil
+}
+
+// alertFingerprint returns the identity of the alert used in the state
+// history, the severity is left out for the rules with multiple thresholds.
+// The labels of the promql alerts hash the same once converted.
+func alertFingerprint(rule Rule, alertLabels labels.BaseLabels) uint64 {
+	lbls := labels.FromMap(alertLabels.Map())
+	if rule.Condition() != nil && rule.Condition().HasThresholds() {
+		return lbls.HashWithoutLabels(labels.AlertSeverityLabel)
+	}
+	return lbls.Hash()
+}
+
+// backtestRule evaluates the rule at every frequency from start to end. The
+// failed evaluations are counted and skipped, the alerts keep their state.
+func backtestRule(ctx context.Context, rule Rule, start, end time.Time, frequency time.Duration, queriers *Queriers) (*RuleBacktest, error) {
+	backtest := &RuleBacktest{
+		RuleStateTimeline: RuleStateTimeline{
+			Start:   start.UnixMilli(),
+			End:     end.UnixMilli(),
+			History: []*RuleStateHistory{},
+		},
+		Frequency: frequency.Milliseconds(),
+	}
+
+	series := make(map[uint64]*BacktestSeries)
+	for ts := start; !ts.After(end); ts = ts.Add(frequency) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		backtest.Evaluations++
+		if _, err := rule.Eval(ctx, ts, queriers); err != nil {
+			zap.S().Debugf("msg: backtest evaluation failed", "\t rule: ", rule.Name(), "\t ts: ", ts, "\t error: ", err)
+			backtest.Failures++
+			backtest.LastError = err.Error()
+			continue
+		}
+		backtest.History = append(backtest.History, rule.TakeStateHistory()...)
+
+		for _, a := range rule.ActiveAlerts() {
+			fp := alertFingerprint(rule, a.Labels)
+			s, ok := series[fp]
+			if !ok {
+				s = &BacktestSeries{Fingerprint: strconv.FormatUint(fp, 10)}
+				series[fp] = s
+			}
+			s.Labels = a.Labels.Map()
+			s.Points = append(s.Points, &BacktestPoint{UnixMilli: ts.UnixMilli(), Value: a.Value, State: a.State.String()})
+		}
+	}
+
+	backtest.Stats = aggregateStateHistory(nil, backtest.History, backtest.Start, backtest.End)
+	backtest.Series = make([]*BacktestSeries, 0, len(series))
+	for _, s := range series {
+		backtest.Series = append(backtest.Series, s)
+	}
+	sort.Slice(backtest.Series, func(i, j int) bool {
+		return backtest.Series[i].Fingerprint < backtest.Series[j].Fingerprint
+	})
+	return backtest, nil
+}
@@ -0,0 +1,112 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.signoz.io/signoz/pkg/query-service/featureManager"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// scriptedRule is a threshold rule that evaluates the given values in
+// order instead of querying clickhouse, a NaN value fails the evaluation
+type scriptedRule struct {
+	*ThresholdRule
+	values []float64
+	idx    int
+}
+
+func (r *scriptedRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (interface{}, error) {
+	v := r.values[r.idx]
+	r.idx++
+	if math.IsNaN(v) {
+		return nil, fmt.Errorf("query failed")
+	}
+
+	var res Vector
+	if r.CheckCondition(v) {
+		metric := labels.Labels{{Name: "service_name", Value: "frontend"}}
+		res = Vector{{Point: Point{T: ts.UnixMilli(), V: v}, Metric: metric}}
+	}
+	return r.updateAlerts(ctx, ts, res, func(v float64) string { return fmt.Sprintf("%f", v) }, "")
+}
+
+func TestBacktestRule(t *testing.T) {
+	target := 100.0
+	postableRule := PostableRule{
+		Alert:      "Backtest",
+		AlertType:  "METRICS_BASED_ALERT",
+		RuleType:   RuleTypeThreshold,
+		EvalWindow: Duration(5 * time.Minute),
+		Frequency:  Duration(1 * time.Minute),
+		RuleCondition: &RuleCondition{
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType: v3.QueryTypeBuilder,
+				BuilderQueries: map[string]*v3.BuilderQuery{
+					"A": {
+						QueryName:    "A",
+						StepInterval: 60,
+						AggregateAttribute: v3.AttributeKey{
+							Key: "signoz_calls_total",
+						},
+						AggregateOperator: v3.AggregateOperatorSumRate,
+						DataSource:        v3.DataSourceMetrics,
+						Expression:        "A",
+					},
+				},
+			},
+			CompareOp: ValueIsAbove,
+			MatchType: AtleastOnce,
+			Target:    &target,
+		},
+	}
+
+	tr, err := NewThresholdRule("69", &postableRule, ThresholdRuleOpts{}, featureManager.StartManager())
+	assert.NoError(t, err)
+
+	rule := &scriptedRule{ThresholdRule: tr, values: []float64{50, 120, 130, math.NaN(), 140, 90, 80}}
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	end := start.Add(6 * time.Minute)
+	backtest, err := backtestRule(context.Background(), rule, start, end, time.Minute, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 7, backtest.Evaluations)
+	assert.Equal(t, 1, backtest.Failures)
+	assert.Equal(t, "query failed", backtest.LastError)
+
+	// pending and firing at +1m, resolved at +5m, the failed evaluation at
+	// +3m is skipped and keeps the alert firing
+	states := []string{}
+	for _, h := range backtest.History {
+		states = append(states, fmt.Sprintf("%s@%d", h.State, (h.UnixMilli-start.UnixMilli())/time.Minute.Milliseconds()))
+	}
+	assert.Equal(t, []string{"pending@1", "firing@1", "resolved@5"}, states)
+
+	assert.Len(t, backtest.Stats, 1)
+	assert.Equal(t, (4 * time.Minute).Milliseconds(), backtest.Stats[0].FiringDuration)
+	assert.Equal(t, stateResolved, backtest.Stats[0].LastState)
+
+	assert.Len(t, backtest.Series, 1)
+	assert.Equal(t, backtest.History[0].Fingerprint, backtest.Series[0].Fingerprint)
+	values := []float64{}
+	for _, p := range backtest.Series[0].Points {
+		values = append(values, p.Value)
+	}
+	assert.Equal(t, []float64{120, 130, 140}, values)
+	assert.Equal(t, StateFiring.String(), backtest.Series[0].Points[2].State)
+}
+
+func TestValidateBacktestRange(t *testing.T) {
+	end := time.Now().Add(-time.Hour)
+
+	assert.NoError(t, validateBacktestRange(end.Add(-24*time.Hour), end, time.Minute))
+	assert.Error(t, validateBacktestRange(end, end.Add(-time.Minute), time.Minute))
+	assert.Error(t, validateBacktestRange(end, time.Now().Add(time.Hour), time.Minute))
+	assert.Error(t, validateBacktestRange(end.Add(-30*24*time.Hour), end, time.Minute))
+}
@@ -0,0 +1,291 @@
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// formats of the rule bundles
+const (
+	BundleFormatYAML = "yaml"
+	BundleFormatJSON = "json"
+)
+
+// BundleRule is a rule of an import/export bundle, the id is the id of
+// the rule in the rule db so that a re-import updates the same rule. The
+// rules without an id are matched with the stored rules by their alert name.
+type BundleRule struct {
+	Id string `json:"id,omitempty"`
+	PostableRule
+}
+
+// RuleBundle is the set of rules exported from or imported into the rule db
+type RuleBundle struct {
+	Rules []*BundleRule `json:"rules"`
+}
+
+// RuleChange is a rule created, updated or deleted by an import
+type RuleChange struct {
+	Id    string `json:"id,omitempty"`
+	Alert string `json:"alert"`
+	// Fields are the top level fields that differ in an updated rule
+	Fields []string `json:"fields,omitempty"`
+}
+
+// RuleImportResult is the diff of an import against the stored rules
+type RuleImportResult struct {
+	DryRun    bool          `json:"dryRun"`
+	Create    []*RuleChange `json:"create"`
+	Update    []*RuleChange `json:"update"`
+	Delete    []*RuleChange `json:"delete"`
+	Unchanged int           `json:"unchanged"`
+}
+
+// ruleImportPlan holds the rules to write for an import
+type ruleImportPlan struct {
+	result  *RuleImportResult
+	creates []*StoredRule
+	updates []*StoredRule
+	deletes []*StoredRule
+	// parsed has the parsed definitions of the rules to write and delete
+	parsed map[*StoredRule]*PostableRule
+}
+
+// EncodeRuleBundle encodes the bundle in the given format. The yaml is
+// converted from the json encoding so that both use the same field names.
+func EncodeRuleBundle(bundle *RuleBundle, format string) ([]byte, error) {
+	content, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case BundleFormatJSON:
+		return content, nil
+	case BundleFormatYAML:
+		var node yaml.Node
+		if err := yaml.Unmarshal(content, &node); err != nil {
+			return nil, err
+		}
+		resetYAMLStyle(&node)
+
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(&node); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("invalid bundle format %s, must be one of yaml or json", format)
+	}
+}
+
+// resetYAMLStyle turns the flow style of the json into the block style of yaml
+func resetYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, n := range node.Content {
+		resetYAMLStyle(n)
+	}
+}
+
+// DecodeRuleBundle decodes a bundle in the given format
+func DecodeRuleBundle(content []byte, format string) (*RuleBundle, error) {
+	switch format {
+	case BundleFormatJSON:
+	case BundleFormatYAML:
+		var data interface{}
+		if err := yaml.Unmarshal(content, &data); err != nil {
+			return nil, fmt.Errorf("failed to load yaml: %v", err)
+		}
+		var err error
+		if content, err = json.Marshal(data); err != nil {
+			return nil, fmt.Errorf("failed to load yaml: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("invalid bundle format %s, must be one of yaml or json", format)
+	}
+
+	bundle := &RuleBundle{}
+	if err := json.Unmarshal(content, bundle); err != nil {
+		return nil, fmt.Errorf("failed to load json: %v", err)
+	}
+	return bundle, nil
+}
+
+// parseStoredRule parses the rule as stored in the rule db, the older
+// rules may have been stored in yaml
+func parseStoredRule(data string) (*PostableRule, []error) {
+	parsedRule, errs := ParsePostableRule([]byte(data))
+	if len(errs) > 0 && errs[0].Error() == "failed to load json" {
+		return parsePostableRule([]byte(data), "yaml")
+	}
+	return parsedRule, errs
+}
+
+// ruleFields returns the top level fields of the json encoding of the rule
+func ruleFields(rule *PostableRule) (map[string]interface{}, error) {
+	content, err := json.Marshal(rule)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// changedFields returns the sorted list of the fields that differ between the rules
+func changedFields(current, updated *PostableRule) ([]string, error) {
+	currentFields, err := ruleFields(current)
+	if err != nil {
+		return nil, err
+	}
+	updatedFields, err := ruleFields(updated)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for k, v := range updatedFields {
+		if !reflect.DeepEqual(currentFields[k], v) {
+			changed = append(changed, k)
+		}
+	}
+	for k := range currentFields {
+		if _, ok := updatedFields[k]; !ok {
+			changed = append(changed, k)
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// planRuleImport validates the rules of the bundle and diffs them against the
+// stored rules. The bundle rules with an id of a stored rule update it, the
+// bundle rules without an id update the stored rule with the same alert name,
+// so that importing them again does not duplicate them. The others are
+// created with the given id if any. The stored rules missing from the bundle
+// are deleted only when prune is set.
+func planRuleImport(stored []StoredRule, bundle *RuleBundle, prune bool) (*ruleImportPlan, error) {
+	plan := &ruleImportPlan{
+		result: &RuleImportResult{
+			Create: []*RuleChange{},
+			Update: []*RuleChange{},
+			Delete: []*RuleChange{},
+		},
+		parsed: make(map[*StoredRule]*PostableRule),
+	}
+
+	storedById := make(map[int]*StoredRule, len(stored))
+	storedByAlert := make(map[string][]int)
+	for i := range stored {
+		storedById[stored[i].Id] = &stored[i]
+		if rule, errs := parseStoredRule(stored[i].Data); len(errs) == 0 {
+			storedByAlert[rule.Alert] = append(storedByAlert[rule.Alert], stored[i].Id)
+		}
+	}
+
+	// the stored rules claimed by the ids of the bundle are not matched by name
+	claimed := make(map[int]bool)
+	for _, r := range bundle.Rules {
+		if r == nil {
+			continue
+		}
+		if id, err := strconv.Atoi(r.Id); err == nil {
+			claimed[id] = true
+		}
+	}
+
+	seen := make(map[int]bool)
+	for i, r := range bundle.Rules {
+		if r == nil {
+			return nil, fmt.Errorf("rule %d of the bundle is empty", i)
+		}
+
+		content, err := json.Marshal(&r.PostableRule)
+		if err != nil {
+			return nil, err
+		}
+		parsedRule, errs := ParsePostableRule(content)
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("invalid rule %d (%s) in the bundle: %v", i, r.Alert, errs[0])
+		}
+		if content, err = json.Marshal(parsedRule); err != nil {
+			return nil, err
+		}
+
+		id := 0
+		if r.Id != "" {
+			if id, err = strconv.Atoi(r.Id); err != nil || id <= 0 {
+				return nil, fmt.Errorf("invalid id %s of rule %d (%s) in the bundle, must be a positive number", r.Id, i, r.Alert)
+			}
+			if seen[id] {
+				return nil, fmt.Errorf("duplicate rule id %s in the bundle", r.Id)
+			}
+			seen[id] = true
+		} else {
+			var matches []int
+			for _, storedId := range storedByAlert[parsedRule.Alert] {
+				if !claimed[storedId] && !seen[storedId] {
+					matches = append(matches, storedId)
+				}
+			}
+			if len(matches) > 1 {
+				return nil, fmt.Errorf("rule %d (%s) in the bundle matches %d stored rules by name, set its id", i, r.Alert, len(matches))
+			}
+			if len(matches) == 1 {
+				id = matches[0]
+				seen[id] = true
+			}
+		}
+
+		rec := &StoredRule{Id: id, Data: string(content)}
+		plan.parsed[rec] = parsedRule
+
+		current, ok := storedById[id]
+		if !ok {
+			plan.creates = append(plan.creates, rec)
+			plan.result.Create = append(plan.result.Create, &RuleChange{Id: r.Id, Alert: parsedRule.Alert})
+			continue
+		}
+
+		currentRule, errs := parseStoredRule(current.Data)
+		var fields []string
+		if len(errs) > 0 {
+			// a stored rule that does not load anymore is replaced
+			fields = []string{"*"}
+		} else if fields, err = changedFields(currentRule, parsedRule); err != nil {
+			return nil, err
+		}
+		if len(fields) == 0 {
+			plan.result.Unchanged++
+			continue
+		}
+		plan.updates = append(plan.updates, rec)
+		plan.result.Update = append(plan.result.Update, &RuleChange{Id: strconv.Itoa(id), Alert: parsedRule.Alert, Fields: fields})
+	}
+
+	if prune {
+		for i := range stored {
+			if seen[stored[i].Id] {
+				continue
+			}
+			change := &RuleChange{Id: strconv.Itoa(stored[i].Id)}
+			if currentRule, errs := parseStoredRule(stored[i].Data); len(errs) == 0 {
+				change.Alert = currentRule.Alert
+				plan.parsed[&stored[i]] = currentRule
+			}
+			plan.deletes = append(plan.deletes, &stored[i])
+			plan.result.Delete = append(plan.result.Delete, change)
+		}
+	}
+	return plan, nil
+}
@@ -0,0 +1,119 @@
+package rules
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func promRuleData(t *testing.T, alert string, target float64) string {
+	rule := map[string]interface{}{
+		"alert": alert,
+		"condition": map[string]interface{}{
+			"compositeQuery": map[string]interface{}{
+				"queryType":   "promql",
+				"promQueries": map[string]interface{}{"A": map[string]interface{}{"query": "sum(rate(signoz_calls_total[5m]))"}},
+			},
+			"op":        "1",
+			"matchType": "1",
+			"target":    target,
+		},
+	}
+	data, err := json.Marshal(rule)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestRuleBundleRoundTrip(t *testing.T) {
+	rule, errs := ParsePostableRule([]byte(promRuleData(t, "high request rate", 100)))
+	assert.Empty(t, errs)
+	bundle := &RuleBundle{Rules: []*BundleRule{{Id: "7", PostableRule: *rule}}}
+
+	for _, format := range []string{BundleFormatYAML, BundleFormatJSON} {
+		content, err := EncodeRuleBundle(bundle, format)
+		assert.NoError(t, err)
+
+		decoded, err := DecodeRuleBundle(content, format)
+		assert.NoError(t, err)
+		assert.Len(t, decoded.Rules, 1)
+		assert.Equal(t, "7", decoded.Rules[0].Id)
+
+		fields, err := changedFields(rule, &decoded.Rules[0].PostableRule)
+		assert.NoError(t, err)
+		assert.Empty(t, fields, format)
+	}
+
+	// the yaml uses the json field names
+	content, err := EncodeRuleBundle(bundle, BundleFormatYAML)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "compositeQuery:")
+	assert.Contains(t, string(content), "promQueries:")
+}
+
+func TestPlanRuleImport(t *testing.T) {
+	stored := []StoredRule{
+		{Id: 1, Data: promRuleData(t, "unchanged", 100)},
+		{Id: 2, Data: promRuleData(t, "updated", 100)},
+		{Id: 3, Data: promRuleData(t, "not in bundle", 100)},
+	}
+
+	bundleRule := func(id, alert string, target float64) *BundleRule {
+		rule, errs := ParsePostableRule([]byte(promRuleData(t, alert, target)))
+		assert.Empty(t, errs)
+		return &BundleRule{Id: id, PostableRule: *rule}
+	}
+	bundle := &RuleBundle{Rules: []*BundleRule{
+		bundleRule("1", "unchanged", 100),
+		bundleRule("2", "updated", 200),
+		bundleRule("10", "created with id", 100),
+		bundleRule("", "created", 100),
+	}}
+
+	plan, err := planRuleImport(stored, bundle, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, plan.result.Unchanged)
+	assert.Len(t, plan.result.Create, 2)
+	assert.Equal(t, "10", plan.result.Create[0].Id)
+	assert.Equal(t, 10, plan.creates[0].Id)
+	assert.Equal(t, 0, plan.creates[1].Id)
+	assert.Len(t, plan.result.Update, 1)
+	assert.Equal(t, "2", plan.result.Update[0].Id)
+	assert.Equal(t, []string{"condition"}, plan.result.Update[0].Fields)
+	assert.Empty(t, plan.result.Delete)
+
+	// with prune the stored rules missing from the bundle are deleted
+	plan, err = planRuleImport(stored, bundle, true)
+	assert.NoError(t, err)
+	assert.Len(t, plan.result.Delete, 1)
+	assert.Equal(t, "3", plan.result.Delete[0].Id)
+	assert.Equal(t, "not in bundle", plan.result.Delete[0].Alert)
+	assert.Equal(t, 3, plan.deletes[0].Id)
+
+	// the rules without an id update the stored rule with the same name
+	plan, err = planRuleImport(stored, &RuleBundle{Rules: []*BundleRule{
+		bundleRule("", "unchanged", 100),
+		bundleRule("", "updated", 300),
+	}}, false)
+	assert.NoError(t, err)
+	assert.Empty(t, plan.result.Create)
+	assert.Equal(t, 1, plan.result.Unchanged)
+	assert.Len(t, plan.result.Update, 1)
+	assert.Equal(t, "2", plan.result.Update[0].Id)
+	assert.Equal(t, 2, plan.updates[0].Id)
+
+	// a name matching several stored rules needs an id
+	ambiguous := append(stored, StoredRule{Id: 4, Data: promRuleData(t, "updated", 100)})
+	_, err = planRuleImport(ambiguous, &RuleBundle{Rules: []*BundleRule{bundleRule("", "updated", 300)}}, false)
+	assert.Error(t, err)
+
+	// duplicate ids are rejected
+	bundle.Rules = append(bundle.Rules, bundleRule("1", "duplicate", 100))
+	_, err = planRuleImport(stored, bundle, false)
+	assert.Error(t, err)
+
+	// invalid rules are rejected
+	bundle.Rules = []*BundleRule{{Id: "4", PostableRule: PostableRule{Alert: "no condition"}}}
+	_, err = planRuleImport(stored, bundle, false)
+	assert.Error(t, err)
+}
@@ -2,6 +2,8 @@ package rules
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"time"
@@ -27,6 +29,45 @@ type RuleDB interface {
 
 	// GetStoredRule for a given ID from DB
 	GetStoredRule(ctx context.Context, id string) (*StoredRule, error)
+
+	// ImportRulesTx creates, updates and deletes the rules in a single
+	// transaction and returns the transaction. The created rules with
+	// an id are stored with that id, the others are given a new id.
+	ImportRulesTx(ctx context.Context, creates, updates, deletes []*StoredRule) (Tx, error)
+
+	// AddStateHistory stores the state transitions of the alerts
+	AddStateHistory(ctx context.Context, history []*RuleStateHistory) error
+
+	// GetStateHistory fetches the state transitions of a rule in the time range (in ms)
+	GetStateHistory(ctx context.Context, ruleId string, start, end int64) ([]*RuleStateHistory, error)
+
+	// GetStatesBefore fetches the last transition of every alert of a rule before the given time (in ms)
+	GetStatesBefore(ctx context.Context, ruleId string, before int64) ([]*RuleStateHistory, error)
+
+	// GetActiveStates fetches the last transition of the alerts that are still pending or firing
+	GetActiveStates(ctx context.Context) ([]*RuleStateHistory, error)
+
+	// DeleteStateHistory deletes the state transitions of a rule
+	DeleteStateHistory(ctx context.Context, ruleId string) error
+
+	// PruneStateHistory deletes the state transitions older than the given time (in ms),
+	// the last transition of every alert is kept so that its state can be restored
+	PruneStateHistory(ctx context.Context, before int64) error
+
+	// CreateSilence stores a silence and returns its id
+	CreateSilence(ctx context.Context, silence string) (int64, error)
+
+	// EditSilence updates the silence with the given id
+	EditSilence(ctx context.Context, silence string, id string) error
+
+	// DeleteSilence deletes the silence with the given id
+	DeleteSilence(ctx context.Context, id string) error
+
+	// GetStoredSilences fetches all the silences
+	GetStoredSilences(ctx context.Context) ([]StoredSilence, error)
+
+	// GetStoredSilence fetches the silence with the given id
+	GetStoredSilence(ctx context.Context, id string) (*StoredSilence, error)
 }
 
 type StoredRule struct {
@@ -38,6 +79,36 @@ type StoredRule struct {
 	Data      string     `json:"data" db:"data"`
 }
 
+// StoredSilence is a silence as stored in the rules db, the silence
+// definition is kept in data in the same way as the rules
+type StoredSilence = StoredRule
+
+type storedStateHistory struct {
+	Id          int64   `db:"id"`
+	RuleId      string  `db:"rule_id"`
+	Fingerprint string  `db:"fingerprint"`
+	State       string  `db:"state"`
+	Labels      string  `db:"labels"`
+	Value       float64 `db:"value"`
+	UnixMilli   int64   `db:"unix_milli"`
+	ActiveAt    int64   `db:"active_at"`
+}
+
+func (s *storedStateHistory) toStateHistory() (*RuleStateHistory, error) {
+	h := &RuleStateHistory{
+		RuleID:      s.RuleId,
+		Fingerprint: s.Fingerprint,
+		State:       s.State,
+		Value:       s.Value,
+		UnixMilli:   s.UnixMilli,
+		ActiveAt:    s.ActiveAt,
+	}
+	if err := json.Unmarshal([]byte(s.Labels), &h.Labels); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
 type Tx interface {
 	Commit() error
 	Rollback() error
@@ -49,10 +120,39 @@ type ruleDB struct {
 
 // todo: move init methods for creating tables
 
-func newRuleDB(db *sqlx.DB) RuleDB {
+func newRuleDB(db *sqlx.DB) (RuleDB, error) {
+	tableSchema := `CREATE TABLE IF NOT EXISTS rule_state_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		rule_id TEXT NOT NULL,
+		fingerprint TEXT NOT NULL,
+		state TEXT NOT NULL,
+		labels TEXT NOT NULL,
+		value REAL,
+		unix_milli INTEGER NOT NULL,
+		active_at INTEGER NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_rule_state_history_rule_id_unix_milli ON rule_state_history (rule_id, unix_milli);`
+
+	if _, err := db.Exec(tableSchema); err != nil {
+		return nil, fmt.Errorf("error in creating rule_state_history table: %s", err.Error())
+	}
+
+	tableSchema = `CREATE TABLE IF NOT EXISTS rule_silences (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at datetime NOT NULL,
+		created_by TEXT,
+		updated_at datetime NOT NULL,
+		updated_by TEXT,
+		data TEXT NOT NULL
+	);`
+
+	if _, err := db.Exec(tableSchema); err != nil {
+		return nil, fmt.Errorf("error in creating rule_silences table: %s", err.Error())
+	}
+
 	return &ruleDB{
 		db,
-	}
+	}, nil
 }
 
 // CreateRuleTx stores a given rule in db and returns task name,
@@ -202,3 +302,219 @@ func (r *ruleDB) GetStoredRule(ctx context.Context, id string) (*StoredRule, err
 
 	return rule, nil
 }
+
+func (r *ruleDB) ImportRulesTx(ctx context.Context, creates, updates, deletes []*StoredRule) (Tx, error) {
+	var userEmail string
+	if user := common.GetUserFromContext(ctx); user != nil {
+		userEmail = user.Email
+	}
+	now := time.Now()
+
+	tx, err := r.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range creates {
+		var result sql.Result
+		if rule.Id > 0 {
+			result, err = tx.Exec(`INSERT into rules (id, created_at, created_by, updated_at, updated_by, data) VALUES($1,$2,$3,$4,$5,$6);`, rule.Id, now, userEmail, now, userEmail, rule.Data)
+		} else {
+			result, err = tx.Exec(`INSERT into rules (created_at, created_by, updated_at, updated_by, data) VALUES($1,$2,$3,$4,$5);`, now, userEmail, now, userEmail, rule.Data)
+		}
+		if err != nil {
+			zap.S().Errorf("Error in Executing statement for INSERT to rules\n", err)
+			tx.Rollback()
+			return nil, err
+		}
+		lastInsertId, err := result.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		rule.Id = int(lastInsertId)
+	}
+
+	for _, rule := range updates {
+		if _, err := tx.Exec(`UPDATE rules SET updated_by=$1, updated_at=$2, data=$3 WHERE id=$4;`, userEmail, now, rule.Data, rule.Id); err != nil {
+			zap.S().Errorf("Error in Executing statement for UPDATE to rules\n", err)
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	for _, rule := range deletes {
+		if _, err := tx.Exec(`DELETE FROM rules WHERE id=$1;`, rule.Id); err != nil {
+			zap.S().Errorf("Error in Executing statement for DELETE to rules\n", err)
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	return tx, nil
+}
+
+func (r *ruleDB) AddStateHistory(ctx context.Context, history []*RuleStateHistory) error {
+	tx, err := r.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO rule_state_history (rule_id, fingerprint, state, labels, value, unix_milli, active_at) VALUES($1,$2,$3,$4,$5,$6,$7);`)
+	if err != nil {
+		zap.S().Errorf("Error in preparing statement for INSERT to rule_state_history\n", err)
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, h := range history {
+		lbls, err := json.Marshal(h.Labels)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := stmt.Exec(h.RuleID, h.Fingerprint, h.State, string(lbls), h.Value, h.UnixMilli, h.ActiveAt); err != nil {
+			zap.S().Errorf("Error in Executing prepared statement for INSERT to rule_state_history\n", err)
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *ruleDB) selectStateHistory(query string, args ...interface{}) ([]*RuleStateHistory, error) {
+	stored := []storedStateHistory{}
+	if err := r.Select(&stored, query, args...); err != nil {
+		zap.S().Debug("Error in processing sql query: ", err)
+		return nil, err
+	}
+
+	history := make([]*RuleStateHistory, 0, len(stored))
+	for i := range stored {
+		h, err := stored[i].toStateHistory()
+		if err != nil {
+			zap.S().Errorf("msg:", "invalid rule state history labels", "\t err:", err)
+			continue
+		}
+		history = append(history, h)
+	}
+	return history, nil
+}
+
+func (r *ruleDB) GetStateHistory(ctx context.Context, ruleId string, start, end int64) ([]*RuleStateHistory, error) {
+	query := `SELECT id, rule_id, fingerprint, state, labels, value, unix_milli, active_at FROM rule_state_history
+		WHERE rule_id=$1 AND unix_milli >= $2 AND unix_milli <= $3 ORDER BY unix_milli, id`
+
+	return r.selectStateHistory(query, ruleId, start, end)
+}
+
+func (r *ruleDB) GetStatesBefore(ctx context.Context, ruleId string, before int64) ([]*RuleStateHistory, error) {
+	query := `SELECT h.id, h.rule_id, h.fingerprint, h.state, h.labels, h.value, h.unix_milli, h.active_at FROM rule_state_history h
+		INNER JOIN (SELECT MAX(id) AS id FROM rule_state_history WHERE rule_id=$1 AND unix_milli < $2 GROUP BY fingerprint) latest ON h.id = latest.id
+		ORDER BY h.unix_milli, h.id`
+
+	return r.selectStateHistory(query, ruleId, before)
+}
+
+func (r *ruleDB) GetActiveStates(ctx context.Context) ([]*RuleStateHistory, error) {
+	query := `SELECT h.id, h.rule_id, h.fingerprint, h.state, h.labels, h.value, h.unix_milli, h.active_at FROM rule_state_history h
+		INNER JOIN (SELECT MAX(id) AS id FROM rule_state_history GROUP BY rule_id, fingerprint) latest ON h.id = latest.id
+		WHERE h.state IN ($1, $2)`
+
+	return r.selectStateHistory(query, StatePending.String(), StateFiring.String())
+}
+
+func (r *ruleDB) DeleteStateHistory(ctx context.Context, ruleId string) error {
+	if _, err := r.Exec(`DELETE FROM rule_state_history WHERE rule_id=$1;`, ruleId); err != nil {
+		zap.S().Errorf("Error in deleting rule state history\n", err)
+		return err
+	}
+	return nil
+}
+
+func (r *ruleDB) PruneStateHistory(ctx context.Context, before int64) error {
+	if _, err := r.Exec(`DELETE FROM rule_state_history WHERE unix_milli < $1
+		AND id NOT IN (SELECT MAX(id) FROM rule_state_history GROUP BY rule_id, fingerprint);`, before); err != nil {
+		zap.S().Errorf("Error in pruning rule state history\n", err)
+		return err
+	}
+	return nil
+}
+
+func (r *ruleDB) CreateSilence(ctx context.Context, silence string) (int64, error) {
+	var userEmail string
+	if user := common.GetUserFromContext(ctx); user != nil {
+		userEmail = user.Email
+	}
+	now := time.Now()
+
+	result, err := r.Exec(`INSERT into rule_silences (created_at, created_by, updated_at, updated_by, data) VALUES($1,$2,$3,$4,$5);`, now, userEmail, now, userEmail, silence)
+	if err != nil {
+		zap.S().Errorf("Error in Executing statement for INSERT to rule_silences\n", err)
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (r *ruleDB) EditSilence(ctx context.Context, silence string, id string) error {
+	idInt, _ := strconv.Atoi(id)
+	if idInt == 0 {
+		return fmt.Errorf("failed to read silence id from parameters")
+	}
+
+	var userEmail string
+	if user := common.GetUserFromContext(ctx); user != nil {
+		userEmail = user.Email
+	}
+
+	result, err := r.Exec(`UPDATE rule_silences SET updated_by=$1, updated_at=$2, data=$3 WHERE id=$4;`, userEmail, time.Now(), silence, idInt)
+	if err != nil {
+		zap.S().Errorf("Error in Executing statement for UPDATE to rule_silences\n", err)
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("silence with id %s not found", id)
+	}
+	return nil
+}
+
+func (r *ruleDB) DeleteSilence(ctx context.Context, id string) error {
+	idInt, _ := strconv.Atoi(id)
+	if idInt == 0 {
+		return fmt.Errorf("failed to read silence id from parameters")
+	}
+
+	if _, err := r.Exec(`DELETE FROM rule_silences WHERE id=$1;`, idInt); err != nil {
+		zap.S().Errorf("Error in Executing statement for DELETE to rule_silences\n", err)
+		return err
+	}
+	return nil
+}
+
+func (r *ruleDB) GetStoredSilences(ctx context.Context) ([]StoredSilence, error) {
+	silences := []StoredSilence{}
+
+	query := "SELECT id, created_at, created_by, updated_at, updated_by, data FROM rule_silences"
+	if err := r.Select(&silences, query); err != nil {
+		zap.S().Debug("Error in processing sql query: ", err)
+		return nil, err
+	}
+	return silences, nil
+}
+
+func (r *ruleDB) GetStoredSilence(ctx context.Context, id string) (*StoredSilence, error) {
+	intId, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id parameter")
+	}
+
+	silence := &StoredSilence{}
+	query := "SELECT id, created_at, created_by, updated_at, updated_by, data FROM rule_silences WHERE id=$1"
+	if err := r.Get(silence, query, intId); err != nil {
+		zap.S().Error("Error in processing sql query: ", err)
+		return nil, err
+	}
+	return silence, nil
+}
@@ -0,0 +1,52 @@
+package rules
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPruneStateHistory(t *testing.T) {
+	conn, err := sqlx.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	defer conn.Close()
+	// every connection to the in-memory database has its own database
+	conn.SetMaxOpenConns(1)
+	db, err := newRuleDB(conn)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, db.AddStateHistory(ctx, []*RuleStateHistory{
+		// the alert firing since before the retention
+		{RuleID: "1", Fingerprint: "firing", State: StatePending.String(), UnixMilli: 1000, ActiveAt: 1000},
+		{RuleID: "1", Fingerprint: "firing", State: StateFiring.String(), UnixMilli: 2000, ActiveAt: 1000},
+		// the alert resolved since
+		{RuleID: "1", Fingerprint: "resolved", State: StateFiring.String(), UnixMilli: 1000, ActiveAt: 1000},
+		{RuleID: "1", Fingerprint: "resolved", State: stateResolved, UnixMilli: 5000, ActiveAt: 1000},
+	}))
+
+	require.NoError(t, db.PruneStateHistory(ctx, 3000))
+
+	history, err := db.GetStateHistory(ctx, "1", 0, 10000)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, "firing", history[0].Fingerprint)
+	assert.Equal(t, StateFiring.String(), history[0].State)
+	assert.Equal(t, "resolved", history[1].Fingerprint)
+	assert.Equal(t, stateResolved, history[1].State)
+
+	active, err := db.GetActiveStates(ctx)
+	require.NoError(t, err)
+	require.Len(t, active, 1)
+	assert.Equal(t, "firing", active[0].Fingerprint)
+
+	before, err := db.GetStatesBefore(ctx, "1", 3000)
+	require.NoError(t, err)
+	require.Len(t, before, 1)
+	assert.Equal(t, "firing", before[0].Fingerprint)
+	assert.Equal(t, StateFiring.String(), before[0].State)
+}
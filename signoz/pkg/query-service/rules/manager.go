@@ -70,12 +70,18 @@ type Manager struct {
 	rules map[string]Rule
 	mtx   sync.RWMutex
 	block chan struct{}
+	// done stops the pruning of the state history
+	done chan struct{}
 	// Notifier sends messages through alert manager
 	notifier *am.Notifier
 
 	// datastore to store alert definitions
 	ruleDB RuleDB
 
+	// silences by id, consulted before the alerts are sent
+	silences   map[string]*Silence
+	silenceMtx sync.RWMutex
+
 	logger log.Logger
 
 	featureFlags interfaces.FeatureLookup
@@ -108,15 +114,20 @@ func NewManager(o *ManagerOptions) (*Manager, error) {
 		return nil, err
 	}
 
-	db := newRuleDB(o.DBConn)
+	db, err := newRuleDB(o.DBConn)
+	if err != nil {
+		return nil, err
+	}
 
 	m := &Manager{
 		tasks:        map[string]Task{},
 		rules:        map[string]Rule{},
+		silences:     map[string]*Silence{},
 		notifier:     notifier,
 		ruleDB:       db,
 		opts:         o,
 		block:        make(chan struct{}),
+		done:         make(chan struct{}),
 		logger:       o.Logger,
 		featureFlags: o.FeatureFlags,
 	}
@@ -138,7 +149,36 @@ func (m *Manager) Pause(b bool) {
 	}
 }
 
+// pruneStateHistory deletes the state history older than the retention
+func (m *Manager) pruneStateHistory() {
+	pruneBefore := time.Now().Add(-stateHistoryRetention).UnixMilli()
+	if err := m.ruleDB.PruneStateHistory(context.Background(), pruneBefore); err != nil {
+		zap.S().Errorf("failed to prune rule state history: %v", err)
+	}
+}
+
+// runStateHistoryPruning prunes the state history periodically until the
+// manager stops
+func (m *Manager) runStateHistoryPruning() {
+	ticker := time.NewTicker(stateHistoryPruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			m.pruneStateHistory()
+		}
+	}
+}
+
 func (m *Manager) initiate() error {
+	m.pruneStateHistory()
+
+	if err := m.loadSilences(context.Background()); err != nil {
+		zap.S().Errorf("failed to load silences: %v", err)
+	}
+
 	storedRules, err := m.ruleDB.GetStoredRules(context.Background())
 	if err != nil {
 		return err
@@ -192,6 +232,10 @@ func (m *Manager) initiate() error {
 		}
 	}
 
+	if err := m.restoreState(context.Background()); err != nil {
+		zap.S().Errorf("failed to restore the state of alerts: %v", err)
+	}
+
 	if len(loadErrors) > 0 {
 		return errors.Join(loadErrors...)
 	}
@@ -199,11 +243,37 @@ func (m *Manager) initiate() error {
 	return nil
 }
 
+// restoreState puts back the alerts that were pending or firing when
+// the service stopped, so that they carry on instead of starting over
+func (m *Manager) restoreState(ctx context.Context) error {
+	states, err := m.ruleDB.GetActiveStates(ctx)
+	if err != nil {
+		return err
+	}
+
+	statesByRule := make(map[string][]*RuleStateHistory)
+	for _, s := range states {
+		statesByRule[s.RuleID] = append(statesByRule[s.RuleID], s)
+	}
+
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	for ruleId, history := range statesByRule {
+		if rule, ok := m.rules[ruleId]; ok {
+			rule.RestoreState(history)
+		}
+	}
+	return nil
+}
+
 // Run starts processing of the rule manager.
 func (m *Manager) run() {
 	// initiate notifier
 	go m.notifier.Run()
 
+	go m.runStateHistoryPruning()
+
 	// initiate blocked tasks
 	close(m.block)
 }
@@ -218,6 +288,7 @@ func (m *Manager) Stop() {
 	for _, t := range m.tasks {
 		t.Stop()
 	}
+	close(m.done)
 
 	zap.S().Info("msg: ", "Rule manager stopped")
 }
@@ -340,6 +411,10 @@ func (m *Manager) DeleteRule(ctx context.Context, id string) error {
 		return err
 	}
 
+	if err := m.ruleDB.DeleteStateHistory(ctx, id); err != nil {
+		zap.S().Errorf("msg: ", "failed to delete the rule state history", "\t ruleid: ", id, "\t error: ", err)
+	}
+
 	err = m.updateFeatureUsage(&rule.PostableRule, -1)
 	if err != nil {
 		zap.S().Errorf("error updating feature usage: %v", err)
@@ -409,6 +484,99 @@ func (m *Manager) CreateRule(ctx context.Context, ruleStr string) (*GettableRule
 	return gettableRule, nil
 }
 
+// ExportRules returns all the stored rules ordered by id
+func (m *Manager) ExportRules(ctx context.Context) (*RuleBundle, error) {
+	storedRules, err := m.ruleDB.GetStoredRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(storedRules, func(i, j int) bool { return storedRules[i].Id < storedRules[j].Id })
+
+	bundle := &RuleBundle{Rules: make([]*BundleRule, 0, len(storedRules))}
+	for _, rec := range storedRules {
+		parsedRule, errs := parseStoredRule(rec.Data)
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("failed to parse stored rule %d: %v", rec.Id, errs[0])
+		}
+		bundle.Rules = append(bundle.Rules, &BundleRule{
+			Id:           fmt.Sprintf("%d", rec.Id),
+			PostableRule: *parsedRule,
+		})
+	}
+	return bundle, nil
+}
+
+// ImportRules creates and updates the rules of the bundle, and deletes the
+// stored rules missing from the bundle when prune is set. The changes are
+// written in a single transaction, nothing is written on a dry run.
+func (m *Manager) ImportRules(ctx context.Context, bundle *RuleBundle, dryRun, prune bool) (*RuleImportResult, error) {
+	storedRules, err := m.ruleDB.GetStoredRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := planRuleImport(storedRules, bundle, prune)
+	if err != nil {
+		return nil, model.BadRequest(err)
+	}
+	plan.result.DryRun = dryRun
+	if dryRun {
+		return plan.result, nil
+	}
+
+	// the usage of the features only grows with the created rules
+	for _, rec := range plan.creates {
+		if err := m.checkFeatureUsage(plan.parsed[rec]); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := m.ruleDB.ImportRulesTx(ctx, plan.creates, plan.updates, plan.deletes)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// the ids of the created rules are known once they are stored
+	for i, rec := range plan.creates {
+		plan.result.Create[i].Id = fmt.Sprintf("%d", rec.Id)
+	}
+
+	var loadErrors []error
+	for _, rec := range plan.deletes {
+		if !m.opts.DisableRules {
+			m.deleteTask(prepareTaskName(int64(rec.Id)))
+		}
+		if err := m.ruleDB.DeleteStateHistory(ctx, fmt.Sprintf("%d", rec.Id)); err != nil {
+			zap.S().Errorf("msg: ", "failed to delete the rule state history", "\t ruleid: ", rec.Id, "\t error: ", err)
+		}
+		if parsedRule, ok := plan.parsed[rec]; ok {
+			if err := m.updateFeatureUsage(parsedRule, -1); err != nil {
+				zap.S().Errorf("error updating feature usage: %v", err)
+			}
+		}
+	}
+	for _, rec := range append(plan.creates, plan.updates...) {
+		if !m.opts.DisableRules {
+			if err := m.syncRuleStateWithTask(prepareTaskName(int64(rec.Id)), plan.parsed[rec]); err != nil {
+				loadErrors = append(loadErrors, fmt.Errorf("rule %d: %v", rec.Id, err))
+			}
+		}
+	}
+	for _, rec := range plan.creates {
+		if err := m.updateFeatureUsage(plan.parsed[rec], 1); err != nil {
+			zap.S().Errorf("error updating feature usage: %v", err)
+		}
+	}
+
+	if len(loadErrors) > 0 {
+		return plan.result, fmt.Errorf("rules were imported but failed to load: %w", errors.Join(loadErrors...))
+	}
+	return plan.result, nil
+}
+
 func (m *Manager) updateFeatureUsage(parsedRule *PostableRule, usage int64) error {
 	isTraceOrLogQB := checkIfTraceOrLogQB(parsedRule)
 	if isTraceOrLogQB {
@@ -525,7 +693,7 @@ func (m *Manager) prepareTask(acquireLock bool, r *PostableRule, taskName string
 		rules = append(rules, tr)
 
 		// create ch rule task for evalution
-		task = newTask(TaskTypeCh, taskName, taskNamesuffix, time.Duration(r.Frequency), rules, m.opts, m.prepareNotifyFunc())
+		task = newTask(TaskTypeCh, taskName, taskNamesuffix, time.Duration(r.Frequency), rules, m.opts, m.prepareNotifyFunc(), m.prepareSilencedFunc(), m.prepareRecordStateFunc())
 
 		// add rule to memory
 		m.rules[ruleId] = tr
@@ -547,13 +715,36 @@ func (m *Manager) prepareTask(acquireLock bool, r *PostableRule, taskName string
 		rules = append(rules, pr)
 
 		// create promql rule task for evalution
-		task = newTask(TaskTypeProm, taskName, taskNamesuffix, time.Duration(r.Frequency), rules, m.opts, m.prepareNotifyFunc())
+		task = newTask(TaskTypeProm, taskName, taskNamesuffix, time.Duration(r.Frequency), rules, m.opts, m.prepareNotifyFunc(), m.prepareSilencedFunc(), m.prepareRecordStateFunc())
 
 		// add rule to memory
 		m.rules[ruleId] = pr
 
+	} else if r.RuleType == RuleTypeAnomaly {
+
+		// create anomaly rule
+		ar, err := NewAnomalyRule(
+			ruleId,
+			r,
+			ThresholdRuleOpts{},
+			m.featureFlags,
+		)
+
+		if err != nil {
+			return task, err
+		}
+
+		rules = append(rules, ar)
+
+		// anomaly rules run builder as well as promql queries,
+		// the ch rule task evaluates both
+		task = newTask(TaskTypeCh, taskName, taskNamesuffix, time.Duration(r.Frequency), rules, m.opts, m.prepareNotifyFunc(), m.prepareSilencedFunc(), m.prepareRecordStateFunc())
+
+		// add rule to memory
+		m.rules[ruleId] = ar
+
 	} else {
-		return nil, fmt.Errorf(fmt.Sprintf("unsupported rule type. Supported types: %s, %s", RuleTypeProm, RuleTypeThreshold))
+		return nil, fmt.Errorf(fmt.Sprintf("unsupported rule type. Supported types: %s, %s, %s", RuleTypeProm, RuleTypeThreshold, RuleTypeAnomaly))
 	}
 
 	return task, nil
@@ -655,12 +846,221 @@ func (m *Manager) prepareNotifyFunc() NotifyFunc {
 			res = append(res, a)
 		}
 
-		if len(alerts) > 0 {
+		if len(res) > 0 {
 			m.notifier.Send(res...)
 		}
 	}
 }
 
+// SilencedFunc tells whether the alert with the given labels is muted at ts.
+type SilencedFunc func(lbls labels.BaseLabels, ts time.Time) bool
+
+// prepareSilencedFunc implements the SilencedFunc for the silences of the manager.
+func (m *Manager) prepareSilencedFunc() SilencedFunc {
+	return func(lbls labels.BaseLabels, ts time.Time) bool {
+		silenceIds := m.silencedBy(lbls, ts)
+		if len(silenceIds) == 0 {
+			return false
+		}
+		zap.S().Debugf("msg: alert is silenced\t labels: %s\t silences: %v", lbls.String(), silenceIds)
+		return true
+	}
+}
+
+// RecordStateFunc persists the state transitions of the alerts of a rule.
+type RecordStateFunc func(ctx context.Context, history ...*RuleStateHistory)
+
+// prepareRecordStateFunc implements the RecordStateFunc for the rule db.
+func (m *Manager) prepareRecordStateFunc() RecordStateFunc {
+	return func(ctx context.Context, history ...*RuleStateHistory) {
+		if err := m.ruleDB.AddStateHistory(ctx, history); err != nil {
+			zap.S().Errorf("msg: ", "failed to record the rule state history", "\t error: ", err)
+		}
+	}
+}
+
+// GetRuleStateHistory returns the state transitions of the alerts of a rule
+// in the time range along with the stats of every alert
+func (m *Manager) GetRuleStateHistory(ctx context.Context, id string, start, end time.Time) (*RuleStateTimeline, error) {
+	if _, err := m.ruleDB.GetStoredRule(ctx, id); err != nil {
+		return nil, err
+	}
+
+	history, err := m.ruleDB.GetStateHistory(ctx, id, start.UnixMilli(), end.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	// the alerts already pending or firing at the start of the range
+	initial, err := m.ruleDB.GetStatesBefore(ctx, id, start.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+
+	return &RuleStateTimeline{
+		Start:   start.UnixMilli(),
+		End:     end.UnixMilli(),
+		History: history,
+		Stats:   aggregateStateHistory(initial, history, start.UnixMilli(), end.UnixMilli()),
+	}, nil
+}
+
+// loadSilences reads the silences from the rule db into memory
+func (m *Manager) loadSilences(ctx context.Context) error {
+	stored, err := m.ruleDB.GetStoredSilences(ctx)
+	if err != nil {
+		return err
+	}
+
+	silences := make(map[string]*Silence, len(stored))
+	for _, rec := range stored {
+		silence, err := ParseSilence([]byte(rec.Data))
+		if err != nil {
+			zap.S().Errorf("msg: failed to parse stored silence\t id: %d\t error: %v", rec.Id, err)
+			continue
+		}
+		silences[fmt.Sprintf("%d", rec.Id)] = silence
+	}
+
+	m.silenceMtx.Lock()
+	defer m.silenceMtx.Unlock()
+	m.silences = silences
+	return nil
+}
+
+// silencedBy returns the ids of the silences muting the alert at ts
+func (m *Manager) silencedBy(lbls labels.BaseLabels, ts time.Time) []string {
+	m.silenceMtx.RLock()
+	defer m.silenceMtx.RUnlock()
+
+	var ids []string
+	for id, silence := range m.silences {
+		if silence.IsActive(ts) && silence.Matches(lbls) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func toGettableSilence(stored *StoredSilence, silence *Silence, ts time.Time) *GettableSilence {
+	return &GettableSilence{
+		Id:        fmt.Sprintf("%d", stored.Id),
+		Silence:   *silence,
+		CreatedAt: stored.CreatedAt,
+		CreatedBy: stored.CreatedBy,
+		UpdatedAt: stored.UpdatedAt,
+		UpdatedBy: stored.UpdatedBy,
+		Active:    silence.IsActive(ts),
+	}
+}
+
+// ListSilences returns all the silences
+func (m *Manager) ListSilences(ctx context.Context) ([]*GettableSilence, error) {
+	stored, err := m.ruleDB.GetStoredSilences(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	silences := make([]*GettableSilence, 0, len(stored))
+	for i := range stored {
+		silence, err := ParseSilence([]byte(stored[i].Data))
+		if err != nil {
+			zap.S().Errorf("msg: failed to parse stored silence\t id: %d\t error: %v", stored[i].Id, err)
+			continue
+		}
+		silences = append(silences, toGettableSilence(&stored[i], silence, now))
+	}
+	return silences, nil
+}
+
+// GetSilence returns the silence with the given id
+func (m *Manager) GetSilence(ctx context.Context, id string) (*GettableSilence, error) {
+	stored, err := m.ruleDB.GetStoredSilence(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	silence, err := ParseSilence([]byte(stored.Data))
+	if err != nil {
+		return nil, err
+	}
+	return toGettableSilence(stored, silence, time.Now()), nil
+}
+
+// CreateSilence stores a new silence, it applies to the alerts sent from now on
+func (m *Manager) CreateSilence(ctx context.Context, silenceStr string) (*GettableSilence, error) {
+	silence, err := ParseSilence([]byte(silenceStr))
+	if err != nil {
+		return nil, err
+	}
+
+	lastInsertId, err := m.ruleDB.CreateSilence(ctx, silenceStr)
+	if err != nil {
+		return nil, err
+	}
+
+	id := fmt.Sprintf("%d", lastInsertId)
+	m.silenceMtx.Lock()
+	m.silences[id] = silence
+	m.silenceMtx.Unlock()
+
+	return m.GetSilence(ctx, id)
+}
+
+// EditSilence replaces the silence with the given id
+func (m *Manager) EditSilence(ctx context.Context, silenceStr string, id string) error {
+	silence, err := ParseSilence([]byte(silenceStr))
+	if err != nil {
+		return err
+	}
+
+	if err := m.ruleDB.EditSilence(ctx, silenceStr, id); err != nil {
+		return err
+	}
+
+	m.silenceMtx.Lock()
+	defer m.silenceMtx.Unlock()
+	m.silences[id] = silence
+	return nil
+}
+
+// DeleteSilence deletes the silence with the given id, the alerts muted
+// by the silence are notified again at the next evaluation
+func (m *Manager) DeleteSilence(ctx context.Context, id string) error {
+	if err := m.ruleDB.DeleteSilence(ctx, id); err != nil {
+		return err
+	}
+
+	m.silenceMtx.Lock()
+	defer m.silenceMtx.Unlock()
+	delete(m.silences, id)
+	return nil
+}
+
+// SuppressedAlerts returns the active alerts that are currently muted by silences
+func (m *Manager) SuppressedAlerts() []*SuppressedAlert {
+	now := time.Now()
+	suppressed := []*SuppressedAlert{}
+
+	for _, r := range m.Rules() {
+		for _, alert := range r.ActiveAlerts() {
+			silenceIds := m.silencedBy(alert.Labels, now)
+			if len(silenceIds) == 0 {
+				continue
+			}
+			suppressed = append(suppressed, &SuppressedAlert{
+				RuleID:     r.ID(),
+				RuleName:   r.Name(),
+				State:      alert.State.String(),
+				Labels:     alert.Labels.Map(),
+				ActiveAt:   alert.ActiveAt,
+				SilenceIDs: silenceIds,
+			})
+		}
+	}
+	return suppressed
+}
+
 func (m *Manager) ListActiveRules() ([]Rule, error) {
 	ruleList := []Rule{}
 
@@ -866,8 +1266,12 @@ func (m *Manager) TestNotification(ctx context.Context, ruleStr string) (int, *m
 	if parsedRule.RuleType == RuleTypeThreshold {
 
 		// add special labels for test alerts
-		parsedRule.Labels[labels.AlertAdditionalInfoLabel] = fmt.Sprintf("The rule threshold is set to %.4f, and the observed metric value is {{$value}}.", *parsedRule.RuleCondition.Target)
-		parsedRule.Annotations[labels.AlertSummaryLabel] = fmt.Sprintf("The rule threshold is set to %.4f, and the observed metric value is {{$value}}.", *parsedRule.RuleCondition.Target)
+		info := "The rule threshold is set to {{$threshold}}, and the observed metric value is {{$value}}."
+		if parsedRule.RuleCondition.Target != nil {
+			info = fmt.Sprintf("The rule threshold is set to %.4f, and the observed metric value is {{$value}}.", *parsedRule.RuleCondition.Target)
+		}
+		parsedRule.Labels[labels.AlertAdditionalInfoLabel] = info
+		parsedRule.Annotations[labels.AlertSummaryLabel] = info
 		parsedRule.Labels[labels.RuleSourceLabel] = ""
 		parsedRule.Labels[labels.AlertRuleIdLabel] = ""
 
@@ -903,6 +1307,29 @@ func (m *Manager) TestNotification(ctx context.Context, ruleStr string) (int, *m
 			zap.S().Errorf("msg: failed to prepare a new promql rule for test:", "\t error: ", err)
 			return 0, newApiErrorBadData(err)
 		}
+	} else if parsedRule.RuleType == RuleTypeAnomaly {
+
+		// add special labels for test alerts
+		parsedRule.Labels[labels.AlertAdditionalInfoLabel] = fmt.Sprintf("The rule deviation is set to %.2f, and the observed z-score is {{$value}}.", parsedRule.RuleCondition.Anomaly.Deviation)
+		parsedRule.Annotations[labels.AlertSummaryLabel] = fmt.Sprintf("The rule deviation is set to %.2f, and the observed z-score is {{$value}}.", parsedRule.RuleCondition.Anomaly.Deviation)
+		parsedRule.Labels[labels.RuleSourceLabel] = ""
+		parsedRule.Labels[labels.AlertRuleIdLabel] = ""
+
+		// create an anomaly rule
+		rule, err = NewAnomalyRule(
+			alertname,
+			parsedRule,
+			ThresholdRuleOpts{
+				SendUnmatched: true,
+				SendAlways:    true,
+			},
+			m.featureFlags,
+		)
+
+		if err != nil {
+			zap.S().Errorf("msg: failed to prepare a new anomaly rule for test:", "\t error: ", err)
+			return 0, newApiErrorBadData(err)
+		}
 	} else {
 		return 0, newApiErrorBadData(fmt.Errorf("failed to derive ruletype with given information"))
 	}
@@ -916,7 +1343,57 @@ func (m *Manager) TestNotification(ctx context.Context, ruleStr string) (int, *m
 		return 0, newApiErrorInternal(fmt.Errorf("rule evaluation failed"))
 	}
 	alertsFound := count.(int)
-	rule.SendAlerts(ctx, ts, 0, time.Duration(1*time.Minute), m.prepareNotifyFunc())
+	rule.SendAlerts(ctx, ts, 0, time.Duration(1*time.Minute), m.prepareNotifyFunc(), m.prepareSilencedFunc())
 
 	return alertsFound, nil
 }
+
+// BacktestRule replays the evaluations of the given rule parameters at the
+// rule frequency over a past time range and returns the simulated state
+// timeline. The alerts of the backtest are not sent.
+func (m *Manager) BacktestRule(ctx context.Context, ruleStr string, start, end time.Time) (*RuleBacktest, *model.ApiError) {
+
+	parsedRule, errs := ParsePostableRule([]byte(ruleStr))
+
+	if len(errs) > 0 {
+		zap.S().Errorf("msg: failed to parse rule from request:", "\t error: ", errs)
+		return nil, newApiErrorBadData(errs[0])
+	}
+
+	if err := validateBacktestRange(start, end, time.Duration(parsedRule.Frequency)); err != nil {
+		return nil, newApiErrorBadData(err)
+	}
+
+	var alertname = parsedRule.Alert
+	if alertname == "" {
+		alertname = uuid.New().String()
+	}
+
+	var rule Rule
+	var err error
+
+	switch parsedRule.RuleType {
+	case RuleTypeThreshold:
+		rule, err = NewThresholdRule(alertname, parsedRule, ThresholdRuleOpts{}, m.featureFlags)
+	case RuleTypeProm:
+		rule, err = NewPromRule(alertname, parsedRule, log.With(m.logger, "alert", alertname), PromRuleOpts{})
+	case RuleTypeAnomaly:
+		rule, err = NewAnomalyRule(alertname, parsedRule, ThresholdRuleOpts{}, m.featureFlags)
+	default:
+		return nil, newApiErrorBadData(fmt.Errorf("failed to derive ruletype with given information"))
+	}
+
+	if err != nil {
+		zap.S().Errorf("msg: failed to prepare a new rule for backtest:", "\t error: ", err)
+		return nil, newApiErrorBadData(err)
+	}
+
+	backtest, err := backtestRule(ctx, rule, start, end, time.Duration(parsedRule.Frequency), m.opts.Queriers)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	if backtest.Failures == backtest.Evaluations {
+		return nil, newApiErrorInternal(fmt.Errorf("rule evaluation failed: %s", backtest.LastError))
+	}
+	return backtest, nil
+}
@@ -52,6 +52,9 @@ type PromRule struct {
 	// map of active alerts
 	active map[uint64]*Alert
 
+	// state transitions of the alerts not yet persisted
+	stateHistory []*RuleStateHistory
+
 	logger log.Logger
 	opts   PromRuleOpts
 }
@@ -266,6 +269,33 @@ func (r *PromRule) Unit() string {
 	return ""
 }
 
+// TakeStateHistory returns the state transitions of the alerts made since the last call
+func (r *PromRule) TakeStateHistory() []*RuleStateHistory {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	history := r.stateHistory
+	r.stateHistory = nil
+	return history
+}
+
+// RestoreState puts back the alerts that were pending or firing before a restart
+func (r *PromRule) RestoreState(history []*RuleStateHistory) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for _, h := range history {
+		fp, err := strconv.ParseUint(h.Fingerprint, 10, 64)
+		if err != nil {
+			continue
+		}
+		if _, ok := r.active[fp]; ok {
+			continue
+		}
+		r.active[fp] = h.restoredAlert(plabels.FromMap(h.Labels), plabels.Labels{}, r.GeneratorURL(), r.preferredChannels)
+	}
+}
+
 // ForEachActiveAlert runs the given function on each alert.
 // This should be used when you want to use the actual alerts from the ThresholdRule
 // and not on its copy.
@@ -279,9 +309,13 @@ func (r *PromRule) ForEachActiveAlert(f func(*Alert)) {
 	}
 }
 
-func (r *PromRule) SendAlerts(ctx context.Context, ts time.Time, resendDelay time.Duration, interval time.Duration, notifyFunc NotifyFunc) {
+func (r *PromRule) SendAlerts(ctx context.Context, ts time.Time, resendDelay time.Duration, interval time.Duration, notifyFunc NotifyFunc, silenced SilencedFunc) {
 	alerts := []*Alert{}
 	r.ForEachActiveAlert(func(alert *Alert) {
+		// the silenced alerts are not marked as sent, see ThresholdRule
+		if silenced != nil && silenced(alert.Labels, ts) {
+			return
+		}
 		if r.opts.SendAlways || alert.needsSending(ts, resendDelay) {
 			alert.LastSentAt = ts
 			// Allow for two Eval or Alertmanager send failures.
@@ -323,6 +357,10 @@ func (r *PromRule) getPqlQuery() (string, error) {
 				if query == "" {
 					return query, fmt.Errorf("a promquery needs to be set for this rule to function")
 				}
+				// multiple thresholds are matched after the query runs
+				if r.ruleCondition.HasThresholds() {
+					return query, nil
+				}
 				if r.ruleCondition.Target != nil && r.ruleCondition.CompareOp != CompareOpNone {
 					unitConverter := converter.FromUnit(converter.Unit(r.ruleCondition.TargetUnit))
 					value := unitConverter.Convert(converter.Value{F: *r.ruleCondition.Target, U: converter.Unit(r.ruleCondition.TargetUnit)}, converter.Unit(r.Unit()))
@@ -357,8 +395,6 @@ func (r *PromRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
-	resultFPs := map[uint64]struct{}{}
-
 	var alerts = make(map[uint64]*Alert, len(res))
 
 	for _, smpl := range res {
@@ -367,7 +403,23 @@ func (r *PromRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (
 			l[lbl.Name] = lbl.Value
 		}
 
-		tmplData := AlertTemplateData(l, valueFormatter.Format(smpl.F, r.Unit()), strconv.FormatFloat(r.targetVal(), 'f', 2, 64)+converter.UnitToName(r.ruleCondition.TargetUnit))
+		// for rules with multiple thresholds, the alert takes the
+		// severity and the channels of the threshold it crosses
+		receivers := r.preferredChannels
+		target := r.targetVal()
+		var severityLevel *RuleThreshold
+		if r.ruleCondition.HasThresholds() {
+			severityLevel = r.ruleCondition.matchThreshold(smpl.F, r.Unit())
+			if severityLevel == nil {
+				continue
+			}
+			target = *severityLevel.Target
+			if len(severityLevel.PreferredChannels) > 0 {
+				receivers = severityLevel.PreferredChannels
+			}
+		}
+
+		tmplData := AlertTemplateData(l, valueFormatter.Format(smpl.F, r.Unit()), strconv.FormatFloat(target, 'f', 2, 64)+converter.UnitToName(r.ruleCondition.TargetUnit))
 		// Inject some convenience variables that are easier to remember for users
 		// who are not used to Go's templating system.
 		defs := "{{$labels := .Labels}}{{$value := .Value}}{{$threshold := .Threshold}}"
@@ -399,6 +451,9 @@ func (r *PromRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (
 		lb.Set(qslabels.AlertNameLabel, r.Name())
 		lb.Set(qslabels.AlertRuleIdLabel, r.ID())
 		lb.Set(qslabels.RuleSourceLabel, r.GeneratorURL())
+		if severityLevel != nil {
+			lb.Set(qslabels.AlertSeverityLabel, severityLevel.Severity)
+		}
 
 		annotations := make(plabels.Labels, 0, len(r.annotations))
 		for _, a := range r.annotations {
@@ -407,7 +462,11 @@ func (r *PromRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (
 
 		lbs := lb.Labels()
 		h := lbs.Hash()
-		resultFPs[h] = struct{}{}
+		if severityLevel != nil {
+			// the severity is left out of the alert identity so that
+			// the alert moves between severities without resolving
+			h, _ = lbs.HashWithoutLabels(make([]byte, 0, 1024), qslabels.AlertSeverityLabel)
+		}
 
 		if _, ok := alerts[h]; ok {
 			err = fmt.Errorf("vector contains metrics with the same labelset after applying alert labels")
@@ -425,46 +484,12 @@ func (r *PromRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (
 			State:        StatePending,
 			Value:        smpl.F,
 			GeneratorURL: r.GeneratorURL(),
-			Receivers:    r.preferredChannels,
+			Receivers:    receivers,
 		}
 	}
 
-	// alerts[h] is ready, add or update active list now
-	for h, a := range alerts {
-		// Check whether we already have alerting state for the identifying label set.
-		// Update the last value and annotations if so, create a new alert entry otherwise.
-		if alert, ok := r.active[h]; ok && alert.State != StateInactive {
-			alert.Value = a.Value
-			alert.Annotations = a.Annotations
-			alert.Receivers = r.preferredChannels
-			continue
-		}
+	r.stateHistory = append(r.stateHistory, transitionAlerts(r.ID(), r.active, alerts, ts, r.holdDuration)...)
 
-		r.active[h] = a
-
-	}
-
-	// Check if any pending alerts should be removed or fire now. Write out alert timeseries.
-	for fp, a := range r.active {
-		if _, ok := resultFPs[fp]; !ok {
-			// If the alert was previously firing, keep it around for a given
-			// retention time so it is reported as resolved to the AlertManager.
-			if a.State == StatePending || (!a.ResolvedAt.IsZero() && ts.Sub(a.ResolvedAt) > resolvedRetention) {
-				delete(r.active, fp)
-			}
-			if a.State != StateInactive {
-				a.State = StateInactive
-				a.ResolvedAt = ts
-			}
-			continue
-		}
-
-		if a.State == StatePending && ts.Sub(a.ActiveAt) >= r.holdDuration {
-			a.State = StateFiring
-			a.FiredAt = ts
-		}
-
-	}
 	r.health = HealthGood
 	r.lastError = err
 
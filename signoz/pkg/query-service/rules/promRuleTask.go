@@ -35,11 +35,15 @@ type PromRuleTask struct {
 	pause  bool
 	logger log.Logger
 	notify NotifyFunc
+	// silenced tells the alerts muted by a silence, they are not sent
+	silenced SilencedFunc
+	// record persists the state transitions of the alerts
+	record RecordStateFunc
 }
 
 // newPromRuleTask holds rules that have promql condition
 // and evalutes the rule at a given frequency
-func newPromRuleTask(name, file string, frequency time.Duration, rules []Rule, opts *ManagerOptions, notify NotifyFunc) *PromRuleTask {
+func newPromRuleTask(name, file string, frequency time.Duration, rules []Rule, opts *ManagerOptions, notify NotifyFunc, silenced SilencedFunc, record RecordStateFunc) *PromRuleTask {
 	zap.S().Info("Initiating a new rule group:", name, "\t frequency:", frequency)
 
 	if time.Now() == time.Now().Add(frequency) {
@@ -57,6 +61,8 @@ func newPromRuleTask(name, file string, frequency time.Duration, rules []Rule, o
 		done:                 make(chan struct{}),
 		terminated:           make(chan struct{}),
 		notify:               notify,
+		silenced:             silenced,
+		record:               record,
 		logger:               log.With(opts.Logger, "group", name),
 	}
 }
@@ -349,7 +355,10 @@ func (g *PromRuleTask) Eval(ctx context.Context, ts time.Time) {
 				//}
 				return
 			}
-			rule.SendAlerts(ctx, ts, g.opts.ResendDelay, g.frequency, g.notify)
+			if history := rule.TakeStateHistory(); len(history) > 0 && g.record != nil {
+				g.record(ctx, history...)
+			}
+			rule.SendAlerts(ctx, ts, g.opts.ResendDelay, g.frequency, g.notify, g.silenced)
 
 		}(i, rule)
 	}
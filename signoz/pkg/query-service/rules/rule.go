@@ -34,5 +34,10 @@ type Rule interface {
 	SetEvaluationTimestamp(time.Time)
 	GetEvaluationTimestamp() time.Time
 
-	SendAlerts(ctx context.Context, ts time.Time, resendDelay time.Duration, interval time.Duration, notifyFunc NotifyFunc)
+	SendAlerts(ctx context.Context, ts time.Time, resendDelay time.Duration, interval time.Duration, notifyFunc NotifyFunc, silenced SilencedFunc)
+
+	// TakeStateHistory returns the state transitions of the alerts made since the last call
+	TakeStateHistory() []*RuleStateHistory
+	// RestoreState puts back the alerts that were pending or firing before a restart
+	RestoreState(history []*RuleStateHistory)
 }
@@ -32,12 +32,16 @@ type RuleTask struct {
 
 	pause  bool
 	notify NotifyFunc
+	// silenced tells the alerts muted by a silence, they are not sent
+	silenced SilencedFunc
+	// record persists the state transitions of the alerts
+	record RecordStateFunc
 }
 
 const DefaultFrequency = 1 * time.Minute
 
 // newRuleTask makes a new RuleTask with the given name, options, and rules.
-func newRuleTask(name, file string, frequency time.Duration, rules []Rule, opts *ManagerOptions, notify NotifyFunc) *RuleTask {
+func newRuleTask(name, file string, frequency time.Duration, rules []Rule, opts *ManagerOptions, notify NotifyFunc, silenced SilencedFunc, record RecordStateFunc) *RuleTask {
 
 	if time.Now() == time.Now().Add(frequency) {
 		frequency = DefaultFrequency
@@ -54,6 +58,8 @@ func newRuleTask(name, file string, frequency time.Duration, rules []Rule, opts
 		done:       make(chan struct{}),
 		terminated: make(chan struct{}),
 		notify:     notify,
+		silenced:   silenced,
+		record:     record,
 	}
 }
 
@@ -180,13 +186,25 @@ func (g *RuleTask) HasAlertingRules() bool {
 	defer g.mtx.Unlock()
 
 	for _, rule := range g.rules {
-		if _, ok := rule.(*ThresholdRule); ok {
+		if _, ok := thresholdRuleOf(rule); ok {
 			return true
 		}
 	}
 	return false
 }
 
+// thresholdRuleOf returns the threshold rule that holds the alerts
+// of the rule, anomaly rules keep them in an embedded threshold rule
+func thresholdRuleOf(rule Rule) (*ThresholdRule, bool) {
+	switch r := rule.(type) {
+	case *ThresholdRule:
+		return r, true
+	case *AnomalyRule:
+		return r.ThresholdRule, true
+	}
+	return nil, false
+}
+
 // GetEvaluationDuration returns the time in seconds it took to evaluate the rule group.
 func (g *RuleTask) GetEvaluationDuration() time.Duration {
 	g.mtx.Lock()
@@ -274,11 +292,11 @@ func (g *RuleTask) CopyState(fromTask Task) error {
 		fi := indexes[0]
 		ruleMap[nameAndLabels] = indexes[1:]
 
-		ar, ok := rule.(*ThresholdRule)
+		ar, ok := thresholdRuleOf(rule)
 		if !ok {
 			continue
 		}
-		far, ok := from.rules[fi].(*ThresholdRule)
+		far, ok := thresholdRuleOf(from.rules[fi])
 		if !ok {
 			continue
 		}
@@ -333,7 +351,10 @@ func (g *RuleTask) Eval(ctx context.Context, ts time.Time) {
 				return
 			}
 
-			rule.SendAlerts(ctx, ts, g.opts.ResendDelay, g.frequency, g.notify)
+			if history := rule.TakeStateHistory(); len(history) > 0 && g.record != nil {
+				g.record(ctx, history...)
+			}
+			rule.SendAlerts(ctx, ts, g.opts.ResendDelay, g.frequency, g.notify, g.silenced)
 
 		}(i, rule)
 	}
@@ -0,0 +1,269 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// SilenceMatchOp is the operator used to match an alert label in a silence
+type SilenceMatchOp string
+
+const (
+	SilenceMatchEqual       SilenceMatchOp = "="
+	SilenceMatchNotEqual    SilenceMatchOp = "!="
+	SilenceMatchRegexp      SilenceMatchOp = "=~"
+	SilenceMatchNotRegexp   SilenceMatchOp = "!~"
+	silenceMatchOpUndefined SilenceMatchOp = ""
+)
+
+// RepeatType is how often the window of a recurring silence repeats
+type RepeatType string
+
+const (
+	RepeatTypeDaily   RepeatType = "daily"
+	RepeatTypeWeekly  RepeatType = "weekly"
+	RepeatTypeMonthly RepeatType = "monthly"
+)
+
+// SilenceMatcher matches the value of an alert label
+type SilenceMatcher struct {
+	Name  string         `json:"name"`
+	Op    SilenceMatchOp `json:"op"`
+	Value string         `json:"value"`
+
+	re *regexp.Regexp
+}
+
+// SilenceRecurrence describes the maintenance window of a recurring silence.
+// The window opens at the time of day (and day of the month for monthly
+// windows) of the silence start and stays open for the duration.
+type SilenceRecurrence struct {
+	RepeatType RepeatType `json:"repeatType"`
+	// RepeatOn is the list of weekdays (e.g. monday) of a weekly window,
+	// the weekday of the silence start is used when empty
+	RepeatOn []string `json:"repeatOn,omitempty"`
+	Duration Duration `json:"duration"`
+}
+
+// Silence mutes the notifications of the alerts that match it while it is
+// active. The alerts are still evaluated and keep their state.
+type Silence struct {
+	// RuleIDs restricts the silence to the alerts of the given rules,
+	// the silence applies to all rules when empty
+	RuleIDs  []string          `json:"ruleIds,omitempty"`
+	Matchers []*SilenceMatcher `json:"matchers,omitempty"`
+
+	StartsAt time.Time `json:"startsAt"`
+	// EndsAt is the end of the silence, for a recurring silence it is
+	// the end of the recurrence and is optional
+	EndsAt     *time.Time         `json:"endsAt,omitempty"`
+	Recurrence *SilenceRecurrence `json:"recurrence,omitempty"`
+	// Timezone is used to compute the windows of a recurring silence
+	Timezone string `json:"timezone,omitempty"`
+
+	Comment string `json:"comment,omitempty"`
+
+	loc *time.Location
+}
+
+// GettableSilence is the silence returned by the silences API
+type GettableSilence struct {
+	Id string `json:"id"`
+	Silence
+
+	CreatedAt *time.Time `json:"createdAt"`
+	CreatedBy *string    `json:"createdBy"`
+	UpdatedAt *time.Time `json:"updatedAt"`
+	UpdatedBy *string    `json:"updatedBy"`
+
+	// Active is set when the silence is muting the alerts at the time of the request
+	Active bool `json:"active"`
+}
+
+// SuppressedAlert is an active alert whose notifications are muted by silences
+type SuppressedAlert struct {
+	RuleID     string            `json:"ruleId"`
+	RuleName   string            `json:"ruleName"`
+	State      string            `json:"state"`
+	Labels     map[string]string `json:"labels"`
+	ActiveAt   time.Time         `json:"activeAt"`
+	SilenceIDs []string          `json:"silenceIds"`
+}
+
+// ParseSilence parses and validates the silence in content
+func ParseSilence(content []byte) (*Silence, error) {
+	s := &Silence{}
+	if err := json.Unmarshal(content, s); err != nil {
+		return nil, fmt.Errorf("failed to load json")
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Validate checks the silence and prepares the matchers and the timezone
+func (s *Silence) Validate() error {
+	if s.StartsAt.IsZero() {
+		return fmt.Errorf("silence start time is required")
+	}
+	if s.EndsAt != nil && !s.EndsAt.After(s.StartsAt) {
+		return fmt.Errorf("silence end time must be after the start time")
+	}
+	if s.Recurrence == nil && s.EndsAt == nil {
+		return fmt.Errorf("silence end time is required for a silence that does not recur")
+	}
+
+	s.loc = time.UTC
+	if s.Timezone != "" {
+		loc, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone %s: %v", s.Timezone, err)
+		}
+		s.loc = loc
+	}
+
+	if r := s.Recurrence; r != nil {
+		if r.Duration <= 0 {
+			return fmt.Errorf("duration of a recurring silence must be greater than zero")
+		}
+		switch r.RepeatType {
+		case RepeatTypeDaily, RepeatTypeMonthly:
+		case RepeatTypeWeekly:
+			for _, day := range r.RepeatOn {
+				if _, ok := parseWeekday(day); !ok {
+					return fmt.Errorf("invalid weekday %s in repeatOn", day)
+				}
+			}
+		default:
+			return fmt.Errorf("invalid repeat type %s, must be one of daily, weekly or monthly", r.RepeatType)
+		}
+	}
+
+	for _, m := range s.Matchers {
+		if m.Name == "" {
+			return fmt.Errorf("silence matcher name is required")
+		}
+		switch m.Op {
+		case silenceMatchOpUndefined:
+			m.Op = SilenceMatchEqual
+		case SilenceMatchEqual, SilenceMatchNotEqual:
+		case SilenceMatchRegexp, SilenceMatchNotRegexp:
+			re, err := regexp.Compile("^(?:" + m.Value + ")$")
+			if err != nil {
+				return fmt.Errorf("invalid regex %s in silence matcher: %v", m.Value, err)
+			}
+			m.re = re
+		default:
+			return fmt.Errorf("invalid silence matcher operator %s", m.Op)
+		}
+	}
+	return nil
+}
+
+func parseWeekday(day string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(d.String(), day) {
+			return d, true
+		}
+	}
+	return time.Sunday, false
+}
+
+func (m *SilenceMatcher) matches(lbls labels.BaseLabels) bool {
+	value := lbls.Get(m.Name)
+	switch m.Op {
+	case SilenceMatchNotEqual:
+		return value != m.Value
+	case SilenceMatchRegexp:
+		return m.re.MatchString(value)
+	case SilenceMatchNotRegexp:
+		return !m.re.MatchString(value)
+	default:
+		return value == m.Value
+	}
+}
+
+// Matches returns true if the alert with the given labels is muted by the silence
+func (s *Silence) Matches(lbls labels.BaseLabels) bool {
+	if len(s.RuleIDs) > 0 {
+		ruleId := lbls.Get(labels.AlertRuleIdLabel)
+		found := false
+		for _, id := range s.RuleIDs {
+			if id == ruleId {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, m := range s.Matchers {
+		if !m.matches(lbls) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsActive returns true if the silence is muting the alerts at ts
+func (s *Silence) IsActive(ts time.Time) bool {
+	if ts.Before(s.StartsAt) || (s.EndsAt != nil && !ts.Before(*s.EndsAt)) {
+		return false
+	}
+	if s.Recurrence == nil {
+		return true
+	}
+
+	loc := s.loc
+	if loc == nil {
+		loc = time.UTC
+	}
+	ts = ts.In(loc)
+	first := s.StartsAt.In(loc)
+	duration := time.Duration(s.Recurrence.Duration)
+
+	// look back over the windows that could still be open at ts
+	if s.Recurrence.RepeatType == RepeatTypeMonthly {
+		for i := 0; i <= int(duration/(28*24*time.Hour))+1; i++ {
+			windowStart := time.Date(ts.Year(), ts.Month()-time.Month(i), first.Day(), first.Hour(), first.Minute(), first.Second(), 0, loc)
+			if s.inWindow(ts, windowStart, duration) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := 0; i <= int(duration/(24*time.Hour))+1; i++ {
+		windowStart := time.Date(ts.Year(), ts.Month(), ts.Day()-i, first.Hour(), first.Minute(), first.Second(), 0, loc)
+		if s.Recurrence.RepeatType == RepeatTypeWeekly && !s.repeatsOn(windowStart.Weekday(), loc) {
+			continue
+		}
+		if s.inWindow(ts, windowStart, duration) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Silence) inWindow(ts, windowStart time.Time, duration time.Duration) bool {
+	return !windowStart.Before(s.StartsAt) && !ts.Before(windowStart) && ts.Before(windowStart.Add(duration))
+}
+
+func (s *Silence) repeatsOn(day time.Weekday, loc *time.Location) bool {
+	if len(s.Recurrence.RepeatOn) == 0 {
+		return s.StartsAt.In(loc).Weekday() == day
+	}
+	for _, d := range s.Recurrence.RepeatOn {
+		if wd, ok := parseWeekday(d); ok && wd == day {
+			return true
+		}
+	}
+	return false
+}
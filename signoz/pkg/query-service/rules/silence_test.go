@@ -0,0 +1,131 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+func TestSilenceMatches(t *testing.T) {
+	silence, err := ParseSilence([]byte(`{
+		"ruleIds": ["1", "2"],
+		"matchers": [
+			{"name": "service_name", "op": "=~", "value": "front.*"},
+			{"name": "env", "op": "!=", "value": "staging"}
+		],
+		"startsAt": "2023-01-10T10:00:00Z",
+		"endsAt": "2023-01-10T12:00:00Z"
+	}`))
+	assert.NoError(t, err)
+
+	cases := []struct {
+		name   string
+		labels labels.Labels
+		expect bool
+	}{
+		{
+			name:   "all matchers match",
+			labels: labels.FromMap(map[string]string{labels.AlertRuleIdLabel: "1", "service_name": "frontend", "env": "prod"}),
+			expect: true,
+		},
+		{
+			name:   "regex is anchored",
+			labels: labels.FromMap(map[string]string{labels.AlertRuleIdLabel: "1", "service_name": "new-frontend", "env": "prod"}),
+			expect: false,
+		},
+		{
+			name:   "excluded label value",
+			labels: labels.FromMap(map[string]string{labels.AlertRuleIdLabel: "2", "service_name": "frontend", "env": "staging"}),
+			expect: false,
+		},
+		{
+			name:   "other rule",
+			labels: labels.FromMap(map[string]string{labels.AlertRuleIdLabel: "3", "service_name": "frontend", "env": "prod"}),
+			expect: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expect, silence.Matches(c.labels))
+		})
+	}
+}
+
+func TestSilenceIsActive(t *testing.T) {
+	cases := []struct {
+		name    string
+		silence string
+		active  []string
+		// times not muted by the silence
+		inactive []string
+	}{
+		{
+			name:     "one off",
+			silence:  `{"startsAt": "2023-01-10T10:00:00Z", "endsAt": "2023-01-10T12:00:00Z"}`,
+			active:   []string{"2023-01-10T10:00:00Z", "2023-01-10T11:59:59Z"},
+			inactive: []string{"2023-01-10T09:59:59Z", "2023-01-10T12:00:00Z"},
+		},
+		{
+			name:     "daily window across midnight",
+			silence:  `{"startsAt": "2023-01-10T23:00:00Z", "recurrence": {"repeatType": "daily", "duration": "2h"}}`,
+			active:   []string{"2023-01-10T23:30:00Z", "2023-01-15T00:59:00Z"},
+			inactive: []string{"2023-01-10T00:30:00Z", "2023-01-15T01:00:00Z", "2023-01-15T22:59:00Z"},
+		},
+		{
+			name:     "weekly window on weekdays",
+			silence:  `{"startsAt": "2023-01-09T02:00:00Z", "endsAt": "2023-02-01T00:00:00Z", "recurrence": {"repeatType": "weekly", "repeatOn": ["saturday", "sunday"], "duration": "1h"}}`,
+			active:   []string{"2023-01-14T02:30:00Z", "2023-01-15T02:00:00Z"},
+			inactive: []string{"2023-01-13T02:30:00Z", "2023-01-14T03:00:00Z", "2023-02-04T02:30:00Z"},
+		},
+		{
+			name:     "monthly window",
+			silence:  `{"startsAt": "2023-01-05T00:00:00Z", "recurrence": {"repeatType": "monthly", "duration": "48h"}}`,
+			active:   []string{"2023-03-05T00:00:00Z", "2023-03-06T23:00:00Z"},
+			inactive: []string{"2023-03-07T00:00:00Z", "2023-03-04T23:00:00Z"},
+		},
+		{
+			name:     "daily window in timezone",
+			silence:  `{"startsAt": "2023-01-10T09:00:00+05:30", "timezone": "Asia/Kolkata", "recurrence": {"repeatType": "daily", "duration": "1h"}}`,
+			active:   []string{"2023-01-12T03:45:00Z"},
+			inactive: []string{"2023-01-12T09:15:00Z"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			silence, err := ParseSilence([]byte(c.silence))
+			assert.NoError(t, err)
+			for _, ts := range c.active {
+				parsed, _ := time.Parse(time.RFC3339, ts)
+				assert.True(t, silence.IsActive(parsed), ts)
+			}
+			for _, ts := range c.inactive {
+				parsed, _ := time.Parse(time.RFC3339, ts)
+				assert.False(t, silence.IsActive(parsed), ts)
+			}
+		})
+	}
+}
+
+func TestParseSilenceInvalid(t *testing.T) {
+	cases := map[string]string{
+		"missing end":          `{"startsAt": "2023-01-10T10:00:00Z"}`,
+		"end before start":     `{"startsAt": "2023-01-10T10:00:00Z", "endsAt": "2023-01-10T09:00:00Z"}`,
+		"invalid repeat type":  `{"startsAt": "2023-01-10T10:00:00Z", "recurrence": {"repeatType": "hourly", "duration": "1h"}}`,
+		"missing duration":     `{"startsAt": "2023-01-10T10:00:00Z", "recurrence": {"repeatType": "daily"}}`,
+		"invalid weekday":      `{"startsAt": "2023-01-10T10:00:00Z", "recurrence": {"repeatType": "weekly", "repeatOn": ["funday"], "duration": "1h"}}`,
+		"invalid regex":        `{"startsAt": "2023-01-10T10:00:00Z", "endsAt": "2023-01-10T12:00:00Z", "matchers": [{"name": "a", "op": "=~", "value": "("}]}`,
+		"invalid timezone":     `{"startsAt": "2023-01-10T10:00:00Z", "endsAt": "2023-01-10T12:00:00Z", "timezone": "Mars/Olympus"}`,
+		"matcher without name": `{"startsAt": "2023-01-10T10:00:00Z", "endsAt": "2023-01-10T12:00:00Z", "matchers": [{"value": "a"}]}`,
+	}
+
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseSilence([]byte(content))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package rules
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// stateResolved is recorded when a firing alert stops matching the rule condition
+const stateResolved = "resolved"
+
+// how long the state history of the rules is kept
+const stateHistoryRetention = 30 * 24 * time.Hour
+
+// how often the state history older than the retention is deleted
+const stateHistoryPruneInterval = 6 * time.Hour
+
+// RuleStateHistory is a state transition (inactive, pending, firing, resolved)
+// of an alert of a rule. The transitions are persisted so that the alerts keep
+// their state across restarts and the timeline of a rule can be looked up.
+type RuleStateHistory struct {
+	RuleID      string            `json:"ruleId"`
+	Fingerprint string            `json:"fingerprint"`
+	State       string            `json:"state"`
+	Labels      map[string]string `json:"labels"`
+	Value       float64           `json:"value"`
+	// UnixMilli is the time of the transition
+	UnixMilli int64 `json:"unixMilli"`
+	// ActiveAt is the time the alert became pending
+	ActiveAt int64 `json:"activeAt"`
+}
+
+// RuleStateStats aggregates the state history of a single alert (label set) of a rule
+type RuleStateStats struct {
+	Fingerprint string            `json:"fingerprint"`
+	Labels      map[string]string `json:"labels"`
+	LastState   string            `json:"lastState"`
+	// FiringDuration is the time (in ms) the alert was firing in the time range
+	FiringDuration int64 `json:"firingDuration"`
+	FiringCount    int   `json:"firingCount"`
+	// FlapCount is the number of times the alert fired again after being resolved
+	FlapCount int `json:"flapCount"`
+}
+
+// RuleStateTimeline is the state history of a rule in a time range (in ms)
+type RuleStateTimeline struct {
+	Start   int64               `json:"start"`
+	End     int64               `json:"end"`
+	History []*RuleStateHistory `json:"history"`
+	Stats   []*RuleStateStats   `json:"stats"`
+}
+
+func newStateHistory(ruleID string, fp uint64, a *Alert, state string, ts time.Time) *RuleStateHistory {
+	return &RuleStateHistory{
+		RuleID:      ruleID,
+		Fingerprint: strconv.FormatUint(fp, 10),
+		State:       state,
+		Labels:      a.Labels.Map(),
+		Value:       a.Value,
+		UnixMilli:   ts.UnixMilli(),
+		ActiveAt:    a.ActiveAt.UnixMilli(),
+	}
+}
+
+// restoredAlert rebuilds an active alert from its last state transition,
+// the annotations are filled in again by the next evaluation
+func (h *RuleStateHistory) restoredAlert(lbls, annotations labels.BaseLabels, generatorURL string, receivers []string) *Alert {
+	a := &Alert{
+		State:        StatePending,
+		Labels:       lbls,
+		Annotations:  annotations,
+		GeneratorURL: generatorURL,
+		Receivers:    receivers,
+		Value:        h.Value,
+		ActiveAt:     time.UnixMilli(h.ActiveAt),
+	}
+	if h.State == StateFiring.String() {
+		a.State = StateFiring
+		a.FiredAt = time.UnixMilli(h.UnixMilli)
+	}
+	return a
+}
+
+// transitionAlerts merges the alerts found in the latest evaluation into the
+// active alerts and moves them through the pending, firing and resolved states.
+// It returns the state transitions made at ts.
+func transitionAlerts(ruleID string, active, alerts map[uint64]*Alert, ts time.Time, holdDuration time.Duration) []*RuleStateHistory {
+	var history []*RuleStateHistory
+
+	// alerts[h] is ready, add or update active list now
+	for h, a := range alerts {
+		// Check whether we already have alerting state for the identifying label set.
+		// Update the last value and annotations if so, create a new alert entry otherwise.
+		if alert, ok := active[h]; ok && alert.State != StateInactive {
+			alert.update(a)
+			continue
+		}
+
+		active[h] = a
+		history = append(history, newStateHistory(ruleID, h, a, StatePending.String(), ts))
+	}
+
+	// Check if any pending alerts should be removed or fire now. Write out alert timeseries.
+	for fp, a := range active {
+		if _, ok := alerts[fp]; !ok {
+			// If the alert was previously firing, keep it around for a given
+			// retention time so it is reported as resolved to the AlertManager.
+			if a.State == StatePending || (!a.ResolvedAt.IsZero() && ts.Sub(a.ResolvedAt) > resolvedRetention) {
+				delete(active, fp)
+			}
+			if a.State != StateInactive {
+				state := stateResolved
+				if a.State == StatePending {
+					state = StateInactive.String()
+				}
+				a.State = StateInactive
+				a.ResolvedAt = ts
+				history = append(history, newStateHistory(ruleID, fp, a, state, ts))
+			}
+			continue
+		}
+
+		if a.State == StatePending && ts.Sub(a.ActiveAt) >= holdDuration {
+			a.State = StateFiring
+			a.FiredAt = ts
+			history = append(history, newStateHistory(ruleID, fp, a, StateFiring.String(), ts))
+		}
+	}
+	return history
+}
+
+// aggregateStateHistory computes the stats of every alert of the history. The
+// history is expected in the order of the transitions, initial holds the last
+// transition of the alerts before the start of the range. Alerts pending or
+// firing at the start are counted from the start. An alert resolved before it
+// fires in the time range, with no earlier transition, is taken as firing since
+// the start of the range.
+func aggregateStateHistory(initial, history []*RuleStateHistory, start, end int64) []*RuleStateStats {
+	stats := make(map[string]*RuleStateStats)
+	firingSince := make(map[string]int64)
+	resolved := make(map[string]bool)
+
+	for _, h := range initial {
+		switch h.State {
+		case StateFiring.String():
+			firingSince[h.Fingerprint] = start
+		case StatePending.String():
+		case stateResolved:
+			resolved[h.Fingerprint] = true
+			continue
+		default:
+			continue
+		}
+		stats[h.Fingerprint] = &RuleStateStats{Fingerprint: h.Fingerprint, Labels: h.Labels, LastState: h.State}
+	}
+
+	for _, h := range history {
+		s, ok := stats[h.Fingerprint]
+		if !ok {
+			s = &RuleStateStats{Fingerprint: h.Fingerprint}
+			stats[h.Fingerprint] = s
+			if h.State == stateResolved && !resolved[h.Fingerprint] {
+				firingSince[h.Fingerprint] = start
+			}
+		}
+		s.Labels = h.Labels
+		s.LastState = h.State
+
+		switch h.State {
+		case StateFiring.String():
+			s.FiringCount++
+			if resolved[h.Fingerprint] {
+				s.FlapCount++
+			}
+			firingSince[h.Fingerprint] = h.UnixMilli
+		case stateResolved:
+			if since, ok := firingSince[h.Fingerprint]; ok {
+				s.FiringDuration += h.UnixMilli - since
+				delete(firingSince, h.Fingerprint)
+			}
+			resolved[h.Fingerprint] = true
+		}
+	}
+
+	// alerts still firing at the end of the range
+	for fp, since := range firingSince {
+		stats[fp].FiringDuration += end - since
+	}
+
+	result := make([]*RuleStateStats, 0, len(stats))
+	for _, s := range stats {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].FiringDuration != result[j].FiringDuration {
+			return result[i].FiringDuration > result[j].FiringDuration
+		}
+		return result[i].Fingerprint < result[j].Fingerprint
+	})
+	return result
+}
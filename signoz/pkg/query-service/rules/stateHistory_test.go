@@ -0,0 +1,122 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+func TestTransitionAlerts(t *testing.T) {
+	lbls := labels.Labels{{Name: "service_name", Value: "frontend"}}
+	newAlert := func(ts time.Time, value float64) map[uint64]*Alert {
+		return map[uint64]*Alert{
+			1: {Labels: lbls, Value: value, ActiveAt: ts, State: StatePending},
+		}
+	}
+
+	start := time.Date(2023, 1, 10, 10, 0, 0, 0, time.UTC)
+	holdDuration := 2 * time.Minute
+	active := make(map[uint64]*Alert)
+
+	steps := []struct {
+		ts             time.Time
+		alerts         map[uint64]*Alert
+		expectedStates []string
+	}{
+		{ts: start, alerts: newAlert(start, 10), expectedStates: []string{"pending"}},
+		{ts: start.Add(time.Minute), alerts: newAlert(start.Add(time.Minute), 11), expectedStates: nil},
+		{ts: start.Add(2 * time.Minute), alerts: newAlert(start.Add(2*time.Minute), 12), expectedStates: []string{"firing"}},
+		{ts: start.Add(3 * time.Minute), alerts: nil, expectedStates: []string{"resolved"}},
+		// the resolved alert is kept around and does not transition again
+		{ts: start.Add(4 * time.Minute), alerts: nil, expectedStates: nil},
+		{ts: start.Add(5 * time.Minute), alerts: newAlert(start.Add(5*time.Minute), 13), expectedStates: []string{"pending"}},
+		{ts: start.Add(6 * time.Minute), alerts: nil, expectedStates: []string{"inactive"}},
+	}
+
+	for i, step := range steps {
+		history := transitionAlerts("rule-1", active, step.alerts, step.ts, holdDuration)
+
+		var states []string
+		for _, h := range history {
+			assert.Equal(t, "rule-1", h.RuleID)
+			assert.Equal(t, "1", h.Fingerprint)
+			assert.Equal(t, step.ts.UnixMilli(), h.UnixMilli)
+			assert.Equal(t, map[string]string{"service_name": "frontend"}, h.Labels)
+			states = append(states, h.State)
+		}
+		assert.Equal(t, step.expectedStates, states, "step %d", i)
+	}
+
+	// pending alerts are dropped as soon as they stop matching
+	assert.Empty(t, active)
+}
+
+func TestAggregateStateHistory(t *testing.T) {
+	at := func(minutes int64) int64 {
+		return minutes * time.Minute.Milliseconds()
+	}
+	history := []*RuleStateHistory{
+		// fired before the start of the range
+		{Fingerprint: "1", State: "resolved", UnixMilli: at(5)},
+		{Fingerprint: "2", State: "pending", UnixMilli: at(10)},
+		{Fingerprint: "2", State: "firing", UnixMilli: at(12)},
+		{Fingerprint: "2", State: "resolved", UnixMilli: at(20)},
+		{Fingerprint: "2", State: "pending", UnixMilli: at(30)},
+		{Fingerprint: "2", State: "firing", UnixMilli: at(32)},
+		{Fingerprint: "3", State: "pending", UnixMilli: at(40)},
+		{Fingerprint: "3", State: "inactive", UnixMilli: at(41)},
+	}
+
+	stats := aggregateStateHistory(nil, history, 0, at(60))
+	assert.Len(t, stats, 3)
+
+	// 8 minutes in the first firing and 28 minutes still firing at the end
+	assert.Equal(t, "2", stats[0].Fingerprint)
+	assert.Equal(t, at(36), stats[0].FiringDuration)
+	assert.Equal(t, 2, stats[0].FiringCount)
+	assert.Equal(t, 1, stats[0].FlapCount)
+	assert.Equal(t, "firing", stats[0].LastState)
+
+	assert.Equal(t, "1", stats[1].Fingerprint)
+	assert.Equal(t, at(5), stats[1].FiringDuration)
+	assert.Equal(t, 0, stats[1].FiringCount)
+	assert.Equal(t, "resolved", stats[1].LastState)
+
+	assert.Equal(t, "3", stats[2].Fingerprint)
+	assert.Equal(t, int64(0), stats[2].FiringDuration)
+	assert.Equal(t, "inactive", stats[2].LastState)
+}
+
+func TestAggregateStateHistoryInitialStates(t *testing.T) {
+	at := func(minutes int64) int64 {
+		return minutes * time.Minute.Milliseconds()
+	}
+	initial := []*RuleStateHistory{
+		// firing since before the range with no transition in it
+		{Fingerprint: "1", State: "firing", UnixMilli: at(5)},
+		// resolved before the range and firing again in it
+		{Fingerprint: "2", State: "resolved", UnixMilli: at(8)},
+		{Fingerprint: "3", State: "inactive", UnixMilli: at(9)},
+	}
+	history := []*RuleStateHistory{
+		{Fingerprint: "2", State: "pending", UnixMilli: at(20)},
+		{Fingerprint: "2", State: "firing", UnixMilli: at(22)},
+		{Fingerprint: "2", State: "resolved", UnixMilli: at(30)},
+	}
+
+	stats := aggregateStateHistory(initial, history, at(10), at(60))
+	assert.Len(t, stats, 2)
+
+	assert.Equal(t, "1", stats[0].Fingerprint)
+	assert.Equal(t, at(50), stats[0].FiringDuration)
+	assert.Equal(t, 0, stats[0].FiringCount)
+	assert.Equal(t, "firing", stats[0].LastState)
+
+	assert.Equal(t, "2", stats[1].Fingerprint)
+	assert.Equal(t, at(8), stats[1].FiringDuration)
+	assert.Equal(t, 1, stats[1].FiringCount)
+	assert.Equal(t, 1, stats[1].FlapCount)
+	assert.Equal(t, "resolved", stats[1].LastState)
+}
@@ -29,9 +29,9 @@ type Task interface {
 
 // newTask returns an appropriate group for
 // rule type
-func newTask(taskType TaskType, name, file string, frequency time.Duration, rules []Rule, opts *ManagerOptions, notify NotifyFunc) Task {
+func newTask(taskType TaskType, name, file string, frequency time.Duration, rules []Rule, opts *ManagerOptions, notify NotifyFunc, silenced SilencedFunc, record RecordStateFunc) Task {
 	if taskType == TaskTypeCh {
-		return newRuleTask(name, file, frequency, rules, opts, notify)
+		return newRuleTask(name, file, frequency, rules, opts, notify, silenced, record)
 	}
-	return newPromRuleTask(name, file, frequency, rules, opts, notify)
+	return newPromRuleTask(name, file, frequency, rules, opts, notify, silenced, record)
 }
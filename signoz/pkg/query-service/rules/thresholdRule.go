@@ -7,6 +7,7 @@ import (
 	"math"
 	"reflect"
 	"sort"
+	"strconv"
 	"sync"
 	"text/template"
 	"time"
@@ -55,6 +56,13 @@ type ThresholdRule struct {
 	// map of active alerts
 	active map[uint64]*Alert
 
+	// state transitions of the alerts not yet persisted
+	stateHistory []*RuleStateHistory
+
+	// series returned by the last query and the series that went missing
+	observedSeries map[uint64]labels.Labels
+	absentTracker  *absentTracker
+
 	queryBuilder *queryBuilder.QueryBuilder
 
 	opts ThresholdRuleOpts
@@ -95,6 +103,7 @@ func NewThresholdRule(
 		preferredChannels: p.PreferredChannels,
 		health:            HealthUnknown,
 		active:            map[uint64]*Alert{},
+		absentTracker:     newAbsentTracker(),
 		opts:              opts,
 	}
 
@@ -268,6 +277,33 @@ func (r *ThresholdRule) ActiveAlerts() []*Alert {
 	return res
 }
 
+// TakeStateHistory returns the state transitions of the alerts made since the last call
+func (r *ThresholdRule) TakeStateHistory() []*RuleStateHistory {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	history := r.stateHistory
+	r.stateHistory = nil
+	return history
+}
+
+// RestoreState puts back the alerts that were pending or firing before a restart
+func (r *ThresholdRule) RestoreState(history []*RuleStateHistory) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for _, h := range history {
+		fp, err := strconv.ParseUint(h.Fingerprint, 10, 64)
+		if err != nil {
+			continue
+		}
+		if _, ok := r.active[fp]; ok {
+			continue
+		}
+		r.active[fp] = h.restoredAlert(labels.FromMap(h.Labels), labels.Labels{}, r.GeneratorURL(), r.preferredChannels)
+	}
+}
+
 // ForEachActiveAlert runs the given function on each alert.
 // This should be used when you want to use the actual alerts from the ThresholdRule
 // and not on its copy.
@@ -281,10 +317,16 @@ func (r *ThresholdRule) ForEachActiveAlert(f func(*Alert)) {
 	}
 }
 
-func (r *ThresholdRule) SendAlerts(ctx context.Context, ts time.Time, resendDelay time.Duration, interval time.Duration, notifyFunc NotifyFunc) {
+func (r *ThresholdRule) SendAlerts(ctx context.Context, ts time.Time, resendDelay time.Duration, interval time.Duration, notifyFunc NotifyFunc, silenced SilencedFunc) {
 	zap.S().Info("msg:", "sending alerts", "\t rule:", r.Name())
 	alerts := []*Alert{}
 	r.ForEachActiveAlert(func(alert *Alert) {
+		// the silenced alerts are not marked as sent, so that the alert
+		// manager does not resolve them when they expire while muted and
+		// they are sent as soon as the silence ends
+		if silenced != nil && silenced(alert.Labels, ts) {
+			return
+		}
 		if r.opts.SendAlways || alert.needsSending(ts, resendDelay) {
 			alert.LastSentAt = ts
 			// Allow for two Eval or Alertmanager send failures.
@@ -316,6 +358,10 @@ func (r *ThresholdRule) CheckCondition(v float64) bool {
 		return false
 	}
 
+	if r.ruleCondition.HasThresholds() {
+		return r.ruleCondition.matchThreshold(v, r.Unit()) != nil
+	}
+
 	if r.ruleCondition.Target == nil {
 		zap.S().Debugf("msg:", "found null target in rule condition", "\t rulename:", r.Name())
 		return false
@@ -388,6 +434,71 @@ func (r *ThresholdRule) shouldSkipFirstRecord() bool {
 	return shouldSkip
 }
 
+// readSample converts a scanned clickhouse row into a sample, the value is read
+// from the reserved target columns and every other column becomes a label
+func (r *ThresholdRule) readSample(vars []interface{}, columnNames []string) (Sample, *labels.Builder) {
+	sample := Sample{}
+	lbls := labels.NewBuilder(labels.Labels{})
+
+	for i, v := range vars {
+
+		colName := columnNames[i]
+
+		switch v := v.(type) {
+		case *string:
+			lbls.Set(colName, *v)
+		case *time.Time:
+			timval := *v
+
+			if colName == "ts" || colName == "interval" {
+				sample.Point.T = timval.Unix()
+			} else {
+				lbls.Set(colName, timval.Format("2006-01-02 15:04:05"))
+			}
+
+		case *float64:
+			if _, ok := constants.ReservedColumnTargetAliases[colName]; ok {
+				sample.Point.V = *v
+			} else {
+				lbls.Set(colName, fmt.Sprintf("%f", *v))
+			}
+		case **float64:
+			// ch seems to return this type when column is derived from
+			// SELECT count(*)/ SELECT count(*)
+			floatVal := *v
+			if floatVal != nil {
+				if _, ok := constants.ReservedColumnTargetAliases[colName]; ok {
+					sample.Point.V = *floatVal
+				} else {
+					lbls.Set(colName, fmt.Sprintf("%f", *floatVal))
+				}
+			}
+		case *float32:
+			float32Val := float32(*v)
+			if _, ok := constants.ReservedColumnTargetAliases[colName]; ok {
+				sample.Point.V = float64(float32Val)
+			} else {
+				lbls.Set(colName, fmt.Sprintf("%f", float32Val))
+			}
+		case *uint8, *uint64, *uint16, *uint32:
+			if _, ok := constants.ReservedColumnTargetAliases[colName]; ok {
+				sample.Point.V = float64(reflect.ValueOf(v).Elem().Uint())
+			} else {
+				lbls.Set(colName, fmt.Sprintf("%v", reflect.ValueOf(v).Elem().Uint()))
+			}
+		case *int8, *int16, *int32, *int64:
+			if _, ok := constants.ReservedColumnTargetAliases[colName]; ok {
+				sample.Point.V = float64(reflect.ValueOf(v).Elem().Int())
+			} else {
+				lbls.Set(colName, fmt.Sprintf("%v", reflect.ValueOf(v).Elem().Int()))
+			}
+		default:
+			zap.S().Errorf("ruleId:", r.ID(), "\t error: invalid var found in query result", v, columnNames[i])
+		}
+	}
+	return sample, lbls
+}
+
 // queryClickhouse runs actual query against clickhouse
 func (r *ThresholdRule) runChQuery(ctx context.Context, db clickhouse.Conn, query string) (Vector, error) {
 	rows, err := db.Query(ctx, query)
@@ -416,6 +527,9 @@ func (r *ThresholdRule) runChQuery(ctx context.Context, db clickhouse.Conn, quer
 	// map[fingerprint]sample
 	resultMap := make(map[uint64]Sample, 0)
 
+	// every series returned, whether it matches the condition or not
+	observed := make(map[uint64]labels.Labels)
+
 	// for rates we want to skip the first record
 	// but we dont know when the rates are being used
 	// so we always pick timeframe - 30 seconds interval
@@ -430,65 +544,7 @@ func (r *ThresholdRule) runChQuery(ctx context.Context, db clickhouse.Conn, quer
 			return nil, err
 		}
 
-		sample := Sample{}
-		lbls := labels.NewBuilder(labels.Labels{})
-
-		for i, v := range vars {
-
-			colName := columnNames[i]
-
-			switch v := v.(type) {
-			case *string:
-				lbls.Set(colName, *v)
-			case *time.Time:
-				timval := *v
-
-				if colName == "ts" || colName == "interval" {
-					sample.Point.T = timval.Unix()
-				} else {
-					lbls.Set(colName, timval.Format("2006-01-02 15:04:05"))
-				}
-
-			case *float64:
-				if _, ok := constants.ReservedColumnTargetAliases[colName]; ok {
-					sample.Point.V = *v
-				} else {
-					lbls.Set(colName, fmt.Sprintf("%f", *v))
-				}
-			case **float64:
-				// ch seems to return this type when column is derived from
-				// SELECT count(*)/ SELECT count(*)
-				floatVal := *v
-				if floatVal != nil {
-					if _, ok := constants.ReservedColumnTargetAliases[colName]; ok {
-						sample.Point.V = *floatVal
-					} else {
-						lbls.Set(colName, fmt.Sprintf("%f", *floatVal))
-					}
-				}
-			case *float32:
-				float32Val := float32(*v)
-				if _, ok := constants.ReservedColumnTargetAliases[colName]; ok {
-					sample.Point.V = float64(float32Val)
-				} else {
-					lbls.Set(colName, fmt.Sprintf("%f", float32Val))
-				}
-			case *uint8, *uint64, *uint16, *uint32:
-				if _, ok := constants.ReservedColumnTargetAliases[colName]; ok {
-					sample.Point.V = float64(reflect.ValueOf(v).Elem().Uint())
-				} else {
-					lbls.Set(colName, fmt.Sprintf("%v", reflect.ValueOf(v).Elem().Uint()))
-				}
-			case *int8, *int16, *int32, *int64:
-				if _, ok := constants.ReservedColumnTargetAliases[colName]; ok {
-					sample.Point.V = float64(reflect.ValueOf(v).Elem().Int())
-				} else {
-					lbls.Set(colName, fmt.Sprintf("%v", reflect.ValueOf(v).Elem().Int()))
-				}
-			default:
-				zap.S().Errorf("ruleId:", r.ID(), "\t error: invalid var found in query result", v, columnNames[i])
-			}
-		}
+		sample, lbls := r.readSample(vars, columnNames)
 
 		if math.IsNaN(sample.Point.V) {
 			continue
@@ -499,6 +555,7 @@ func (r *ThresholdRule) runChQuery(ctx context.Context, db clickhouse.Conn, quer
 		sample.Metric = lbls.Labels()
 
 		labelHash := lbls.Labels().Hash()
+		observed[labelHash] = sample.Metric
 
 		// here we walk through values of time series
 		// and calculate the final value used to compare
@@ -591,6 +648,10 @@ func (r *ThresholdRule) runChQuery(ctx context.Context, db clickhouse.Conn, quer
 		}
 	}
 
+	r.mtx.Lock()
+	r.observedSeries = observed
+	r.mtx.Unlock()
+
 	zap.S().Debugf("ruleid:", r.ID(), "\t resultmap(potential alerts):", len(resultMap))
 
 	for _, sample := range resultMap {
@@ -766,10 +827,24 @@ func (r *ThresholdRule) Eval(ctx context.Context, ts time.Time, queriers *Querie
 		return nil, err
 	}
 
+	thresholdFormatter := formatter.FromUnit(r.ruleCondition.TargetUnit)
+	threshold := thresholdFormatter.Format(r.targetVal(), r.ruleCondition.TargetUnit)
+
+	formatValue := func(v float64) string {
+		return valueFormatter.Format(v, r.Unit())
+	}
+	return r.updateAlerts(ctx, ts, res, formatValue, threshold)
+}
+
+// updateAlerts creates an alert for every sample in the result and moves
+// the active alerts through the pending, firing and resolved states
+func (r *ThresholdRule) updateAlerts(ctx context.Context, ts time.Time, res Vector, formatValue func(float64) string, threshold string) (interface{}, error) {
+
+	var err error
+
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
-	resultFPs := map[uint64]struct{}{}
 	var alerts = make(map[uint64]*Alert, len(res))
 
 	for _, smpl := range res {
@@ -778,33 +853,26 @@ func (r *ThresholdRule) Eval(ctx context.Context, ts time.Time, queriers *Querie
 			l[lbl.Name] = lbl.Value
 		}
 
-		value := valueFormatter.Format(smpl.V, r.Unit())
-		thresholdFormatter := formatter.FromUnit(r.ruleCondition.TargetUnit)
-		threshold := thresholdFormatter.Format(r.targetVal(), r.ruleCondition.TargetUnit)
-		zap.S().Debugf("Alert template data for rule %s: Formatter=%s, Value=%s, Threshold=%s", r.Name(), valueFormatter.Name(), value, threshold)
+		// for rules with multiple thresholds, the alert takes the
+		// severity and the channels of the threshold it crosses
+		receivers := r.preferredChannels
+		alertThreshold := threshold
+		severityLevel := r.ruleCondition.matchThreshold(smpl.V, r.Unit())
+		if severityLevel != nil {
+			alertThreshold = formatter.FromUnit(r.ruleCondition.TargetUnit).Format(*severityLevel.Target, r.ruleCondition.TargetUnit)
+			if len(severityLevel.PreferredChannels) > 0 {
+				receivers = severityLevel.PreferredChannels
+			}
+		}
+
+		value := formatValue(smpl.V)
+		zap.S().Debugf("Alert template data for rule %s: Value=%s, Threshold=%s", r.Name(), value, alertThreshold)
 
-		tmplData := AlertTemplateData(l, value, threshold)
-		// Inject some convenience variables that are easier to remember for users
-		// who are not used to Go's templating system.
-		defs := "{{$labels := .Labels}}{{$value := .Value}}{{$threshold := .Threshold}}"
+		tmplData := AlertTemplateData(l, value, alertThreshold)
 
 		// utility function to apply go template on labels and annots
 		expand := func(text string) string {
-
-			tmpl := NewTemplateExpander(
-				ctx,
-				defs+text,
-				"__alert_"+r.Name(),
-				tmplData,
-				times.Time(timestamp.FromTime(ts)),
-				nil,
-			)
-			result, err := tmpl.Expand()
-			if err != nil {
-				result = fmt.Sprintf("<error expanding template: %s>", err)
-				zap.S().Errorf("msg:", "Expanding alert template failed", "\t err", err, "\t data", tmplData)
-			}
-			return result
+			return r.expandTemplate(ctx, ts, tmplData, text)
 		}
 
 		lb := labels.NewBuilder(smpl.Metric).Del(labels.MetricNameLabel)
@@ -816,6 +884,9 @@ func (r *ThresholdRule) Eval(ctx context.Context, ts time.Time, queriers *Querie
 		lb.Set(labels.AlertNameLabel, r.Name())
 		lb.Set(labels.AlertRuleIdLabel, r.ID())
 		lb.Set(labels.RuleSourceLabel, r.GeneratorURL())
+		if severityLevel != nil {
+			lb.Set(labels.AlertSeverityLabel, severityLevel.Severity)
+		}
 
 		annotations := make(labels.Labels, 0, len(r.annotations))
 		for _, a := range r.annotations {
@@ -824,7 +895,11 @@ func (r *ThresholdRule) Eval(ctx context.Context, ts time.Time, queriers *Querie
 
 		lbs := lb.Labels()
 		h := lbs.Hash()
-		resultFPs[h] = struct{}{}
+		if r.ruleCondition.HasThresholds() {
+			// the severity is left out of the alert identity so that
+			// the alert moves between severities without resolving
+			h = lbs.HashWithoutLabels(labels.AlertSeverityLabel)
+		}
 
 		if _, ok := alerts[h]; ok {
 			zap.S().Errorf("ruleId: ", r.ID(), "\t msg:", "the alert query returns duplicate records:", alerts[h])
@@ -843,55 +918,48 @@ func (r *ThresholdRule) Eval(ctx context.Context, ts time.Time, queriers *Querie
 			State:        StatePending,
 			Value:        smpl.V,
 			GeneratorURL: r.GeneratorURL(),
-			Receivers:    r.preferredChannels,
+			Receivers:    receivers,
 		}
 	}
 
-	zap.S().Info("rule:", r.Name(), "\t alerts found: ", len(alerts))
-
-	// alerts[h] is ready, add or update active list now
-	for h, a := range alerts {
-		// Check whether we already have alerting state for the identifying label set.
-		// Update the last value and annotations if so, create a new alert entry otherwise.
-		if alert, ok := r.active[h]; ok && alert.State != StateInactive {
-
-			alert.Value = a.Value
-			alert.Annotations = a.Annotations
-			alert.Receivers = r.preferredChannels
-			continue
+	if r.ruleCondition.Absent != nil {
+		for _, a := range r.absentAlerts(ctx, ts) {
+			alerts[alertFingerprint(r, a.Labels)] = a
 		}
-
-		r.active[h] = a
-
 	}
 
-	// Check if any pending alerts should be removed or fire now. Write out alert timeseries.
-	for fp, a := range r.active {
-		if _, ok := resultFPs[fp]; !ok {
-			// If the alert was previously firing, keep it around for a given
-			// retention time so it is reported as resolved to the AlertManager.
-			if a.State == StatePending || (!a.ResolvedAt.IsZero() && ts.Sub(a.ResolvedAt) > resolvedRetention) {
-				delete(r.active, fp)
-			}
-			if a.State != StateInactive {
-				a.State = StateInactive
-				a.ResolvedAt = ts
-			}
-			continue
-		}
+	zap.S().Info("rule:", r.Name(), "\t alerts found: ", len(alerts))
 
-		if a.State == StatePending && ts.Sub(a.ActiveAt) >= r.holdDuration {
-			a.State = StateFiring
-			a.FiredAt = ts
-		}
+	r.stateHistory = append(r.stateHistory, transitionAlerts(r.ID(), r.active, alerts, ts, r.holdDuration)...)
 
-	}
 	r.health = HealthGood
 	r.lastError = err
 
 	return len(r.active), nil
 }
 
+// expandTemplate applies the go template of a label or an annotation of the rule
+func (r *ThresholdRule) expandTemplate(ctx context.Context, ts time.Time, tmplData interface{}, text string) string {
+	// Inject some convenience variables that are easier to remember for users
+	// who are not used to Go's templating system.
+	defs := "{{$labels := .Labels}}{{$value := .Value}}{{$threshold := .Threshold}}"
+
+	tmpl := NewTemplateExpander(
+		ctx,
+		defs+text,
+		"__alert_"+r.Name(),
+		tmplData,
+		times.Time(timestamp.FromTime(ts)),
+		nil,
+	)
+	result, err := tmpl.Expand()
+	if err != nil {
+		result = fmt.Sprintf("<error expanding template: %s>", err)
+		zap.S().Errorf("msg:", "Expanding alert template failed", "\t err", err, "\t data", tmplData)
+	}
+	return result
+}
+
 func (r *ThresholdRule) String() string {
 
 	ar := PostableRule{
@@ -2,6 +2,8 @@ package rules
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"testing"
 	"time"
 
@@ -9,6 +11,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"go.signoz.io/signoz/pkg/query-service/featureManager"
 	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+	yaml "gopkg.in/yaml.v2"
 )
 
 func TestThresholdRuleCombinations(t *testing.T) {
@@ -295,3 +299,141 @@ func TestThresholdRuleCombinations(t *testing.T) {
 		}
 	}
 }
+
+func TestThresholdRuleSeverityTransition(t *testing.T) {
+	warning, critical := 50.0, 100.0
+	postableRule := PostableRule{
+		Alert:      "Multiple Thresholds Tests",
+		AlertType:  "METRICS_BASED_ALERT",
+		RuleType:   RuleTypeThreshold,
+		EvalWindow: Duration(5 * time.Minute),
+		Frequency:  Duration(1 * time.Minute),
+		RuleCondition: &RuleCondition{
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType: v3.QueryTypeBuilder,
+				BuilderQueries: map[string]*v3.BuilderQuery{
+					"A": {
+						QueryName:    "A",
+						StepInterval: 60,
+						AggregateAttribute: v3.AttributeKey{
+							Key: "signoz_calls_total",
+						},
+						AggregateOperator: v3.AggregateOperatorSumRate,
+						DataSource:        v3.DataSourceMetrics,
+						Expression:        "A",
+					},
+				},
+			},
+			CompareOp: ValueIsAbove,
+			MatchType: AtleastOnce,
+			Thresholds: []*RuleThreshold{
+				{Severity: "warning", Target: &warning},
+				{Severity: "critical", Target: &critical, PreferredChannels: []string{"pagerduty"}},
+			},
+		},
+		PreferredChannels: []string{"slack"},
+	}
+	fm := featureManager.StartManager()
+
+	rule, err := NewThresholdRule("69", &postableRule, ThresholdRuleOpts{}, fm)
+	assert.NoError(t, err)
+
+	assert.False(t, rule.CheckCondition(40))
+	assert.True(t, rule.CheckCondition(60))
+
+	metric := labels.Labels{{Name: "service_name", Value: "frontend"}}
+	formatValue := func(v float64) string { return fmt.Sprintf("%f", v) }
+	start := time.Now()
+
+	cases := []struct {
+		value           float64
+		expectSeverity  string
+		expectReceivers []string
+	}{
+		{value: 60, expectSeverity: "warning", expectReceivers: []string{"slack"}},
+		{value: 120, expectSeverity: "critical", expectReceivers: []string{"pagerduty"}},
+		{value: 70, expectSeverity: "warning", expectReceivers: []string{"slack"}},
+	}
+
+	for idx, c := range cases {
+		ts := start.Add(time.Duration(idx) * time.Minute)
+		res := Vector{{Point: Point{T: ts.UnixMilli(), V: c.value}, Metric: metric}}
+		_, err := rule.updateAlerts(context.Background(), ts, res, formatValue, "")
+		assert.NoError(t, err)
+
+		alerts := rule.ActiveAlerts()
+		assert.Equal(t, 1, len(alerts), "case %d", idx)
+		assert.Equal(t, c.expectSeverity, alerts[0].Labels.Get(labels.AlertSeverityLabel), "case %d", idx)
+		assert.Equal(t, c.expectReceivers, alerts[0].Receivers, "case %d", idx)
+		// the alert moves between severities without being resolved
+		assert.Equal(t, start, alerts[0].ActiveAt, "case %d", idx)
+		assert.True(t, alerts[0].ResolvedAt.IsZero(), "case %d", idx)
+	}
+}
+
+func TestParsePostableRuleThresholds(t *testing.T) {
+	content := []byte(`{
+		"alert": "high latency",
+		"condition": {
+			"compositeQuery": {
+				"queryType": "promql",
+				"promQueries": {"A": {"query": "histogram_quantile(0.99, sum(rate(signoz_latency_bucket[5m])) by (le))"}}
+			},
+			"op": "1",
+			"matchType": "1",
+			"thresholds": [
+				{"severity": "warning", "target": 500},
+				{"severity": "critical", "target": 1000, "preferredChannels": ["pagerduty"]}
+			]
+		}
+	}`)
+
+	rule, errs := ParsePostableRule(content)
+	assert.Empty(t, errs)
+	assert.Equal(t, RuleType(RuleTypeProm), rule.RuleType)
+	assert.Equal(t, 2, len(rule.RuleCondition.Thresholds))
+
+	// the thresholds round trip through json and yaml
+	jsonData, err := json.Marshal(GettableRule{Id: "1", PostableRule: *rule})
+	assert.NoError(t, err)
+	var gettable GettableRule
+	assert.NoError(t, json.Unmarshal(jsonData, &gettable))
+	assert.Equal(t, rule.RuleCondition.Thresholds, gettable.RuleCondition.Thresholds)
+
+	yamlData, err := yaml.Marshal(rule)
+	assert.NoError(t, err)
+	yamlRule, errs := parsePostableRule(yamlData, "yaml")
+	assert.Empty(t, errs)
+	assert.Equal(t, rule.RuleCondition.Thresholds, yamlRule.RuleCondition.Thresholds)
+
+	// equality compare ops can not be used with multiple thresholds
+	rule.RuleCondition.CompareOp = ValueIsEq
+	assert.NotEmpty(t, rule.Validate())
+}
+
+func TestThresholdRuleSendAlertsSilenced(t *testing.T) {
+	rule := &ThresholdRule{active: map[uint64]*Alert{}}
+	alert := &Alert{
+		State:  StateFiring,
+		Labels: labels.Labels{{Name: labels.AlertNameLabel, Value: "HighLatency"}},
+	}
+	rule.active[alert.Labels.Hash()] = alert
+
+	var sent []*Alert
+	notify := func(ctx context.Context, expr string, alerts ...*Alert) { sent = append(sent, alerts...) }
+	silenced := true
+	isSilenced := func(lbls labels.BaseLabels, ts time.Time) bool { return silenced }
+
+	// the silenced alert is neither sent nor marked as sent
+	ts := time.Now()
+	rule.SendAlerts(context.Background(), ts, time.Minute, time.Minute, notify, isSilenced)
+	assert.Empty(t, sent)
+	assert.True(t, alert.LastSentAt.IsZero())
+	assert.True(t, alert.ValidUntil.IsZero())
+
+	// it is sent as soon as the silence ends
+	silenced = false
+	rule.SendAlerts(context.Background(), ts.Add(time.Minute), time.Minute, time.Minute, notify, isSilenced)
+	assert.Len(t, sent, 1)
+	assert.Equal(t, ts.Add(time.Minute), alert.LastSentAt)
+}
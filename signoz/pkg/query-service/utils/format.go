@@ -258,3 +258,12 @@ func GetEpochNanoSecs(epoch int64) int64 {
 	}
 	return temp * int64(math.Pow(10, float64(19-count)))
 }
+
+// AddTimeShiftToQuery moves the points of a time series query forward by the
+// shift (in seconds) and realigns them to the step interval
+func AddTimeShiftToQuery(query string, shift, step int64) string {
+	if shift == 0 {
+		return query
+	}
+	return fmt.Sprintf("SELECT * REPLACE (toStartOfInterval(ts + INTERVAL %d SECOND, INTERVAL %d SECOND) AS ts) FROM (%s)", shift, step, query)
+}
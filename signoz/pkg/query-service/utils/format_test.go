@@ -465,3 +465,14 @@ func TestGetEpochNanoSecs(t *testing.T) {
 		})
 	}
 }
+
+func TestAddTimeShiftToQuery(t *testing.T) {
+	query := "SELECT ts, value FROM table"
+	if got := AddTimeShiftToQuery(query, 0, 60); got != query {
+		t.Errorf("AddTimeShiftToQuery() = %v, want %v", got, query)
+	}
+	want := "SELECT * REPLACE (toStartOfInterval(ts + INTERVAL 3600 SECOND, INTERVAL 60 SECOND) AS ts) FROM (SELECT ts, value FROM table)"
+	if got := AddTimeShiftToQuery(query, 3600, 60); got != want {
+		t.Errorf("AddTimeShiftToQuery() = %v, want %v", got, want)
+	}
+}
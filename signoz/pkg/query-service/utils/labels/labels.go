@@ -28,6 +28,12 @@ const (
 	RuleThresholdLabel       = "threshold"
 	AlertAdditionalInfoLabel = "additionalInfo"
 	AlertSummaryLabel        = "summary"
+	AlertSeverityLabel       = "severity"
+
+	// set to true on the alerts fired when the rule query returns no data
+	AlertNoDataLabel = "nodata"
+	// set to true on the alerts fired when a series stops being returned
+	AlertAbsentLabel = "absent"
 )
 
 // Label is a key/value pair of strings.